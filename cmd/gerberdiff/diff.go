@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/gmlewis/go-gerber/gerber"
+)
+
+// diffFormat is the format used to compare primitives, rounding
+// coordinates to 0.1µm to hide floating point noise.
+var diffFormat = gerber.Format{
+	Units:     gerber.Millimeters,
+	IntDigits: 6,
+	DecDigits: 4,
+}
+
+// layerKey identifies a layer of a design.
+type layerKey struct {
+	Type gerber.LayerType
+	N    int
+}
+
+func (k layerKey) String() string {
+	switch k.Type {
+	case gerber.LayerTopCopper:
+		return "top copper"
+	case gerber.LayerTopSolderMask:
+		return "top solder mask"
+	case gerber.LayerTopSilkscreen:
+		return "top silkscreen"
+	case gerber.LayerBottomCopper:
+		return "bottom copper"
+	case gerber.LayerBottomSolderMask:
+		return "bottom solder mask"
+	case gerber.LayerBottomSilkscreen:
+		return "bottom silkscreen"
+	case gerber.LayerInnerCopper:
+		return fmt.Sprintf("inner copper L%v", k.N)
+	case gerber.LayerDrill:
+		return "drill"
+	case gerber.LayerOutline:
+		return "outline"
+	case gerber.LayerNonPlatedDrill:
+		return "non-plated drill"
+	}
+	return fmt.Sprintf("layer type %v", int(k.Type))
+}
+
+// layerData collects the layers of a design with the same key.
+type layerData struct {
+	filenames  []string
+	primitives []gerber.Primitive
+	apertures  []*gerber.Aperture
+}
+
+// move represents a primitive that moved from one position to another.
+type move struct {
+	prim     gerber.Primitive
+	from, to gerber.Pt
+}
+
+// layerDiff represents the differences of a layer between two designs.
+type layerDiff struct {
+	key           layerKey
+	before, after *layerData // nil if the design has no such layer
+
+	added, removed   []gerber.Primitive
+	moved            []move
+	aperturesAdded   []string
+	aperturesRemoved []string
+
+	// area is the area that differs in mm², as rasterized.
+	area float64
+}
+
+// changed reports whether the layer differs between the designs.
+func (d *layerDiff) changed() bool {
+	return d.before == nil || d.after == nil ||
+		len(d.added) > 0 || len(d.removed) > 0 || len(d.moved) > 0 ||
+		len(d.aperturesAdded) > 0 || len(d.aperturesRemoved) > 0 || d.area > 0
+}
+
+// layersByKey groups the layers of a design by key.
+func layersByKey(g *gerber.Gerber) map[layerKey]*layerData {
+	result := map[layerKey]*layerData{}
+	for _, layer := range g.Layers {
+		key := layerKey{Type: layer.Type, N: layer.N}
+		data, ok := result[key]
+		if !ok {
+			data = &layerData{}
+			result[key] = data
+		}
+		data.filenames = append(data.filenames, layer.Filename)
+		data.primitives = append(data.primitives, layer.Primitives...)
+		data.apertures = append(data.apertures, layer.Apertures...)
+	}
+	return result
+}
+
+// diffDesigns compares two designs layer by layer.
+func diffDesigns(before, after *gerber.Gerber) []*layerDiff {
+	b, a := layersByKey(before), layersByKey(after)
+	var keys []layerKey
+	for key := range b {
+		keys = append(keys, key)
+	}
+	for key := range a {
+		if _, ok := b[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Type != keys[j].Type {
+			return keys[i].Type < keys[j].Type
+		}
+		return keys[i].N < keys[j].N
+	})
+
+	var result []*layerDiff
+	for _, key := range keys {
+		d := &layerDiff{key: key, before: b[key], after: a[key]}
+		var bp, ap []gerber.Primitive
+		var ba, aa []*gerber.Aperture
+		if d.before != nil {
+			bp, ba = d.before.primitives, d.before.apertures
+		}
+		if d.after != nil {
+			ap, aa = d.after.primitives, d.after.apertures
+		}
+		d.added, d.removed, d.moved = diffPrimitives(bp, ap)
+		d.aperturesAdded, d.aperturesRemoved = diffApertures(ba, aa)
+		result = append(result, d)
+	}
+	return result
+}
+
+// diffPrimitives returns the primitives that were added, removed or moved.
+// Primitives of the same shape that changed position are moved; the
+// nearest candidates are paired first.
+func diffPrimitives(before, after []gerber.Primitive) (added, removed []gerber.Primitive, moved []move) {
+	removed = subtract(before, after)
+	added = subtract(after, before)
+
+	candidates := map[string][]gerber.Primitive{}
+	for _, p := range removed {
+		key := shapeKey(p)
+		candidates[key] = append(candidates[key], p)
+	}
+	paired := map[gerber.Primitive]bool{}
+	var rest []gerber.Primitive
+	for _, p := range added {
+		key := shapeKey(p)
+		to := center(p)
+		best, bestDist := -1, math.Inf(1)
+		for i, c := range candidates[key] {
+			from := center(c)
+			if dist := math.Hypot(to[0]-from[0], to[1]-from[1]); dist < bestDist {
+				best, bestDist = i, dist
+			}
+		}
+		if best < 0 {
+			rest = append(rest, p)
+			continue
+		}
+		c := candidates[key][best]
+		candidates[key] = append(candidates[key][:best], candidates[key][best+1:]...)
+		paired[c] = true
+		moved = append(moved, move{prim: p, from: center(c), to: to})
+	}
+
+	var unpaired []gerber.Primitive
+	for _, p := range removed {
+		if !paired[p] {
+			unpaired = append(unpaired, p)
+		}
+	}
+	return rest, unpaired, moved
+}
+
+// subtract returns the primitives of a that have no identical
+// primitive in b, in order.
+func subtract(a, b []gerber.Primitive) []gerber.Primitive {
+	counts := map[string]int{}
+	for _, p := range b {
+		counts[signature(p)]++
+	}
+	var result []gerber.Primitive
+	for _, p := range a {
+		s := signature(p)
+		if counts[s] > 0 {
+			counts[s]--
+			continue
+		}
+		result = append(result, p)
+	}
+	return result
+}
+
+// diffApertures returns the aperture definitions that were added or removed.
+func diffApertures(before, after []*gerber.Aperture) (added, removed []string) {
+	names := func(apertures []*gerber.Aperture) map[string]int {
+		result := map[string]int{}
+		for _, a := range apertures {
+			result[apertureName(a)]++
+		}
+		return result
+	}
+	b, a := names(before), names(after)
+	for name, n := range a {
+		for i := b[name]; i < n; i++ {
+			added = append(added, name)
+		}
+	}
+	for name, n := range b {
+		for i := a[name]; i < n; i++ {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
+// apertureName describes an aperture like its Gerber definition,
+// without the D code.
+func apertureName(a *gerber.Aperture) string {
+	if a.Block != nil {
+		return fmt.Sprintf("block of %v primitives", len(a.Block.Primitives))
+	}
+	var buf bytes.Buffer
+	if err := a.WriteGerber(&buf, &diffFormat, 0); err != nil {
+		return a.ID()
+	}
+	name := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(buf.String()), "%ADD0"), "*%")
+	if a.Function != "" {
+		name += fmt.Sprintf(" (%v)", a.Function)
+	}
+	return name
+}
+
+// apertureKey identifies an aperture by value.
+func apertureKey(a *gerber.Aperture) string {
+	if a == nil {
+		return ""
+	}
+	if a.Block != nil {
+		// Block IDs are pointers, so compare their primitives instead.
+		return "block{" + signatures(a.Block.Primitives) + "}"
+	}
+	return a.ID()
+}
+
+// signature identifies a primitive by its type, aperture and geometry.
+func signature(p gerber.Primitive) string {
+	switch v := p.(type) {
+	case *gerber.ClearT:
+		return fmt.Sprintf("%T{%v}", p, signatures(v.Primitives))
+	case *gerber.KnockoutT:
+		return fmt.Sprintf("%T%v{%v}", p, v.Margin, signatures(v.Primitives))
+	}
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%T %v\n", p, apertureKey(p.Aperture()))
+	if err := p.WriteGerber(&buf, &diffFormat, 0); err != nil {
+		return fmt.Sprintf("%T %v", p, p.MBB())
+	}
+	return buf.String()
+}
+
+func signatures(primitives []gerber.Primitive) string {
+	var result []string
+	for _, p := range primitives {
+		result = append(result, signature(p))
+	}
+	return strings.Join(result, ";")
+}
+
+// shapeKey identifies a primitive by its type, aperture and size,
+// regardless of its position.
+func shapeKey(p gerber.Primitive) string {
+	mbb := p.MBB()
+	return fmt.Sprintf("%T %v %.4fx%.4f", p, apertureKey(p.Aperture()), mbb.Max[0]-mbb.Min[0], mbb.Max[1]-mbb.Min[1])
+}
+
+// center returns the center of the bounding box of a primitive.
+func center(p gerber.Primitive) gerber.Pt {
+	mbb := p.MBB()
+	return gerber.Pt{0.5 * (mbb.Min[0] + mbb.Max[0]), 0.5 * (mbb.Min[1] + mbb.Max[1])}
+}
+
+// typeName returns the name of the type of a primitive without its package.
+func typeName(p gerber.Primitive) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", p), "*gerber.")
+}
+
+// report writes the differences of the layers to w, listing at most
+// limit primitives per change and layer (all if limit is 0).
+func report(w io.Writer, diffs []*layerDiff, limit int) {
+	list := func(format string, n int, item func(i int) string) {
+		if n == 0 {
+			return
+		}
+		fmt.Fprintf(w, format, n)
+		for i := 0; i < n; i++ {
+			if limit > 0 && i == limit {
+				fmt.Fprintf(w, "    ... and %v more\n", n-limit)
+				break
+			}
+			fmt.Fprintf(w, "    %v\n", item(i))
+		}
+	}
+
+	for _, d := range diffs {
+		switch {
+		case d.before == nil:
+			fmt.Fprintf(w, "%v: added (%v)\n", d.key, strings.Join(d.after.filenames, ", "))
+		case d.after == nil:
+			fmt.Fprintf(w, "%v: removed (%v)\n", d.key, strings.Join(d.before.filenames, ", "))
+		case !d.changed():
+			fmt.Fprintf(w, "%v: unchanged\n", d.key)
+			continue
+		default:
+			fmt.Fprintf(w, "%v: changed (%v -> %v)\n", d.key, strings.Join(d.before.filenames, ", "), strings.Join(d.after.filenames, ", "))
+		}
+		list("  %v apertures added:\n", len(d.aperturesAdded), func(i int) string { return d.aperturesAdded[i] })
+		list("  %v apertures removed:\n", len(d.aperturesRemoved), func(i int) string { return d.aperturesRemoved[i] })
+		list("  %v primitives added:\n", len(d.added), func(i int) string {
+			return fmt.Sprintf("%v at %v", typeName(d.added[i]), formatPt(center(d.added[i])))
+		})
+		list("  %v primitives removed:\n", len(d.removed), func(i int) string {
+			return fmt.Sprintf("%v at %v", typeName(d.removed[i]), formatPt(center(d.removed[i])))
+		})
+		list("  %v primitives moved:\n", len(d.moved), func(i int) string {
+			m := d.moved[i]
+			return fmt.Sprintf("%v from %v to %v", typeName(m.prim), formatPt(m.from), formatPt(m.to))
+		})
+		fmt.Fprintf(w, "  area difference: %.4f mm²\n", d.area)
+	}
+}
+
+// formatPt formats a point in millimeters.
+func formatPt(pt gerber.Pt) string {
+	return fmt.Sprintf("(%.4f, %.4f)", pt[0], pt[1])
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/gmlewis/go-gerber/gerber"
+)
+
+func TestDiffDesigns(t *testing.T) {
+	before := gerber.New("before")
+	top := before.TopCopper()
+	top.Add(
+		gerber.Line(0, 0, 10, 0, gerber.CircleShape, 0.25),
+		gerber.Flash(gerber.Pt{5, 5}, gerber.RectAperture(1, 2)),
+		gerber.Flash(gerber.Pt{8, 8}, gerber.RectAperture(1, 2)),
+		gerber.Circle(gerber.Pt{1, 1}, 0.5),
+	)
+	before.Drill().Add(gerber.Circle(gerber.Pt{0, 0}, 0.8))
+	before.BottomSilkscreen()
+
+	after := gerber.New("after")
+	after.TopCopper().Add(
+		gerber.Line(0, 0, 10, 0, gerber.CircleShape, 0.25),
+		gerber.Flash(gerber.Pt{8, 8}, gerber.RectAperture(1, 2)),
+		gerber.Flash(gerber.Pt{6, 5}, gerber.RectAperture(1, 2)),
+		gerber.Flash(gerber.Pt{1, 1}, gerber.CircleAperture(0.6)),
+	)
+	after.Drill().Add(gerber.Circle(gerber.Pt{0, 0}, 0.8))
+	after.Outline()
+
+	diffs := diffDesigns(before, after)
+	var keys []string
+	for _, d := range diffs {
+		keys = append(keys, d.key.String())
+	}
+	if got, want := strings.Join(keys, ", "), "top copper, bottom silkscreen, drill, outline"; got != want {
+		t.Fatalf("layers = %v, want %v", got, want)
+	}
+
+	d := diffs[0]
+	if len(d.moved) != 1 || d.moved[0].from != (gerber.Pt{5, 5}) || d.moved[0].to != (gerber.Pt{6, 5}) {
+		t.Errorf("moved = %+v, want (5,5) to (6,5)", d.moved)
+	}
+	if len(d.added) != 1 || typeName(d.added[0]) != "FlashT" {
+		t.Errorf("added = %v, want a FlashT", d.added)
+	}
+	if len(d.removed) != 1 || typeName(d.removed[0]) != "CircleT" {
+		t.Errorf("removed = %v, want a CircleT", d.removed)
+	}
+	if got, want := strings.Join(d.aperturesAdded, "; "), "C,0.60000"; got != want {
+		t.Errorf("aperturesAdded = %v, want %v", got, want)
+	}
+	if got, want := strings.Join(d.aperturesRemoved, "; "), "C,0.50000"; got != want {
+		t.Errorf("aperturesRemoved = %v, want %v", got, want)
+	}
+
+	if diffs[1].after != nil || diffs[3].before != nil {
+		t.Errorf("missing layers not detected")
+	}
+	if diffs[2].changed() {
+		t.Errorf("drill layer changed, want unchanged")
+	}
+
+	var buf bytes.Buffer
+	report(&buf, diffs, 0)
+	for _, want := range []string{
+		"top copper: changed (before.gtl -> after.gtl)\n",
+		"  1 primitives moved:\n    FlashT from (5.0000, 5.0000) to (6.0000, 5.0000)\n",
+		"bottom silkscreen: removed (before.gbo)\n",
+		"drill: unchanged\n",
+		"outline: added (after.gko)\n",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("report missing %q:\n%v", want, buf.String())
+		}
+	}
+}
+
+func TestReport_Limit(t *testing.T) {
+	var primitives []gerber.Primitive
+	for i := 0; i < 5; i++ {
+		primitives = append(primitives, gerber.Circle(gerber.Pt{float64(i), 0}, 0.5))
+	}
+	d := &layerDiff{key: layerKey{Type: gerber.LayerDrill}, before: &layerData{}, after: &layerData{}, added: primitives}
+	var buf bytes.Buffer
+	report(&buf, []*layerDiff{d}, 2)
+	want := `drill: changed ( -> )
+  5 primitives added:
+    CircleT at (0.0000, 0.0000)
+    CircleT at (1.0000, 0.0000)
+    ... and 3 more
+  area difference: 0.0000 mm²
+`
+	if got := buf.String(); got != want {
+		t.Errorf("report =\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestOverlay_Add(t *testing.T) {
+	o := newOverlay(2, 2)
+	if got := o.add([]bool{true, true, false, false}, []bool{true, false, true, false}); got != 2 {
+		t.Errorf("add = %v, want 2", got)
+	}
+	img := o.image()
+	for i, want := range []color.RGBA{unchangedColor, removedColor, addedColor, {A: 255}} {
+		if got := img.RGBAAt(i%2, i/2); got != want {
+			t.Errorf("pixel %v = %v, want %v", i, got, want)
+		}
+	}
+}
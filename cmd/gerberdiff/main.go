@@ -0,0 +1,105 @@
+// gerberdiff compares two Gerber designs layer by layer.
+//
+// Usage:
+//
+//	gerberdiff [flags] before after
+//
+// where before and after are directories or ZIP files of Gerber and
+// Excellon files. It reports the added, removed and moved primitives
+// and the aperture table changes of each layer, then rasterizes both
+// designs and writes an overlay PNG of the differences: removed areas
+// are red, added areas green and unchanged areas gray.
+//
+// It exits with status 1 if the total area that differs exceeds
+// the threshold.
+package main
+
+import (
+	"archive/zip"
+	"flag"
+	"fmt"
+	"image/png"
+	"log"
+	"os"
+
+	"github.com/gmlewis/go-gerber/gerber"
+)
+
+var (
+	width     = flag.Int("width", 2000, "Maximum overlay image width")
+	height    = flag.Int("height", 2000, "Maximum overlay image height")
+	out       = flag.String("out", "diff.png", "Output overlay image filename")
+	threshold = flag.Float64("threshold", 0, "Maximum area difference in mm² before exiting with status 1")
+	limit     = flag.Int("limit", 10, "Maximum number of primitives listed per change and layer (0 lists all)")
+)
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 2 {
+		log.Fatal("usage: gerberdiff [flags] before after")
+	}
+
+	before, err := load(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	after, err := load(flag.Arg(1))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	diffs := diffDesigns(before, after)
+	mbb := before.MBB()
+	afterMBB := after.MBB()
+	mbb.Join(&afterMBB)
+	r := newRasterizer(mbb, *width, *height)
+	o := newOverlay(r.width, r.height)
+	var total float64
+	for _, d := range diffs {
+		var bp, ap []gerber.Primitive
+		if d.before != nil {
+			bp = d.before.primitives
+		}
+		if d.after != nil {
+			ap = d.after.primitives
+		}
+		d.area = float64(o.add(r.render(bp), r.render(ap))) * r.pixelArea()
+		total += d.area
+	}
+
+	report(os.Stdout, diffs, *limit)
+	fmt.Printf("total area difference: %.4f mm² (threshold %v mm²)\n", total, *threshold)
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := png.Encode(f, o.image()); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Wrote %v", *out)
+
+	if total > *threshold {
+		os.Exit(1)
+	}
+}
+
+// load loads a design from a directory or a ZIP file.
+func load(name string) (*gerber.Gerber, error) {
+	fi, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if fi.IsDir() {
+		return gerber.LoadFS(os.DirFS(name))
+	}
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return gerber.Load(&zr.Reader)
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"log"
+	"math"
+
+	"github.com/fogleman/gg"
+	"github.com/gmlewis/go-gerber/gerber"
+)
+
+// rasterizer renders layers to coverage masks that all share the
+// same size and transformation.
+type rasterizer struct {
+	mbb           gerber.MBB
+	scale         float64 // pixels per millimeter
+	width, height int
+}
+
+// newRasterizer returns a rasterizer fitting the bounding box
+// in an image of at most width x height pixels.
+func newRasterizer(mbb gerber.MBB, width, height int) *rasterizer {
+	// A small margin avoids empty images for degenerate designs.
+	const margin = 0.1
+	mbb.Min = gerber.Pt{mbb.Min[0] - margin, mbb.Min[1] - margin}
+	mbb.Max = gerber.Pt{mbb.Max[0] + margin, mbb.Max[1] + margin}
+	w, h := mbb.Max[0]-mbb.Min[0], mbb.Max[1]-mbb.Min[1]
+	r := &rasterizer{mbb: mbb, scale: math.Min(float64(width)/w, float64(height)/h)}
+	r.width = int(math.Ceil(r.scale * w))
+	r.height = int(math.Ceil(r.scale * h))
+	return r
+}
+
+func (r *rasterizer) xf(x float64) float64 { return r.scale * (x - r.mbb.Min[0]) }
+func (r *rasterizer) yf(y float64) float64 { return float64(r.height) - r.scale*(y-r.mbb.Min[1]) }
+
+// pixelArea returns the area of a pixel in mm².
+func (r *rasterizer) pixelArea() float64 {
+	return 1 / (r.scale * r.scale)
+}
+
+// render returns the coverage mask of the primitives, one bool per pixel.
+func (r *rasterizer) render(primitives []gerber.Primitive) []bool {
+	dc := gg.NewContext(r.width, r.height)
+	dc.SetRGB(0, 0, 0)
+	dc.Clear()
+	for _, p := range primitives {
+		r.draw(dc, p, true)
+	}
+
+	img := dc.Image()
+	bounds := img.Bounds()
+	mask := make([]bool, r.width*r.height)
+	for y := 0; y < r.height; y++ {
+		for x := 0; x < r.width; x++ {
+			c := color.GrayModel.Convert(img.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.Gray)
+			mask[y*r.width+x] = c.Y >= 0x80
+		}
+	}
+	return mask
+}
+
+// draw draws a primitive in white if dark, or erases it in black.
+func (r *rasterizer) draw(dc *gg.Context, p gerber.Primitive, dark bool) {
+	if dark {
+		dc.SetRGB(1, 1, 1)
+	} else {
+		dc.SetRGB(0, 0, 0)
+	}
+	setLineCap := func(shape gerber.Shape) {
+		if shape == gerber.RectShape {
+			dc.SetLineCapSquare()
+		} else {
+			dc.SetLineCapRound()
+		}
+	}
+
+	switch v := p.(type) {
+	case *gerber.ArcT:
+		dc.SetLineWidth(v.Thickness * r.scale)
+		setLineCap(v.Shape)
+		delta := v.EndAngle - v.StartAngle
+		// Resolution of segments is 0.1mm
+		segments := int(0.5+math.Abs(delta*v.Radius)*10.0) + 1
+		delta /= float64(segments)
+		for i := 0; i <= segments; i++ {
+			angle := v.StartAngle + float64(i)*delta
+			x := v.Center[0] + v.XScale*math.Cos(angle)*v.Radius
+			y := v.Center[1] + v.YScale*math.Sin(angle)*v.Radius
+			dc.LineTo(r.xf(x), r.yf(y))
+		}
+		dc.Stroke()
+	case *gerber.CircleT:
+		mbb := v.MBB()
+		x, y, radius := 0.5*(mbb.Min[0]+mbb.Max[0]), 0.5*(mbb.Min[1]+mbb.Max[1]), 0.5*(mbb.Max[0]-mbb.Min[0])
+		dc.DrawCircle(r.xf(x), r.yf(y), radius*r.scale)
+		dc.Fill()
+	case *gerber.ClearT:
+		for _, p := range v.Primitives {
+			r.draw(dc, p, !dark)
+		}
+	case *gerber.KnockoutT:
+		r.draw(dc, v.Box(), dark)
+		for _, p := range v.Primitives {
+			r.draw(dc, p, !dark)
+		}
+	case *gerber.FlashT:
+		r.drawFlash(dc, v, dark)
+	case *gerber.ImageT:
+		for _, p := range v.Regions {
+			r.draw(dc, p, dark)
+		}
+	case *gerber.BarcodeT:
+		if v.Inverted {
+			r.draw(dc, v.Box(), dark)
+		}
+		for _, p := range v.Regions {
+			r.draw(dc, p, dark != v.Inverted)
+		}
+	case *gerber.LineT:
+		dc.SetLineWidth(v.Thickness * r.scale)
+		setLineCap(v.Shape)
+		dc.DrawLine(r.xf(v.P1[0]), r.yf(v.P1[1]), r.xf(v.P2[0]), r.yf(v.P2[1]))
+		dc.Stroke()
+	case *gerber.PolygonT:
+		for _, pt := range v.Points {
+			dc.LineTo(r.xf(pt[0]+v.Offset[0]), r.yf(pt[1]+v.Offset[1]))
+		}
+		dc.ClosePath()
+		if v.Filled {
+			dc.FillPreserve()
+		}
+		if v.Thickness > 0 {
+			dc.SetLineWidth(v.Thickness * r.scale)
+			dc.SetLineCapRound()
+			dc.Stroke()
+		}
+		dc.ClearPath()
+	case *gerber.RegionT:
+		for _, c := range append([]gerber.Contour{v.Outer}, v.Holes...) {
+			dc.NewSubPath()
+			for _, pt := range c.Points() {
+				dc.LineTo(r.xf(pt[0]), r.yf(pt[1]))
+			}
+			dc.ClosePath()
+		}
+		dc.SetFillRuleEvenOdd()
+		dc.Fill()
+		dc.SetFillRuleWinding()
+	default:
+		log.Printf("%T not yet supported", v)
+	}
+}
+
+// drawFlash draws the aperture of a flash with its transformations.
+func (r *rasterizer) drawFlash(dc *gg.Context, f *gerber.FlashT, dark bool) {
+	a := f.Aperture()
+	if a == nil {
+		return
+	}
+	dc.Push()
+	defer dc.Pop()
+	// Move the aperture origin to the flash center, then apply the
+	// transformations (the image Y axis points down).
+	dc.Translate(r.xf(f.Center[0]), r.yf(f.Center[1]))
+	if f.Scale != 0 {
+		dc.Scale(f.Scale, f.Scale)
+	}
+	dc.Rotate(-gg.Radians(f.Rotation))
+	switch f.Mirror {
+	case gerber.MirrorX:
+		dc.Scale(-1, 1)
+	case gerber.MirrorY:
+		dc.Scale(1, -1)
+	case gerber.MirrorXY:
+		dc.Scale(-1, -1)
+	}
+	dc.Translate(-r.xf(0), -r.yf(0))
+
+	if a.Block != nil {
+		for _, p := range a.Block.Primitives {
+			r.draw(dc, p, dark)
+		}
+		return
+	}
+
+	x, y := r.xf(0), r.yf(0)
+	switch {
+	case a.Macro != nil:
+		// Approximate macro apertures by their bounding box.
+		mbb := a.MBB()
+		dc.DrawRectangle(r.xf(mbb.Min[0]), r.yf(mbb.Max[1]), r.scale*(mbb.Max[0]-mbb.Min[0]), r.scale*(mbb.Max[1]-mbb.Min[1]))
+	case a.Shape == gerber.RectShape, a.Shape == gerber.ObroundShape:
+		mbb := a.MBB()
+		w, h := r.scale*(mbb.Max[0]-mbb.Min[0]), r.scale*(mbb.Max[1]-mbb.Min[1])
+		if a.Shape == gerber.ObroundShape {
+			dc.DrawRoundedRectangle(x-0.5*w, y-0.5*h, w, h, 0.5*math.Min(w, h))
+		} else {
+			dc.DrawRectangle(x-0.5*w, y-0.5*h, w, h)
+		}
+	case a.Shape == gerber.PolygonShape:
+		radius := 0.5 * a.Size
+		for i := 0; i < a.Vertices; i++ {
+			angle := math.Pi * (a.Rotation + 360*float64(i)/float64(a.Vertices)) / 180
+			dc.LineTo(r.xf(radius*math.Cos(angle)), r.yf(radius*math.Sin(angle)))
+		}
+		dc.ClosePath()
+	default:
+		dc.DrawCircle(x, y, 0.5*a.Size*r.scale)
+	}
+	if a.Hole > 0 {
+		dc.SetFillRuleEvenOdd()
+		dc.NewSubPath()
+		dc.DrawCircle(x, y, 0.5*a.Hole*r.scale)
+		dc.Fill()
+		dc.SetFillRuleWinding()
+		return
+	}
+	dc.Fill()
+}
+
+// overlay accumulates the coverage of the layers of both designs.
+type overlay struct {
+	width, height  int
+	before, after  []bool // covered by any layer
+	removed, added []bool // differences of any layer
+}
+
+func newOverlay(width, height int) *overlay {
+	n := width * height
+	return &overlay{
+		width:   width,
+		height:  height,
+		before:  make([]bool, n),
+		after:   make([]bool, n),
+		removed: make([]bool, n),
+		added:   make([]bool, n),
+	}
+}
+
+// add adds the masks of a layer and returns the number of pixels
+// that differ.
+func (o *overlay) add(before, after []bool) int {
+	var n int
+	for i := range before {
+		o.before[i] = o.before[i] || before[i]
+		o.after[i] = o.after[i] || after[i]
+		if before[i] == after[i] {
+			continue
+		}
+		n++
+		if before[i] {
+			o.removed[i] = true
+		} else {
+			o.added[i] = true
+		}
+	}
+	return n
+}
+
+var (
+	removedColor   = color.RGBA{R: 250, G: 50, B: 50, A: 255}
+	addedColor     = color.RGBA{R: 50, G: 250, B: 50, A: 255}
+	bothColor      = color.RGBA{R: 250, G: 250, B: 50, A: 255}
+	unchangedColor = color.RGBA{R: 100, G: 100, B: 100, A: 255}
+)
+
+// image returns the overlay image: pixels removed in red, added
+// in green, both (on different layers) in yellow and unchanged
+// in gray.
+func (o *overlay) image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, o.width, o.height))
+	for y := 0; y < o.height; y++ {
+		for x := 0; x < o.width; x++ {
+			i := y*o.width + x
+			c := color.RGBA{A: 255}
+			switch {
+			case o.removed[i] && o.added[i]:
+				c = bothColor
+			case o.removed[i]:
+				c = removedColor
+			case o.added[i]:
+				c = addedColor
+			case o.before[i] || o.after[i]:
+				c = unchangedColor
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
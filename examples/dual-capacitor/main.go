@@ -81,8 +81,8 @@ func main() {
 
 	top := g.TopCopper()
 	top.Add(
-		Polygon(Pt{0, 0}, false, railL, 0.0),
-		Polygon(Pt{0, 0}, false, railR, 0.0),
+		Polygon(Pt{0, 0}, true, railL, 0.0),
+		Polygon(Pt{0, 0}, true, railR, 0.0),
 
 		padLine(Pt{padR, 2 * padD}, pad1),
 		padLine(Pt{*width - padR, *height - 2*padD}, pad2),
@@ -100,8 +100,8 @@ func main() {
 
 	bottom := g.BottomCopper()
 	bottom.Add(
-		Polygon(Pt{0, 0}, false, railL, 0.0),
-		Polygon(Pt{0, 0}, false, railR, 0.0),
+		Polygon(Pt{0, 0}, true, railL, 0.0),
+		Polygon(Pt{0, 0}, true, railR, 0.0),
 
 		padLine(Pt{2 * padD, padR}, pad1),
 		padLine(Pt{*width - 2*padD, *height - padR}, pad2),
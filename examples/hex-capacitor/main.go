@@ -76,8 +76,8 @@ func main() {
 
 	top := g.TopCopper()
 	top.Add(
-		Polygon(Pt{0, 0}, false, railL, 0.0),
-		Polygon(Pt{0, 0}, false, railR, 0.0),
+		Polygon(Pt{0, 0}, true, railL, 0.0),
+		Polygon(Pt{0, 0}, true, railR, 0.0),
 
 		padLine(Pt{padR, 2 * padD}, pad1),
 		padLine(Pt{*width - padR, *height - 2*padD}, pad2),
@@ -95,8 +95,8 @@ func main() {
 
 	layer2 := g.LayerN(2)
 	layer2.Add(
-		Polygon(Pt{0, 0}, false, railL, 0.0),
-		Polygon(Pt{0, 0}, false, railR, 0.0),
+		Polygon(Pt{0, 0}, true, railL, 0.0),
+		Polygon(Pt{0, 0}, true, railR, 0.0),
 
 		padLine(Pt{2 * padD, padR}, pad1),
 		padLine(Pt{*width - 2*padD, *height - padR}, pad2),
@@ -108,8 +108,8 @@ func main() {
 
 	layer4 := g.LayerN(4)
 	layer4.Add(
-		Polygon(Pt{0, 0}, false, railL, 0.0),
-		Polygon(Pt{0, 0}, false, railR, 0.0),
+		Polygon(Pt{0, 0}, true, railL, 0.0),
+		Polygon(Pt{0, 0}, true, railR, 0.0),
 
 		padLine(Pt{2 * padD, padR}, pad1),
 		padLine(Pt{*width - 2*padD, *height - padR}, pad2),
@@ -121,8 +121,8 @@ func main() {
 
 	bottom := g.BottomCopper()
 	bottom.Add(
-		Polygon(Pt{0, 0}, false, railL, 0.0),
-		Polygon(Pt{0, 0}, false, railR, 0.0),
+		Polygon(Pt{0, 0}, true, railL, 0.0),
+		Polygon(Pt{0, 0}, true, railR, 0.0),
 
 		padLine(Pt{2 * padD, padR}, pad1),
 		padLine(Pt{*width - 2*padD, *height - padR}, pad2),
@@ -140,8 +140,8 @@ func main() {
 
 	layer3 := g.LayerN(3)
 	layer3.Add(
-		Polygon(Pt{0, 0}, false, railL, 0.0),
-		Polygon(Pt{0, 0}, false, railR, 0.0),
+		Polygon(Pt{0, 0}, true, railL, 0.0),
+		Polygon(Pt{0, 0}, true, railR, 0.0),
 
 		padLine(Pt{padR, 2 * padD}, pad1),
 		padLine(Pt{*width - padR, *height - 2*padD}, pad2),
@@ -153,8 +153,8 @@ func main() {
 
 	layer5 := g.LayerN(5)
 	layer5.Add(
-		Polygon(Pt{0, 0}, false, railL, 0.0),
-		Polygon(Pt{0, 0}, false, railR, 0.0),
+		Polygon(Pt{0, 0}, true, railL, 0.0),
+		Polygon(Pt{0, 0}, true, railR, 0.0),
 
 		padLine(Pt{padR, 2 * padD}, pad1),
 		padLine(Pt{*width - padR, *height - 2*padD}, pad2),
@@ -0,0 +1,193 @@
+package gerber
+
+import (
+	"io"
+)
+
+// BarcodeOpts provides options for barcode symbols.
+type BarcodeOpts struct {
+	// XAlign and YAlign align the symbol, including its quiet zone,
+	// on the given position like text (default XLeft and YBottom).
+	XAlign float64
+	YAlign float64
+	// QuietZone is the width in modules of the light margin around
+	// the symbol. Zero means the minimum of the symbology: 4 for
+	// QR codes, 1 for Data Matrix and 10 (left and right) for Code 128.
+	QuietZone int
+	// Inverted draws the quiet zone and the light modules, clearing
+	// the dark modules, so the symbol reads light on dark.
+	Inverted bool
+	// Mirror mirrors the symbol left to right, for bottom layers.
+	Mirror bool
+}
+
+// BarcodeT represents a barcode symbol and satisfies
+// the Primitive interface.
+type BarcodeT struct {
+	// Modules are the rows of modules from the top, true for dark.
+	// 1D symbols have a single row of bars.
+	Modules [][]bool
+	// Regions are the dark modules merged into regions.
+	Regions []*RegionT
+	// Inverted draws the box of the symbol dark and clears
+	// the regions.
+	Inverted bool
+	Attributes
+	box MBB // the symbol with its quiet zone
+}
+
+// newBarcode returns a barcode symbol of the modules. The modules are
+// moduleWidth by moduleHeight millimeters, surrounded by a quiet zone
+// of quietX and quietY modules.
+func newBarcode(x, y float64, modules [][]bool, moduleWidth, moduleHeight float64, quietX, quietY int, opts *BarcodeOpts) *BarcodeT {
+	if opts == nil {
+		opts = &BarcodeOpts{}
+	}
+	rows, cols := len(modules), len(modules[0])
+	width := float64(cols+2*quietX) * moduleWidth
+	height := float64(rows+2*quietY) * moduleHeight
+	ll := Pt{x - opts.XAlign*width, y - opts.YAlign*height}
+
+	if opts.Mirror {
+		// Mirror a copy; the caller's rows are left unchanged.
+		mirrored := make([][]bool, rows)
+		for i, row := range modules {
+			mirrored[i] = make([]bool, cols)
+			for j, dark := range row {
+				mirrored[i][cols-1-j] = dark
+			}
+		}
+		modules = mirrored
+	}
+
+	g := &imageGrid{
+		cols:   cols,
+		rows:   rows,
+		cellW:  moduleWidth,
+		cellH:  moduleHeight,
+		origin: Pt{ll[0] + float64(quietX)*moduleWidth, ll[1] + float64(quietY)*moduleHeight},
+		dark:   make([]bool, cols*rows),
+	}
+	for i, row := range modules {
+		// Grid rows point up.
+		copy(g.dark[(rows-1-i)*cols:], row)
+	}
+	b := &BarcodeT{
+		Modules:  modules,
+		Inverted: opts.Inverted,
+		box:      MBB{Min: ll, Max: Pt{ll[0] + width, ll[1] + height}},
+	}
+	for _, c := range g.trace() {
+		b.Regions = append(b.Regions, Region(c.outer, c.holes...))
+	}
+	return b
+}
+
+// Box returns the dark region drawn behind inverted symbols.
+func (b *BarcodeT) Box() *RegionT {
+	return Region(PolygonContour([]Pt{
+		b.box.Min,
+		{b.box.Max[0], b.box.Min[1]},
+		b.box.Max,
+		{b.box.Min[0], b.box.Max[1]},
+	}))
+}
+
+// WriteGerber writes the primitive to the Gerber file.
+func (b *BarcodeT) WriteGerber(w io.Writer, f *Format, apertureIndex int) error {
+	pol := &polarity{}
+	if err := b.writePolarized(w, f, false, pol); err != nil {
+		return err
+	}
+	pol.set(w, false)
+	return nil
+}
+
+// writePolarized writes the symbol with clear polarity for the dark
+// modules if clear is true, switching the polarity for inverted symbols.
+func (b *BarcodeT) writePolarized(w io.Writer, f *Format, clear bool, pol *polarity) error {
+	if b.Inverted {
+		pol.set(w, clear)
+		if err := b.Box().WriteGerber(w, f, 0); err != nil {
+			return err
+		}
+		clear = !clear
+	}
+	pol.set(w, clear)
+	for _, r := range b.Regions {
+		if err := r.WriteGerber(w, f, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Aperture returns nil for BarcodeT because its regions use
+// the default aperture.
+func (b *BarcodeT) Aperture() *Aperture {
+	return nil
+}
+
+// MBB returns the box of the symbol, including its quiet zone,
+// so that placing it next to other primitives keeps it readable.
+func (b *BarcodeT) MBB() MBB {
+	return b.box
+}
+
+// gf256 is the Galois field GF(2⁸) of a primitive polynomial,
+// used for the Reed-Solomon error correction of 2D symbols.
+type gf256 struct {
+	exp [510]byte
+	log [256]int
+}
+
+func newGF256(poly int) *gf256 {
+	gf := &gf256{}
+	x := 1
+	for i := 0; i < 255; i++ {
+		gf.exp[i], gf.exp[i+255] = byte(x), byte(x)
+		gf.log[x] = i
+		x <<= 1
+		if x >= 256 {
+			x ^= poly
+		}
+	}
+	return gf
+}
+
+func (gf *gf256) mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gf.exp[gf.log[a]+gf.log[b]]
+}
+
+// ecc returns the n Reed-Solomon error correction codewords of data
+// for the generator polynomial with roots α^first to α^(first+n-1).
+func (gf *gf256) ecc(data []byte, n, first int) []byte {
+	// The generator coefficients from the highest degree down,
+	// without the leading 1.
+	gen := make([]byte, n)
+	gen[n-1] = 1
+	for i := 0; i < n; i++ {
+		root := gf.exp[(first+i)%255]
+		// Multiply by (x - root).
+		for j := 0; j < n; j++ {
+			gen[j] = gf.mul(gen[j], root)
+			if j+1 < n {
+				gen[j] ^= gen[j+1]
+			}
+		}
+	}
+
+	ecc := make([]byte, n)
+	for _, d := range data {
+		factor := d ^ ecc[0]
+		copy(ecc, ecc[1:])
+		ecc[n-1] = 0
+		for j := range ecc {
+			ecc[j] ^= gf.mul(gen[j], factor)
+		}
+	}
+	return ecc
+}
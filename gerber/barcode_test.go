@@ -0,0 +1,188 @@
+package gerber
+
+import (
+	"bytes"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCode128Codes(t *testing.T) {
+	tests := []struct {
+		data string
+		want []int
+	}{
+		{data: "PJJ123C", want: []int{code128StartB, 48, 42, 42, 17, 18, 19, 35, 55, code128Stop}},
+		{data: "12345678", want: []int{code128StartC, 12, 34, 56, 78, 47, code128Stop}},
+		{data: "12345", want: []int{code128StartC, 12, 34, code128CodeB, 21, 54, code128Stop}},
+		{data: "A1234", want: []int{code128StartB, 33, code128CodeC, 12, 34, 95, code128Stop}},
+		{data: "SN-1234567-A", want: []int{code128StartB, 51, 46, 13, 17, code128CodeC, 23, 45, 67, code128CodeB, 13, 33, 38, code128Stop}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			got, err := code128Codes(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The expected checksums are recomputed here.
+			sum := tt.want[0]
+			for i, code := range tt.want[1 : len(tt.want)-2] {
+				sum += (i + 1) * code
+			}
+			if sum%103 != tt.want[len(tt.want)-2] {
+				t.Fatalf("bad test: checksum = %v", sum%103)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("code128Codes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, data := range []string{"", "tab\there", "é"} {
+		if _, err := code128Codes(data); err == nil {
+			t.Errorf("code128Codes(%q) = nil error", data)
+		}
+	}
+}
+
+func TestCode128Patterns(t *testing.T) {
+	for i, p := range code128Patterns {
+		var sum int
+		for _, c := range p {
+			sum += int(c - '0')
+		}
+		want := 11
+		if i == code128Stop {
+			want = 13
+		}
+		if sum != want {
+			t.Errorf("pattern %v (%v) is %v modules wide, want %v", i, p, sum, want)
+		}
+	}
+}
+
+func TestCode128(t *testing.T) {
+	tests := []struct {
+		name string
+		opts *BarcodeOpts
+		want MBB
+	}{
+		{
+			name: "bottom left",
+			want: MBB{Max: Pt{49.5, 10}},
+		},
+		{
+			name: "center",
+			opts: &BarcodeOpts{XAlign: XCenter, YAlign: YCenter},
+			want: MBB{Min: Pt{-24.75, -5}, Max: Pt{24.75, 5}},
+		},
+		{
+			name: "quiet zone",
+			opts: &BarcodeOpts{XAlign: XRight, YAlign: YTop, QuietZone: 2},
+			want: MBB{Min: Pt{-41.5, -10}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 79 modules of 0.5mm with a quiet zone of 10 on each side.
+			b, err := Code128(0, 0, "12345678", 0.5, 10, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := b.MBB(); !mbbClose(got, tt.want) {
+				t.Errorf("MBB = %v, want %v", got, tt.want)
+			}
+			if got := len(b.Modules[0]); got != 79 {
+				t.Errorf("got %v modules, want 79", got)
+			}
+			// Each bar is a region: 3 per symbol, 4 in the stop code.
+			if got, want := len(b.Regions), 3*6+4; got != want {
+				t.Errorf("got %v regions, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestBarcode_Mirror(t *testing.T) {
+	b, err := Code128(0, 0, "MIRROR", 1, 5, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := Code128(0, 0, "MIRROR", 1, 5, &BarcodeOpts{Mirror: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, mirrored := b.Modules[0], m.Modules[0]
+	for i := range row {
+		if row[i] != mirrored[len(row)-1-i] {
+			t.Fatalf("module %v is not mirrored", i)
+		}
+	}
+	if b.MBB() != m.MBB() {
+		t.Errorf("mirrored MBB = %v, want %v", m.MBB(), b.MBB())
+	}
+
+	// The caller's modules are not mirrored in place.
+	modules := [][]bool{{true, false, false}}
+	newBarcode(0, 0, modules, 1, 1, 0, 0, &BarcodeOpts{Mirror: true})
+	if !modules[0][0] || modules[0][2] {
+		t.Errorf("modules = %v, want unchanged", modules)
+	}
+}
+
+func TestBarcode_WriteGerber(t *testing.T) {
+	polarities := regexp.MustCompile(`%LP[CD]\*%`)
+	tests := []struct {
+		name string
+		prim func(b *BarcodeT) Primitive
+		want []string
+	}{
+		{
+			name: "plain",
+			prim: func(b *BarcodeT) Primitive { return b },
+			want: []string{"%LPD*%"},
+		},
+		{
+			name: "inverted",
+			prim: func(b *BarcodeT) Primitive {
+				b.Inverted = true
+				return b
+			},
+			want: []string{"%LPD*%", "%LPC*%", "%LPD*%"},
+		},
+		{
+			name: "inverted and cleared",
+			prim: func(b *BarcodeT) Primitive {
+				b.Inverted = true
+				return Clear(b)
+			},
+			want: []string{"%LPD*%", "%LPC*%", "%LPD*%"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := DataMatrix(0, 0, "SN0001", 0.3, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			layer := New("barcode").TopSilkscreen()
+			layer.Add(tt.prim(b))
+			var buf bytes.Buffer
+			if err := layer.WriteGerber(&buf); err != nil {
+				t.Fatal(err)
+			}
+			got := polarities.FindAllString(buf.String(), -1)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("polarities = %v, want %v", got, tt.want)
+			}
+			wantRegions := len(b.Regions)
+			if b.Inverted {
+				wantRegions++
+			}
+			if got := strings.Count(buf.String(), "G36*"); got != wantRegions {
+				t.Errorf("got %v regions, want %v", got, wantRegions)
+			}
+		})
+	}
+}
@@ -0,0 +1,165 @@
+package gerber
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Block represents a named group of primitives that is written once
+// as a Gerber block aperture (%AB) and flashed at many locations.
+type Block struct {
+	// Name identifies the block in error messages.
+	Name string
+	// Primitives are the primitives of the block relative to its origin.
+	Primitives []Primitive
+}
+
+// BlockAperture returns a block aperture made from the primitives.
+// Flash it at each location with Flash.
+// All dimensions are in millimeters.
+func BlockAperture(name string, primitives ...Primitive) *Aperture {
+	return &Aperture{Block: &Block{Name: name, Primitives: primitives}}
+}
+
+// MBB returns the minimum bounding box of the block relative to its origin.
+func (b *Block) MBB() MBB {
+	return primitivesMBB(b.Primitives)
+}
+
+// writeBlock writes the block aperture definition. The apertures
+// used by the block's primitives must already be defined.
+func (l *Layer) writeBlock(w io.Writer, f *Format, b *Block, apertureIndex int) error {
+	fmt.Fprintf(w, "%%ABD%v*%%\n", apertureIndex)
+	if err := l.writePrimitives(w, f, b.Primitives); err != nil {
+		return fmt.Errorf("block %v: %v", b.Name, err)
+	}
+	io.WriteString(w, "%AB*%\n")
+	return nil
+}
+
+// Mirroring represents the mirroring of a flashed aperture.
+type Mirroring string
+
+const (
+	// MirrorX inverts the X coordinates (mirrors left to right).
+	MirrorX Mirroring = "X"
+	// MirrorY inverts the Y coordinates (mirrors top to bottom).
+	MirrorY Mirroring = "Y"
+	// MirrorXY inverts both the X and Y coordinates.
+	MirrorXY Mirroring = "XY"
+)
+
+// transform applies the flash's mirroring, rotation and scaling
+// (in that order) to a point relative to the aperture origin.
+func (f *FlashT) transform(pt Pt) Pt {
+	switch f.Mirror {
+	case MirrorX:
+		pt[0] = -pt[0]
+	case MirrorY:
+		pt[1] = -pt[1]
+	case MirrorXY:
+		pt[0], pt[1] = -pt[0], -pt[1]
+	}
+	pt = rotate(pt, f.Rotation)
+	if f.Scale != 0 {
+		pt[0], pt[1] = f.Scale*pt[0], f.Scale*pt[1]
+	}
+	return pt
+}
+
+// writeTransforms loads the flash's Gerber 2016 aperture transformations
+// (%LM, %LR and %LS) into the graphics state. It returns a function
+// that restores the defaults.
+func (f *FlashT) writeTransforms(w io.Writer) (reset func()) {
+	var resets []string
+	if f.Mirror != "" {
+		fmt.Fprintf(w, "%%LM%v*%%\n", f.Mirror)
+		resets = append(resets, "%LMN*%\n")
+	}
+	if f.Rotation != 0 {
+		fmt.Fprintf(w, "%%LR%v*%%\n", strconv.FormatFloat(f.Rotation, 'f', -1, 64))
+		resets = append(resets, "%LR0*%\n")
+	}
+	if f.Scale != 0 && f.Scale != 1 {
+		fmt.Fprintf(w, "%%LS%v*%%\n", strconv.FormatFloat(f.Scale, 'f', -1, 64))
+		resets = append(resets, "%LS1*%\n")
+	}
+	return func() {
+		for _, s := range resets {
+			io.WriteString(w, s)
+		}
+	}
+}
+
+// flashTransform is the transformation of the flashed block apertures
+// containing a primitive, innermost last.
+type flashTransform struct {
+	flashes []*FlashT
+}
+
+// apply transforms a point to design coordinates.
+func (t flashTransform) apply(pt Pt) Pt {
+	for i := len(t.flashes) - 1; i >= 0; i-- {
+		f := t.flashes[i]
+		pt = f.transform(pt)
+		pt = Pt{f.Center[0] + pt[0], f.Center[1] + pt[1]}
+	}
+	return pt
+}
+
+// mirrored reports whether the transformation reverses the direction
+// of arcs.
+func (t flashTransform) mirrored() bool {
+	var result bool
+	for _, f := range t.flashes {
+		if f.Mirror == MirrorX || f.Mirror == MirrorY {
+			result = !result
+		}
+	}
+	return result
+}
+
+// scale returns the scale of the transformation.
+func (t flashTransform) scale() float64 {
+	result := 1.0
+	for _, f := range t.flashes {
+		if f.Scale != 0 {
+			result *= f.Scale
+		}
+	}
+	return result
+}
+
+// with returns the transformation of the primitives of a flashed block.
+func (t flashTransform) with(f *FlashT) flashTransform {
+	return flashTransform{flashes: append(append([]*FlashT{}, t.flashes...), f)}
+}
+
+// segment returns the contour segment in design coordinates.
+func (t flashTransform) segment(s Segment) Segment {
+	s.End = t.apply(s.End)
+	if s.Arc {
+		s.Center = t.apply(s.Center)
+		s.Clockwise = s.Clockwise != t.mirrored()
+	}
+	return s
+}
+
+// contour returns the contour in design coordinates.
+func (t flashTransform) contour(c Contour) Contour {
+	result := Contour{Start: t.apply(c.Start), Segments: make([]Segment, len(c.Segments))}
+	for i, s := range c.Segments {
+		result.Segments[i] = t.segment(s)
+	}
+	return result
+}
+
+// region returns a copy of the region in design coordinates.
+func (t flashTransform) region(r *RegionT) *RegionT {
+	result := &RegionT{Outer: t.contour(r.Outer)}
+	for _, h := range r.Holes {
+		result.Holes = append(result.Holes, t.contour(h))
+	}
+	return result
+}
@@ -0,0 +1,93 @@
+package gerber
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBlock_WriteGerber(t *testing.T) {
+	via := BlockAperture("via",
+		Flash(Pt{0, 0}, CircleAperture(1)),
+		Line(0, 0, 2, 0, CircleShape, 0.25),
+	)
+	g := New("block")
+	layer := g.TopCopper()
+	layer.Add(
+		Flash(Pt{10, 0}, via),
+		&FlashT{Center: Pt{20, 0}, Mirror: MirrorX, Rotation: 90, Scale: 2, aperture: via},
+	)
+
+	if got, want := len(layer.Apertures), 3; got != want {
+		t.Fatalf("len(Apertures) = %v, want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := layer.WriteGerber(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	want := `%ADD11C,0.00100*%
+%ADD12C,1.00000*%
+%TA.AperFunction,Conductor*%
+%ADD13C,0.25000*%
+%TD.AperFunction*%
+%ABD14*%
+G54D12*
+X0Y0D03*
+G54D13*
+X0Y0D02*
+X2000000Y0D01*
+%AB*%
+G54D14*
+X10000000Y0D03*
+G54D14*
+%LMX*%
+%LR90*%
+%LS2*%
+X20000000Y0D03*
+%LMN*%
+%LR0*%
+%LS1*%
+M02*
+`
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("WriteGerber =\n%v\nwant suffix:\n%v", got, want)
+	}
+}
+
+func TestFlashT_BlockMBB(t *testing.T) {
+	block := BlockAperture("pad-and-trace",
+		Flash(Pt{0, 0}, RectAperture(2, 2)),
+		Line(0, 0, 4, 0, RectShape, 1),
+	)
+	tests := []struct {
+		name  string
+		flash *FlashT
+		want  MBB
+	}{
+		{
+			name:  "plain",
+			flash: Flash(Pt{10, 10}, block),
+			want:  MBB{Min: Pt{9, 9}, Max: Pt{14.5, 11}},
+		},
+		{
+			name:  "mirrored",
+			flash: &FlashT{Center: Pt{10, 10}, Mirror: MirrorX, aperture: block},
+			want:  MBB{Min: Pt{5.5, 9}, Max: Pt{11, 11}},
+		},
+		{
+			name:  "rotated and scaled",
+			flash: &FlashT{Center: Pt{10, 10}, Rotation: 90, Scale: 2, aperture: block},
+			want:  MBB{Min: Pt{8, 8}, Max: Pt{12, 19}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.flash.MBB(); !mbbClose(got, tt.want) {
+				t.Errorf("MBB = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package gerber
+
+import (
+	"fmt"
+)
+
+// code128Patterns are the widths of the alternating bars and spaces
+// of the Code 128 symbols, starting with a bar.
+var code128Patterns = []string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232", "2331112",
+}
+
+const (
+	code128CodeB  = 100
+	code128CodeC  = 99
+	code128StartB = 104
+	code128StartC = 105
+	code128Stop   = 106
+)
+
+// Code128 returns a Code 128 barcode of the printable ASCII data at
+// (x, y), aligned by opts like text. Runs of digits are encoded in
+// pairs (code set C). The narrowest bar is moduleSize wide and the
+// bars are height tall. opts may be nil.
+// All dimensions are in millimeters.
+func Code128(x, y float64, data string, moduleSize, height float64, opts *BarcodeOpts) (*BarcodeT, error) {
+	codes, err := code128Codes(data)
+	if err != nil {
+		return nil, err
+	}
+	var bars []bool
+	for _, code := range codes {
+		for i, c := range code128Patterns[code] {
+			for j := 0; j < int(c-'0'); j++ {
+				bars = append(bars, i%2 == 0)
+			}
+		}
+	}
+
+	quiet := 10
+	if opts != nil && opts.QuietZone > 0 {
+		quiet = opts.QuietZone
+	}
+	return newBarcode(x, y, [][]bool{bars}, moduleSize, height, quiet, 0, opts), nil
+}
+
+// code128Codes returns the symbol values of the data, from the start
+// code to the stop code.
+func code128Codes(data string) ([]int, error) {
+	if data == "" {
+		return nil, fmt.Errorf("barcode data is empty")
+	}
+	for i := 0; i < len(data); i++ {
+		if data[i] < ' ' || data[i] > '~' {
+			return nil, fmt.Errorf("Code 128 data %q: unsupported character %q", data, data[i])
+		}
+	}
+
+	// digits returns the length of the run of digits at i.
+	digits := func(i int) int {
+		n := 0
+		for i+n < len(data) && data[i+n] >= '0' && data[i+n] <= '9' {
+			n++
+		}
+		return n
+	}
+
+	var codes []int
+	codeC := false
+	if n := digits(0); n >= 4 || n == len(data) && n%2 == 0 {
+		codes = append(codes, code128StartC)
+		codeC = true
+	} else {
+		codes = append(codes, code128StartB)
+	}
+	for i := 0; i < len(data); {
+		n := digits(i)
+		switch {
+		case codeC && n >= 2:
+			codes = append(codes, int(data[i]-'0')*10+int(data[i+1]-'0'))
+			i += 2
+			continue
+		case codeC:
+			codes = append(codes, code128CodeB)
+			codeC = false
+		case n >= 6 || n >= 4 && i+n == len(data):
+			// Switch to pairs, after an odd digit.
+			if n%2 == 1 {
+				codes = append(codes, int(data[i]-' '))
+				i++
+			}
+			codes = append(codes, code128CodeC)
+			codeC = true
+			continue
+		}
+		codes = append(codes, int(data[i]-' '))
+		i++
+	}
+
+	sum := codes[0]
+	for i, code := range codes[1:] {
+		sum += (i + 1) * code
+	}
+	return append(codes, sum%103, code128Stop), nil
+}
@@ -0,0 +1,267 @@
+package gerber
+
+import (
+	"fmt"
+)
+
+// dataMatrixSize is a square ECC 200 Data Matrix symbol size.
+type dataMatrixSize struct {
+	size    int // modules per side, including the finder patterns
+	regions int // data regions per side
+	data    int // data codewords
+	ecc     int // error correction codewords
+	blocks  int // interleaved Reed-Solomon blocks
+}
+
+var dataMatrixSizes = []dataMatrixSize{
+	{10, 1, 3, 5, 1},
+	{12, 1, 5, 7, 1},
+	{14, 1, 8, 10, 1},
+	{16, 1, 12, 12, 1},
+	{18, 1, 18, 14, 1},
+	{20, 1, 22, 18, 1},
+	{22, 1, 30, 20, 1},
+	{24, 1, 36, 24, 1},
+	{26, 1, 44, 28, 1},
+	{32, 2, 62, 36, 1},
+	{36, 2, 86, 42, 1},
+	{40, 2, 114, 48, 1},
+	{44, 2, 144, 56, 1},
+	{48, 2, 174, 68, 1},
+	{52, 2, 204, 84, 2},
+	{64, 4, 280, 112, 2},
+	{72, 4, 368, 144, 4},
+	{80, 4, 456, 192, 4},
+	{88, 4, 576, 224, 4},
+	{96, 4, 696, 272, 4},
+	{104, 4, 816, 336, 6},
+	{120, 6, 1050, 408, 6},
+	{132, 6, 1304, 496, 8},
+	{144, 6, 1558, 620, 10},
+}
+
+// DataMatrix returns a square ECC 200 Data Matrix symbol of the data at
+// (x, y), aligned by opts like text, with modules of moduleSize. The
+// data is encoded in ASCII mode with digit pairs, in the smallest
+// symbol that fits. opts may be nil.
+// All dimensions are in millimeters.
+func DataMatrix(x, y float64, data string, moduleSize float64, opts *BarcodeOpts) (*BarcodeT, error) {
+	modules, err := dataMatrixModules(data)
+	if err != nil {
+		return nil, err
+	}
+	quiet := 1
+	if opts != nil && opts.QuietZone > 0 {
+		quiet = opts.QuietZone
+	}
+	return newBarcode(x, y, modules, moduleSize, moduleSize, quiet, quiet, opts), nil
+}
+
+// dataMatrixEncode returns the ASCII encodation of the data.
+func dataMatrixEncode(data string) []byte {
+	var result []byte
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case isDigit(c) && i+1 < len(data) && isDigit(data[i+1]):
+			result = append(result, 130+(c-'0')*10+data[i+1]-'0')
+			i++
+		case c >= 128:
+			result = append(result, 235, c-127) // upper shift
+		default:
+			result = append(result, c+1)
+		}
+	}
+	return result
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// dataMatrixCodewords returns the interleaved data and error correction
+// codewords of the data and the symbol size that holds them.
+func dataMatrixCodewords(data string) ([]byte, *dataMatrixSize, error) {
+	if data == "" {
+		return nil, nil, fmt.Errorf("barcode data is empty")
+	}
+	codewords := dataMatrixEncode(data)
+	var s *dataMatrixSize
+	for i := range dataMatrixSizes {
+		if len(codewords) <= dataMatrixSizes[i].data {
+			s = &dataMatrixSizes[i]
+			break
+		}
+	}
+	if s == nil {
+		return nil, nil, fmt.Errorf("Data Matrix data is too long (%v codewords)", len(codewords))
+	}
+
+	// Pad with 129, then with scrambled pad values.
+	for i, n := len(codewords), len(codewords); i < s.data; i++ {
+		if i == n {
+			codewords = append(codewords, 129)
+			continue
+		}
+		v := 129 + (149*(i+1))%253 + 1
+		if v > 254 {
+			v -= 254
+		}
+		codewords = append(codewords, byte(v))
+	}
+
+	gf := newGF256(0x12d)
+	eccLen := s.ecc / s.blocks
+	result := append([]byte{}, codewords...)
+	result = append(result, make([]byte, s.ecc)...)
+	for b := 0; b < s.blocks; b++ {
+		var block []byte
+		for i := b; i < s.data; i += s.blocks {
+			block = append(block, codewords[i])
+		}
+		for j, e := range gf.ecc(block, eccLen, 1) {
+			result[s.data+j*s.blocks+b] = e
+		}
+	}
+	return result, s, nil
+}
+
+// dataMatrixModules returns the rows of modules of the symbol,
+// from the top.
+func dataMatrixModules(data string) ([][]bool, error) {
+	codewords, s, err := dataMatrixCodewords(data)
+	if err != nil {
+		return nil, err
+	}
+
+	regionSize := s.size/s.regions - 2
+	n := s.regions * regionSize
+	placement := dataMatrixPlacement(n, n)
+
+	modules := make([][]bool, s.size)
+	for i := range modules {
+		modules[i] = make([]bool, s.size)
+	}
+	// The finder pattern of each region is solid on the left and
+	// bottom and alternates on the top and right.
+	for row := 0; row < s.size; row++ {
+		for col := 0; col < s.size; col++ {
+			r, c := row%(regionSize+2), col%(regionSize+2)
+			switch {
+			case c == 0 || r == regionSize+1:
+				modules[row][col] = true
+			case r == 0:
+				modules[row][col] = c%2 == 0
+			case c == regionSize+1:
+				modules[row][col] = r%2 == 1
+			}
+		}
+	}
+	for row := 0; row < n; row++ {
+		for col := 0; col < n; col++ {
+			var dark bool
+			switch v := placement[row*n+col]; v {
+			case dataMatrixFixedDark:
+				dark = true
+			case dataMatrixFixedLight:
+			default:
+				cw, bit := v/8-1, v%8
+				dark = codewords[cw]>>(7-bit)&1 != 0
+			}
+			symRow := row/regionSize*(regionSize+2) + 1 + row%regionSize
+			symCol := col/regionSize*(regionSize+2) + 1 + col%regionSize
+			modules[symRow][symCol] = dark
+		}
+	}
+	return modules, nil
+}
+
+const (
+	dataMatrixFixedDark  = 1
+	dataMatrixFixedLight = 2
+)
+
+// dataMatrixPlacement returns, for each module of the nrow by ncol
+// mapping matrix, 8*(codeword+1)+bit with bit 0 the most significant,
+// or a fixed module value, following ISO/IEC 16022 Annex F.
+func dataMatrixPlacement(nrow, ncol int) []int {
+	array := make([]int, nrow*ncol)
+
+	module := func(row, col, chr, bit int) {
+		if row < 0 {
+			row += nrow
+			col += 4 - (nrow+4)%8
+		}
+		if col < 0 {
+			col += ncol
+			row += 4 - (ncol+4)%8
+		}
+		array[row*ncol+col] = 8*chr + bit
+	}
+	// place places the bits of the codeword at the (row, col) pairs.
+	place := func(chr int, pos ...int) {
+		for bit := 0; bit < 8; bit++ {
+			module(pos[2*bit], pos[2*bit+1], chr, bit)
+		}
+	}
+	utah := func(row, col, chr int) {
+		place(chr,
+			row-2, col-2, row-2, col-1,
+			row-1, col-2, row-1, col-1, row-1, col,
+			row, col-2, row, col-1, row, col)
+	}
+
+	chr, row, col := 1, 4, 0
+	for row < nrow || col < ncol {
+		if row == nrow && col == 0 {
+			place(chr, nrow-1, 0, nrow-1, 1, nrow-1, 2, 0, ncol-2, 0, ncol-1, 1, ncol-1, 2, ncol-1, 3, ncol-1)
+			chr++
+		}
+		if row == nrow-2 && col == 0 && ncol%4 != 0 {
+			place(chr, nrow-3, 0, nrow-2, 0, nrow-1, 0, 0, ncol-4, 0, ncol-3, 0, ncol-2, 0, ncol-1, 1, ncol-1)
+			chr++
+		}
+		if row == nrow-2 && col == 0 && ncol%8 == 4 {
+			place(chr, nrow-3, 0, nrow-2, 0, nrow-1, 0, 0, ncol-2, 0, ncol-1, 1, ncol-1, 2, ncol-1, 3, ncol-1)
+			chr++
+		}
+		if row == nrow+4 && col == 2 && ncol%8 == 0 {
+			place(chr, nrow-1, 0, nrow-1, ncol-1, 0, ncol-3, 0, ncol-2, 0, ncol-1, 1, ncol-3, 1, ncol-2, 1, ncol-1)
+			chr++
+		}
+
+		// Sweep up and to the right, then down and to the left.
+		for {
+			if row < nrow && col >= 0 && array[row*ncol+col] == 0 {
+				utah(row, col, chr)
+				chr++
+			}
+			row, col = row-2, col+2
+			if row < 0 || col >= ncol {
+				break
+			}
+		}
+		row, col = row+1, col+3
+		for {
+			if row >= 0 && col < ncol && array[row*ncol+col] == 0 {
+				utah(row, col, chr)
+				chr++
+			}
+			row, col = row+2, col-2
+			if row >= nrow || col < 0 {
+				break
+			}
+		}
+		row, col = row+3, col+1
+	}
+
+	// Sizes that leave the bottom right corner unfilled get
+	// a fixed pattern.
+	if last := nrow*ncol - 1; array[last] == 0 {
+		array[last] = dataMatrixFixedDark
+		array[last-ncol-1] = dataMatrixFixedDark
+		array[last-1] = dataMatrixFixedLight
+		array[last-ncol] = dataMatrixFixedLight
+	}
+	return array
+}
@@ -0,0 +1,118 @@
+package gerber
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDataMatrixEncode(t *testing.T) {
+	tests := []struct {
+		data string
+		want []byte
+	}{
+		{data: "123456", want: []byte{142, 164, 186}},
+		{data: "A1B", want: []byte{66, 50, 67}},
+		{data: "SN01234", want: []byte{84, 79, 131, 153, 53}},
+		{data: "\xe9", want: []byte{235, 106}},
+	}
+	for _, tt := range tests {
+		if got := dataMatrixEncode(tt.data); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("dataMatrixEncode(%q) = %v, want %v", tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestDataMatrixCodewords(t *testing.T) {
+	tests := []struct {
+		data string
+		size int
+		want []byte
+	}{
+		// The example of ISO/IEC 16022 Annex O.
+		{data: "123456", size: 10, want: []byte{142, 164, 186, 114, 25, 5, 88, 102}},
+		{data: "A", size: 10, want: []byte{66, 129, 70}},
+		{data: "ABCD", size: 12, want: []byte{66, 67, 68, 69, 129}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			got, s, err := dataMatrixCodewords(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.size != tt.size {
+				t.Errorf("size = %v, want %v", s.size, tt.size)
+			}
+			if got := got[:len(tt.want)]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("codewords = %v, want %v", got, tt.want)
+			}
+			if len(got) != s.data+s.ecc {
+				t.Errorf("got %v codewords, want %v", len(got), s.data+s.ecc)
+			}
+		})
+	}
+
+	if _, _, err := dataMatrixCodewords(""); err == nil {
+		t.Errorf("empty data error = nil")
+	}
+	if _, _, err := dataMatrixCodewords(strings.Repeat("x", 1559)); err == nil {
+		t.Errorf("long data error = nil")
+	}
+}
+
+func TestDataMatrixPlacement(t *testing.T) {
+	for _, s := range dataMatrixSizes {
+		n := s.regions * (s.size/s.regions - 2)
+		placement := dataMatrixPlacement(n, n)
+		// Every bit of every codeword is placed exactly once.
+		seen := map[int]bool{}
+		for i, v := range placement {
+			switch {
+			case v == dataMatrixFixedDark || v == dataMatrixFixedLight:
+				continue
+			case v < 8 || v >= 8*(s.data+s.ecc+1):
+				t.Fatalf("size %v: module %v = %v", s.size, i, v)
+			case seen[v]:
+				t.Fatalf("size %v: codeword %v bit %v is placed twice", s.size, v/8-1, v%8)
+			}
+			seen[v] = true
+		}
+		if len(seen) != 8*(s.data+s.ecc) {
+			t.Errorf("size %v: placed %v bits, want %v", s.size, len(seen), 8*(s.data+s.ecc))
+		}
+	}
+}
+
+func TestDataMatrix(t *testing.T) {
+	modules, err := dataMatrixModules("123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The finder pattern of the 10x10 symbol.
+	for i := 0; i < 10; i++ {
+		if !modules[i][0] || !modules[9][i] {
+			t.Fatalf("solid finder pattern is broken at %v", i)
+		}
+		if modules[0][i] != (i%2 == 0) || modules[i][9] != (i%2 == 1) {
+			t.Fatalf("alternating finder pattern is broken at %v", i)
+		}
+	}
+
+	// The 2x2 regions of the 32x32 symbol each have a finder pattern.
+	b, err := DataMatrix(0, 0, strings.Repeat("SERIAL", 8), 0.5, &BarcodeOpts{YAlign: YTop})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Modules) != 32 {
+		t.Fatalf("got %v rows, want 32", len(b.Modules))
+	}
+	for i := 0; i < 32; i++ {
+		if !b.Modules[i][16] || !b.Modules[15][i] || b.Modules[i][15] != (i%16%2 == 1) {
+			t.Fatalf("inner finder pattern is broken at %v", i)
+		}
+	}
+	// 32 modules with a quiet zone of 1 on each side.
+	if got, want := b.MBB(), (MBB{Min: Pt{0, -17}, Max: Pt{17, 0}}); !mbbClose(got, want) {
+		t.Errorf("MBB = %v, want %v", got, want)
+	}
+}
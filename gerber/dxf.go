@@ -0,0 +1,496 @@
+package gerber
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// dxfColors are the AutoCAD color indexes of the layer types.
+var dxfColors = map[LayerType]int{
+	LayerTopCopper:        1, // red
+	LayerTopSolderMask:    3, // green
+	LayerTopSilkscreen:    7, // white
+	LayerBottomCopper:     5, // blue
+	LayerBottomSolderMask: 4, // cyan
+	LayerBottomSilkscreen: 6, // magenta
+	LayerInnerCopper:      30,
+	LayerDrill:            9,
+	LayerOutline:          2, // yellow
+	LayerNonPlatedDrill:   8,
+}
+
+// dxfClearColor is the color of the layers of clear primitives.
+const dxfClearColor = 8
+
+// WriteDXF writes the layer as an AutoCAD 2000 DXF drawing in
+// millimeters to w. See (*Gerber).WriteDXF.
+func (l *Layer) WriteDXF(w io.Writer) error {
+	return writeDXF(w, []*Layer{l})
+}
+
+// WriteDXF writes the design as an AutoCAD 2000 DXF drawing in
+// millimeters to w, with a DXF layer named after the filename of
+// each layer.
+//
+// Lines and arcs are written as LINE and ARC entities along their
+// center lines, circles and circular flashes as CIRCLE entities, and
+// the other flashes and the outlines of polygons as closed LWPOLYLINE
+// entities. Filled polygons, regions, text, images and barcodes are
+// written as solid HATCH entities. DXF has no clear polarity, so clear
+// primitives are written to a separate layer with a "-clear" suffix.
+// Panels are not drawn.
+func (g *Gerber) WriteDXF(w io.Writer) error {
+	return writeDXF(w, g.Layers)
+}
+
+// writeDXF writes the layers to a DXF drawing.
+func writeDXF(w io.Writer, layers []*Layer) error {
+	d := &dxfWriter{}
+	type dxfLayer struct {
+		name  string
+		color int
+	}
+	var tableLayers []dxfLayer
+	seen := map[string]bool{}
+	for _, l := range layers {
+		d.layer = dxfLayerName(l.Filename)
+		d.clearUsed = false
+		if err := d.writePrimitives(l.Primitives, true, flashTransform{}); err != nil {
+			return fmt.Errorf("%v: %v", l.Filename, err)
+		}
+		if !seen[d.layer] {
+			seen[d.layer] = true
+			tableLayers = append(tableLayers, dxfLayer{name: d.layer, color: dxfColors[l.Type]})
+		}
+		if d.clearUsed && !seen[d.clearLayer()] {
+			seen[d.clearLayer()] = true
+			tableLayers = append(tableLayers, dxfLayer{name: d.clearLayer(), color: dxfClearColor})
+		}
+	}
+
+	var tables bytes.Buffer
+	dxfGroup(&tables, 0, "SECTION")
+	dxfGroup(&tables, 2, "TABLES")
+	dxfGroup(&tables, 0, "TABLE")
+	dxfGroup(&tables, 2, "LTYPE")
+	d.handleGroup(&tables)
+	dxfGroup(&tables, 100, "AcDbSymbolTable")
+	dxfGroup(&tables, 70, 1)
+	dxfGroup(&tables, 0, "LTYPE")
+	d.handleGroup(&tables)
+	dxfGroup(&tables, 100, "AcDbSymbolTableRecord")
+	dxfGroup(&tables, 100, "AcDbLinetypeTableRecord")
+	dxfGroup(&tables, 2, "CONTINUOUS")
+	dxfGroup(&tables, 70, 0)
+	dxfGroup(&tables, 3, "Solid line")
+	dxfGroup(&tables, 72, 65)
+	dxfGroup(&tables, 73, 0)
+	dxfGroup(&tables, 40, "0")
+	dxfGroup(&tables, 0, "ENDTAB")
+	dxfGroup(&tables, 0, "TABLE")
+	dxfGroup(&tables, 2, "LAYER")
+	d.handleGroup(&tables)
+	dxfGroup(&tables, 100, "AcDbSymbolTable")
+	dxfGroup(&tables, 70, len(tableLayers))
+	for _, tl := range tableLayers {
+		dxfGroup(&tables, 0, "LAYER")
+		d.handleGroup(&tables)
+		dxfGroup(&tables, 100, "AcDbSymbolTableRecord")
+		dxfGroup(&tables, 100, "AcDbLayerTableRecord")
+		dxfGroup(&tables, 2, tl.name)
+		dxfGroup(&tables, 70, 0)
+		dxfGroup(&tables, 62, tl.color)
+		dxfGroup(&tables, 6, "CONTINUOUS")
+	}
+	dxfGroup(&tables, 0, "ENDTAB")
+	dxfGroup(&tables, 0, "ENDSEC")
+
+	var objects bytes.Buffer
+	dxfGroup(&objects, 0, "SECTION")
+	dxfGroup(&objects, 2, "OBJECTS")
+	dxfGroup(&objects, 0, "DICTIONARY")
+	d.handleGroup(&objects)
+	dxfGroup(&objects, 100, "AcDbDictionary")
+	dxfGroup(&objects, 0, "ENDSEC")
+
+	// The handle seed follows the last handle used.
+	var header bytes.Buffer
+	dxfGroup(&header, 0, "SECTION")
+	dxfGroup(&header, 2, "HEADER")
+	dxfGroup(&header, 9, "$ACADVER")
+	dxfGroup(&header, 1, "AC1015")
+	dxfGroup(&header, 9, "$INSUNITS")
+	dxfGroup(&header, 70, 4) // millimeters
+	dxfGroup(&header, 9, "$MEASUREMENT")
+	dxfGroup(&header, 70, 1) // metric
+	dxfGroup(&header, 9, "$HANDSEED")
+	dxfGroup(&header, 5, strconv.FormatInt(int64(d.handle+1), 16))
+	dxfGroup(&header, 0, "ENDSEC")
+
+	w.Write(header.Bytes())
+	w.Write(tables.Bytes())
+	dxfGroup(w, 0, "SECTION")
+	dxfGroup(w, 2, "ENTITIES")
+	w.Write(d.entities.Bytes())
+	dxfGroup(w, 0, "ENDSEC")
+	w.Write(objects.Bytes())
+	_, err := io.WriteString(w, "  0\nEOF\n")
+	return err
+}
+
+// dxfLayerName returns a valid DXF layer name for a layer filename.
+func dxfLayerName(filename string) string {
+	if filename == "" {
+		return "0"
+	}
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>/\":;?*|=,'`+"`", r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, filename)
+}
+
+// dxfGroup writes a group code and its value.
+func dxfGroup(w io.Writer, code int, value interface{}) {
+	fmt.Fprintf(w, "%3d\n%v\n", code, value)
+}
+
+// dxfNum formats a dimension in millimeters (or an angle in degrees),
+// rounded to the nanometer.
+func dxfNum(v float64) string {
+	v = math.Round(v*1e6) / 1e6
+	if v == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// dxfWriter holds the state of a DXF drawing being written.
+type dxfWriter struct {
+	entities  bytes.Buffer
+	handle    int
+	layer     string // DXF layer of the current layer
+	clearUsed bool   // the current layer has clear primitives
+}
+
+// clearLayer returns the DXF layer of the clear primitives.
+func (d *dxfWriter) clearLayer() string {
+	return d.layer + "-clear"
+}
+
+// handleGroup writes the next handle.
+func (d *dxfWriter) handleGroup(w io.Writer) {
+	d.handle++
+	dxfGroup(w, 5, strconv.FormatInt(int64(d.handle), 16))
+}
+
+// entity starts an entity with its subclass on the layer of
+// the polarity.
+func (d *dxfWriter) entity(kind, subclass string, dark bool) {
+	layer := d.layer
+	if !dark {
+		layer = d.clearLayer()
+		d.clearUsed = true
+	}
+	dxfGroup(&d.entities, 0, kind)
+	d.handleGroup(&d.entities)
+	dxfGroup(&d.entities, 100, "AcDbEntity")
+	dxfGroup(&d.entities, 8, layer)
+	dxfGroup(&d.entities, 100, subclass)
+}
+
+// point writes the coordinates of a point with the group code of X.
+func (d *dxfWriter) point(code int, pt Pt) {
+	dxfGroup(&d.entities, code, dxfNum(pt[0]))
+	dxfGroup(&d.entities, code+10, dxfNum(pt[1]))
+	dxfGroup(&d.entities, code+20, "0")
+}
+
+// dxfVertex is a vertex of a polyline or of a hatch boundary. The bulge
+// is the tangent of a quarter of the angle swept (counterclockwise
+// positive) by the segment to the next vertex, zero for a line.
+type dxfVertex struct {
+	pt    Pt
+	bulge float64
+}
+
+// dxfContour returns the vertices of the closed contour. Arcs of more
+// than 180 degrees are split in two.
+func dxfContour(c Contour) []dxfVertex {
+	var result []dxfVertex
+	prev := c.Start
+	for _, s := range c.ring() {
+		if !s.Arc {
+			result = append(result, dxfVertex{pt: prev})
+			prev = s.End
+			continue
+		}
+		sweep := s.sweep(prev)
+		if math.Abs(sweep) > math.Pi {
+			r := s.radius(prev)
+			a := s.angle(prev) + 0.5*sweep
+			sweep *= 0.5
+			result = append(result, dxfVertex{pt: prev, bulge: math.Tan(sweep / 4)})
+			prev = Pt{s.Center[0] + r*math.Cos(a), s.Center[1] + r*math.Sin(a)}
+		}
+		result = append(result, dxfVertex{pt: prev, bulge: math.Tan(sweep / 4)})
+		prev = s.End
+	}
+	return result
+}
+
+// dxfPoints returns the vertices of straight segments through the points.
+func dxfPoints(pts []Pt) []dxfVertex {
+	result := make([]dxfVertex, len(pts))
+	for i, pt := range pts {
+		result[i] = dxfVertex{pt: pt}
+	}
+	return result
+}
+
+// transform returns the vertices in design coordinates.
+func (t flashTransform) transform(vertices []dxfVertex) []dxfVertex {
+	result := make([]dxfVertex, len(vertices))
+	for i, v := range vertices {
+		result[i] = dxfVertex{pt: t.apply(v.pt), bulge: v.bulge}
+		if t.mirrored() {
+			result[i].bulge = -v.bulge
+		}
+	}
+	return result
+}
+
+// writePrimitives writes the primitives as entities, transformed by t.
+func (d *dxfWriter) writePrimitives(primitives []Primitive, dark bool, t flashTransform) error {
+	for _, p := range primitives {
+		if err := d.writePrimitive(p, dark, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *dxfWriter) writePrimitive(p Primitive, dark bool, t flashTransform) error {
+	switch v := p.(type) {
+	case *ClearT:
+		return d.writePrimitives(v.Primitives, !dark, t)
+	case *KnockoutT:
+		if err := d.writePrimitive(v.Box(), dark, t); err != nil {
+			return err
+		}
+		return d.writePrimitives(v.Primitives, !dark, t)
+	case *LineT:
+		d.entity("LINE", "AcDbLine", dark)
+		d.point(10, t.apply(v.P1))
+		d.point(11, t.apply(v.P2))
+	case *ArcT:
+		d.arc(v, dark, t)
+	case *CircleT:
+		d.circle(t.apply(v.pt), 0.5*v.thickness*math.Abs(t.scale()), dark)
+	case *FlashT:
+		return d.flash(v, dark, t)
+	case *PolygonT:
+		if !v.Filled && v.Thickness <= 0 {
+			return fmt.Errorf("polygon is neither filled nor stroked")
+		}
+		if len(v.Points) == 0 {
+			break
+		}
+		pts := make([]Pt, len(v.Points))
+		for i, pt := range v.Points {
+			pts[i] = Pt{pt[0] + v.Offset[0], pt[1] + v.Offset[1]}
+		}
+		vertices := t.transform(dxfPoints(pts))
+		if v.Filled {
+			d.hatch(dark, vertices)
+		}
+		if v.Thickness > 0 {
+			d.polyline(vertices, true, dark)
+		}
+	case *RegionT:
+		d.region(v, dark, t)
+	case *TextT:
+		if err := v.renderText(); err != nil {
+			return err
+		}
+		// The glyph counters are nested within the glyphs.
+		var loops [][]dxfVertex
+		for _, poly := range v.Render.Polygons {
+			loops = append(loops, t.transform(dxfPoints(poly.Pts)))
+		}
+		d.hatch(dark, loops...)
+	case *ImageT:
+		for _, r := range v.Regions {
+			d.region(r, dark, t)
+		}
+	case *BarcodeT:
+		if v.Inverted {
+			d.region(v.Box(), dark, t)
+		}
+		for _, r := range v.Regions {
+			d.region(r, dark != v.Inverted, t)
+		}
+	default:
+		return fmt.Errorf("unsupported primitive %T in DXF output", v)
+	}
+	return nil
+}
+
+// arc writes a circular arc as an ARC (or CIRCLE) entity and an
+// elliptical arc as a polyline.
+func (d *dxfWriter) arc(a *ArcT, dark bool, t flashTransform) {
+	delta := a.EndAngle - a.StartAngle
+	if a.XScale != a.YScale {
+		// Resolution of segments is 0.1mm
+		segments := int(0.5+delta*a.Radius*math.Max(a.XScale, a.YScale)*10.0) + 1
+		var pts []Pt
+		for i := 0; i <= segments; i++ {
+			pts = append(pts, a.point(a.StartAngle+delta*float64(i)/float64(segments)))
+		}
+		d.polyline(t.transform(dxfPoints(pts)), false, dark)
+		return
+	}
+
+	center := t.apply(a.Center)
+	r := a.Radius * a.XScale * math.Abs(t.scale())
+	if delta >= 2*math.Pi-1e-9 {
+		d.circle(center, r, dark)
+		return
+	}
+	start, end := t.apply(a.point(a.StartAngle)), t.apply(a.point(a.EndAngle))
+	if t.mirrored() {
+		start, end = end, start
+	}
+	angle := func(pt Pt) string {
+		return dxfNum(180 / math.Pi * math.Atan2(pt[1]-center[1], pt[0]-center[0]))
+	}
+	d.entity("ARC", "AcDbCircle", dark)
+	d.point(10, center)
+	dxfGroup(&d.entities, 40, dxfNum(r))
+	dxfGroup(&d.entities, 100, "AcDbArc")
+	dxfGroup(&d.entities, 50, angle(start))
+	dxfGroup(&d.entities, 51, angle(end))
+}
+
+// circle writes a CIRCLE entity.
+func (d *dxfWriter) circle(center Pt, r float64, dark bool) {
+	d.entity("CIRCLE", "AcDbCircle", dark)
+	d.point(10, center)
+	dxfGroup(&d.entities, 40, dxfNum(r))
+}
+
+// polyline writes an LWPOLYLINE entity.
+func (d *dxfWriter) polyline(vertices []dxfVertex, closed bool, dark bool) {
+	d.entity("LWPOLYLINE", "AcDbPolyline", dark)
+	dxfGroup(&d.entities, 90, len(vertices))
+	flags := 0
+	if closed {
+		flags = 1
+	}
+	dxfGroup(&d.entities, 70, flags)
+	for _, v := range vertices {
+		dxfGroup(&d.entities, 10, dxfNum(v.pt[0]))
+		dxfGroup(&d.entities, 20, dxfNum(v.pt[1]))
+		if b := dxfNum(v.bulge); b != "0" {
+			dxfGroup(&d.entities, 42, b)
+		}
+	}
+}
+
+// region writes a region as a hatch of its contours.
+func (d *dxfWriter) region(r *RegionT, dark bool, t flashTransform) {
+	loops := [][]dxfVertex{t.transform(dxfContour(r.Outer))}
+	for _, h := range r.Holes {
+		loops = append(loops, t.transform(dxfContour(h)))
+	}
+	d.hatch(dark, loops...)
+}
+
+// hatch writes a solid HATCH entity bounded by the closed loops.
+// Nested loops alternate between filled and empty.
+func (d *dxfWriter) hatch(dark bool, loops ...[]dxfVertex) {
+	d.entity("HATCH", "AcDbHatch", dark)
+	d.point(10, Pt{}) // elevation
+	dxfGroup(&d.entities, 210, "0")
+	dxfGroup(&d.entities, 220, "0")
+	dxfGroup(&d.entities, 230, "1")
+	dxfGroup(&d.entities, 2, "SOLID")
+	dxfGroup(&d.entities, 70, 1) // solid fill
+	dxfGroup(&d.entities, 71, 0) // not associative
+	dxfGroup(&d.entities, 91, len(loops))
+	for _, loop := range loops {
+		hasBulge := 0
+		for _, v := range loop {
+			if dxfNum(v.bulge) != "0" {
+				hasBulge = 1
+			}
+		}
+		dxfGroup(&d.entities, 92, 2) // polyline
+		dxfGroup(&d.entities, 72, hasBulge)
+		dxfGroup(&d.entities, 73, 1) // closed
+		dxfGroup(&d.entities, 93, len(loop))
+		for _, v := range loop {
+			dxfGroup(&d.entities, 10, dxfNum(v.pt[0]))
+			dxfGroup(&d.entities, 20, dxfNum(v.pt[1]))
+			if hasBulge != 0 {
+				dxfGroup(&d.entities, 42, dxfNum(v.bulge))
+			}
+		}
+		dxfGroup(&d.entities, 97, 0) // no source objects
+	}
+	dxfGroup(&d.entities, 75, 0) // odd parity
+	dxfGroup(&d.entities, 76, 1) // predefined pattern
+	dxfGroup(&d.entities, 98, 0) // no seed points
+}
+
+// flash writes the outline of a flashed aperture, or the primitives
+// of a block aperture. Macro apertures are approximated by their
+// bounding boxes.
+func (d *dxfWriter) flash(f *FlashT, dark bool, t flashTransform) error {
+	a := f.aperture
+	if a == nil {
+		return fmt.Errorf("flash has no aperture")
+	}
+	t = t.with(f)
+	if a.Block != nil {
+		return d.writePrimitives(a.Block.Primitives, dark, t)
+	}
+
+	rect := func(lo, hi Pt) []dxfVertex {
+		return dxfPoints([]Pt{lo, {hi[0], lo[1]}, hi, {lo[0], hi[1]}})
+	}
+	var outline []dxfVertex
+	switch {
+	case a.Macro != nil:
+		mbb := a.MBB()
+		outline = rect(mbb.Min, mbb.Max)
+	case a.Shape == RectShape:
+		hw, hh := 0.5*a.Size, 0.5*a.ySize()
+		outline = rect(Pt{-hw, -hh}, Pt{hw, hh})
+	case a.Shape == ObroundShape:
+		hw, hh := 0.5*a.Size, 0.5*a.ySize()
+		if hw >= hh {
+			outline = []dxfVertex{{Pt{-hw + hh, -hh}, 0}, {Pt{hw - hh, -hh}, 1}, {Pt{hw - hh, hh}, 0}, {Pt{-hw + hh, hh}, 1}}
+		} else {
+			outline = []dxfVertex{{Pt{hw, -hh + hw}, 0}, {Pt{hw, hh - hw}, 1}, {Pt{-hw, hh - hw}, 0}, {Pt{-hw, -hh + hw}, 1}}
+		}
+	case a.Shape == PolygonShape:
+		for i := 0; i < a.Vertices; i++ {
+			outline = append(outline, dxfVertex{pt: rotate(Pt{0.5 * a.Size, 0}, a.Rotation+360*float64(i)/float64(a.Vertices))})
+		}
+	default:
+		d.circle(t.apply(Pt{}), 0.5*a.Size*math.Abs(t.scale()), dark)
+	}
+	if outline != nil {
+		d.polyline(t.transform(outline), true, dark)
+	}
+	if a.Hole > 0 {
+		d.circle(t.apply(Pt{}), 0.5*a.Hole*math.Abs(t.scale()), dark)
+	}
+	return nil
+}
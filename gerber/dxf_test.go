@@ -0,0 +1,173 @@
+package gerber
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// dxfEntities returns the entities of a DXF drawing and checks
+// that their handles are unique and below the handle seed.
+func dxfEntities(t *testing.T, dxf string) []*dxfEntity {
+	t.Helper()
+	pairs, err := readDXF(strings.NewReader(dxf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seed int64
+	handles := map[string]bool{}
+	var entities []*dxfEntity
+	var section string
+	for i, pair := range pairs {
+		switch {
+		case pair.code == 2 && i > 0 && pairs[i-1].value == "SECTION":
+			section = pair.value
+		case pair.code == 5 && pairs[i-1].value == "$HANDSEED":
+			seed, _ = strconv.ParseInt(pair.value, 16, 64)
+		case pair.code == 5:
+			if handles[pair.value] {
+				t.Errorf("handle %v is used twice", pair.value)
+			}
+			handles[pair.value] = true
+			if h, _ := strconv.ParseInt(pair.value, 16, 64); h >= seed {
+				t.Errorf("handle %v is not below the handle seed %x", pair.value, seed)
+			}
+		}
+		if section != "ENTITIES" {
+			continue
+		}
+		if pair.code == 0 {
+			if pair.value != "ENDSEC" {
+				entities = append(entities, &dxfEntity{kind: pair.value})
+			}
+			continue
+		}
+		if len(entities) > 0 {
+			e := entities[len(entities)-1]
+			e.pairs = append(e.pairs, pair)
+		}
+	}
+	return entities
+}
+
+func TestLayer_WriteDXF(t *testing.T) {
+	g := New("test")
+	top := g.TopCopper()
+	pad := Flash(Pt{5, 5}, RectAperture(1, 2))
+	pad.Rotation = 90
+	top.Add(
+		Line(0, 0, 10, 0, CircleShape, 0.25),
+		Arc(Pt{5, 5}, 2, CircleShape, 1, 1, 0, 90, 0.25),
+		Arc(Pt{5, 5}, 3, CircleShape, 1, 1, 0, 360, 0.25),
+		Circle(Pt{1, 1}, 0.5),
+		pad,
+		Flash(Pt{8, 8}, &Aperture{Shape: ObroundShape, Size: 2, YSize: 1, Hole: 0.5}),
+		Polygon(Pt{1, 1}, true, []Pt{{0, 0}, {2, 0}, {2, 2}}, 0),
+		Clear(Circle(Pt{3, 3}, 1)),
+		Region(Contour{Start: Pt{12, 0}, Segments: []Segment{LineTo(Pt{14, 0}), ArcTo(Pt{14, 2}, Pt{14, 1}, false)}}),
+	)
+
+	var buf bytes.Buffer
+	if err := top.WriteDXF(&buf); err != nil {
+		t.Fatal(err)
+	}
+	dxf := buf.String()
+	entities := dxfEntities(t, dxf)
+
+	counts := map[string]int{}
+	layers := map[string]int{}
+	for _, e := range entities {
+		counts[e.kind]++
+		layers[e.layer()]++
+	}
+	wantCounts := map[string]int{"LINE": 1, "ARC": 1, "CIRCLE": 4, "LWPOLYLINE": 2, "HATCH": 2}
+	for kind, want := range wantCounts {
+		if counts[kind] != want {
+			t.Errorf("got %v %v entities, want %v", counts[kind], kind, want)
+		}
+	}
+	if layers["test.gtl"] != 9 || layers["test.gtl-clear"] != 1 {
+		t.Errorf("entities by layer = %v, want 9 on test.gtl and 1 on test.gtl-clear", layers)
+	}
+
+	for _, want := range []string{
+		"  9\n$ACADVER\n  1\nAC1015\n",
+		"  9\n$INSUNITS\n 70\n4\n",
+		"  2\ntest.gtl\n 70\n0\n 62\n1\n",
+		"  2\ntest.gtl-clear\n 70\n0\n 62\n8\n",
+		"AcDbCircle\n 10\n5\n 20\n5\n 30\n0\n 40\n2\n100\nAcDbArc\n 50\n0\n 51\n90\n",
+		// The rotated pad.
+		" 10\n6\n 20\n4.5\n 10\n6\n 20\n5.5\n 10\n4\n 20\n5.5\n 10\n4\n 20\n4.5\n",
+		// The obround with its semicircles.
+		" 10\n7.5\n 20\n7.5\n 10\n8.5\n 20\n7.5\n 42\n1\n",
+		// The region with its arc.
+		" 92\n2\n 72\n1\n 73\n1\n 93\n3\n 10\n12\n 20\n0\n 42\n0\n 10\n14\n 20\n0\n 42\n1\n",
+	} {
+		if !strings.Contains(dxf, want) {
+			t.Errorf("missing %q in DXF:\n%v", want, dxf)
+		}
+	}
+	if !strings.HasSuffix(dxf, "  0\nEOF\n") {
+		t.Errorf("DXF does not end with EOF")
+	}
+}
+
+func TestGerber_WriteDXF_RoundTrip(t *testing.T) {
+	g := New("outline")
+	outline := g.Outline()
+	block := BlockAperture("corner", Arc(Pt{1, 0}, 1, CircleShape, 1, 1, 0, 90, 0.1))
+	corner := Flash(Pt{10, 10}, block)
+	corner.Mirror = MirrorX
+	outline.Add(
+		Line(0, 0, 20, 0, CircleShape, 0.1),
+		Arc(Pt{20, 5}, 5, CircleShape, 1, 1, -90, 90, 0.1),
+		Line(20, 10, 0, 10, CircleShape, 0.1),
+		Arc(Pt{0, 5}, 5, CircleShape, 1, 1, 90, 270, 0.1),
+		corner,
+	)
+	g.TopSilkscreen().Add(Circle(Pt{5, 5}, 1))
+
+	var buf bytes.Buffer
+	if err := g.WriteDXF(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ParseDXF(&buf, &DXFImportOptions{Layers: []string{"outline.gko"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(outline.Primitives) {
+		t.Fatalf("got %v primitives, want %v", len(got), len(outline.Primitives))
+	}
+	for i, p := range outline.Primitives {
+		if !mbbClose(got[i].MBB(), p.MBB()) {
+			t.Errorf("primitive %v: MBB = %v, want %v", i, got[i].MBB(), p.MBB())
+		}
+	}
+	// The mirrored block arc goes from 90 to 180 degrees.
+	if arc, ok := got[4].(*ArcT); !ok || math.Abs(arc.StartAngle-0.5*math.Pi) > 1e-6 || math.Abs(arc.EndAngle-math.Pi) > 1e-6 {
+		t.Errorf("mirrored arc = %#v", got[4])
+	}
+}
+
+func TestDXFContour(t *testing.T) {
+	got := dxfContour(CircleContour(Pt{1, 1}, 2))
+	want := []dxfVertex{{pt: Pt{3, 1}, bulge: 1}, {pt: Pt{-1, 1}, bulge: 1}}
+	if len(got) != len(want) {
+		t.Fatalf("got %v vertices, want %v", len(got), len(want))
+	}
+	for i := range want {
+		if math.Hypot(got[i].pt[0]-want[i].pt[0], got[i].pt[1]-want[i].pt[1]) > 1e-9 || math.Abs(got[i].bulge-want[i].bulge) > 1e-9 {
+			t.Errorf("vertex %v = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestWriteDXF_Unsupported(t *testing.T) {
+	layer := New("bad").TopCopper()
+	layer.Add(Polygon(Pt{}, false, []Pt{{0, 0}, {1, 0}, {1, 1}}, 0))
+	if err := layer.WriteDXF(&bytes.Buffer{}); err == nil {
+		t.Errorf("WriteDXF = nil, want error")
+	}
+}
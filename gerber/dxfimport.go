@@ -0,0 +1,399 @@
+package gerber
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DXFImportOptions represents the options of the DXF importer.
+type DXFImportOptions struct {
+	// Layers selects the DXF layers to read, ignoring case.
+	// If empty, all the layers are read.
+	Layers []string
+	// Offset moves the drawing, in millimeters.
+	Offset Pt
+	// Thickness is the width in millimeters of the lines and arcs.
+	// Zero means 0.1mm.
+	Thickness float64
+}
+
+// dxfUnits are the millimeters per drawing unit by $INSUNITS value.
+var dxfUnits = map[int]float64{
+	0:  1, // unitless, assumed to be millimeters
+	1:  25.4,
+	2:  304.8,
+	4:  1,
+	5:  10,
+	6:  1000,
+	8:  25.4e-6,
+	9:  0.0254,
+	13: 0.001,
+	14: 100,
+}
+
+// ImportDXF reads the lines and arcs of a DXF drawing from a file.
+// See ParseDXF.
+func ImportDXF(filename string, opts *DXFImportOptions) ([]Primitive, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	primitives, err := ParseDXF(f, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", filename, err)
+	}
+	return primitives, nil
+}
+
+// ParseDXF reads the LINE, ARC, CIRCLE, LWPOLYLINE and POLYLINE
+// entities of an ASCII DXF drawing and returns them as lines and arcs
+// drawn with a circle aperture, ready to be added to an Outline layer.
+// Polyline bulges become arcs.
+//
+// The coordinates are converted to millimeters from the units of the
+// drawing ($INSUNITS, millimeters if unset). Other entities, such as
+// splines, text and block references (INSERT), are ignored.
+// opts may be nil.
+func ParseDXF(r io.Reader, opts *DXFImportOptions) ([]Primitive, error) {
+	if opts == nil {
+		opts = &DXFImportOptions{}
+	}
+	pairs, err := readDXF(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &dxfParser{opts: opts, unit: 1, thickness: opts.Thickness}
+	if p.thickness <= 0 {
+		p.thickness = 0.1
+	}
+	var section string
+	var entities []*dxfEntity
+	for i := 0; i < len(pairs); i++ {
+		pair := pairs[i]
+		switch {
+		case pair.code == 0 && pair.value == "SECTION":
+			if i+1 < len(pairs) && pairs[i+1].code == 2 {
+				section = pairs[i+1].value
+				i++
+			}
+		case pair.code == 0 && pair.value == "ENDSEC":
+			section = ""
+		case section == "HEADER" && pair.code == 9 && pair.value == "$INSUNITS":
+			if i+1 < len(pairs) {
+				n, err := strconv.Atoi(pairs[i+1].value)
+				if err != nil {
+					return nil, fmt.Errorf("invalid $INSUNITS %q", pairs[i+1].value)
+				}
+				unit, ok := dxfUnits[n]
+				if !ok {
+					return nil, fmt.Errorf("unsupported $INSUNITS %v", n)
+				}
+				p.unit = unit
+				i++
+			}
+		case section == "ENTITIES" && pair.code == 0:
+			entities = append(entities, &dxfEntity{kind: pair.value})
+		case section == "ENTITIES" && len(entities) > 0:
+			e := entities[len(entities)-1]
+			e.pairs = append(e.pairs, pair)
+		}
+	}
+
+	for i := 0; i < len(entities); i++ {
+		e := entities[i]
+		if e.kind == "POLYLINE" {
+			// The vertices follow as entities up to a SEQEND.
+			j := i + 1
+			for j < len(entities) && entities[j].kind == "VERTEX" {
+				j++
+			}
+			if err := p.polyline(e, entities[i+1:j]); err != nil {
+				return nil, err
+			}
+			i = j - 1
+			if j < len(entities) && entities[j].kind == "SEQEND" {
+				i = j
+			}
+			continue
+		}
+		if err := p.entity(e); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(p.primitives) == 0 {
+		if len(opts.Layers) > 0 {
+			return nil, fmt.Errorf("no lines or arcs found on layers %v", strings.Join(opts.Layers, ", "))
+		}
+		return nil, fmt.Errorf("no lines or arcs found")
+	}
+	return p.primitives, nil
+}
+
+// dxfPair is a group code and its value.
+type dxfPair struct {
+	code  int
+	value string
+}
+
+// readDXF returns the group code and value pairs of an ASCII DXF file.
+func readDXF(r io.Reader) ([]dxfPair, error) {
+	br := bufio.NewReader(r)
+	if head, _ := br.Peek(18); bytes.Equal(head, []byte("AutoCAD Binary DXF")) {
+		return nil, fmt.Errorf("binary DXF files are not supported")
+	}
+	s := bufio.NewScanner(br)
+	s.Buffer(nil, 1<<20)
+	var pairs []dxfPair
+	for line := 1; s.Scan(); line += 2 {
+		text := strings.TrimSpace(s.Text())
+		code, err := strconv.Atoi(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: invalid group code %q", line, text)
+		}
+		if !s.Scan() {
+			if err := s.Err(); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("line %v: missing value of group code %v", line+1, code)
+		}
+		pair := dxfPair{code: code, value: strings.TrimSpace(s.Text())}
+		if pair.code == 0 && pair.value == "EOF" {
+			break
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, s.Err()
+}
+
+// dxfEntity is an entity of the drawing with its groups.
+type dxfEntity struct {
+	kind  string
+	pairs []dxfPair
+}
+
+// float returns the value of the first group with the code,
+// or zero if it is missing.
+func (e *dxfEntity) float(code int) (float64, error) {
+	for _, pair := range e.pairs {
+		if pair.code != code {
+			continue
+		}
+		v, err := strconv.ParseFloat(pair.value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%v: invalid value %q of group code %v", e.kind, pair.value, code)
+		}
+		return v, nil
+	}
+	return 0, nil
+}
+
+// floats returns the values of the groups with the codes,
+// stopping at the first error.
+func (e *dxfEntity) floats(codes ...int) ([]float64, error) {
+	result := make([]float64, len(codes))
+	for i, code := range codes {
+		var err error
+		if result[i], err = e.float(code); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// layer returns the layer of the entity.
+func (e *dxfEntity) layer() string {
+	for _, pair := range e.pairs {
+		if pair.code == 8 {
+			return pair.value
+		}
+	}
+	return "0"
+}
+
+// dxfParser converts the entities to primitives.
+type dxfParser struct {
+	opts       *DXFImportOptions
+	unit       float64 // millimeters per drawing unit
+	thickness  float64
+	primitives []Primitive
+}
+
+// selected reports whether the entity is on one of the selected layers.
+func (p *dxfParser) selected(e *dxfEntity) bool {
+	if len(p.opts.Layers) == 0 {
+		return true
+	}
+	for _, name := range p.opts.Layers {
+		if strings.EqualFold(name, e.layer()) {
+			return true
+		}
+	}
+	return false
+}
+
+// point returns a point of the drawing in millimeters. Entities whose
+// extrusion direction points down are mirrored left to right.
+func (p *dxfParser) point(x, y float64, mirrored bool) Pt {
+	if mirrored {
+		x = -x
+	}
+	return Pt{p.unit*x + p.opts.Offset[0], p.unit*y + p.opts.Offset[1]}
+}
+
+// mirrored reports whether the object coordinate system of
+// the entity is mirrored.
+func (p *dxfParser) mirrored(e *dxfEntity) (bool, error) {
+	z, err := e.float(230)
+	return z < 0, err
+}
+
+func (p *dxfParser) entity(e *dxfEntity) error {
+	if !p.selected(e) {
+		return nil
+	}
+	mirrored, err := p.mirrored(e)
+	if err != nil {
+		return err
+	}
+	switch e.kind {
+	case "LINE":
+		v, err := e.floats(10, 20, 11, 21)
+		if err != nil {
+			return err
+		}
+		// Lines are in world coordinates.
+		p1, p2 := p.point(v[0], v[1], false), p.point(v[2], v[3], false)
+		p.primitives = append(p.primitives, Line(p1[0], p1[1], p2[0], p2[1], CircleShape, p.thickness))
+	case "CIRCLE":
+		v, err := e.floats(10, 20, 40)
+		if err != nil {
+			return err
+		}
+		p.primitives = append(p.primitives, Arc(p.point(v[0], v[1], mirrored), p.unit*v[2], CircleShape, 1, 1, 0, 360, p.thickness))
+	case "ARC":
+		v, err := e.floats(10, 20, 40, 50, 51)
+		if err != nil {
+			return err
+		}
+		start, end := v[3], v[4]
+		if mirrored {
+			start, end = 180-end, 180-start
+		}
+		for end <= start {
+			end += 360
+		}
+		p.primitives = append(p.primitives, Arc(p.point(v[0], v[1], mirrored), p.unit*v[2], CircleShape, 1, 1, start, end, p.thickness))
+	case "LWPOLYLINE":
+		flags, err := e.float(70)
+		if err != nil {
+			return err
+		}
+		var vertices []dxfVertex
+		for _, pair := range e.pairs {
+			if pair.code != 10 && pair.code != 20 && pair.code != 42 {
+				continue
+			}
+			v, err := strconv.ParseFloat(pair.value, 64)
+			if err != nil {
+				return fmt.Errorf("%v: invalid value %q of group code %v", e.kind, pair.value, pair.code)
+			}
+			switch pair.code {
+			case 10:
+				vertices = append(vertices, dxfVertex{pt: Pt{v, 0}})
+			case 20:
+				if len(vertices) > 0 {
+					vertices[len(vertices)-1].pt[1] = v
+				}
+			case 42:
+				if len(vertices) > 0 {
+					vertices[len(vertices)-1].bulge = v
+				}
+			}
+		}
+		p.addPolyline(vertices, int(flags)&1 != 0, mirrored)
+	}
+	return nil
+}
+
+// polyline adds an old style POLYLINE entity with its VERTEX entities.
+// Polygon meshes and polyface meshes are ignored.
+func (p *dxfParser) polyline(e *dxfEntity, vertexEntities []*dxfEntity) error {
+	if !p.selected(e) {
+		return nil
+	}
+	mirrored, err := p.mirrored(e)
+	if err != nil {
+		return err
+	}
+	flags, err := e.float(70)
+	if err != nil {
+		return err
+	}
+	if int(flags)&(16|64) != 0 {
+		return nil
+	}
+	var vertices []dxfVertex
+	for _, ve := range vertexEntities {
+		v, err := ve.floats(10, 20, 42)
+		if err != nil {
+			return err
+		}
+		vertices = append(vertices, dxfVertex{pt: Pt{v[0], v[1]}, bulge: v[2]})
+	}
+	p.addPolyline(vertices, int(flags)&1 != 0, mirrored)
+	return nil
+}
+
+// addPolyline adds the segments of a polyline in object coordinates.
+func (p *dxfParser) addPolyline(vertices []dxfVertex, closed, mirrored bool) {
+	n := len(vertices) - 1
+	if closed {
+		n++
+	}
+	for i := 0; i < n; i++ {
+		v1, v2 := vertices[i], vertices[(i+1)%len(vertices)]
+		p1, p2 := p.point(v1.pt[0], v1.pt[1], mirrored), p.point(v2.pt[0], v2.pt[1], mirrored)
+		bulge := v1.bulge
+		if mirrored {
+			bulge = -bulge
+		}
+		chord := math.Hypot(p2[0]-p1[0], p2[1]-p1[1])
+		switch {
+		case chord < 1e-9:
+			continue
+		case math.Abs(bulge) < 1e-9:
+			p.primitives = append(p.primitives, Line(p1[0], p1[1], p2[0], p2[1], CircleShape, p.thickness))
+			continue
+		}
+
+		// The center is on the left of the chord for counterclockwise
+		// arcs of less than 180 degrees.
+		sweep := 4 * math.Atan(bulge)
+		mid := Pt{0.5 * (p1[0] + p2[0]), 0.5 * (p1[1] + p2[1])}
+		normal := Pt{-(p2[1] - p1[1]) / chord, (p2[0] - p1[0]) / chord}
+		offset := 0.5 * chord / math.Tan(0.5*sweep)
+		center := Pt{mid[0] + offset*normal[0], mid[1] + offset*normal[1]}
+		radius := 0.5 * chord / math.Abs(math.Sin(0.5*sweep))
+
+		angle := func(pt Pt) float64 {
+			return 180 / math.Pi * math.Atan2(pt[1]-center[1], pt[0]-center[0])
+		}
+		start, end := angle(p1), angle(p2)
+		if sweep < 0 {
+			start, end = end, start
+		}
+		for end <= start {
+			end += 360
+		}
+		p.primitives = append(p.primitives, Arc(center, radius, CircleShape, 1, 1, start, end, p.thickness))
+	}
+}
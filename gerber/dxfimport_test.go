@@ -0,0 +1,224 @@
+package gerber
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testDXF returns a DXF drawing in the units with the entities, given
+// as alternating group codes and values. Negative units are not set.
+func testDXF(units int, groups ...interface{}) string {
+	var buf bytes.Buffer
+	if units >= 0 {
+		dxfGroup(&buf, 0, "SECTION")
+		dxfGroup(&buf, 2, "HEADER")
+		dxfGroup(&buf, 9, "$INSUNITS")
+		dxfGroup(&buf, 70, units)
+		dxfGroup(&buf, 0, "ENDSEC")
+	}
+	dxfGroup(&buf, 0, "SECTION")
+	dxfGroup(&buf, 2, "ENTITIES")
+	for i := 0; i+1 < len(groups); i += 2 {
+		dxfGroup(&buf, groups[i].(int), groups[i+1])
+	}
+	dxfGroup(&buf, 0, "ENDSEC")
+	dxfGroup(&buf, 0, "EOF")
+	return buf.String()
+}
+
+func TestParseDXF(t *testing.T) {
+	square := []interface{}{0, "LWPOLYLINE", 8, "0", 90, 4, 70, 1,
+		10, 0, 20, 0, 10, 2, 20, 0, 42, 1, 10, 2, 20, 2, 10, 0, 20, 2}
+	tests := []struct {
+		name        string
+		dxf         string
+		opts        *DXFImportOptions
+		lines, arcs int
+		want        MBB
+	}{
+		{
+			name:  "line",
+			dxf:   testDXF(-1, 0, "LINE", 8, "0", 10, 0, 20, 0, 11, 10, 21, 5),
+			lines: 1,
+			want:  MBB{Min: Pt{-0.05, -0.05}, Max: Pt{10.05, 5.05}},
+		},
+		{
+			name:  "inches",
+			dxf:   testDXF(1, 0, "LINE", 10, 0, 20, 0, 11, 1, 21, 1),
+			lines: 1,
+			want:  MBB{Min: Pt{-0.05, -0.05}, Max: Pt{25.45, 25.45}},
+		},
+		{
+			name: "arc",
+			dxf:  testDXF(4, 0, "ARC", 10, 0, 20, 0, 40, 1, 50, 0, 51, 90),
+			arcs: 1,
+			want: MBB{Min: Pt{-0.05, -0.05}, Max: Pt{1.05, 1.05}},
+		},
+		{
+			name: "arc across zero degrees",
+			dxf:  testDXF(4, 0, "ARC", 10, 0, 20, 0, 40, 1, 50, 270, 51, 90),
+			arcs: 1,
+			want: MBB{Min: Pt{-0.05, -1.05}, Max: Pt{1.05, 1.05}},
+		},
+		{
+			name: "arc with mirrored coordinate system",
+			dxf:  testDXF(4, 0, "ARC", 10, 2, 20, 0, 40, 1, 210, 0, 220, 0, 230, -1, 50, 0, 51, 90),
+			arcs: 1,
+			want: MBB{Min: Pt{-3.05, -0.05}, Max: Pt{-1.95, 1.05}},
+		},
+		{
+			name: "circle",
+			dxf:  testDXF(4, 0, "CIRCLE", 10, 0, 20, 0, 40, 2),
+			arcs: 1,
+			want: MBB{Min: Pt{-2.05, -2.05}, Max: Pt{2.05, 2.05}},
+		},
+		{
+			name:  "closed polyline with a bulge",
+			dxf:   testDXF(4, square...),
+			lines: 3,
+			arcs:  1,
+			want:  MBB{Min: Pt{-0.05, -0.05}, Max: Pt{3.05, 2.05}},
+		},
+		{
+			name: "clockwise bulge",
+			dxf:  testDXF(4, 0, "LWPOLYLINE", 90, 2, 70, 0, 10, 0, 20, 0, 42, -1, 10, 2, 20, 0),
+			arcs: 1,
+			want: MBB{Min: Pt{-0.05, -0.05}, Max: Pt{2.05, 1.05}},
+		},
+		{
+			name: "old style polyline",
+			dxf: testDXF(4, 0, "POLYLINE", 66, 1, 70, 1,
+				0, "VERTEX", 10, 0, 20, 0, 0, "VERTEX", 10, 1, 20, 0, 0, "VERTEX", 10, 1, 20, 1,
+				0, "SEQEND", 0, "LINE", 10, 5, 20, 5, 11, 6, 21, 5),
+			lines: 4,
+			want:  MBB{Min: Pt{-0.05, -0.05}, Max: Pt{6.05, 5.05}},
+		},
+		{
+			name: "layers",
+			dxf: testDXF(4, 0, "LINE", 8, "OUTLINE", 10, 0, 20, 0, 11, 1, 21, 0,
+				0, "LINE", 8, "Dimensions", 10, 0, 20, 0, 11, 100, 21, 0),
+			opts:  &DXFImportOptions{Layers: []string{"outline"}},
+			lines: 1,
+			want:  MBB{Min: Pt{-0.05, -0.05}, Max: Pt{1.05, 0.05}},
+		},
+		{
+			name:  "offset and thickness",
+			dxf:   testDXF(4, 0, "LINE", 10, 0, 20, 0, 11, 1, 21, 0),
+			opts:  &DXFImportOptions{Offset: Pt{10, 10}, Thickness: 0.2},
+			lines: 1,
+			want:  MBB{Min: Pt{9.9, 9.9}, Max: Pt{11.1, 10.1}},
+		},
+		{
+			name: "other entities",
+			dxf: testDXF(4, 0, "TEXT", 10, 50, 20, 50, 1, "REV A",
+				0, "INSERT", 2, "LOGO", 10, 50, 20, 50,
+				0, "LINE", 10, 0, 20, 0, 11, 1, 21, 0),
+			lines: 1,
+			want:  MBB{Min: Pt{-0.05, -0.05}, Max: Pt{1.05, 0.05}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primitives, err := ParseDXF(strings.NewReader(tt.dxf), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var lines, arcs int
+			mbb := primitives[0].MBB()
+			for _, p := range primitives {
+				switch p.(type) {
+				case *LineT:
+					lines++
+				case *ArcT:
+					arcs++
+				}
+				v := p.MBB()
+				mbb.Join(&v)
+			}
+			if lines != tt.lines || arcs != tt.arcs {
+				t.Errorf("got %v lines and %v arcs, want %v and %v", lines, arcs, tt.lines, tt.arcs)
+			}
+			if !mbbClose(mbb, tt.want) {
+				t.Errorf("MBB = %v, want %v", mbb, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDXF_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		dxf  string
+		opts *DXFImportOptions
+		want string
+	}{
+		{
+			name: "empty",
+			dxf:  testDXF(4),
+			want: "no lines or arcs found",
+		},
+		{
+			name: "no lines on the layers",
+			dxf:  testDXF(4, 0, "LINE", 8, "0", 10, 0, 20, 0, 11, 1, 21, 0),
+			opts: &DXFImportOptions{Layers: []string{"Edge", "Outline"}},
+			want: "no lines or arcs found on layers Edge, Outline",
+		},
+		{
+			name: "bad group code",
+			dxf:  "  0\nSECTION\nX\nHEADER\n",
+			want: `line 3: invalid group code "X"`,
+		},
+		{
+			name: "missing value",
+			dxf:  "  0\nSECTION\n  2\n",
+			want: "line 4: missing value of group code 2",
+		},
+		{
+			name: "bad number",
+			dxf:  testDXF(4, 0, "LINE", 10, "zero"),
+			want: `LINE: invalid value "zero" of group code 10`,
+		},
+		{
+			name: "unsupported units",
+			dxf:  testDXF(3, 0, "LINE", 10, 0, 20, 0, 11, 1, 21, 0),
+			want: "unsupported $INSUNITS 3",
+		},
+		{
+			name: "binary",
+			dxf:  "AutoCAD Binary DXF\r\n\x1a\x00",
+			want: "binary DXF files are not supported",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseDXF(strings.NewReader(tt.dxf), tt.opts)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("ParseDXF error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportDXF(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "board.dxf")
+	if err := os.WriteFile(filename, []byte(testDXF(4, 0, "CIRCLE", 10, 0, 20, 0, 40, 5)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	primitives, err := ImportDXF(filename, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := New("board")
+	outline := g.Outline()
+	outline.Add(primitives...)
+	if got, want := outline.MBB(), (MBB{Min: Pt{-5.05, -5.05}, Max: Pt{5.05, 5.05}}); !mbbClose(got, want) {
+		t.Errorf("MBB = %v, want %v", got, want)
+	}
+
+	if _, err := ImportDXF(filepath.Join(t.TempDir(), "missing.dxf"), nil); err == nil {
+		t.Errorf("ImportDXF of a missing file = nil error")
+	}
+}
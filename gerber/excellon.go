@@ -0,0 +1,451 @@
+package gerber
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DrillFormat represents the file format used to write drill layers.
+type DrillFormat int
+
+const (
+	// ExcellonDrill writes drill layers as Excellon drill files (the default).
+	ExcellonDrill DrillFormat = iota
+	// GerberDrill writes drill layers as RS274X Gerber files
+	// for manufacturers that accept Gerber drill data.
+	GerberDrill
+)
+
+// Units represents the measurement units of an output file.
+type Units int
+
+const (
+	// Millimeters writes coordinates in millimeters (the default).
+	Millimeters Units = iota
+	// Inches writes coordinates in inches.
+	Inches
+)
+
+const mmPerInch = 25.4
+
+// WriteExcellon writes a drill layer as an Excellon drill file.
+// A tool is defined for each distinct CircleT (or circular FlashT)
+// diameter in the layer. If the design is panelized, every hit is
+// repeated for each copy of the board.
+func (l *Layer) WriteExcellon(w io.Writer) error {
+	var buf bytes.Buffer
+	if err := l.writeExcellon(&buf); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeExcellon renders the Excellon drill file.
+func (l *Layer) writeExcellon(w io.Writer) error {
+	units := Millimeters
+	if l.g != nil {
+		units = l.g.DrillUnits
+		if l.g.Panel != nil {
+			if err := l.g.Panel.Validate(); err != nil {
+				return fmt.Errorf("%v: %v", l.Filename, err)
+			}
+		}
+	}
+
+	// Group the hits by tool diameter as formatted in the output units.
+	hits := map[string][]Pt{}
+	var diameters []float64
+	for _, p := range l.Primitives {
+		var pt Pt
+		var diameter float64
+		switch v := p.(type) {
+		case *CircleT:
+			pt, diameter = v.pt, v.thickness
+		case *FlashT:
+			if v.aperture == nil || v.aperture.Shape != CircleShape || v.aperture.Macro != nil {
+				return fmt.Errorf("unsupported non-circular flash in Excellon drill layer %v", l.Filename)
+			}
+			pt, diameter = v.Center, v.aperture.Size
+		default:
+			return fmt.Errorf("unsupported primitive %T in Excellon drill layer %v", p, l.Filename)
+		}
+		d := excellonValue(diameter, units)
+		if _, ok := hits[d]; !ok {
+			diameters = append(diameters, diameter)
+		}
+		hits[d] = append(hits[d], pt)
+	}
+	sort.Float64s(diameters)
+
+	io.WriteString(w, "M48\n")
+	io.WriteString(w, "; DRILL file generated by github.com/gmlewis/go-gerber\n")
+	io.WriteString(w, "; #@! TF.GenerationSoftware,gmlewis,go-gerber\n")
+	fmt.Fprintf(w, "; #@! TF.CreationDate,%v\n", l.g.creationDate().Format(x2DateFormat))
+	fmt.Fprintf(w, "; #@! TF.FileFunction,%v\n", l.FileFunction())
+	io.WriteString(w, "FMAT,2\n")
+	if units == Inches {
+		io.WriteString(w, "INCH\n")
+	} else {
+		io.WriteString(w, "METRIC\n")
+	}
+	for i, d := range diameters {
+		fmt.Fprintf(w, "T%vC%v\n", i+1, excellonValue(d, units))
+	}
+	io.WriteString(w, "%\n")
+	io.WriteString(w, "G90\n")
+	io.WriteString(w, "G05\n")
+
+	offsets := l.offsets()
+	for i, d := range diameters {
+		fmt.Fprintf(w, "T%v\n", i+1)
+		for _, offset := range offsets {
+			for _, pt := range hits[excellonValue(d, units)] {
+				x, y := pt[0]+offset[0], pt[1]+offset[1]
+				fmt.Fprintf(w, "X%vY%v\n", excellonValue(x, units), excellonValue(y, units))
+			}
+		}
+	}
+
+	io.WriteString(w, "T0\n")
+	io.WriteString(w, "M30\n")
+	return nil
+}
+
+// excellonValue formats a value in millimeters as an Excellon decimal
+// number in the provided units with a fixed number of decimals. The
+// decimal point is always written because readers take numbers without
+// one as zero-suppressed integers.
+func excellonValue(v float64, units Units) string {
+	prec := 3
+	if units == Inches {
+		v /= mmPerInch
+		prec = 4
+	}
+	s := fmt.Sprintf("%.*f", prec, v)
+	if strings.Trim(s, "-0.") == "" {
+		return s[strings.IndexByte(s, '0'):]
+	}
+	return s
+}
+
+// ParseExcellon reads an Excellon drill file into a new drill layer.
+//
+// Coordinates and tool diameters are converted to millimeters.
+// Drill hits become CircleT primitives. G85 slots and routed (G00/G01)
+// paths become LineT primitives with the diameter of the tool, which
+// can be written back with the GerberDrill format.
+//
+// The layer is a non-plated drill layer if its TF.FileFunction
+// comment says so. Unsupported commands are reported as errors
+// with their line numbers.
+func ParseExcellon(r io.Reader) (*Layer, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &excellonParser{
+		units: Millimeters,
+		tools: map[int]float64{},
+		mode:  5,
+		layer: &Layer{Type: LayerDrill, apertureMap: map[string]int{"default": -1}},
+	}
+	lines := strings.Split(string(data), "\n")
+	for _, line := range lines {
+		// Coordinates without a decimal point are whole numbers in
+		// files that mix both styles.
+		line = strings.TrimSpace(line)
+		if (strings.HasPrefix(line, "X") || strings.HasPrefix(line, "Y")) && strings.Contains(line, ".") {
+			p.decimal = true
+			break
+		}
+	}
+	for i, line := range lines {
+		if p.done {
+			break
+		}
+		if err := p.parseLine(strings.TrimSpace(line)); err != nil {
+			return nil, fmt.Errorf("line %v: %v", i+1, err)
+		}
+	}
+	p.layer.Add(p.primitives...)
+	return p.layer, nil
+}
+
+// excellonParser holds the state of an Excellon drill file being parsed.
+type excellonParser struct {
+	header bool
+	done   bool
+
+	units Units
+	// keepLeading is set when leading zeros are kept (LZ) and the
+	// trailing zeros of coordinates are omitted.
+	keepLeading bool
+	zerosSet    bool
+	intDigits   int
+	decDigits   int
+	// decimal is set when the coordinates are decimal numbers.
+	decimal bool
+
+	tools   map[int]float64 // diameters in millimeters
+	current int             // current tool
+
+	pt   Pt
+	mode int // 5 (drill), 0 (rout move) or 1 (rout cut)
+
+	primitives []Primitive
+	layer      *Layer
+}
+
+// parseLine processes a line of an Excellon drill file.
+func (p *excellonParser) parseLine(line string) error {
+	if strings.HasPrefix(line, ";") {
+		comment := strings.TrimSpace(line[1:])
+		if v := strings.TrimPrefix(comment, "#@! TF.FileFunction,"); v != comment {
+			if t, _, ok := parseFileFunction(v); ok && (t == LayerDrill || t == LayerNonPlatedDrill) {
+				p.layer.Type = t
+			}
+		}
+		if comment == "TYPE=NON_PLATED" {
+			p.layer.Type = LayerNonPlatedDrill
+		}
+		return nil
+	}
+
+	switch {
+	case line == "":
+		return nil
+	case line == "M48":
+		p.header = true
+		return nil
+	case line == "%" || line == "M95":
+		p.header = false
+		return nil
+	case line == "M30" || line == "M00":
+		p.done = true
+		return nil
+	case strings.HasPrefix(line, "METRIC") || strings.HasPrefix(line, "INCH"):
+		return p.unitsSpec(line)
+	case line == "M71":
+		p.units = Millimeters
+		return nil
+	case line == "M72":
+		p.units = Inches
+		return nil
+	case line == "G90" || line == "M15" || line == "M16" || line == "M17" || strings.HasPrefix(line, "M47"):
+		// Absolute mode is the only one supported; the tool
+		// plunge and retract commands and messages do not
+		// affect the image.
+		return nil
+	}
+
+	if p.header {
+		switch {
+		case strings.HasPrefix(line, "FMAT,"), strings.HasPrefix(line, "VER,"), strings.HasPrefix(line, "ICI,OFF"):
+			return nil
+		case strings.HasPrefix(line, "T"):
+			return p.tool(line)
+		}
+		return fmt.Errorf("unsupported header command %q", line)
+	}
+
+	fields := splitFields(line)
+	if len(fields) == 0 {
+		return fmt.Errorf("unsupported command %q", line)
+	}
+	if fields[0].letter == 'T' {
+		return p.tool(line)
+	}
+
+	var coords []field
+	for i, f := range fields {
+		switch f.letter {
+		case 'X', 'Y':
+			coords = append(coords, f)
+		case 'G':
+			switch f.value {
+			case "05", "5":
+				p.mode = 5
+			case "00", "0":
+				p.mode = 0
+			case "01", "1":
+				p.mode = 1
+			case "85":
+				// A slot from the preceding coordinates to the following ones.
+				start, err := p.point(coords)
+				if err != nil {
+					return err
+				}
+				end, err := p.move(start, fields[i+1:])
+				if err != nil {
+					return err
+				}
+				return p.addSlot(start, end)
+			default:
+				return fmt.Errorf("unsupported command G%v in %q", f.value, line)
+			}
+		default:
+			return fmt.Errorf("unsupported command %q", line)
+		}
+	}
+	if len(coords) == 0 {
+		return nil
+	}
+
+	pt, err := p.point(coords)
+	if err != nil {
+		return err
+	}
+	prev := p.pt
+	p.pt = pt
+	switch p.mode {
+	case 5:
+		diameter, err := p.diameter()
+		if err != nil {
+			return err
+		}
+		p.primitives = append(p.primitives, Circle(pt, diameter))
+	case 1:
+		return p.addSlot(prev, pt)
+	}
+	return nil
+}
+
+// unitsSpec parses the METRIC or INCH header command with its
+// optional zero suppression and format, e.g. "METRIC,LZ,000.000".
+func (p *excellonParser) unitsSpec(line string) error {
+	parts := strings.Split(line, ",")
+	p.units, p.intDigits, p.decDigits = Millimeters, 3, 3
+	if parts[0] == "INCH" {
+		p.units, p.intDigits, p.decDigits = Inches, 2, 4
+	} else if parts[0] != "METRIC" {
+		return fmt.Errorf("unsupported units %q", line)
+	}
+	for _, part := range parts[1:] {
+		switch {
+		case part == "LZ":
+			p.keepLeading, p.zerosSet = true, true
+		case part == "TZ":
+			p.keepLeading, p.zerosSet = false, true
+		case strings.Trim(part, "0") == ".":
+			i := strings.IndexByte(part, '.')
+			p.intDigits, p.decDigits = i, len(part)-i-1
+		default:
+			return fmt.Errorf("unsupported units %q", line)
+		}
+	}
+	return nil
+}
+
+// tool defines (T1C0.8) or selects (T1) a tool.
+func (p *excellonParser) tool(line string) error {
+	fields := splitFields(line)
+	n, err := strconv.Atoi(fields[0].value)
+	if err != nil {
+		return fmt.Errorf("invalid tool %q", line)
+	}
+	for _, f := range fields[1:] {
+		if f.letter != 'C' {
+			continue // feeds, speeds and such
+		}
+		v, err := strconv.ParseFloat(f.value, 64)
+		if err != nil || v <= 0 {
+			return fmt.Errorf("invalid tool diameter %q", line)
+		}
+		if p.units == Inches {
+			v *= mmPerInch
+		}
+		p.tools[n] = v
+	}
+	if !p.header {
+		p.current = n
+	}
+	return nil
+}
+
+// diameter returns the diameter of the current tool.
+func (p *excellonParser) diameter() (float64, error) {
+	d, ok := p.tools[p.current]
+	if !ok {
+		return 0, fmt.Errorf("undefined tool T%v", p.current)
+	}
+	return d, nil
+}
+
+// addSlot adds a slot (or routed segment) with the current tool.
+func (p *excellonParser) addSlot(start, end Pt) error {
+	diameter, err := p.diameter()
+	if err != nil {
+		return err
+	}
+	p.pt = end
+	p.primitives = append(p.primitives, Line(start[0], start[1], end[0], end[1], CircleShape, diameter))
+	return nil
+}
+
+// point returns the current point updated by the coordinates.
+func (p *excellonParser) point(coords []field) (Pt, error) {
+	return p.move(p.pt, coords)
+}
+
+// move returns the point updated by the X and Y coordinates.
+func (p *excellonParser) move(pt Pt, coords []field) (Pt, error) {
+	for _, f := range coords {
+		if f.letter != 'X' && f.letter != 'Y' {
+			return Pt{}, fmt.Errorf("unexpected %c%v", f.letter, f.value)
+		}
+		v, err := p.coord(f.value)
+		if err != nil {
+			return Pt{}, err
+		}
+		pt[f.letter-'X'] = v
+	}
+	return pt, nil
+}
+
+// coord parses coordinate data and returns it in millimeters.
+func (p *excellonParser) coord(s string) (float64, error) {
+	var v float64
+	if strings.Contains(s, ".") || (p.decimal && !p.zerosSet) {
+		var err error
+		if v, err = strconv.ParseFloat(s, 64); err != nil {
+			return 0, fmt.Errorf("invalid coordinate %q", s)
+		}
+	} else {
+		if p.intDigits == 0 {
+			p.intDigits, p.decDigits = 3, 3
+			if p.units == Inches {
+				p.intDigits, p.decDigits = 2, 4
+			}
+		}
+		sign := 1.0
+		digits := s
+		switch {
+		case strings.HasPrefix(s, "-"):
+			sign, digits = -1, s[1:]
+		case strings.HasPrefix(s, "+"):
+			digits = s[1:]
+		}
+		n := p.intDigits + p.decDigits
+		if digits == "" || len(digits) > n {
+			return 0, fmt.Errorf("invalid coordinate %q", s)
+		}
+		if p.keepLeading {
+			digits += strings.Repeat("0", n-len(digits))
+		}
+		i, err := strconv.ParseInt(digits, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid coordinate %q", s)
+		}
+		v = sign * float64(i) / math.Pow10(p.decDigits)
+	}
+	if p.units == Inches {
+		v *= mmPerInch
+	}
+	return v, nil
+}
@@ -0,0 +1,259 @@
+package gerber
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLayer_WriteExcellon(t *testing.T) {
+	tests := []struct {
+		name  string
+		units Units
+		want  string
+	}{
+		{
+			name:  "metric",
+			units: Millimeters,
+			want: `M48
+; DRILL file generated by github.com/gmlewis/go-gerber
+; #@! TF.GenerationSoftware,gmlewis,go-gerber
+; #@! TF.CreationDate,2019-06-08T19:30:00+00:00
+; #@! TF.FileFunction,Plated,1,2,PTH
+FMAT,2
+METRIC
+T1C0.250
+T2C1.000
+%
+G90
+G05
+T1
+X-1.500Y2.000
+T2
+X10.000Y20.000
+X0.000Y-3.125
+T0
+M30
+`,
+		},
+		{
+			name:  "inch",
+			units: Inches,
+			want: `M48
+; DRILL file generated by github.com/gmlewis/go-gerber
+; #@! TF.GenerationSoftware,gmlewis,go-gerber
+; #@! TF.CreationDate,2019-06-08T19:30:00+00:00
+; #@! TF.FileFunction,Plated,1,2,PTH
+FMAT,2
+INCH
+T1C0.0098
+T2C0.0394
+%
+G90
+G05
+T1
+X-0.0591Y0.0787
+T2
+X0.3937Y0.7874
+X0.0000Y-0.1230
+T0
+M30
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := New("test")
+			g.DrillUnits = tt.units
+			g.CreationDate = time.Date(2019, 6, 8, 19, 30, 0, 0, time.UTC)
+			drill := g.Drill()
+			drill.Add(
+				Circle(Pt{10, 20}, 1),
+				Circle(Pt{-1.5, 2}, 0.25),
+				Circle(Pt{0, -3.125}, 1),
+			)
+
+			var buf strings.Builder
+			if err := drill.WriteGerber(&buf); err != nil {
+				t.Fatalf("WriteGerber: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("WriteGerber =\n%v\nwant:\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayer_WriteExcellon_GerberDrill(t *testing.T) {
+	g := New("test")
+	g.DrillFormat = GerberDrill
+	drill := g.Drill()
+	drill.Add(Circle(Pt{1, 2}, 1))
+
+	var buf strings.Builder
+	if err := drill.WriteGerber(&buf); err != nil {
+		t.Fatalf("WriteGerber: %v", err)
+	}
+	if got := buf.String(); !strings.Contains(got, "%FSLAX36Y36*%") {
+		t.Errorf("WriteGerber =\n%v\nwant RS274X output", got)
+	}
+}
+
+func TestLayer_WriteExcellon_UnsupportedPrimitive(t *testing.T) {
+	g := New("test")
+	drill := g.Drill()
+	drill.Add(Line(0, 0, 1, 1, CircleShape, 0.1))
+
+	var buf strings.Builder
+	if err := drill.WriteGerber(&buf); err == nil {
+		t.Errorf("WriteGerber = nil, want error")
+	}
+}
+
+func TestParseExcellon_RoundTrip(t *testing.T) {
+	for _, units := range []Units{Millimeters, Inches} {
+		g := New("test")
+		g.DrillUnits = units
+		drill := g.Drill()
+		drill.Add(
+			Circle(Pt{10, 20}, 1),
+			Circle(Pt{-1.5, 2}, 0.25),
+			Circle(Pt{0, -3.125}, 1),
+		)
+		var buf strings.Builder
+		if err := drill.WriteGerber(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		layer, err := ParseExcellon(strings.NewReader(buf.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if layer.Type != LayerDrill {
+			t.Errorf("Type = %v, want %v", layer.Type, LayerDrill)
+		}
+		// Hits are written grouped by tool.
+		want := []*CircleT{Circle(Pt{-1.5, 2}, 0.25), Circle(Pt{10, 20}, 1), Circle(Pt{0, -3.125}, 1)}
+		if got := len(layer.Primitives); got != len(want) {
+			t.Fatalf("units %v: len(Primitives) = %v, want %v", units, got, len(want))
+		}
+		// Inch files have a resolution of 0.0001 inch.
+		const eps = 0.0026
+		for i, p := range layer.Primitives {
+			got, want := p.(*CircleT).MBB(), want[i].MBB()
+			if math.Abs(got.Min[0]-want.Min[0]) > eps || math.Abs(got.Min[1]-want.Min[1]) > eps ||
+				math.Abs(got.Max[0]-want.Max[0]) > eps || math.Abs(got.Max[1]-want.Max[1]) > eps {
+				t.Errorf("units %v: hit %v = %v, want %v", units, i, got, want)
+			}
+		}
+	}
+}
+
+func TestParseExcellon_WholeNumbers(t *testing.T) {
+	// A file with whole millimeters only keeps its decimal points.
+	g := New("test")
+	drill := g.Drill()
+	drill.Add(Circle(Pt{10, 20}, 1))
+	var buf strings.Builder
+	if err := drill.WriteGerber(&buf); err != nil {
+		t.Fatal(err)
+	}
+	layer, err := ParseExcellon(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(layer.Primitives) != 1 {
+		t.Fatalf("len(Primitives) = %v, want 1", len(layer.Primitives))
+	}
+	if got, want := layer.Primitives[0].MBB(), Circle(Pt{10, 20}, 1).MBB(); !mbbClose(got, want) {
+		t.Errorf("MBB = %v, want %v", got, want)
+	}
+}
+
+func TestParseExcellon(t *testing.T) {
+	layer, err := ParseExcellon(strings.NewReader(`M48
+; #@! TF.FileFunction,NonPlated,1,2,NPTH
+FMAT,2
+INCH,LZ,00.0000
+T1C0.0400
+T2F200S65C0.1250
+%
+G90
+G05
+T1
+X01Y005
+X015
+T2
+X02Y01G85X03Y01
+G00X0Y02
+M15
+G01X01Y02
+Y03
+M16
+G05
+T0
+M30
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layer.Type != LayerNonPlatedDrill {
+		t.Errorf("Type = %v, want %v", layer.Type, LayerNonPlatedDrill)
+	}
+
+	want := []Primitive{
+		Circle(Pt{25.4, 12.7}, 1.016),
+		Circle(Pt{38.1, 12.7}, 1.016),
+		Line(50.8, 25.4, 76.2, 25.4, CircleShape, 3.175),
+		Line(0, 50.8, 25.4, 50.8, CircleShape, 3.175),
+		Line(25.4, 50.8, 25.4, 76.2, CircleShape, 3.175),
+	}
+	if got := len(layer.Primitives); got != len(want) {
+		t.Fatalf("len(Primitives) = %v, want %v", got, len(want))
+	}
+	for i, p := range layer.Primitives {
+		if typeName(p) != typeName(want[i]) || !mbbClose(p.MBB(), want[i].MBB()) {
+			t.Errorf("primitive %v = %T %v, want %T %v", i, p, p.MBB(), want[i], want[i].MBB())
+		}
+	}
+}
+
+func TestParseExcellon_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "undefined tool",
+			input: "M48\nMETRIC\nT1C0.8\n%\nT2\nX1Y1\n",
+			want:  "line 6: undefined tool T2",
+		},
+		{
+			name:  "unsupported header command",
+			input: "M48\nMETRIC\nDETECT,ON\n%\n",
+			want:  "line 3: unsupported header command",
+		},
+		{
+			name:  "unsupported command",
+			input: "M48\nMETRIC\nT1C0.8\n%\nT1\nG93X1Y1\n",
+			want:  "line 6: unsupported command G93",
+		},
+		{
+			name:  "invalid coordinate",
+			input: "M48\nMETRIC,TZ,000.000\nT1C0.8\n%\nT1\nX1234567Y0\n",
+			want:  "line 6: invalid coordinate",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseExcellon(strings.NewReader(tt.input))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("ParseExcellon = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package gerber
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ZeroSuppression represents which zeros are omitted from coordinate data.
+type ZeroSuppression int
+
+const (
+	// OmitLeadingZeros omits the leading zeros of coordinates (the default).
+	OmitLeadingZeros ZeroSuppression = iota
+	// OmitTrailingZeros omits the trailing zeros of coordinates.
+	// Note that this is deprecated in the Gerber specification.
+	OmitTrailingZeros
+)
+
+// Format represents the coordinate format and units of the Gerber files
+// of a design.
+type Format struct {
+	// Units selects the units of the coordinates and aperture sizes.
+	Units Units
+	// IntDigits is the number of integer digits in coordinates (1-6).
+	IntDigits int
+	// DecDigits is the number of decimal digits in coordinates (1-6).
+	DecDigits int
+	// Zeros selects which zeros are omitted from coordinates.
+	Zeros ZeroSuppression
+}
+
+// DefaultFormat is the format used by New: millimeters with
+// 3 integer and 6 decimal digits, omitting leading zeros.
+var DefaultFormat = Format{
+	Units:     Millimeters,
+	IntDigits: 3,
+	DecDigits: 6,
+	Zeros:     OmitLeadingZeros,
+}
+
+// Validate returns an error if the format cannot be written.
+func (f *Format) Validate() error {
+	if f.IntDigits < 1 || f.IntDigits > 6 {
+		return fmt.Errorf("invalid number of integer digits %v: want 1-6", f.IntDigits)
+	}
+	if f.DecDigits < 1 || f.DecDigits > 6 {
+		return fmt.Errorf("invalid number of decimal digits %v: want 1-6", f.DecDigits)
+	}
+	if f.Units != Millimeters && f.Units != Inches {
+		return fmt.Errorf("invalid units %v", f.Units)
+	}
+	if f.Zeros != OmitLeadingZeros && f.Zeros != OmitTrailingZeros {
+		return fmt.Errorf("invalid zero suppression %v", f.Zeros)
+	}
+	return nil
+}
+
+// writeHeader writes the %FS and %MO commands for the format.
+func (f *Format) writeHeader(w io.Writer) {
+	zeros := "L"
+	if f.Zeros == OmitTrailingZeros {
+		zeros = "T"
+	}
+	fmt.Fprintf(w, "%%FS%vAX%v%vY%v%v*%%\n", zeros, f.IntDigits, f.DecDigits, f.IntDigits, f.DecDigits)
+	if f.Units == Inches {
+		io.WriteString(w, "%MOIN*%\n")
+	} else {
+		io.WriteString(w, "%MOMM*%\n")
+	}
+}
+
+// toUnits converts a value in millimeters to the format's units.
+func (f *Format) toUnits(v float64) float64 {
+	if f.Units == Inches {
+		return v / mmPerInch
+	}
+	return v
+}
+
+// Size returns an aperture size (or other decimal value) in millimeters
+// formatted in the format's units.
+func (f *Format) Size(v float64) string {
+	return fmt.Sprintf("%0.5f", f.toUnits(v))
+}
+
+// Coord returns the coordinate data for a value in millimeters.
+// It returns an error if the value does not fit in the format.
+func (f *Format) Coord(v float64) (string, error) {
+	n := math.Round(f.toUnits(v) * math.Pow10(f.DecDigits))
+	if math.Abs(n) >= math.Pow10(f.IntDigits+f.DecDigits) || math.IsNaN(n) {
+		return "", fmt.Errorf("coordinate %v mm overflows the %v.%v coordinate format", v, f.IntDigits, f.DecDigits)
+	}
+	if n == 0 {
+		return "0", nil
+	}
+
+	digits := strconv.FormatInt(int64(math.Abs(n)), 10)
+	if f.Zeros == OmitTrailingZeros {
+		digits = strings.Repeat("0", f.IntDigits+f.DecDigits-len(digits)) + digits
+		digits = strings.TrimRight(digits, "0")
+	}
+	if n < 0 {
+		return "-" + digits, nil
+	}
+	return digits, nil
+}
+
+// XY returns the X and Y coordinate data for a point in millimeters.
+func (f *Format) XY(pt Pt) (string, error) {
+	x, err := f.Coord(pt[0])
+	if err != nil {
+		return "", err
+	}
+	y, err := f.Coord(pt[1])
+	if err != nil {
+		return "", err
+	}
+	return "X" + x + "Y" + y, nil
+}
+
+// writeOperation writes a D01 (interpolate), D02 (move)
+// or D03 (flash) operation at the point.
+func (f *Format) writeOperation(w io.Writer, pt Pt, d int) error {
+	xy, err := f.XY(pt)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%vD%02d*\n", xy, d)
+	return nil
+}
+
+// writeArc writes a circular interpolation (D01) operation to the end
+// point with the offsets from the start point to the center.
+func (f *Format) writeArc(w io.Writer, end Pt, ij Pt) error {
+	xy, err := f.XY(end)
+	if err != nil {
+		return err
+	}
+	i, err := f.Coord(ij[0])
+	if err != nil {
+		return err
+	}
+	j, err := f.Coord(ij[1])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%vI%vJ%vD01*\n", xy, i, j)
+	return nil
+}
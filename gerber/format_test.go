@@ -0,0 +1,118 @@
+package gerber
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormat_Coord(t *testing.T) {
+	tests := []struct {
+		name    string
+		f       Format
+		v       float64
+		want    string
+		wantErr bool
+	}{
+		{name: "zero", f: DefaultFormat, v: 0, want: "0"},
+		{name: "positive", f: DefaultFormat, v: 1.5, want: "1500000"},
+		{name: "negative", f: DefaultFormat, v: -1, want: "-1000000"},
+		{name: "negative rounds away from zero", f: DefaultFormat, v: -0.0000006, want: "-1"},
+		{name: "negative rounds toward zero", f: DefaultFormat, v: -0.0000004, want: "0"},
+		{name: "max", f: DefaultFormat, v: 999.999999, want: "999999999"},
+		{name: "overflow", f: DefaultFormat, v: 1000, wantErr: true},
+		{name: "negative overflow", f: DefaultFormat, v: -1000, wantErr: true},
+		{
+			name: "inches",
+			f:    Format{Units: Inches, IntDigits: 2, DecDigits: 4},
+			v:    25.4,
+			want: "10000",
+		},
+		{
+			name: "trailing zeros",
+			f:    Format{IntDigits: 3, DecDigits: 6, Zeros: OmitTrailingZeros},
+			v:    1.5,
+			want: "0015",
+		},
+		{
+			name: "negative trailing zeros",
+			f:    Format{IntDigits: 3, DecDigits: 6, Zeros: OmitTrailingZeros},
+			v:    -12.34,
+			want: "-01234",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.f.Coord(tt.v)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Coord(%v) = %q, want error", tt.v, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Coord(%v): %v", tt.v, err)
+			}
+			if got != tt.want {
+				t.Errorf("Coord(%v) = %q, want %q", tt.v, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormat_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		f       Format
+		wantErr bool
+	}{
+		{name: "default", f: DefaultFormat},
+		{name: "zero value", f: Format{}, wantErr: true},
+		{name: "too many integer digits", f: Format{IntDigits: 7, DecDigits: 6}, wantErr: true},
+		{name: "too many decimal digits", f: Format{IntDigits: 3, DecDigits: 7}, wantErr: true},
+		{name: "inches", f: Format{Units: Inches, IntDigits: 2, DecDigits: 6}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.f.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLayer_WriteGerber_Format(t *testing.T) {
+	g := New("test")
+	g.Format = Format{Units: Inches, IntDigits: 2, DecDigits: 5, Zeros: OmitTrailingZeros}
+	top := g.TopCopper()
+	top.Add(Line(-25.4, 0, 12.7, 2.54, CircleShape, 0.254))
+
+	var buf strings.Builder
+	if err := top.WriteGerber(&buf); err != nil {
+		t.Fatalf("WriteGerber: %v", err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"%FSTAX25Y25*%\n",
+		"%MOIN*%\n",
+		"%ADD12C,0.01000*%\n",
+		"X-01Y0D02*\n",
+		"X005Y001D01*\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("WriteGerber =\n%v\nwant it to contain %q", got, want)
+		}
+	}
+}
+
+func TestLayer_WriteGerber_Overflow(t *testing.T) {
+	g := New("test")
+	top := g.TopCopper()
+	top.Add(Line(0, 0, 1000, 0, CircleShape, 0.1))
+
+	var buf strings.Builder
+	if err := top.WriteGerber(&buf); err == nil {
+		t.Errorf("WriteGerber = nil, want overflow error")
+	}
+}
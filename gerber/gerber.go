@@ -3,8 +3,11 @@ package gerber
 
 import (
 	"archive/zip"
+	"io"
 	"os"
+	"path/filepath"
 	"sync"
+	"time"
 )
 
 // Gerber represents the layers needed to build a PCB.
@@ -13,6 +16,19 @@ type Gerber struct {
 	FilenamePrefix string
 	// Layers represents the layers making up the Gerber design.
 	Layers []*Layer
+	// Format is the coordinate format and units of the Gerber files.
+	Format Format
+	// DrillFormat selects the file format of the drill layers.
+	DrillFormat DrillFormat
+	// DrillUnits selects the units of Excellon drill files.
+	DrillUnits Units
+	// CreationDate is written to the Gerber X2 file attributes.
+	// If zero, the time the files are written is used.
+	CreationDate time.Time
+	// Panel, if non-nil, step-and-repeats the design into a panel.
+	Panel *Panel
+	// Job holds the board specifications written to the Gerber job file.
+	Job JobSpecs
 
 	mu  sync.Mutex // protects mbb against multiple requests
 	mbb *MBB       // cached minimum bounding box
@@ -23,38 +39,109 @@ type Gerber struct {
 func New(filenamePrefix string) *Gerber {
 	return &Gerber{
 		FilenamePrefix: filenamePrefix,
+		Format:         DefaultFormat,
 	}
 }
 
-// WriteGerber writes all the Gerber layers to their respective files
-// then zips them all together into a ZIP file with the same prefix
-// for sending to PCB manufacturers.
+// WriteGerber writes all the Gerber layers and the Gerber job file
+// (.gbrjob) to their respective files then zips them all together
+// into a ZIP file with the same prefix for sending to PCB manufacturers.
 func (g *Gerber) WriteGerber() error {
-	zf, err := os.Create(g.FilenamePrefix + ".zip")
+	files, err := g.render()
 	if err != nil {
 		return err
 	}
-	zw := zip.NewWriter(zf)
-	for _, layer := range g.Layers {
-		f, err := zw.Create(layer.Filename)
-		if err != nil {
-			return err
-		}
-		if err := layer.WriteGerber(f); err != nil {
-			return err
-		}
-		w, err := os.Create(layer.Filename)
+	if err := writeZipFile(g.FilenamePrefix+".zip", files); err != nil {
+		return err
+	}
+	return writeDir(".", files)
+}
+
+// WriteZip writes a ZIP file containing all the Gerber layers to w.
+// No files are created.
+func (g *Gerber) WriteZip(w io.Writer) error {
+	files, err := g.render()
+	if err != nil {
+		return err
+	}
+	return writeZip(w, files)
+}
+
+// WriteDir writes all the Gerber layers to their respective files
+// in the directory, creating it if necessary.
+func (g *Gerber) WriteDir(dir string) error {
+	files, err := g.render()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return writeDir(dir, files)
+}
+
+// Files renders all the Gerber layers and returns their contents
+// keyed by filename, for example for tests or servers.
+// No files are created.
+func (g *Gerber) Files() (map[string][]byte, error) {
+	files, err := g.render()
+	if err != nil {
+		return nil, err
+	}
+	result := map[string][]byte{}
+	for _, f := range files {
+		result[f.name] = f.data
+	}
+	return result, nil
+}
+
+// file represents a rendered layer file.
+type file struct {
+	name string
+	data []byte
+}
+
+// render renders each layer exactly once, in order.
+func (g *Gerber) render() ([]file, error) {
+	return g.renderProfile(nil)
+}
+
+// writeZip writes the files as a ZIP archive to w.
+func writeZip(w io.Writer, files []file) error {
+	zw := zip.NewWriter(w)
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
 		if err != nil {
 			return err
 		}
-		if err := layer.WriteGerber(w); err != nil {
+		if _, err := fw.Write(f.data); err != nil {
 			return err
 		}
-		if err := w.Close(); err != nil {
+	}
+	return zw.Close()
+}
+
+// writeZipFile writes the files as a ZIP archive to the named file.
+func writeZipFile(name string, files []file) error {
+	zf, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := writeZip(zf, files); err != nil {
+		zf.Close()
+		return err
+	}
+	return zf.Close()
+}
+
+// writeDir writes the files to the directory.
+func writeDir(dir string, files []file) error {
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, f.name), f.data, 0644); err != nil {
 			return err
 		}
 	}
-	return zw.Close()
+	return nil
 }
 
 // MBB returns the minimum bounding box of the design in millimeters.
@@ -0,0 +1,133 @@
+package gerber
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testDesign() *Gerber {
+	g := New("test")
+	g.CreationDate = time.Date(2019, 6, 8, 19, 30, 0, 0, time.UTC)
+	g.TopCopper().Add(Line(0, 0, 1, 0, CircleShape, 0.1))
+	g.Drill().Add(Circle(Pt{0, 0}, 0.5))
+	g.Outline().Add(Line(0, 0, 1, 0, CircleShape, 0.1))
+	return g
+}
+
+func TestGerber_Files(t *testing.T) {
+	g := testDesign()
+	files, err := g.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(files), len(g.Layers)+1; got != want {
+		t.Fatalf("len(Files) = %v, want %v", got, want)
+	}
+	if _, ok := files["test.gbrjob"]; !ok {
+		t.Errorf("Files is missing the job file test.gbrjob")
+	}
+	for _, layer := range g.Layers {
+		var buf bytes.Buffer
+		if err := layer.WriteGerber(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(files[layer.Filename]), buf.String(); got != want {
+			t.Errorf("Files[%q] =\n%v\nwant:\n%v", layer.Filename, got, want)
+		}
+	}
+}
+
+func TestGerber_WriteZip(t *testing.T) {
+	g := testDesign()
+	var buf bytes.Buffer
+	if err := g.WriteZip(&buf); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := g.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(zr.File), len(g.Layers)+1; got != want {
+		t.Fatalf("got %v zipped files, want %v", got, want)
+	}
+	for i, zf := range zr.File {
+		want := "test.gbrjob"
+		if i < len(g.Layers) {
+			want = g.Layers[i].Filename
+		}
+		if got := zf.Name; got != want {
+			t.Errorf("zipped file %v = %q, want %q", i, got, want)
+		}
+		r, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, files[zf.Name]) {
+			t.Errorf("zipped file %q differs from Files", zf.Name)
+		}
+	}
+}
+
+func TestGerber_WriteDir(t *testing.T) {
+	g := testDesign()
+	dir := filepath.Join(t.TempDir(), "out")
+	if err := g.WriteDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(entries), len(g.Layers)+1; got != want {
+		t.Errorf("got %v files, want %v", got, want)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "test.gtl")); err != nil {
+		t.Error(err)
+	}
+}
+
+type failingWriter struct{}
+
+var errWrite = errors.New("write failed")
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errWrite
+}
+
+func TestGerber_WriteErrors(t *testing.T) {
+	g := testDesign()
+	if err := g.WriteZip(failingWriter{}); !errors.Is(err, errWrite) {
+		t.Errorf("WriteZip = %v, want %v", err, errWrite)
+	}
+	for _, layer := range g.Layers {
+		if err := layer.WriteGerber(failingWriter{}); !errors.Is(err, errWrite) {
+			t.Errorf("%v: WriteGerber = %v, want %v", layer.Filename, err, errWrite)
+		}
+	}
+}
+
+func TestLayer_WriteGerber_MissingAperture(t *testing.T) {
+	g := New("test")
+	layer := g.TopCopper()
+	// Appending bypasses Add, so the aperture is not in the table.
+	layer.Primitives = append(layer.Primitives, Line(0, 0, 1, 1, CircleShape, 0.3))
+	var buf bytes.Buffer
+	if err := layer.WriteGerber(&buf); err == nil || !strings.Contains(err.Error(), "not in the aperture table") {
+		t.Errorf("WriteGerber = %v, want aperture table error", err)
+	}
+}
@@ -0,0 +1,350 @@
+package gerber
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"math"
+)
+
+// ImageOpts provides options for converting a raster image
+// into regions.
+type ImageOpts struct {
+	// Threshold is the luminance from 0 (black) to 1 (white) below
+	// which a cell of the image is drawn. Zero means 0.5.
+	Threshold float64
+	// Dither diffuses the error of the threshold to the neighboring
+	// cells (Floyd-Steinberg), so photos become halftones.
+	Dither bool
+	// Invert draws the light cells instead of the dark ones.
+	Invert bool
+	// MinFeature is the minimum size of the cells the image is
+	// resampled to in millimeters, so that the drawn features and the
+	// gaps between them are at least this wide (except where cells
+	// touch at a corner). Zero keeps one cell per pixel.
+	MinFeature float64
+}
+
+// ImageT represents a raster image traced into regions and
+// satisfies the Primitive interface.
+type ImageT struct {
+	// Regions are the traced areas of the image, one per group of
+	// cells connected by their edges. Cells only touching at a corner
+	// are separated by a tiny notch so that no contour touches itself.
+	Regions []*RegionT
+	Attributes
+	mbb *MBB // cached minimum bounding box
+}
+
+// Image returns a primitive that draws the image centered on center,
+// scaled to fit in width by height millimeters and keeping its aspect
+// ratio. A zero width or height does not constrain the size.
+// Transparent pixels are treated as white. An image with nothing to
+// draw is an error. opts may be nil.
+// All dimensions are in millimeters.
+func Image(img image.Image, center Pt, width, height float64, opts *ImageOpts) (*ImageT, error) {
+	if opts == nil {
+		opts = &ImageOpts{}
+	}
+	bounds := img.Bounds()
+	w0, h0 := float64(bounds.Dx()), float64(bounds.Dy())
+	if w0 == 0 || h0 == 0 {
+		return nil, fmt.Errorf("image is empty")
+	}
+	if width <= 0 && height <= 0 {
+		return nil, fmt.Errorf("image width and height are zero")
+	}
+	scale := math.Inf(1) // millimeters per pixel
+	if width > 0 {
+		scale = width / w0
+	}
+	if height > 0 {
+		scale = math.Min(scale, height/h0)
+	}
+
+	cell := math.Max(scale, opts.MinFeature)
+	cols := max(1, int(w0*scale/cell+1e-9))
+	rows := max(1, int(h0*scale/cell+1e-9))
+	g := &imageGrid{
+		cols:   cols,
+		rows:   rows,
+		cellW:  w0 * scale / float64(cols),
+		cellH:  h0 * scale / float64(rows),
+		origin: Pt{center[0] - 0.5*w0*scale, center[1] - 0.5*h0*scale},
+	}
+	g.sample(img, opts)
+
+	t := &ImageT{}
+	for _, c := range g.trace() {
+		t.Regions = append(t.Regions, Region(c.outer, c.holes...))
+	}
+	if len(t.Regions) == 0 {
+		if opts.Invert {
+			return nil, fmt.Errorf("image has no light cells")
+		}
+		return nil, fmt.Errorf("image has no dark cells")
+	}
+	return t, nil
+}
+
+// WriteGerber writes the primitive to the Gerber file.
+func (t *ImageT) WriteGerber(w io.Writer, f *Format, apertureIndex int) error {
+	for _, r := range t.Regions {
+		if err := r.WriteGerber(w, f, apertureIndex); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Aperture returns nil for ImageT because its regions use
+// the default aperture.
+func (t *ImageT) Aperture() *Aperture {
+	return nil
+}
+
+func (t *ImageT) MBB() MBB {
+	if t.mbb != nil {
+		return *t.mbb
+	}
+	for i, r := range t.Regions {
+		v := r.MBB()
+		if i == 0 {
+			t.mbb = &v
+			continue
+		}
+		t.mbb.Join(&v)
+	}
+	if t.mbb == nil {
+		t.mbb = &MBB{}
+	}
+	return *t.mbb
+}
+
+// imageGrid is the image resampled to cells. Cell (x, y) covers
+// [x, x+1] by [y, y+1] in grid units with y pointing up, and
+// vertex (i, j) is the corner at (i, j).
+type imageGrid struct {
+	cols, rows   int
+	cellW, cellH float64 // in millimeters
+	origin       Pt      // lower left corner in millimeters
+	dark         []bool
+}
+
+// sample sets the dark cells from the average luminance of the
+// pixels whose centers are in each cell.
+func (g *imageGrid) sample(img image.Image, opts *ImageOpts) {
+	bounds := img.Bounds()
+	sums := make([]float64, g.cols*g.rows)
+	counts := make([]int, g.cols*g.rows)
+	for py := bounds.Min.Y; py < bounds.Max.Y; py++ {
+		// Image rows point down.
+		y := g.rows - 1 - int((float64(py-bounds.Min.Y)+0.5)*float64(g.rows)/float64(bounds.Dy()))
+		for px := bounds.Min.X; px < bounds.Max.X; px++ {
+			x := int((float64(px-bounds.Min.X) + 0.5) * float64(g.cols) / float64(bounds.Dx()))
+			r, gr, b, a := img.At(px, py).RGBA()
+			// Composite the premultiplied color over white.
+			lum := (0.299*float64(r)+0.587*float64(gr)+0.114*float64(b))/0xffff + 1 - float64(a)/0xffff
+			sums[y*g.cols+x] += lum
+			counts[y*g.cols+x]++
+		}
+	}
+
+	threshold := opts.Threshold
+	if threshold <= 0 {
+		threshold = 0.5
+	}
+	g.dark = make([]bool, g.cols*g.rows)
+	for i := range sums {
+		if counts[i] > 0 {
+			sums[i] /= float64(counts[i])
+		}
+	}
+	// Dither from the top row down, as the image is read.
+	for y := g.rows - 1; y >= 0; y-- {
+		for x := 0; x < g.cols; x++ {
+			i := y*g.cols + x
+			lum := sums[i]
+			dark := lum < threshold
+			g.dark[i] = dark != opts.Invert
+			if !opts.Dither {
+				continue
+			}
+			e := lum
+			if !dark {
+				e = lum - 1
+			}
+			diffuse := func(dx, dy int, weight float64) {
+				if x+dx >= 0 && x+dx < g.cols && y-dy >= 0 {
+					sums[(y-dy)*g.cols+x+dx] += e * weight
+				}
+			}
+			diffuse(1, 0, 7.0/16)
+			diffuse(-1, 1, 3.0/16)
+			diffuse(0, 1, 5.0/16)
+			diffuse(1, 1, 1.0/16)
+		}
+	}
+}
+
+// isDark reports whether the cell is dark. Cells outside
+// the grid are light.
+func (g *imageGrid) isDark(x, y int) bool {
+	return x >= 0 && x < g.cols && y >= 0 && y < g.rows && g.dark[y*g.cols+x]
+}
+
+// Directions of the boundary edges, counterclockwise.
+const (
+	dirEast = iota
+	dirNorth
+	dirWest
+	dirSouth
+)
+
+var imageSteps = [4][2]int{{1, 0}, {0, 1}, {-1, 0}, {0, -1}}
+
+// imageComponent is the outline of a group of dark cells
+// connected by their edges.
+type imageComponent struct {
+	outer Contour
+	holes []Contour
+}
+
+// trace returns the outlines of the groups of dark cells. Outlines
+// run counterclockwise around dark cells and clockwise around holes,
+// along the boundary edges that have a dark cell on their left.
+func (g *imageGrid) trace() []*imageComponent {
+	// Label the groups of dark cells in scanning order.
+	labels := make([]int, g.cols*g.rows)
+	var components []*imageComponent
+	for i, dark := range g.dark {
+		if !dark || labels[i] != 0 {
+			continue
+		}
+		components = append(components, &imageComponent{})
+		label := len(components)
+		labels[i] = label
+		stack := []int{i}
+		for len(stack) > 0 {
+			c := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := c%g.cols, c/g.cols
+			for _, s := range imageSteps {
+				if g.isDark(x+s[0], y+s[1]) && labels[c+s[1]*g.cols+s[0]] == 0 {
+					labels[c+s[1]*g.cols+s[0]] = label
+					stack = append(stack, c+s[1]*g.cols+s[0])
+				}
+			}
+		}
+	}
+
+	// Find the boundary edges leaving each vertex.
+	vcols := g.cols + 1
+	out := make([]uint8, vcols*(g.rows+1))
+	for y := 0; y < g.rows; y++ {
+		for x := 0; x < g.cols; x++ {
+			if !g.dark[y*g.cols+x] {
+				continue
+			}
+			if !g.isDark(x, y-1) {
+				out[y*vcols+x] |= 1 << dirEast
+			}
+			if !g.isDark(x+1, y) {
+				out[y*vcols+x+1] |= 1 << dirNorth
+			}
+			if !g.isDark(x, y+1) {
+				out[(y+1)*vcols+x+1] |= 1 << dirWest
+			}
+			if !g.isDark(x-1, y) {
+				out[(y+1)*vcols+x] |= 1 << dirSouth
+			}
+		}
+	}
+
+	// The notch at corners where two dark cells touch diagonally.
+	notch := 0.01 * math.Min(g.cellW, g.cellH)
+	for v0 := range out {
+		for out[v0] != 0 {
+			d0 := 0
+			for out[v0]&(1<<d0) == 0 {
+				d0++
+			}
+			i0, j0 := v0%vcols, v0/vcols
+			// The dark cell on the left of the first edge.
+			cx, cy := i0, j0
+			switch d0 {
+			case dirNorth:
+				cx--
+			case dirWest:
+				cx, cy = cx-1, cy-1
+			case dirSouth:
+				cy--
+			}
+			component := components[labels[cy*g.cols+cx]-1]
+
+			var pts []Pt
+			v, d := v0, d0
+			for {
+				out[v] &^= 1 << d
+				v += imageSteps[d][1]*vcols + imageSteps[d][0]
+				// Turn left at the corners where two dark cells touch,
+				// so that they stay apart.
+				nd := -1
+				for _, turn := range []int{1, 0, 3} {
+					if c := (d + turn) % 4; out[v]&(1<<c) != 0 || v == v0 && c == d0 {
+						nd = c
+						break
+					}
+				}
+				if nd != d {
+					pt := g.point(v)
+					if g.saddle(v) {
+						// Cut the corner of the dark cell.
+						pts = append(pts,
+							Pt{pt[0] - notch*float64(imageSteps[d][0]), pt[1] - notch*float64(imageSteps[d][1])},
+							Pt{pt[0] + notch*float64(imageSteps[nd][0]), pt[1] + notch*float64(imageSteps[nd][1])})
+					} else {
+						pts = append(pts, pt)
+					}
+				}
+				if v == v0 && nd == d0 {
+					break
+				}
+				d = nd
+			}
+
+			contour := PolygonContour(pts)
+			if imageArea(pts) > 0 {
+				component.outer = contour
+			} else {
+				component.holes = append(component.holes, contour)
+			}
+		}
+	}
+	return components
+}
+
+// saddle reports whether two dark cells touch diagonally
+// at the vertex.
+func (g *imageGrid) saddle(v int) bool {
+	i, j := v%(g.cols+1), v/(g.cols+1)
+	ne, nw, se, sw := g.isDark(i, j), g.isDark(i-1, j), g.isDark(i, j-1), g.isDark(i-1, j-1)
+	return ne == sw && nw == se && ne != nw
+}
+
+// point returns the position of a vertex in millimeters.
+func (g *imageGrid) point(v int) Pt {
+	i, j := v%(g.cols+1), v/(g.cols+1)
+	return Pt{g.origin[0] + float64(i)*g.cellW, g.origin[1] + float64(j)*g.cellH}
+}
+
+// imageArea returns the signed area of a polygon, positive if
+// counterclockwise.
+func imageArea(pts []Pt) float64 {
+	var area float64
+	for i, a := range pts {
+		b := pts[(i+1)%len(pts)]
+		area += a[0]*b[1] - b[0]*a[1]
+	}
+	return 0.5 * area
+}
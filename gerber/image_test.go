@@ -0,0 +1,297 @@
+package gerber
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// testImage returns an image with black pixels where rows have '#'.
+func testImage(rows ...string) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, len(rows[0]), len(rows)))
+	for y, row := range rows {
+		for x, c := range row {
+			if c != '#' {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	return img
+}
+
+// imageTArea returns the area covered by the regions of the image.
+func imageTArea(t *ImageT) float64 {
+	var area float64
+	for _, r := range t.Regions {
+		area += imageArea(r.Outer.Points())
+		for _, h := range r.Holes {
+			area += imageArea(h.Points())
+		}
+	}
+	return area
+}
+
+func TestImage(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    []string
+		regions int
+		holes   int
+		points  int // total number of contour points
+		area    float64
+	}{
+		{
+			name:    "pixel",
+			rows:    []string{"#"},
+			regions: 1,
+			points:  4,
+			area:    1,
+		},
+		{
+			name:    "ring",
+			rows:    []string{"###", "#.#", "###"},
+			regions: 1,
+			holes:   1,
+			points:  8,
+			area:    8,
+		},
+		{
+			name:    "island in a hole",
+			rows:    []string{"#####", "#...#", "#.#.#", "#...#", "#####"},
+			regions: 2,
+			holes:   1,
+			points:  12,
+			area:    17,
+		},
+		{
+			name:    "diagonal pixels",
+			rows:    []string{".#", "#."},
+			regions: 2,
+			points:  10,
+			area:    2,
+		},
+		{
+			// The ends of the C touch at a corner.
+			name:    "closed C",
+			rows:    []string{"###", "#.#", "##."},
+			regions: 1,
+			points:  12,
+			area:    7,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := Image(testImage(tt.rows...), Pt{10, 10}, float64(len(tt.rows[0])), 0, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var holes, points int
+			for _, r := range img.Regions {
+				holes += len(r.Holes)
+				points += len(r.Outer.Segments) + 1
+				for _, h := range r.Holes {
+					points += len(h.Segments) + 1
+				}
+			}
+			if len(img.Regions) != tt.regions || holes != tt.holes || points != tt.points {
+				t.Errorf("got %v regions with %v holes and %v points, want %v, %v and %v", len(img.Regions), holes, points, tt.regions, tt.holes, tt.points)
+			}
+			if got := imageTArea(img); math.Abs(got-tt.area) > 1e-3 {
+				t.Errorf("area = %v, want %v", got, tt.area)
+			}
+		})
+	}
+}
+
+func TestImage_Size(t *testing.T) {
+	src := testImage("####", "####")
+	tests := []struct {
+		name          string
+		width, height float64
+		opts          *ImageOpts
+		want          MBB
+		regions       int
+	}{
+		{
+			name:    "fit to width",
+			width:   8,
+			want:    MBB{Min: Pt{-4, -2}, Max: Pt{4, 2}},
+			regions: 1,
+		},
+		{
+			name:    "fit to height",
+			width:   8,
+			height:  1,
+			want:    MBB{Min: Pt{-1, -0.5}, Max: Pt{1, 0.5}},
+			regions: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := Image(src, Pt{}, tt.width, tt.height, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(img.Regions) != tt.regions {
+				t.Errorf("got %v regions, want %v", len(img.Regions), tt.regions)
+			}
+			if got := img.MBB(); got != tt.want {
+				t.Errorf("MBB = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImage_Options(t *testing.T) {
+	// A horizontal gradient from black to white.
+	src := image.NewGray(image.Rect(0, 0, 100, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 100; x++ {
+			src.SetGray(x, y, color.Gray{Y: uint8(x * 255 / 99)})
+		}
+	}
+	tests := []struct {
+		name string
+		opts *ImageOpts
+		want float64 // drawn area in mm²
+	}{
+		{name: "threshold", opts: nil, want: 50 * 20},
+		{name: "low threshold", opts: &ImageOpts{Threshold: 0.25}, want: 25 * 20},
+		{name: "invert", opts: &ImageOpts{Threshold: 0.25, Invert: true}, want: 75 * 20},
+		{name: "dither", opts: &ImageOpts{Dither: true}, want: 50 * 20},
+		// The cell of the pixels 48 to 51 averages to just under 0.5.
+		{name: "min feature", opts: &ImageOpts{MinFeature: 4}, want: 52 * 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, err := Image(src, Pt{}, 100, 20, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := imageTArea(img); math.Abs(got-tt.want) > 0.03*tt.want {
+				t.Errorf("area = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Dithering draws isolated dots in the middle tones.
+	plain, err := Image(src, Pt{}, 100, 20, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dithered, err := Image(src, Pt{}, 100, 20, &ImageOpts{Dither: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plain.Regions) != 1 || len(dithered.Regions) < 100 {
+		t.Errorf("got %v plain and %v dithered regions, want 1 and at least 100", len(plain.Regions), len(dithered.Regions))
+	}
+
+	// Cells of the minimum feature size.
+	img, err := Image(src, Pt{}, 100, 20, &ImageOpts{MinFeature: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pt := range img.Regions[0].Outer.Points() {
+		if x := pt[0] + 50; math.Abs(x/(100.0/33)-math.Round(x/(100.0/33))) > 1e-9 {
+			t.Errorf("point %v is not on the grid of %v cells", pt, 100.0/33)
+		}
+	}
+}
+
+func TestImage_SimpleContours(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 40, 30))
+	r := rand.New(rand.NewSource(1))
+	for i := range src.Pix {
+		src.Pix[i] = uint8(r.Intn(256))
+	}
+	img, err := Image(src, Pt{}, 40, 0, &ImageOpts{Dither: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dark int
+	for y := 0; y < 30; y++ {
+		for x := 0; x < 40; x++ {
+			// Count the cells drawn by testing the centers of the pixels.
+			pt := Pt{float64(x) - 19.5, 14.5 - float64(y)}
+			var inside int
+			for _, region := range img.Regions {
+				for _, c := range append([]Contour{region.Outer}, region.Holes...) {
+					inside += svgWinding(pt, c.Points())
+				}
+			}
+			if inside != 0 && inside != 1 {
+				t.Fatalf("winding number at %v = %v", pt, inside)
+			}
+			dark += inside
+		}
+	}
+	if got, want := imageTArea(img), float64(dark); math.Abs(got-want) > 0.01*want {
+		t.Errorf("area = %v, want %v", got, want)
+	}
+
+	seen := map[Pt]bool{}
+	for _, region := range img.Regions {
+		for _, c := range append([]Contour{region.Outer}, region.Holes...) {
+			for _, pt := range c.Points()[1:] {
+				if seen[pt] {
+					t.Fatalf("contours touch at %v", pt)
+				}
+				seen[pt] = true
+			}
+		}
+	}
+
+	layer := New("random").TopCopper()
+	layer.Add(img)
+	if err := layer.WriteGerber(io.Discard); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestImage_WriteGerber(t *testing.T) {
+	g := New("image")
+	layer := g.TopSilkscreen()
+	img, err := Image(testImage("###", "#.#", "###"), Pt{1.5, 1.5}, 3, 3, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer.Add(img)
+
+	var buf bytes.Buffer
+	if err := layer.WriteGerber(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(buf.String(), "G36*"); got != 1 {
+		t.Errorf("got %v regions, want 1:\n%v", got, buf.String())
+	}
+
+	parsed, err := ParseGerber(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := parsed.MBB(), (MBB{Max: Pt{3, 3}}); got != want {
+		t.Errorf("parsed MBB = %v, want %v", got, want)
+	}
+}
+
+func TestImage_Errors(t *testing.T) {
+	if _, err := Image(image.NewGray(image.Rect(0, 0, 0, 0)), Pt{}, 1, 1, nil); err == nil || err.Error() != "image is empty" {
+		t.Errorf("empty image error = %v", err)
+	}
+	if _, err := Image(testImage("#"), Pt{}, 0, 0, nil); err == nil || err.Error() != "image width and height are zero" {
+		t.Errorf("zero size error = %v", err)
+	}
+	if _, err := Image(testImage("..", ".."), Pt{}, 1, 1, nil); err == nil || err.Error() != "image has no dark cells" {
+		t.Errorf("blank image error = %v", err)
+	}
+	if _, err := Image(testImage("##"), Pt{}, 1, 1, &ImageOpts{Invert: true}); err == nil || err.Error() != "image has no light cells" {
+		t.Errorf("inverted dark image error = %v", err)
+	}
+}
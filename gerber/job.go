@@ -0,0 +1,259 @@
+package gerber
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+)
+
+// Finish represents the surface finish of a board,
+// as written to the Gerber job file.
+type Finish string
+
+const (
+	// FinishNone is bare copper.
+	FinishNone Finish = "None"
+	// FinishHASL is tin-lead hot air solder leveling.
+	FinishHASL Finish = "HAL SnPb"
+	// FinishLeadFreeHASL is lead-free hot air solder leveling.
+	FinishLeadFreeHASL Finish = "HAL lead-free"
+	// FinishENIG is electroless nickel immersion gold.
+	FinishENIG Finish = "ENIG"
+	// FinishENEPIG is electroless nickel electroless palladium immersion gold.
+	FinishENEPIG Finish = "ENEPIG"
+	// FinishOSP is an organic solderability preservative.
+	FinishOSP Finish = "OSP"
+	// FinishImmersionSilver is immersion silver.
+	FinishImmersionSilver Finish = "Immersion silver"
+	// FinishImmersionTin is immersion tin.
+	FinishImmersionTin Finish = "Immersion tin"
+)
+
+const (
+	defaultBoardThickness      = 1.6
+	defaultCopperThickness     = 0.035
+	defaultSolderMaskThickness = 0.01
+	defaultDielectricMaterial  = "FR4"
+	defaultFinish              = FinishLeadFreeHASL
+)
+
+// JobSpecs represents the board specifications written to the
+// Gerber job file. All dimensions are in millimeters and zero
+// values select the defaults.
+type JobSpecs struct {
+	// Finish is the surface finish (default FinishLeadFreeHASL).
+	Finish Finish
+	// BoardThickness is the thickness of the finished board (default 1.6mm).
+	BoardThickness float64
+	// CopperThickness is the thickness of each copper layer (default 0.035mm).
+	CopperThickness float64
+	// DielectricMaterial is the material between the copper layers
+	// (default "FR4").
+	DielectricMaterial string
+	// Revision is the optional revision of the design.
+	Revision string
+}
+
+// jobFile is the JSON Gerber job file, per the Ucamco specification.
+type jobFile struct {
+	Header          jobHeader         `json:"Header"`
+	GeneralSpecs    jobGeneralSpecs   `json:"GeneralSpecs"`
+	FilesAttributes []jobFileAttrs    `json:"FilesAttributes"`
+	MaterialStackup []jobStackupLayer `json:"MaterialStackup"`
+}
+
+type jobHeader struct {
+	GenerationSoftware struct {
+		Vendor      string `json:"Vendor"`
+		Application string `json:"Application"`
+	} `json:"GenerationSoftware"`
+	CreationDate string `json:"CreationDate"`
+}
+
+type jobGeneralSpecs struct {
+	ProjectID struct {
+		Name     string `json:"Name"`
+		GUID     string `json:"GUID"`
+		Revision string `json:"Revision,omitempty"`
+	} `json:"ProjectId"`
+	Size struct {
+		X float64 `json:"X"`
+		Y float64 `json:"Y"`
+	} `json:"Size"`
+	LayerNumber    int     `json:"LayerNumber"`
+	BoardThickness float64 `json:"BoardThickness"`
+	Finish         Finish  `json:"Finish"`
+}
+
+type jobFileAttrs struct {
+	Path         string `json:"Path"`
+	FileFunction string `json:"FileFunction"`
+	FilePolarity string `json:"FilePolarity"`
+}
+
+type jobStackupLayer struct {
+	Type      string  `json:"Type"`
+	Thickness float64 `json:"Thickness,omitempty"`
+	Material  string  `json:"Material,omitempty"`
+	Name      string  `json:"Name"`
+}
+
+// renderJob renders the Gerber job file describing the layer files.
+// The paths of the files are relative to the job file.
+func (g *Gerber) renderJob(name string, layers []*Layer, files []file) ([]byte, error) {
+	specs := g.jobSpecs()
+
+	var job jobFile
+	job.Header.GenerationSoftware.Vendor = "gmlewis"
+	job.Header.GenerationSoftware.Application = "go-gerber"
+	job.Header.CreationDate = g.creationDate().Format(x2DateFormat)
+
+	project := filepath.Base(g.FilenamePrefix)
+	sum := md5.Sum([]byte(project))
+	job.GeneralSpecs.ProjectID.Name = project
+	job.GeneralSpecs.ProjectID.GUID = fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+	job.GeneralSpecs.ProjectID.Revision = specs.Revision
+	size := g.boardSize()
+	job.GeneralSpecs.Size.X = roundMM(size[0])
+	job.GeneralSpecs.Size.Y = roundMM(size[1])
+	job.GeneralSpecs.BoardThickness = specs.BoardThickness
+	job.GeneralSpecs.Finish = specs.Finish
+
+	dir := filepath.Dir(name)
+	for i, f := range files {
+		path, err := filepath.Rel(dir, f.name)
+		if err != nil {
+			path = f.name
+		}
+		job.FilesAttributes = append(job.FilesAttributes, jobFileAttrs{
+			Path:         filepath.ToSlash(path),
+			FileFunction: layers[i].FileFunction(),
+			FilePolarity: layers[i].FilePolarity(),
+		})
+	}
+
+	job.MaterialStackup = g.stackup(&specs)
+	for _, layer := range job.MaterialStackup {
+		if layer.Type == "Copper" {
+			job.GeneralSpecs.LayerNumber++
+		}
+	}
+
+	data, err := json.MarshalIndent(&job, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// jobSpecs returns the board specifications with the defaults filled in.
+func (g *Gerber) jobSpecs() JobSpecs {
+	specs := g.Job
+	if specs.Finish == "" {
+		specs.Finish = defaultFinish
+	}
+	if specs.BoardThickness <= 0 {
+		specs.BoardThickness = defaultBoardThickness
+	}
+	if specs.CopperThickness <= 0 {
+		specs.CopperThickness = defaultCopperThickness
+	}
+	if specs.DielectricMaterial == "" {
+		specs.DielectricMaterial = defaultDielectricMaterial
+	}
+	return specs
+}
+
+// boardSize returns the size of the finished board, or of the
+// whole panel if the design is panelized.
+func (g *Gerber) boardSize() Pt {
+	if len(g.Layers) == 0 {
+		return Pt{}
+	}
+	mbb := g.MBB()
+	size := Pt{mbb.Max[0] - mbb.Min[0], mbb.Max[1] - mbb.Min[1]}
+	if p := g.Panel; p != nil {
+		size[0] += float64(p.XRepeat-1) * p.XStep
+		size[1] += float64(p.YRepeat-1)*p.YStep + 2*p.RailWidth
+	}
+	return size
+}
+
+// stackup returns the material stackup of the design from top to bottom.
+func (g *Gerber) stackup(specs *JobSpecs) []jobStackupLayer {
+	has := map[LayerType]bool{}
+	var inner []int
+	for _, layer := range g.Layers {
+		has[layer.Type] = true
+		if layer.Type == LayerInnerCopper {
+			inner = append(inner, layer.N)
+		}
+	}
+	sort.Ints(inner)
+
+	var coppers []string
+	if has[LayerTopCopper] {
+		coppers = append(coppers, "Top Copper")
+	}
+	for _, n := range inner {
+		coppers = append(coppers, fmt.Sprintf("Inner Copper L%v", n))
+	}
+	if has[LayerBottomCopper] {
+		coppers = append(coppers, "Bottom Copper")
+	}
+
+	var masks int
+	if has[LayerTopSolderMask] {
+		masks++
+	}
+	if has[LayerBottomSolderMask] {
+		masks++
+	}
+	dielectric := specs.BoardThickness - float64(len(coppers))*specs.CopperThickness - float64(masks)*defaultSolderMaskThickness
+	if len(coppers) > 1 {
+		dielectric /= float64(len(coppers) - 1)
+	}
+
+	var result []jobStackupLayer
+	if has[LayerTopSilkscreen] {
+		result = append(result, jobStackupLayer{Type: "Legend", Name: "Top Silkscreen"})
+	}
+	if has[LayerTopSolderMask] {
+		result = append(result, jobStackupLayer{Type: "SolderMask", Thickness: defaultSolderMaskThickness, Name: "Top Solder Mask"})
+	}
+	for i, name := range coppers {
+		if i > 0 {
+			result = append(result, jobStackupLayer{
+				Type:      "Dielectric",
+				Thickness: roundMM(dielectric),
+				Material:  specs.DielectricMaterial,
+				Name:      fmt.Sprintf("Dielectric %v", i),
+			})
+		}
+		result = append(result, jobStackupLayer{Type: "Copper", Thickness: specs.CopperThickness, Name: name})
+	}
+	if len(coppers) == 1 {
+		result = append(result, jobStackupLayer{
+			Type:      "Dielectric",
+			Thickness: roundMM(dielectric),
+			Material:  specs.DielectricMaterial,
+			Name:      "Dielectric 1",
+		})
+	}
+	if has[LayerBottomSolderMask] {
+		result = append(result, jobStackupLayer{Type: "SolderMask", Thickness: defaultSolderMaskThickness, Name: "Bottom Solder Mask"})
+	}
+	if has[LayerBottomSilkscreen] {
+		result = append(result, jobStackupLayer{Type: "Legend", Name: "Bottom Silkscreen"})
+	}
+	return result
+}
+
+// roundMM rounds a dimension to the nanometer to hide
+// floating point noise in the job file.
+func roundMM(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
@@ -0,0 +1,112 @@
+package gerber
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func jobDesign() *Gerber {
+	g := New("out/board")
+	g.CreationDate = time.Date(2019, 6, 8, 19, 30, 0, 0, time.UTC)
+	g.TopCopper()
+	g.TopSolderMask()
+	g.TopSilkscreen()
+	g.LayerN(3)
+	g.LayerN(2)
+	g.BottomCopper()
+	g.BottomSolderMask()
+	g.Drill()
+	g.Outline().Add(rectangle(MBB{Max: Pt{10, 5}}, func(p1, p2 Pt) *LineT {
+		return Line(p1[0], p1[1], p2[0], p2[1], CircleShape, 0.1)
+	})...)
+	return g
+}
+
+func renderTestJob(t *testing.T, g *Gerber) *jobFile {
+	t.Helper()
+	files, err := g.Files()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, ok := files["out/board.gbrjob"]
+	if !ok {
+		t.Fatalf("missing job file out/board.gbrjob")
+	}
+	var job jobFile
+	if err := json.Unmarshal(data, &job); err != nil {
+		t.Fatal(err)
+	}
+	return &job
+}
+
+func TestGerber_Job(t *testing.T) {
+	g := jobDesign()
+	g.Job.Finish = FinishENIG
+	job := renderTestJob(t, g)
+
+	if got, want := job.Header.CreationDate, "2019-06-08T19:30:00+00:00"; got != want {
+		t.Errorf("CreationDate = %v, want %v", got, want)
+	}
+	specs := job.GeneralSpecs
+	if got, want := specs.ProjectID.Name, "board"; got != want {
+		t.Errorf("ProjectId.Name = %v, want %v", got, want)
+	}
+	if specs.Size.X != 10.1 || specs.Size.Y != 5.1 {
+		t.Errorf("Size = %v x %v, want 10.1 x 5.1", specs.Size.X, specs.Size.Y)
+	}
+	if got, want := specs.LayerNumber, 4; got != want {
+		t.Errorf("LayerNumber = %v, want %v", got, want)
+	}
+	if got, want := specs.BoardThickness, 1.6; got != want {
+		t.Errorf("BoardThickness = %v, want %v", got, want)
+	}
+	if got, want := specs.Finish, FinishENIG; got != want {
+		t.Errorf("Finish = %v, want %v", got, want)
+	}
+
+	if got, want := len(job.FilesAttributes), len(g.Layers); got != want {
+		t.Fatalf("len(FilesAttributes) = %v, want %v", got, want)
+	}
+	for i, attrs := range job.FilesAttributes {
+		layer := g.Layers[i]
+		want := jobFileAttrs{
+			Path:         layer.Filename[len("out/"):],
+			FileFunction: layer.FileFunction(),
+			FilePolarity: layer.FilePolarity(),
+		}
+		if attrs != want {
+			t.Errorf("FilesAttributes[%v] = %+v, want %+v", i, attrs, want)
+		}
+	}
+
+	wantStackup := []jobStackupLayer{
+		{Type: "Legend", Name: "Top Silkscreen"},
+		{Type: "SolderMask", Thickness: 0.01, Name: "Top Solder Mask"},
+		{Type: "Copper", Thickness: 0.035, Name: "Top Copper"},
+		{Type: "Dielectric", Thickness: 0.48, Material: "FR4", Name: "Dielectric 1"},
+		{Type: "Copper", Thickness: 0.035, Name: "Inner Copper L2"},
+		{Type: "Dielectric", Thickness: 0.48, Material: "FR4", Name: "Dielectric 2"},
+		{Type: "Copper", Thickness: 0.035, Name: "Inner Copper L3"},
+		{Type: "Dielectric", Thickness: 0.48, Material: "FR4", Name: "Dielectric 3"},
+		{Type: "Copper", Thickness: 0.035, Name: "Bottom Copper"},
+		{Type: "SolderMask", Thickness: 0.01, Name: "Bottom Solder Mask"},
+	}
+	if got, want := len(job.MaterialStackup), len(wantStackup); got != want {
+		t.Fatalf("len(MaterialStackup) = %v, want %v", got, want)
+	}
+	for i, layer := range job.MaterialStackup {
+		if layer != wantStackup[i] {
+			t.Errorf("MaterialStackup[%v] = %+v, want %+v", i, layer, wantStackup[i])
+		}
+	}
+}
+
+func TestGerber_Job_Panel(t *testing.T) {
+	g := jobDesign()
+	g.Panel = &Panel{XRepeat: 3, YRepeat: 2, XStep: 12, YStep: 7, Separation: MouseBites, RailWidth: 5}
+	specs := renderTestJob(t, g).GeneralSpecs
+	if specs.Size.X != 34.1 || specs.Size.Y != 22.1 {
+		t.Errorf("Size = %v x %v, want 34.1 x 22.1", specs.Size.X, specs.Size.Y)
+	}
+}
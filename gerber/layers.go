@@ -1,15 +1,46 @@
 package gerber
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"log"
 )
 
+// LayerType represents the function of a layer in the design.
+type LayerType int
+
+const (
+	// LayerTopCopper is the top copper layer.
+	LayerTopCopper LayerType = iota
+	// LayerTopSolderMask is the top solder mask layer.
+	LayerTopSolderMask
+	// LayerTopSilkscreen is the top silkscreen layer.
+	LayerTopSilkscreen
+	// LayerBottomCopper is the bottom copper layer.
+	LayerBottomCopper
+	// LayerBottomSolderMask is the bottom solder mask layer.
+	LayerBottomSolderMask
+	// LayerBottomSilkscreen is the bottom silkscreen layer.
+	LayerBottomSilkscreen
+	// LayerInnerCopper is an inner copper layer of a multi-layer design.
+	LayerInnerCopper
+	// LayerDrill is the drill layer.
+	LayerDrill
+	// LayerOutline is the board outline (profile) layer.
+	LayerOutline
+	// LayerNonPlatedDrill is the non-plated drill layer.
+	LayerNonPlatedDrill
+)
+
 // Layer represents a printed circuit board layer.
 type Layer struct {
 	// Filename is the filename of the Gerber layer.
 	Filename string
+	// Type is the function of the layer in the design.
+	Type LayerType
+	// N is the copper layer number of an inner copper layer.
+	N int
 	// Primitives represents the collection of primitives.
 	Primitives []Primitive
 	// Apertures represents the apertures used in the layer.
@@ -25,41 +56,245 @@ type Layer struct {
 // Add adds primitives to a layer.
 // It generates new apertures as necessary.
 func (l *Layer) Add(primitives ...Primitive) {
+	l.addApertures(primitives)
+	l.Primitives = append(l.Primitives, primitives...)
+}
+
+// setType sets the function of the layer and rebuilds its aperture
+// table, since the default aperture functions depend on the layer type.
+func (l *Layer) setType(layerType LayerType, n int) {
+	l.Type, l.N = layerType, n
+	l.Apertures, l.apertureMap = nil, map[string]int{"default": -1}
+	l.addApertures(l.Primitives)
+}
+
+// addApertures adds the apertures of the primitives, including those
+// of grouped primitives, to the aperture table.
+func (l *Layer) addApertures(primitives []Primitive) {
 	for _, p := range primitives {
-		a := p.Aperture()
-		if a == nil {
-			continue // use the default layer
-		}
-		id := a.ID()
-		if _, ok := l.apertureMap[id]; ok {
+		if g, ok := p.(group); ok {
+			l.addApertures(g.primitives())
 			continue
 		}
-		l.apertureMap[id] = len(l.Apertures)
-		l.Apertures = append(l.Apertures, a)
+		l.addAperture(l.aperture(p))
 	}
-	l.Primitives = append(l.Primitives, primitives...)
+}
+
+// addAperture adds the aperture to the aperture table if it is new.
+// The apertures used by a block aperture are added before the block.
+func (l *Layer) addAperture(a *Aperture) {
+	if a == nil {
+		return // use the default layer
+	}
+	id := a.ID()
+	if _, ok := l.apertureMap[id]; ok {
+		return
+	}
+	if a.Block != nil {
+		l.addApertures(a.Block.Primitives)
+	}
+	l.apertureMap[id] = len(l.Apertures)
+	l.Apertures = append(l.Apertures, a)
 }
 
 // WriteGerber writes a layer to its corresponding Gerber layer file.
+// Drill layers are written as Excellon drill files unless the design
+// selects GerberDrill as its DrillFormat.
+//
+// If the design is panelized, the primitives are wrapped in a
+// step-and-repeat block and the outline layer is replaced by
+// the generated panel outline.
+//
+// The layer is rendered completely before anything is written to w.
 func (l *Layer) WriteGerber(w io.Writer) error {
-	io.WriteString(w, "%FSLAX36Y36*%\n")
-	io.WriteString(w, "%MOMM*%\n")
+	var buf bytes.Buffer
+	if err := l.render(&buf); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// render renders the layer file.
+func (l *Layer) render(w io.Writer) error {
+	if l.isDrill() && (l.g == nil || l.g.DrillFormat == ExcellonDrill) {
+		return l.writeExcellon(w)
+	}
+	if l.g == nil || l.g.Panel == nil {
+		return l.writeGerber(w, nil)
+	}
+	if l.Type != LayerOutline {
+		return l.writeGerber(w, l.g.Panel)
+	}
+	outline, err := l.g.Panel.outlineLayer(l)
+	if err != nil {
+		return fmt.Errorf("%v: %v", l.Filename, err)
+	}
+	return outline.writeGerber(w, nil)
+}
+
+// writeGerber writes the layer, step-and-repeating its primitives
+// if sr is non-nil.
+func (l *Layer) writeGerber(w io.Writer, sr *Panel) error {
+	f := l.format()
+	if err := f.Validate(); err != nil {
+		return err
+	}
+
+	l.writeFileAttributes(w)
+	f.writeHeader(w)
 	io.WriteString(w, "%LPD*%\n")
+	io.WriteString(w, "G75*\n")
+
+	if err := l.writeMacros(w); err != nil {
+		return err
+	}
 
-	io.WriteString(w, "%ADD11C,0.00100*%\n")
+	fmt.Fprintf(w, "%%ADD11C,%v*%%\n", f.Size(0.001))
+	var function AperFunction
 	for i, a := range l.Apertures {
-		a.WriteGerber(w, 12+i)
+		if a.Block != nil {
+			if function != "" {
+				io.WriteString(w, "%TD.AperFunction*%\n")
+				function = ""
+			}
+			if err := l.writeBlock(w, f, a.Block, 12+i); err != nil {
+				return fmt.Errorf("%v: %v", l.Filename, err)
+			}
+			continue
+		}
+		if a.Function != function {
+			if a.Function == "" {
+				io.WriteString(w, "%TD.AperFunction*%\n")
+			} else {
+				fmt.Fprintf(w, "%%TA.AperFunction,%v*%%\n", a.Function)
+			}
+			function = a.Function
+		}
+		if err := a.WriteGerber(w, f, 12+i); err != nil {
+			return err
+		}
+	}
+	if function != "" {
+		io.WriteString(w, "%TD.AperFunction*%\n")
 	}
 
-	for _, p := range l.Primitives {
-		ai := l.apertureMap[p.Aperture().ID()]
-		p.WriteGerber(w, 12+ai)
+	if sr != nil {
+		if err := sr.Validate(); err != nil {
+			return fmt.Errorf("%v: %v", l.Filename, err)
+		}
+		sr.writeStepAndRepeat(w, f)
+	}
+	if err := l.writePrimitives(w, f, l.Primitives); err != nil {
+		return fmt.Errorf("%v: %v", l.Filename, err)
+	}
+	if sr != nil {
+		io.WriteString(w, "%SR*%\n")
 	}
 
 	io.WriteString(w, "M02*\n")
 	return nil
 }
 
+// writePrimitives writes the primitives with their attributes,
+// switching the polarity around clear and knockout groups.
+// The polarity is dark before and after the primitives.
+func (l *Layer) writePrimitives(w io.Writer, f *Format, primitives []Primitive) error {
+	pol := &polarity{}
+	if err := l.writePolarized(w, f, primitives, false, pol); err != nil {
+		return err
+	}
+	pol.set(w, false)
+	return nil
+}
+
+// writePolarized writes the primitives with clear (or dark) polarity.
+func (l *Layer) writePolarized(w io.Writer, f *Format, primitives []Primitive, clear bool, pol *polarity) error {
+	for _, p := range primitives {
+		switch v := p.(type) {
+		case *ClearT:
+			if err := l.writePolarized(w, f, v.Primitives, !clear, pol); err != nil {
+				return err
+			}
+			continue
+		case *KnockoutT:
+			if err := l.writePolarized(w, f, []Primitive{v.Box()}, clear, pol); err != nil {
+				return err
+			}
+			if err := l.writePolarized(w, f, v.Primitives, !clear, pol); err != nil {
+				return err
+			}
+			continue
+		}
+
+		pol.set(w, clear)
+		a := l.aperture(p)
+		ai, ok := l.apertureMap[a.ID()]
+		if !ok {
+			return fmt.Errorf("aperture %v of %T is not in the aperture table", a.ID(), p)
+		}
+		// Regions use the default aperture and take their function
+		// from the attribute dictionary when they are created.
+		var regionFunction bool
+		if v, ok := p.(*PolygonT); a == nil || ok && v.Filled {
+			if f := l.function(p); f != "" {
+				fmt.Fprintf(w, "%%TA.AperFunction,%v*%%\n", f)
+				regionFunction = true
+			}
+		}
+		hasObjectAttributes := primitiveAttributes(p).writeObjectAttributes(w)
+		var err error
+		if v, ok := p.(polarizedWriter); ok {
+			err = v.writePolarized(w, f, clear, pol)
+		} else {
+			err = p.WriteGerber(w, f, 12+ai)
+		}
+		if err != nil {
+			return err
+		}
+		if hasObjectAttributes || regionFunction {
+			io.WriteString(w, "%TD*%\n")
+		}
+	}
+	return nil
+}
+
+// writeMacros writes the definitions of the aperture macros used
+// by the layer's apertures.
+func (l *Layer) writeMacros(w io.Writer) error {
+	macros := map[string]*Macro{}
+	for _, a := range l.Apertures {
+		if a.Macro == nil {
+			continue
+		}
+		if m, ok := macros[a.Macro.Name]; ok {
+			if m != a.Macro {
+				return fmt.Errorf("%v: two different aperture macros named %q", l.Filename, m.Name)
+			}
+			continue
+		}
+		macros[a.Macro.Name] = a.Macro
+		if err := a.Macro.WriteGerber(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isDrill reports whether the layer is a (plated or non-plated) drill layer.
+func (l *Layer) isDrill() bool {
+	return l.Type == LayerDrill || l.Type == LayerNonPlatedDrill
+}
+
+// format returns the coordinate format of the layer's design.
+func (l *Layer) format() *Format {
+	if l.g == nil {
+		f := DefaultFormat
+		return &f
+	}
+	return &l.g.Format
+}
+
 // MBB returns the minimum bounding box of the layer in millimeters.
 func (l *Layer) MBB() MBB {
 	if l.mbb != nil {
@@ -81,10 +316,16 @@ func (l *Layer) MBB() MBB {
 	return *l.mbb
 }
 
-func (g *Gerber) makeLayer(extension string) *Layer {
+// makeLayer adds a layer named with the default Protel filename
+// extension to the design.
+func (g *Gerber) makeLayer(layerType LayerType, n int) *Layer {
+	filename, _ := ProtelProfile.filename(g.FilenamePrefix, layerType, n)
 	layer := &Layer{
-		Filename:    g.FilenamePrefix + "." + extension,
+		Filename:    filename,
+		Type:        layerType,
+		N:           n,
 		apertureMap: map[string]int{"default": -1},
+		g:           g,
 	}
 	g.Layers = append(g.Layers, layer)
 	return layer
@@ -93,53 +334,59 @@ func (g *Gerber) makeLayer(extension string) *Layer {
 // TopCopper adds a top copper layer to the design
 // and returns the layer.
 func (g *Gerber) TopCopper() *Layer {
-	return g.makeLayer("gtl")
+	return g.makeLayer(LayerTopCopper, 0)
 }
 
 // TopSolderMask adds a top solder mask layer to the design
 // and returns the layer.
 func (g *Gerber) TopSolderMask() *Layer {
-	return g.makeLayer("gts")
+	return g.makeLayer(LayerTopSolderMask, 0)
 }
 
 // TopSilkscreen adds a top silkscreen layer to the design
 // and returns the layer.
 func (g *Gerber) TopSilkscreen() *Layer {
-	return g.makeLayer("gto")
+	return g.makeLayer(LayerTopSilkscreen, 0)
 }
 
 // BottomCopper adds a bottom copper layer to the design
 // and returns the layer.
 func (g *Gerber) BottomCopper() *Layer {
-	return g.makeLayer("gbl")
+	return g.makeLayer(LayerBottomCopper, 0)
 }
 
 // BottomSolderMask adds a bottom solder mask layer to the design
 // and returns the layer.
 func (g *Gerber) BottomSolderMask() *Layer {
-	return g.makeLayer("gbs")
+	return g.makeLayer(LayerBottomSolderMask, 0)
 }
 
 // BottomSilkscreen adds a bottom silkscreen layer to the design
 // and returns the layer.
 func (g *Gerber) BottomSilkscreen() *Layer {
-	return g.makeLayer("gbo")
+	return g.makeLayer(LayerBottomSilkscreen, 0)
 }
 
 // LayerN adds a layer-n copper layer to a multi-layer design
 // and returns the layer.
 func (g *Gerber) LayerN(n int) *Layer {
-	return g.makeLayer(fmt.Sprintf("gl%v", n))
+	return g.makeLayer(LayerInnerCopper, n)
 }
 
 // Drill adds a drill layer to the design
 // and returns the layer.
 func (g *Gerber) Drill() *Layer {
-	return g.makeLayer("drl")
+	return g.makeLayer(LayerDrill, 0)
 }
 
 // Outline adds an outline layer to the design
 // and returns the layer.
 func (g *Gerber) Outline() *Layer {
-	return g.makeLayer("gko")
+	return g.makeLayer(LayerOutline, 0)
+}
+
+// NonPlatedDrill adds a non-plated drill layer to the design
+// and returns the layer.
+func (g *Gerber) NonPlatedDrill() *Layer {
+	return g.makeLayer(LayerNonPlatedDrill, 0)
 }
@@ -0,0 +1,243 @@
+package gerber
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Load reads a design from a ZIP archive of Gerber and Excellon
+// files, like those written by WriteZip, WriteProfileZip or other
+// CAD tools.
+//
+// See LoadFS for how the layers are identified.
+func Load(r *zip.Reader) (*Gerber, error) {
+	return LoadFS(r)
+}
+
+// LoadFS reads a design from the Gerber and Excellon files of a
+// file system, for example a directory opened with os.DirFS.
+//
+// The function of each file is taken from the Gerber job file
+// (.gbrjob) if present, then from its X2 TF.FileFunction attribute,
+// then from its filename using the conventions of the fab profiles.
+// Files that are not Gerber or Excellon files (readmes, for example)
+// or whose function is unknown or unsupported (such as solder paste)
+// are skipped. The layer filenames are the paths within fsys.
+func LoadFS(fsys fs.FS) (*Gerber, error) {
+	var names []string
+	var job *jobFile
+	var project string
+	functions := map[string]string{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == "__MACOSX" {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !strings.EqualFold(path.Ext(name), ".gbrjob") {
+			names = append(names, name)
+			return nil
+		}
+
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		var jf jobFile
+		if err := json.Unmarshal(data, &jf); err != nil {
+			return fmt.Errorf("%v: %v", name, err)
+		}
+		if job == nil {
+			job, project = &jf, strings.TrimSuffix(name, path.Ext(name))
+		}
+		for _, attrs := range jf.FilesAttributes {
+			functions[path.Join(path.Dir(name), attrs.Path)] = attrs.FileFunction
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	g := New(project)
+	if job != nil {
+		g.Job = job.specs()
+	}
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		layer, err := loadLayer(name, data, functions)
+		if err != nil {
+			return nil, err
+		}
+		if layer == nil {
+			continue
+		}
+		layer.Filename = name
+		layer.g = g
+		g.Layers = append(g.Layers, layer)
+
+		if g.FilenamePrefix == "" {
+			if prefix, _, _, ok := layerFromFilename(name); ok {
+				g.FilenamePrefix = prefix
+			}
+		}
+	}
+	if len(g.Layers) == 0 {
+		return nil, errors.New("no Gerber or Excellon layers found")
+	}
+	if g.FilenamePrefix == "" {
+		name := g.Layers[0].Filename
+		g.FilenamePrefix = strings.TrimSuffix(name, path.Ext(name))
+	}
+
+	sort.SliceStable(g.Layers, func(a, b int) bool {
+		if g.Layers[a].Type != g.Layers[b].Type {
+			return g.Layers[a].Type < g.Layers[b].Type
+		}
+		return g.Layers[a].N < g.Layers[b].N
+	})
+	return g, nil
+}
+
+// loadLayer parses the named file and identifies its layer.
+// It returns a nil layer if the file should be skipped.
+func loadLayer(name string, data []byte, functions map[string]string) (*Layer, error) {
+	var layer *Layer
+	var hasFunction bool
+	switch {
+	case bytes.Contains(data, []byte("%FS")):
+		p, err := parseGerber(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", name, err)
+		}
+		layer, hasFunction = p.layer, p.hasFunction
+	case bytes.Contains(data, []byte("M48")):
+		var err error
+		if layer, err = ParseExcellon(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("%v: %v", name, err)
+		}
+		// Excellon files are drill files; only their plating is unknown.
+		hasFunction = true
+		if layer.Type == LayerDrill && strings.Contains(strings.ToLower(path.Base(name)), "npth") {
+			layer.setType(LayerNonPlatedDrill, 0)
+		}
+	default:
+		return nil, nil
+	}
+
+	if function, ok := functions[name]; ok {
+		layerType, n, ok := parseFileFunction(function)
+		if !ok {
+			log.Printf("Skipping %v: unsupported file function %q", name, function)
+			return nil, nil
+		}
+		layer.setType(layerType, n)
+		return layer, nil
+	}
+	if !hasFunction {
+		_, layerType, n, ok := layerFromFilename(name)
+		if !ok {
+			log.Printf("Skipping %v: unknown layer function", name)
+			return nil, nil
+		}
+		layer.setType(layerType, n)
+	}
+	return layer, nil
+}
+
+// filenamePattern matches the filenames of a layer type.
+type filenamePattern struct {
+	re        *regexp.Regexp
+	layerType LayerType
+	// inner is set when the number in the filename is the inner
+	// layer number (1 for the first inner layer).
+	inner bool
+}
+
+// filenamePatterns match the filenames written by the fab profiles,
+// ignoring case.
+var filenamePatterns = func() []filenamePattern {
+	var result []filenamePattern
+	for _, p := range []*FabProfile{ProtelProfile, JLCPCBProfile, OSHParkProfile, PCBWayProfile, AislerProfile} {
+		var types []LayerType
+		for t := range p.Filenames {
+			types = append(types, t)
+		}
+		sort.Slice(types, func(a, b int) bool { return types[a] < types[b] })
+		for _, t := range types {
+			suffix := regexp.QuoteMeta(strings.TrimPrefix(p.Filenames[t], "%[1]v"))
+			inner := strings.Contains(suffix, `%\[3\]v`)
+			suffix = strings.NewReplacer(`%\[2\]v`, `(\d+)`, `%\[3\]v`, `(\d+)`).Replace(suffix)
+			result = append(result, filenamePattern{
+				re:        regexp.MustCompile(`(?i)^(.+)` + suffix + `$`),
+				layerType: t,
+				inner:     inner,
+			})
+		}
+	}
+	return result
+}()
+
+// outlineExtensions are other common extensions of board outline files.
+var outlineExtensions = map[string]bool{".gm1": true, ".gml": true}
+
+// layerFromFilename identifies the layer of a file from its name.
+// When several patterns match, the one with the longest suffix wins,
+// so that "board-NPTH.drl" is a non-plated drill file.
+// It also returns the filename prefix.
+func layerFromFilename(name string) (prefix string, layerType LayerType, n int, ok bool) {
+	for _, p := range filenamePatterns {
+		m := p.re.FindStringSubmatch(name)
+		if m == nil || (ok && len(m[1]) >= len(prefix)) {
+			continue
+		}
+		prefix, layerType, n, ok = m[1], p.layerType, 0, true
+		if len(m) > 2 {
+			n, _ = strconv.Atoi(m[2])
+			if p.inner {
+				n++
+			}
+		}
+	}
+	if !ok {
+		if ext := path.Ext(name); outlineExtensions[strings.ToLower(ext)] {
+			return strings.TrimSuffix(name, ext), LayerOutline, 0, true
+		}
+	}
+	return prefix, layerType, n, ok
+}
+
+// specs returns the board specifications of the job file.
+func (j *jobFile) specs() JobSpecs {
+	specs := JobSpecs{
+		Finish:         j.GeneralSpecs.Finish,
+		BoardThickness: j.GeneralSpecs.BoardThickness,
+		Revision:       j.GeneralSpecs.ProjectID.Revision,
+	}
+	for _, layer := range j.MaterialStackup {
+		switch {
+		case layer.Type == "Copper" && specs.CopperThickness == 0:
+			specs.CopperThickness = layer.Thickness
+		case layer.Type == "Dielectric" && specs.DielectricMaterial == "":
+			specs.DielectricMaterial = layer.Material
+		}
+	}
+	return specs
+}
@@ -0,0 +1,212 @@
+package gerber
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func loadZip(t *testing.T, data []byte) *Gerber {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := Load(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func layerNames(g *Gerber) string {
+	var names []string
+	for _, layer := range g.Layers {
+		names = append(names, fmt.Sprintf("%v:%v:%v", layer.Filename, layer.Type, layer.N))
+	}
+	return strings.Join(names, " ")
+}
+
+func TestLoad(t *testing.T) {
+	want := profileDesign()
+	want.Layers[0].Add(Arc(Pt{5, 5}, 2, CircleShape, 1, 1, 0, 90, 0.25))
+	want.Job = JobSpecs{Finish: FinishENIG, BoardThickness: 0.8, CopperThickness: 0.07, DielectricMaterial: "FR408", Revision: "B"}
+	var buf bytes.Buffer
+	if err := want.WriteZip(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	g := loadZip(t, buf.Bytes())
+	if g.FilenamePrefix != "out/board" {
+		t.Errorf("FilenamePrefix = %v, want out/board", g.FilenamePrefix)
+	}
+	if g.Job != want.Job {
+		t.Errorf("Job = %+v, want %+v", g.Job, want.Job)
+	}
+	wantLayers := fmt.Sprintf("out/board.gtl:%v:0 out/board.gts:%v:0 out/board.gto:%v:0 out/board.gbl:%v:0 out/board.gbs:%v:0 out/board.gbo:%v:0 out/board.gl2:%v:2 out/board.gl3:%v:3 out/board.drl:%v:0 out/board.gko:%v:0 out/board-NPTH.drl:%v:0",
+		LayerTopCopper, LayerTopSolderMask, LayerTopSilkscreen, LayerBottomCopper, LayerBottomSolderMask, LayerBottomSilkscreen,
+		LayerInnerCopper, LayerInnerCopper, LayerDrill, LayerOutline, LayerNonPlatedDrill)
+	if got := layerNames(g); got != wantLayers {
+		t.Errorf("layers =\n%v\nwant:\n%v", got, wantLayers)
+	}
+	if got, want := g.MBB(), want.MBB(); !mbbClose(got, want) {
+		t.Errorf("MBB = %v, want %v", got, want)
+	}
+	if got, want := len(g.Layers[0].Primitives), 2; got != want {
+		t.Errorf("len(top copper Primitives) = %v, want %v", got, want)
+	}
+}
+
+func TestLoad_Profiles(t *testing.T) {
+	for _, p := range []*FabProfile{ProtelProfile, JLCPCBProfile, OSHParkProfile, PCBWayProfile, AislerProfile} {
+		t.Run(p.Name, func(t *testing.T) {
+			want := profileDesign()
+			var buf bytes.Buffer
+			if err := want.WriteProfileZip(&buf, p); err != nil {
+				t.Fatal(err)
+			}
+			g := loadZip(t, buf.Bytes())
+			if g.FilenamePrefix != "board" {
+				t.Errorf("FilenamePrefix = %v, want board", g.FilenamePrefix)
+			}
+			layers := want.Layers
+			if !p.SplitDrill {
+				layers = want.mergeDrills()
+			}
+			if got, want := len(g.Layers), len(layers); got != want {
+				t.Fatalf("len(Layers) = %v, want %v: %v", got, want, layerNames(g))
+			}
+			for i, layer := range g.Layers {
+				var found bool
+				for _, w := range layers {
+					found = found || (w.Type == layer.Type && w.N == layer.N)
+				}
+				if !found {
+					t.Errorf("Layers[%v] = %v:%v:%v, not in the design", i, layer.Filename, layer.Type, layer.N)
+				}
+			}
+			if got, want := g.MBB(), want.MBB(); !mbbClose(got, want) {
+				t.Errorf("MBB = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoad_Filenames(t *testing.T) {
+	const gerber = "%FSLAX36Y36*%\n%MOMM*%\n%ADD10C,0.1*%\nD10*\nX0Y0D03*\nM02*\n"
+	const drill = "M48\nMETRIC\nT1C0.5\n%\nT1\nX1.0Y1.0\nM30\n"
+	files := []struct {
+		name string
+		data string
+	}{
+		{"b.GTL", gerber},
+		{"b.G1", gerber},
+		{"b.gbl", gerber},
+		{"b.GM1", gerber},
+		{"b-In2_Cu.gbr", gerber},
+		{"b.gtp", gerber},
+		{"b-NPTH.DRL", drill},
+		{"b.TXT", drill},
+		{"b-readme.txt", "readme\n"},
+		{"__MACOSX/b.GTL", gerber},
+		{"b.gbrjob", `{"FilesAttributes": [{"Path": "b.gbl", "FileFunction": "Soldermask,Bot"}]}`},
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range files {
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(f.data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	g := loadZip(t, buf.Bytes())
+	want := fmt.Sprintf("b.GTL:%v:0 b.gbl:%v:0 b.G1:%v:2 b-In2_Cu.gbr:%v:3 b.TXT:%v:0 b.GM1:%v:0 b-NPTH.DRL:%v:0",
+		LayerTopCopper, LayerBottomSolderMask, LayerInnerCopper, LayerInnerCopper, LayerDrill, LayerOutline, LayerNonPlatedDrill)
+	if got := layerNames(g); got != want {
+		t.Errorf("layers =\n%v\nwant:\n%v", got, want)
+	}
+	if g.FilenamePrefix != "b" {
+		t.Errorf("FilenamePrefix = %v, want b", g.FilenamePrefix)
+	}
+}
+
+func TestLoad_RewriteApertures(t *testing.T) {
+	// A file without X2 attributes gets its layer type from its name
+	// after its primitives are parsed.
+	const outline = "%FSLAX36Y36*%\n%MOMM*%\n%ADD10C,0.1*%\n%ADD11C,0.5*%\n" +
+		"D10*\nX0Y0D02*\nX10000000Y0D01*\nD11*\nX10000000Y10000000D01*\nM02*\n"
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	fw, err := zw.Create("b.gko")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write([]byte(outline))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	g := loadZip(t, buf.Bytes())
+	var out bytes.Buffer
+	if err := g.Layers[0].WriteGerber(&out); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); strings.Contains(got, "Conductor") || !strings.Contains(got, "%TA.AperFunction,Profile*%") {
+		t.Errorf("WriteGerber =\n%v\nwant Profile apertures", got)
+	}
+	layer, err := ParseGerber(&out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []float64
+	for _, p := range layer.Primitives {
+		if l, ok := p.(*LineT); ok {
+			got = append(got, l.Thickness)
+		}
+	}
+	if len(got) != 2 || got[0] != 0.1 || got[1] != 0.5 {
+		t.Errorf("line thicknesses = %v, want [0.1 0.5]", got)
+	}
+}
+
+func TestLoad_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{name: "empty.txt", data: "nothing here\n", want: "no Gerber or Excellon layers found"},
+		{name: "bad.gtl", data: "%FSLAX36Y36*%\n%MOMM*%\nD10*\n", want: "bad.gtl: line 3: undefined aperture D10"},
+		{name: "bad.gbrjob", data: "{", want: "bad.gbrjob: unexpected end of JSON input"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			zw := zip.NewWriter(&buf)
+			fw, err := zw.Create(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fw.Write([]byte(tt.data))
+			if err := zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(zr); err == nil || err.Error() != tt.want {
+				t.Errorf("Load = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,456 @@
+package gerber
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MacroCode is the code of an aperture macro primitive.
+type MacroCode int
+
+const (
+	// MacroComment is a comment. Its single modifier is the comment text.
+	MacroComment MacroCode = 0
+	// MacroCircle modifiers: exposure, diameter, center x, center y[, rotation].
+	MacroCircle MacroCode = 1
+	// MacroVectorLine modifiers: exposure, width, start x, start y,
+	// end x, end y, rotation.
+	MacroVectorLine MacroCode = 20
+	// MacroCenterLine modifiers: exposure, width, height, center x,
+	// center y, rotation.
+	MacroCenterLine MacroCode = 21
+	// MacroOutline modifiers: exposure, number of vertices n, followed by
+	// n+1 x,y pairs (the last equal to the first), rotation.
+	MacroOutline MacroCode = 4
+	// MacroPolygon modifiers: exposure, number of vertices, center x,
+	// center y, diameter, rotation.
+	MacroPolygon MacroCode = 5
+	// MacroMoire modifiers: center x, center y, outer diameter,
+	// ring thickness, gap, maximum number of rings, crosshair thickness,
+	// crosshair length, rotation.
+	MacroMoire MacroCode = 6
+	// MacroThermal modifiers: center x, center y, outer diameter,
+	// inner diameter, gap, rotation.
+	MacroThermal MacroCode = 7
+)
+
+// Expr is an arithmetic expression used as a macro modifier.
+// It may contain decimal numbers, variables ($1, $2, ...), parentheses
+// and the operators +, -, x (multiplication) and /.
+type Expr string
+
+// MacroStatement is a statement of an aperture macro: either
+// a MacroPrimitive or a MacroVariable.
+type MacroStatement interface {
+	// gerber returns the statement as written in the %AM command.
+	gerber() string
+}
+
+// MacroPrimitive is a primitive of an aperture macro.
+type MacroPrimitive struct {
+	Code      MacroCode
+	Modifiers []Expr
+}
+
+func (p MacroPrimitive) gerber() string {
+	if p.Code == MacroComment {
+		var comment string
+		if len(p.Modifiers) > 0 {
+			comment = string(p.Modifiers[0])
+		}
+		return "0 " + comment
+	}
+	parts := []string{strconv.Itoa(int(p.Code))}
+	for _, m := range p.Modifiers {
+		parts = append(parts, string(m))
+	}
+	return strings.Join(parts, ",")
+}
+
+// MacroVariable defines the variable $N of an aperture macro.
+type MacroVariable struct {
+	N     int
+	Value Expr
+}
+
+func (v MacroVariable) gerber() string {
+	return fmt.Sprintf("$%v=%v", v.N, v.Value)
+}
+
+// Macro represents an aperture macro (%AM).
+//
+// Apertures that use the macro provide its parameters ($1, $2, ...)
+// in millimeters. They are converted to the units of the file when
+// written, except for the parameters listed in UnitlessParams.
+// Numeric constants within the macro's expressions are written as-is.
+type Macro struct {
+	// Name is the unique name of the macro within a layer.
+	Name string
+	// Statements are the primitives and variable definitions of the macro.
+	Statements []MacroStatement
+	// UnitlessParams lists the (1-based) parameters that are not lengths,
+	// such as rotation angles in degrees.
+	UnitlessParams []int
+}
+
+// WriteGerber writes the %AM command defining the macro.
+func (m *Macro) WriteGerber(w io.Writer) error {
+	if m.Name == "" {
+		return fmt.Errorf("aperture macro has no name")
+	}
+	fmt.Fprintf(w, "%%AM%v*\n", m.Name)
+	for _, s := range m.Statements {
+		fmt.Fprintf(w, "%v*\n", s.gerber())
+	}
+	io.WriteString(w, "%\n")
+	return nil
+}
+
+// isUnitless reports whether the (1-based) parameter n is not a length.
+func (m *Macro) isUnitless(n int) bool {
+	for _, v := range m.UnitlessParams {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
+
+// eval evaluates the macro's statements for the provided parameters
+// (in millimeters) and calls fn with the code and the evaluated
+// modifiers of each primitive in order. Comments are skipped.
+func (m *Macro) eval(params []float64, fn func(code MacroCode, mods []float64) error) error {
+	vars := map[int]float64{}
+	for i, v := range params {
+		vars[i+1] = v
+	}
+	for _, s := range m.Statements {
+		switch s := s.(type) {
+		case MacroVariable:
+			v, err := s.Value.Eval(vars)
+			if err != nil {
+				return fmt.Errorf("macro %v: $%v: %v", m.Name, s.N, err)
+			}
+			vars[s.N] = v
+		case MacroPrimitive:
+			if s.Code == MacroComment {
+				continue
+			}
+			mods := make([]float64, len(s.Modifiers))
+			for i, e := range s.Modifiers {
+				v, err := e.Eval(vars)
+				if err != nil {
+					return fmt.Errorf("macro %v: primitive %v: %v", m.Name, s.Code, err)
+				}
+				mods[i] = v
+			}
+			if err := fn(s.Code, mods); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MBB returns the minimum bounding box of the macro's exposed primitives
+// for the provided parameters (in millimeters), centered on the origin.
+func (m *Macro) MBB(params []float64) (MBB, error) {
+	var mbb *MBB
+	join := func(pts []Pt, r, rotation float64) {
+		for _, pt := range pts {
+			pt = rotate(pt, rotation)
+			v := MBB{Min: Pt{pt[0] - r, pt[1] - r}, Max: Pt{pt[0] + r, pt[1] + r}}
+			if mbb == nil {
+				mbb = &v
+				continue
+			}
+			mbb.Join(&v)
+		}
+	}
+
+	err := m.eval(params, func(code MacroCode, mods []float64) error {
+		mod := func(i int) float64 {
+			if i < len(mods) {
+				return mods[i]
+			}
+			return 0
+		}
+
+		switch code {
+		case MacroCircle:
+			if mod(0) == 0 {
+				return nil
+			}
+			join([]Pt{{mod(2), mod(3)}}, 0.5*mod(1), mod(4))
+		case MacroVectorLine:
+			if mod(0) == 0 {
+				return nil
+			}
+			start, end := Pt{mod(2), mod(3)}, Pt{mod(4), mod(5)}
+			dx, dy := end[0]-start[0], end[1]-start[1]
+			length := math.Hypot(dx, dy)
+			if length == 0 {
+				return nil
+			}
+			nx, ny := -0.5*mod(1)*dy/length, 0.5*mod(1)*dx/length
+			join([]Pt{
+				{start[0] + nx, start[1] + ny},
+				{start[0] - nx, start[1] - ny},
+				{end[0] + nx, end[1] + ny},
+				{end[0] - nx, end[1] - ny},
+			}, 0, mod(6))
+		case MacroCenterLine:
+			if mod(0) == 0 {
+				return nil
+			}
+			hw, hh, cx, cy := 0.5*mod(1), 0.5*mod(2), mod(3), mod(4)
+			join([]Pt{{cx - hw, cy - hh}, {cx + hw, cy - hh}, {cx + hw, cy + hh}, {cx - hw, cy + hh}}, 0, mod(5))
+		case MacroOutline:
+			if mod(0) == 0 {
+				return nil
+			}
+			n := int(mod(1))
+			if len(mods) < 2*n+5 {
+				return fmt.Errorf("macro %v: outline with %v vertices has %v modifiers", m.Name, n, len(mods))
+			}
+			var pts []Pt
+			for i := 0; i <= n; i++ {
+				pts = append(pts, Pt{mod(2 + 2*i), mod(3 + 2*i)})
+			}
+			join(pts, 0, mod(2*n+4))
+		case MacroPolygon:
+			if mod(0) == 0 {
+				return nil
+			}
+			join([]Pt{{mod(2), mod(3)}}, 0.5*mod(4), mod(5))
+		case MacroMoire:
+			join([]Pt{{mod(0), mod(1)}}, 0.5*math.Max(mod(2), mod(7)), mod(8))
+		case MacroThermal:
+			join([]Pt{{mod(0), mod(1)}}, 0.5*mod(2), mod(5))
+		default:
+			return fmt.Errorf("macro %v: unknown primitive code %v", m.Name, code)
+		}
+		return nil
+	})
+	if err != nil {
+		return MBB{}, err
+	}
+
+	if mbb == nil {
+		return MBB{}, nil
+	}
+	return *mbb, nil
+}
+
+// rotate rotates the point about the origin by the angle in degrees.
+func rotate(pt Pt, degrees float64) Pt {
+	if degrees == 0 {
+		return pt
+	}
+	s, c := math.Sincos(math.Pi * degrees / 180)
+	return Pt{c*pt[0] - s*pt[1], s*pt[0] + c*pt[1]}
+}
+
+// Eval evaluates the expression with the provided variable values.
+// Undefined variables evaluate to zero as required by the Gerber spec.
+func (e Expr) Eval(vars map[int]float64) (float64, error) {
+	p := &exprParser{s: strings.ReplaceAll(string(e), " ", "")}
+	v, err := p.parseSum(vars)
+	if err != nil {
+		return 0, err
+	}
+	if p.pos < len(p.s) {
+		return 0, fmt.Errorf("unexpected %q in expression %q", p.s[p.pos:], e)
+	}
+	return v, nil
+}
+
+// exprParser is a recursive-descent parser for macro expressions.
+type exprParser struct {
+	s   string
+	pos int
+}
+
+func (p *exprParser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *exprParser) parseSum(vars map[int]float64) (float64, error) {
+	v, err := p.parseProduct(vars)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch p.peek() {
+		case '+':
+			p.pos++
+			rhs, err := p.parseProduct(vars)
+			if err != nil {
+				return 0, err
+			}
+			v += rhs
+		case '-':
+			p.pos++
+			rhs, err := p.parseProduct(vars)
+			if err != nil {
+				return 0, err
+			}
+			v -= rhs
+		default:
+			return v, nil
+		}
+	}
+}
+
+func (p *exprParser) parseProduct(vars map[int]float64) (float64, error) {
+	v, err := p.parseUnary(vars)
+	if err != nil {
+		return 0, err
+	}
+	for {
+		switch p.peek() {
+		case 'x', 'X':
+			p.pos++
+			rhs, err := p.parseUnary(vars)
+			if err != nil {
+				return 0, err
+			}
+			v *= rhs
+		case '/':
+			p.pos++
+			rhs, err := p.parseUnary(vars)
+			if err != nil {
+				return 0, err
+			}
+			if rhs == 0 {
+				return 0, fmt.Errorf("division by zero in expression %q", p.s)
+			}
+			v /= rhs
+		default:
+			return v, nil
+		}
+	}
+}
+
+func (p *exprParser) parseUnary(vars map[int]float64) (float64, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		v, err := p.parseUnary(vars)
+		return -v, err
+	case '+':
+		p.pos++
+		return p.parseUnary(vars)
+	}
+	return p.parseOperand(vars)
+}
+
+func (p *exprParser) parseOperand(vars map[int]float64) (float64, error) {
+	start := p.pos
+	switch c := p.peek(); {
+	case c == '(':
+		p.pos++
+		v, err := p.parseSum(vars)
+		if err != nil {
+			return 0, err
+		}
+		if p.peek() != ')' {
+			return 0, fmt.Errorf("missing ')' in expression %q", p.s)
+		}
+		p.pos++
+		return v, nil
+	case c == '$':
+		p.pos++
+		for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+			p.pos++
+		}
+		n, err := strconv.Atoi(p.s[start+1 : p.pos])
+		if err != nil {
+			return 0, fmt.Errorf("invalid variable in expression %q", p.s)
+		}
+		return vars[n], nil
+	case c == '.' || (c >= '0' && c <= '9'):
+		for p.pos < len(p.s) && (p.s[p.pos] == '.' || (p.s[p.pos] >= '0' && p.s[p.pos] <= '9')) {
+			p.pos++
+		}
+		return strconv.ParseFloat(p.s[start:p.pos], 64)
+	}
+	return 0, fmt.Errorf("unexpected end of expression %q", p.s)
+}
+
+// RoundRectMacro is a rectangle with rounded corners.
+// Parameters: $1 width, $2 height, $3 corner radius, $4 rotation.
+var RoundRectMacro = &Macro{
+	Name: "RoundRect",
+	Statements: []MacroStatement{
+		MacroPrimitive{Code: MacroComment, Modifiers: []Expr{"Rounded rectangle: $1 width, $2 height, $3 corner radius, $4 rotation"}},
+		MacroPrimitive{Code: MacroCenterLine, Modifiers: []Expr{"1", "$1", "$2-$3x2", "0", "0", "$4"}},
+		MacroPrimitive{Code: MacroCenterLine, Modifiers: []Expr{"1", "$1-$3x2", "$2", "0", "0", "$4"}},
+		MacroPrimitive{Code: MacroCircle, Modifiers: []Expr{"1", "$3x2", "$1/2-$3", "$2/2-$3", "$4"}},
+		MacroPrimitive{Code: MacroCircle, Modifiers: []Expr{"1", "$3x2", "-$1/2+$3", "$2/2-$3", "$4"}},
+		MacroPrimitive{Code: MacroCircle, Modifiers: []Expr{"1", "$3x2", "-$1/2+$3", "-$2/2+$3", "$4"}},
+		MacroPrimitive{Code: MacroCircle, Modifiers: []Expr{"1", "$3x2", "$1/2-$3", "-$2/2+$3", "$4"}},
+	},
+	UnitlessParams: []int{4},
+}
+
+// ChamferedRectMacro is a rectangle with chamfered (beveled) corners.
+// Parameters: $1 width, $2 height, $3 chamfer size, $4 rotation.
+var ChamferedRectMacro = &Macro{
+	Name: "ChamferedRect",
+	Statements: []MacroStatement{
+		MacroPrimitive{Code: MacroComment, Modifiers: []Expr{"Chamfered rectangle: $1 width, $2 height, $3 chamfer, $4 rotation"}},
+		MacroVariable{N: 5, Value: "$1/2"},
+		MacroVariable{N: 6, Value: "$2/2"},
+		MacroPrimitive{Code: MacroOutline, Modifiers: []Expr{
+			"1", "8",
+			"-$5+$3", "-$6",
+			"$5-$3", "-$6",
+			"$5", "-$6+$3",
+			"$5", "$6-$3",
+			"$5-$3", "$6",
+			"-$5+$3", "$6",
+			"-$5", "$6-$3",
+			"-$5", "-$6+$3",
+			"-$5+$3", "-$6",
+			"$4",
+		}},
+	},
+	UnitlessParams: []int{4},
+}
+
+// ThermalMacro is a thermal relief pad.
+// Parameters: $1 outer diameter, $2 inner diameter, $3 gap, $4 rotation.
+var ThermalMacro = &Macro{
+	Name: "Thermal",
+	Statements: []MacroStatement{
+		MacroPrimitive{Code: MacroComment, Modifiers: []Expr{"Thermal relief: $1 outer diameter, $2 inner diameter, $3 gap, $4 rotation"}},
+		MacroPrimitive{Code: MacroThermal, Modifiers: []Expr{"0", "0", "$1", "$2", "$3", "$4"}},
+	},
+	UnitlessParams: []int{4},
+}
+
+// RoundRectAperture returns an aperture for a rectangle with rounded corners.
+// All dimensions are in millimeters and the rotation is in degrees.
+func RoundRectAperture(width, height, radius, rotation float64) *Aperture {
+	return &Aperture{Macro: RoundRectMacro, Params: []float64{width, height, radius, rotation}}
+}
+
+// ChamferedRectAperture returns an aperture for a rectangle with
+// chamfered corners.
+// All dimensions are in millimeters and the rotation is in degrees.
+func ChamferedRectAperture(width, height, chamfer, rotation float64) *Aperture {
+	return &Aperture{Macro: ChamferedRectMacro, Params: []float64{width, height, chamfer, rotation}}
+}
+
+// ThermalAperture returns an aperture for a thermal relief pad.
+// All dimensions are in millimeters and the rotation is in degrees.
+func ThermalAperture(outer, inner, gap, rotation float64) *Aperture {
+	return &Aperture{Macro: ThermalMacro, Params: []float64{outer, inner, gap, rotation}}
+}
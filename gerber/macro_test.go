@@ -0,0 +1,146 @@
+package gerber
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestExpr_Eval(t *testing.T) {
+	vars := map[int]float64{1: 2, 2: 3}
+	tests := []struct {
+		e       Expr
+		want    float64
+		wantErr bool
+	}{
+		{e: "1.5", want: 1.5},
+		{e: "$1", want: 2},
+		{e: "$9", want: 0},
+		{e: "$1+$2", want: 5},
+		{e: "$1-$2x2", want: -4},
+		{e: "($1-$2)x2", want: -2},
+		{e: "-$1/2+$2", want: 2},
+		{e: "$2/2-$1", want: -0.5},
+		{e: "1X2", want: 2},
+		{e: "$1/0", wantErr: true},
+		{e: "($1", wantErr: true},
+		{e: "$1$2", wantErr: true},
+		{e: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.e), func(t *testing.T) {
+			got, err := tt.e.Eval(vars)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Eval = %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Eval: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Eval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMacro_MBB(t *testing.T) {
+	const eps = 1e-9
+	tests := []struct {
+		name string
+		a    *Aperture
+		want MBB
+	}{
+		{
+			name: "round rect",
+			a:    RoundRectAperture(2, 1, 0.25, 0),
+			want: MBB{Min: Pt{-1, -0.5}, Max: Pt{1, 0.5}},
+		},
+		{
+			name: "rotated round rect",
+			a:    RoundRectAperture(2, 1, 0.25, 90),
+			want: MBB{Min: Pt{-0.5, -1}, Max: Pt{0.5, 1}},
+		},
+		{
+			name: "chamfered rect",
+			a:    ChamferedRectAperture(2, 1, 0.25, 0),
+			want: MBB{Min: Pt{-1, -0.5}, Max: Pt{1, 0.5}},
+		},
+		{
+			name: "thermal",
+			a:    ThermalAperture(2, 1.5, 0.3, 45),
+			want: MBB{Min: Pt{-1, -1}, Max: Pt{1, 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.a.Macro.MBB(tt.a.Params)
+			if err != nil {
+				t.Fatalf("MBB: %v", err)
+			}
+			for i := 0; i < 2; i++ {
+				if math.Abs(got.Min[i]-tt.want.Min[i]) > eps || math.Abs(got.Max[i]-tt.want.Max[i]) > eps {
+					t.Errorf("MBB = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestMacro_WriteGerber(t *testing.T) {
+	var buf strings.Builder
+	if err := ChamferedRectMacro.WriteGerber(&buf); err != nil {
+		t.Fatalf("WriteGerber: %v", err)
+	}
+	want := `%AMChamferedRect*
+0 Chamfered rectangle: $1 width, $2 height, $3 chamfer, $4 rotation*
+$5=$1/2*
+$6=$2/2*
+4,1,8,-$5+$3,-$6,$5-$3,-$6,$5,-$6+$3,$5,$6-$3,$5-$3,$6,-$5+$3,$6,-$5,$6-$3,-$5,-$6+$3,-$5+$3,-$6,$4*
+%
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteGerber =\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestLayer_WriteGerber_Macros(t *testing.T) {
+	g := New("test")
+	g.Format = Format{Units: Inches, IntDigits: 2, DecDigits: 6}
+	top := g.TopCopper()
+	top.Add(
+		Flash(Pt{0, 0}, RoundRectAperture(2.54, 1.27, 0.254, 45)),
+		Flash(Pt{0, 0}, RoundRectAperture(2.54, 1.27, 0.254, 45)),
+		Flash(Pt{0, 0}, RoundRectAperture(2.54, 2.54, 0.254, 0)),
+		Flash(Pt{0, 0}, ThermalAperture(2.54, 1.27, 0.254, 45)),
+	)
+
+	var buf strings.Builder
+	if err := top.WriteGerber(&buf); err != nil {
+		t.Fatalf("WriteGerber: %v", err)
+	}
+	got := buf.String()
+	if n := strings.Count(got, "%AMRoundRect*"); n != 1 {
+		t.Errorf("WriteGerber wrote %v RoundRect macros, want 1:\n%v", n, got)
+	}
+	if n := strings.Count(got, "%AMThermal*"); n != 1 {
+		t.Errorf("WriteGerber wrote %v Thermal macros, want 1:\n%v", n, got)
+	}
+	for _, want := range []string{
+		"%ADD12RoundRect,0.10000X0.05000X0.01000X45*%\n",
+		"%ADD13RoundRect,0.10000X0.10000X0.01000X0*%\n",
+		"%ADD14Thermal,0.10000X0.05000X0.01000X45*%\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("WriteGerber =\n%v\nwant it to contain %q", got, want)
+		}
+	}
+	if strings.Index(got, "%AMThermal*") > strings.Index(got, "%ADD12") {
+		t.Errorf("WriteGerber =\n%v\nwant macros defined before the apertures", got)
+	}
+}
@@ -0,0 +1,1050 @@
+package gerber
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gmlewis/go3d/float64/vec3"
+)
+
+// ModelFormat represents the file format of a 3D model.
+type ModelFormat int
+
+const (
+	// STLModel writes a binary STL file in millimeters.
+	STLModel ModelFormat = iota
+	// OBJModel writes a Wavefront OBJ file in millimeters with an
+	// object per mesh.
+	OBJModel
+	// GLTFModel writes a binary glTF 2.0 file (.glb) in meters with
+	// the Y axis up, as glTF requires, and a material per mesh.
+	GLTFModel
+)
+
+var (
+	// modelBoardColor is the color of the board laminate (FR4 green).
+	modelBoardColor = color.NRGBA{R: 0x2e, G: 0x6b, B: 0x30, A: 0xff}
+	// modelCopperColor is the color of the copper layers.
+	modelCopperColor = color.NRGBA{R: 0xc8, G: 0x7f, B: 0x4a, A: 0xff}
+)
+
+// Model represents a 3D model of an assembled board in millimeters,
+// with the bottom of the board at Z=0.
+type Model struct {
+	// Meshes are the board laminate followed by the copper layers.
+	Meshes []*Mesh
+}
+
+// Mesh represents a triangle mesh of a 3D model.
+type Mesh struct {
+	// Name is "board" for the laminate or the filename of a copper layer.
+	Name string
+	// Color is the color of the material of the mesh.
+	Color color.NRGBA
+	// Vertices are the distinct vertices of the mesh in millimeters.
+	Vertices []vec3.T
+	// Triangles are the indexes of the vertices of each triangle,
+	// counterclockwise as seen from outside the solid.
+	Triangles [][3]int
+
+	index map[vec3.T]int // index of each vertex
+}
+
+// newMesh returns an empty mesh.
+func newMesh(name string, c color.NRGBA) *Mesh {
+	return &Mesh{Name: name, Color: c, index: map[vec3.T]int{}}
+}
+
+// WriteModel writes a 3D model of the assembled board to w in the
+// format. See (*Gerber).Model.
+func (g *Gerber) WriteModel(w io.Writer, format ModelFormat) error {
+	m, err := g.Model()
+	if err != nil {
+		return err
+	}
+	switch format {
+	case STLModel:
+		return m.WriteSTL(w)
+	case OBJModel:
+		return m.WriteOBJ(w)
+	case GLTFModel:
+		return m.WriteGLTF(w)
+	}
+	return fmt.Errorf("unknown model format %v", format)
+}
+
+// Model returns a 3D model of the assembled board for enclosure fit
+// checks and renders.
+//
+// The board is the largest closed contour of the lines and arcs of the
+// outline layer, with the closed contours inside it as cutouts. If the
+// outline layer has no closed contour, the bounding box of the design
+// is used. The board is extruded through the dielectric of the material
+// stackup of the Gerber job file (see JobSpecs) and each copper layer is
+// extruded to its copper thickness at its place in the stackup.
+//
+// The holes of the drill layers are subtracted from the board and from
+// the copper shapes surrounding them. Holes must not overlap each other
+// or the edges of the shapes they are subtracted from; holes crossing
+// those edges are logged and left out of the shape. Overlapping
+// copper shapes are separate solids. Clear primitives, knockouts and
+// inverted barcodes are not supported. Panels are not modeled.
+func (g *Gerber) Model() (*Model, error) {
+	if len(g.Layers) == 0 {
+		return nil, fmt.Errorf("design has no layers")
+	}
+	specs := g.jobSpecs()
+
+	board, err := g.boardRegion()
+	if err != nil {
+		return nil, err
+	}
+	var holes []*modelHole
+	for _, l := range g.Layers {
+		if !l.isDrill() {
+			continue
+		}
+		regions, err := modelRegions(l.Primitives, flashTransform{})
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", l.Filename, err)
+		}
+		for _, r := range regions {
+			holes = append(holes, newModelHole(r.Outer))
+		}
+	}
+	if crossing := addHoles(board, holes); len(crossing) > 0 {
+		logCrossingHoles("board", crossing)
+	}
+
+	// Walk the stackup from the top of the board down.
+	type span struct{ z0, z1 float64 }
+	coppers := map[string]span{}
+	bottom, top := math.Inf(1), math.Inf(-1)
+	z := specs.BoardThickness
+	for _, s := range g.stackup(&specs) {
+		z0 := z - s.Thickness
+		switch s.Type {
+		case "Copper":
+			coppers[s.Name] = span{z0: z0, z1: z}
+		case "Dielectric":
+			bottom, top = math.Min(bottom, z0), math.Max(top, z)
+		}
+		z = z0
+	}
+	if bottom >= top { // no copper layers
+		bottom, top = 0, specs.BoardThickness
+	}
+
+	m := &Model{}
+	mesh := newMesh("board", modelBoardColor)
+	if err := mesh.extrude(board, bottom, top); err != nil {
+		return nil, fmt.Errorf("board: %v", err)
+	}
+	m.Meshes = append(m.Meshes, mesh)
+
+	for _, l := range g.Layers {
+		s, ok := coppers[stackupName(l)]
+		if !ok {
+			continue
+		}
+		regions, err := modelRegions(l.Primitives, flashTransform{})
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", l.Filename, err)
+		}
+		mesh := newMesh(l.Filename, modelCopperColor)
+		var crossing []*modelHole
+		seen := map[*modelHole]bool{}
+		for _, r := range regions {
+			for _, h := range addHoles(r, holes) {
+				if !seen[h] {
+					seen[h] = true
+					crossing = append(crossing, h)
+				}
+			}
+			if err := mesh.extrude(r, s.z0, s.z1); err != nil {
+				return nil, fmt.Errorf("%v: %v", l.Filename, err)
+			}
+		}
+		if len(crossing) > 0 {
+			logCrossingHoles(l.Filename, crossing)
+		}
+		m.Meshes = append(m.Meshes, mesh)
+	}
+	return m, nil
+}
+
+// logCrossingHoles reports the holes that cross the edges of the shapes
+// of the named solid and so are not subtracted from them.
+func logCrossingHoles(name string, holes []*modelHole) {
+	c := holes[0].center()
+	log.Printf("%v: not subtracting %v holes that cross the edges of its shapes, the first at (%g, %g)", name, len(holes), c[0], c[1])
+}
+
+// stackupName returns the name of a copper layer in the material
+// stackup of the Gerber job file, or "" for other layers.
+func stackupName(l *Layer) string {
+	switch l.Type {
+	case LayerTopCopper:
+		return "Top Copper"
+	case LayerInnerCopper:
+		return fmt.Sprintf("Inner Copper L%v", l.N)
+	case LayerBottomCopper:
+		return "Bottom Copper"
+	}
+	return ""
+}
+
+// Box returns the bounding box of the model in millimeters.
+func (m *Model) Box() vec3.Box {
+	box := vec3.Box{Min: vec3.MaxVal, Max: vec3.MinVal}
+	for _, mesh := range m.Meshes {
+		for _, v := range mesh.Vertices {
+			box.Join(&vec3.Box{Min: v, Max: v})
+		}
+	}
+	return box
+}
+
+// boardRegion returns the shape of the board from the outline layers.
+func (g *Gerber) boardRegion() (*RegionT, error) {
+	var edges []modelEdge
+	var contours []Contour
+	for _, l := range g.Layers {
+		if l.Type != LayerOutline {
+			continue
+		}
+		e, c := outlineEdges(l.Primitives, flashTransform{})
+		edges = append(edges, e...)
+		contours = append(contours, c...)
+	}
+	contours = append(contours, outlineContours(edges)...)
+	if len(contours) == 0 {
+		mbb := g.MBB()
+		if mbb.Max[0] <= mbb.Min[0] || mbb.Max[1] <= mbb.Min[1] {
+			return nil, fmt.Errorf("design has no board outline")
+		}
+		return Region(PolygonContour([]Pt{mbb.Min, {mbb.Max[0], mbb.Min[1]}, mbb.Max, {mbb.Min[0], mbb.Max[1]}})), nil
+	}
+
+	areas := make([]float64, len(contours))
+	for i, c := range contours {
+		areas[i] = math.Abs(signedArea(c.ring()))
+	}
+	order := make([]int, len(contours))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return areas[order[a]] > areas[order[b]] })
+
+	board := Region(contours[order[0]])
+	var cutouts []*modelHole
+	for _, i := range order[1:] {
+		cutouts = append(cutouts, newModelHole(contours[i]))
+	}
+	if crossing := addHoles(board, cutouts); len(crossing) > 0 {
+		logCrossingHoles("board outline", crossing)
+	}
+	return board, nil
+}
+
+// modelEdge is a line or a circular arc of the outline starting at start.
+type modelEdge struct {
+	start Pt
+	seg   Segment
+}
+
+// reversed returns the edge traversed in the opposite direction.
+func (e modelEdge) reversed() modelEdge {
+	s := e.seg
+	s.End = e.start
+	s.Clockwise = !s.Clockwise
+	return modelEdge{start: e.seg.End, seg: s}
+}
+
+// outlineEdges returns the lines and arcs of the outline primitives as
+// edges and the closed shapes (circles, polygons and regions) as
+// contours, in design coordinates.
+func outlineEdges(primitives []Primitive, t flashTransform) ([]modelEdge, []Contour) {
+	var edges []modelEdge
+	var contours []Contour
+	line := func(p1, p2 Pt) {
+		edges = append(edges, modelEdge{start: t.apply(p1), seg: LineTo(t.apply(p2))})
+	}
+	for _, p := range primitives {
+		switch v := p.(type) {
+		case *LineT:
+			line(v.P1, v.P2)
+		case *ArcT:
+			delta := v.EndAngle - v.StartAngle
+			switch {
+			case v.XScale != v.YScale:
+				// Resolution of segments is 0.1mm
+				segments := int(0.5+delta*v.Radius*math.Max(v.XScale, v.YScale)*10.0) + 1
+				for i := 0; i < segments; i++ {
+					line(v.point(v.StartAngle+delta*float64(i)/float64(segments)), v.point(v.StartAngle+delta*float64(i+1)/float64(segments)))
+				}
+			case delta >= 2*math.Pi-1e-9:
+				contours = append(contours, t.contour(CircleContour(v.Center, v.Radius*v.XScale)))
+			default:
+				seg := t.segment(ArcTo(v.point(v.EndAngle), v.Center, false))
+				edges = append(edges, modelEdge{start: t.apply(v.point(v.StartAngle)), seg: seg})
+			}
+		case *PolygonT:
+			if len(v.Points) < 3 {
+				break
+			}
+			pts := make([]Pt, len(v.Points))
+			for i, pt := range v.Points {
+				pts[i] = Pt{pt[0] + v.Offset[0], pt[1] + v.Offset[1]}
+			}
+			contours = append(contours, t.contour(PolygonContour(pts)))
+		case *RegionT:
+			contours = append(contours, t.contour(v.Outer))
+		case *FlashT:
+			if v.aperture != nil && v.aperture.Block != nil {
+				e, c := outlineEdges(v.aperture.Block.Primitives, t.with(v))
+				edges = append(edges, e...)
+				contours = append(contours, c...)
+			}
+		}
+	}
+	return edges, contours
+}
+
+// outlineContours chains the edges end to end into closed contours.
+// Edges that do not close a contour are ignored.
+func outlineContours(edges []modelEdge) []Contour {
+	const eps = 1e-3 // ends closer than 1µm are joined
+	near := func(a, b Pt) bool {
+		return math.Hypot(a[0]-b[0], a[1]-b[1]) < eps
+	}
+	used := make([]bool, len(edges))
+	var result []Contour
+	for i, e := range edges {
+		if used[i] {
+			continue
+		}
+		used[i] = true
+		c := Contour{Start: e.start, Segments: []Segment{e.seg}}
+		end := e.seg.End
+		for !near(end, c.Start) {
+			found := false
+			for j, f := range edges {
+				if used[j] {
+					continue
+				}
+				switch {
+				case near(f.start, end):
+				case near(f.seg.End, end):
+					f = f.reversed()
+				default:
+					continue
+				}
+				used[j] = true
+				c.Segments = append(c.Segments, f.seg)
+				end = f.seg.End
+				found = true
+				break
+			}
+			if !found {
+				break
+			}
+		}
+		if near(end, c.Start) {
+			c.Segments[len(c.Segments)-1].End = c.Start
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// modelHole is a hole (or cutout) with its points.
+type modelHole struct {
+	contour Contour
+	pts     []Pt
+	mbb     MBB
+}
+
+// newModelHole returns the hole of the contour.
+func newModelHole(c Contour) *modelHole {
+	return &modelHole{contour: c, pts: c.Points(), mbb: c.MBB()}
+}
+
+// center returns the center of the bounding box of the hole.
+func (h *modelHole) center() Pt {
+	return Pt{0.5 * (h.mbb.Min[0] + h.mbb.Max[0]), 0.5 * (h.mbb.Min[1] + h.mbb.Max[1])}
+}
+
+// addHoles adds the holes that lie within the outer contour of the
+// region and that do not overlap its other holes by their centers.
+// It returns the holes that cross the outer contour, which are not
+// added.
+func addHoles(r *RegionT, holes []*modelHole) []*modelHole {
+	mbb := r.Outer.MBB()
+	var outer []Pt
+	var existing, crossing []*modelHole
+	for _, h := range r.Holes {
+		existing = append(existing, newModelHole(h))
+	}
+	for _, h := range holes {
+		if !mbb.Intersects(&h.mbb) {
+			continue
+		}
+		if outer == nil {
+			outer = r.Outer.Points()
+		}
+		var in int
+		for _, pt := range h.pts {
+			if svgWinding(pt, outer) != 0 {
+				in++
+			}
+		}
+		if in == 0 {
+			continue
+		}
+		inside := in == len(h.pts)
+		if !inside {
+			crossing = append(crossing, h)
+		}
+		for _, e := range existing {
+			if inside && (svgWinding(h.center(), e.pts) != 0 || svgWinding(e.center(), h.pts) != 0) {
+				inside = false
+			}
+		}
+		if inside {
+			r.Holes = append(r.Holes, h.contour)
+			existing = append(existing, h)
+		}
+	}
+	return crossing
+}
+
+// modelRegions returns the dark shapes of the primitives as new regions
+// in design coordinates.
+func modelRegions(primitives []Primitive, t flashTransform) ([]*RegionT, error) {
+	var result []*RegionT
+	add := func(r *RegionT) {
+		if r != nil {
+			result = append(result, t.region(r))
+		}
+	}
+	for _, p := range primitives {
+		switch v := p.(type) {
+		case *ClearT, *KnockoutT:
+			return nil, fmt.Errorf("clear polarity of %T is unsupported in 3D model", v)
+		case *LineT:
+			add(strokeRegion(v.P1, v.P2, v.Shape, v.Thickness))
+		case *ArcT:
+			for _, r := range arcRegions(v) {
+				add(r)
+			}
+		case *CircleT:
+			add(Region(CircleContour(v.pt, 0.5*v.thickness)))
+		case *FlashT:
+			a := v.aperture
+			if a == nil {
+				return nil, fmt.Errorf("flash has no aperture")
+			}
+			if a.Block != nil {
+				regions, err := modelRegions(a.Block.Primitives, t.with(v))
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, regions...)
+				break
+			}
+			r := Region(apertureContour(a))
+			if a.Hole > 0 {
+				r.Holes = []Contour{CircleContour(Pt{}, 0.5*a.Hole)}
+			}
+			result = append(result, t.with(v).region(r))
+		case *PolygonT:
+			if !v.Filled && v.Thickness <= 0 {
+				return nil, fmt.Errorf("polygon is neither filled nor stroked")
+			}
+			if len(v.Points) == 0 {
+				break
+			}
+			pts := make([]Pt, len(v.Points))
+			for i, pt := range v.Points {
+				pts[i] = Pt{pt[0] + v.Offset[0], pt[1] + v.Offset[1]}
+			}
+			if v.Filled {
+				add(Region(PolygonContour(pts)))
+			}
+			if v.Thickness > 0 {
+				for i, pt := range pts {
+					add(strokeRegion(pt, pts[(i+1)%len(pts)], CircleShape, v.Thickness))
+				}
+			}
+		case *RegionT:
+			add(v)
+		case *TextT:
+			if err := v.renderText(); err != nil {
+				return nil, err
+			}
+			// The glyph counters are holes of the smallest dark glyph
+			// polygon around them.
+			var glyphs []*RegionT
+			var pts [][]Pt
+			for _, poly := range v.Render.Polygons {
+				if poly.Dark {
+					glyphs = append(glyphs, Region(PolygonContour(poly.Pts)))
+					pts = append(pts, poly.Pts)
+				}
+			}
+			for _, poly := range v.Render.Polygons {
+				if poly.Dark || len(poly.Pts) == 0 {
+					continue
+				}
+				best := -1
+				for i, glyph := range pts {
+					if svgWinding(poly.Pts[0], glyph) != 0 && (best < 0 || math.Abs(imageArea(glyph)) < math.Abs(imageArea(pts[best]))) {
+						best = i
+					}
+				}
+				if best >= 0 {
+					glyphs[best].Holes = append(glyphs[best].Holes, PolygonContour(poly.Pts))
+				}
+			}
+			for _, r := range glyphs {
+				add(r)
+			}
+		case *ImageT:
+			for _, r := range v.Regions {
+				add(r)
+			}
+		case *BarcodeT:
+			if v.Inverted {
+				return nil, fmt.Errorf("inverted barcode is unsupported in 3D model")
+			}
+			for _, r := range v.Regions {
+				add(r)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported primitive %T in 3D model", v)
+		}
+	}
+	return result, nil
+}
+
+// strokeRegion returns the region swept by an aperture of the shape
+// drawn from p1 to p2, or nil if the thickness is zero. Shapes other
+// than RectShape are drawn with a circle.
+func strokeRegion(p1, p2 Pt, shape Shape, thickness float64) *RegionT {
+	if thickness <= 0 {
+		return nil
+	}
+	r := 0.5 * thickness
+	if shape == RectShape {
+		var corners []Pt
+		for _, pt := range []Pt{p1, p2} {
+			corners = append(corners, Pt{pt[0] - r, pt[1] - r}, Pt{pt[0] + r, pt[1] - r}, Pt{pt[0] + r, pt[1] + r}, Pt{pt[0] - r, pt[1] + r})
+		}
+		return Region(PolygonContour(convexHull(corners)))
+	}
+	length := math.Hypot(p2[0]-p1[0], p2[1]-p1[1])
+	if length < 1e-6 {
+		return Region(CircleContour(p1, r))
+	}
+	// n is the left normal of the line with a length of r.
+	n := Pt{-(p2[1] - p1[1]) * r / length, (p2[0] - p1[0]) * r / length}
+	return Region(Contour{
+		Start: Pt{p1[0] - n[0], p1[1] - n[1]},
+		Segments: []Segment{
+			LineTo(Pt{p2[0] - n[0], p2[1] - n[1]}),
+			ArcTo(Pt{p2[0] + n[0], p2[1] + n[1]}, p2, false),
+			LineTo(Pt{p1[0] + n[0], p1[1] + n[1]}),
+			ArcTo(Pt{p1[0] - n[0], p1[1] - n[1]}, p1, false),
+		},
+	})
+}
+
+// arcRegions returns the region swept by the aperture of the arc.
+// Elliptical arcs, arcs of other shapes and arcs narrower than their
+// aperture are approximated by straight strokes.
+func arcRegions(a *ArcT) []*RegionT {
+	if a.Thickness <= 0 {
+		return nil
+	}
+	r := 0.5 * a.Thickness
+	radius := a.Radius * a.XScale
+	delta := a.EndAngle - a.StartAngle
+	if a.Shape == CircleShape && a.XScale == a.YScale && radius > r+1e-6 {
+		if delta >= 2*math.Pi-1e-9 {
+			return []*RegionT{Region(CircleContour(a.Center, radius+r), CircleContour(a.Center, radius-r))}
+		}
+		at := func(angle, radius float64) Pt {
+			return Pt{a.Center[0] + radius*math.Cos(angle), a.Center[1] + radius*math.Sin(angle)}
+		}
+		return []*RegionT{Region(Contour{
+			Start: at(a.StartAngle, radius+r),
+			Segments: []Segment{
+				ArcTo(at(a.EndAngle, radius+r), a.Center, false),
+				ArcTo(at(a.EndAngle, radius-r), a.point(a.EndAngle), false),
+				ArcTo(at(a.StartAngle, radius-r), a.Center, true),
+				ArcTo(at(a.StartAngle, radius+r), a.point(a.StartAngle), false),
+			},
+		})}
+	}
+
+	// Resolution of segments is 0.1mm
+	segments := int(0.5+delta*a.Radius*math.Max(a.XScale, a.YScale)*10.0) + 1
+	var result []*RegionT
+	for i := 0; i < segments; i++ {
+		p1 := a.point(a.StartAngle + delta*float64(i)/float64(segments))
+		p2 := a.point(a.StartAngle + delta*float64(i+1)/float64(segments))
+		result = append(result, strokeRegion(p1, p2, a.Shape, a.Thickness))
+	}
+	return result
+}
+
+// apertureContour returns the outline of a standard aperture around
+// its origin. Macro apertures are approximated by their bounding boxes.
+func apertureContour(a *Aperture) Contour {
+	rect := func(lo, hi Pt) Contour {
+		return PolygonContour([]Pt{lo, {hi[0], lo[1]}, hi, {lo[0], hi[1]}})
+	}
+	switch {
+	case a.Macro != nil:
+		mbb := a.MBB()
+		return rect(mbb.Min, mbb.Max)
+	case a.Shape == RectShape:
+		hw, hh := 0.5*a.Size, 0.5*a.ySize()
+		return rect(Pt{-hw, -hh}, Pt{hw, hh})
+	case a.Shape == ObroundShape:
+		hw, hh := 0.5*a.Size, 0.5*a.ySize()
+		if hw >= hh {
+			return Contour{Start: Pt{-hw + hh, -hh}, Segments: []Segment{
+				LineTo(Pt{hw - hh, -hh}),
+				ArcTo(Pt{hw - hh, hh}, Pt{hw - hh, 0}, false),
+				LineTo(Pt{-hw + hh, hh}),
+				ArcTo(Pt{-hw + hh, -hh}, Pt{-hw + hh, 0}, false),
+			}}
+		}
+		return Contour{Start: Pt{hw, -hh + hw}, Segments: []Segment{
+			LineTo(Pt{hw, hh - hw}),
+			ArcTo(Pt{-hw, hh - hw}, Pt{0, hh - hw}, false),
+			LineTo(Pt{-hw, -hh + hw}),
+			ArcTo(Pt{hw, -hh + hw}, Pt{0, -hh + hw}, false),
+		}}
+	case a.Shape == PolygonShape:
+		var pts []Pt
+		for i := 0; i < a.Vertices; i++ {
+			pts = append(pts, rotate(Pt{0.5 * a.Size, 0}, a.Rotation+360*float64(i)/float64(a.Vertices)))
+		}
+		return PolygonContour(pts)
+	}
+	return CircleContour(Pt{}, 0.5*a.Size)
+}
+
+// convexHull returns the convex hull of the points, counterclockwise.
+func convexHull(pts []Pt) []Pt {
+	pts = append([]Pt{}, pts...)
+	sort.Slice(pts, func(a, b int) bool {
+		if pts[a][0] != pts[b][0] {
+			return pts[a][0] < pts[b][0]
+		}
+		return pts[a][1] < pts[b][1]
+	})
+	cross := func(o, a, b Pt) float64 {
+		return (a[0]-o[0])*(b[1]-o[1]) - (a[1]-o[1])*(b[0]-o[0])
+	}
+	var hull []Pt
+	// The lower hull from left to right, then the upper hull back.
+	for pass := 0; pass < 2; pass++ {
+		start := len(hull)
+		for _, pt := range pts {
+			for len(hull) >= start+2 && cross(hull[len(hull)-2], hull[len(hull)-1], pt) <= 0 {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, pt)
+		}
+		hull = hull[:len(hull)-1]
+		for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+			pts[i], pts[j] = pts[j], pts[i]
+		}
+	}
+	return hull
+}
+
+// vertex returns the index of the vertex, adding it if it is new.
+func (m *Mesh) vertex(v vec3.T) int {
+	if i, ok := m.index[v]; ok {
+		return i
+	}
+	m.index[v] = len(m.Vertices)
+	m.Vertices = append(m.Vertices, v)
+	return len(m.Vertices) - 1
+}
+
+// triangle adds a triangle unless two of its vertices are the same.
+func (m *Mesh) triangle(a, b, c int) {
+	if a != b && b != c && c != a {
+		m.Triangles = append(m.Triangles, [3]int{a, b, c})
+	}
+}
+
+// extrude adds the solid of the region from z0 up to z1 to the mesh.
+func (m *Mesh) extrude(r *RegionT, z0, z1 float64) error {
+	ring, err := r.fracture()
+	if err != nil {
+		return err
+	}
+	pts := modelPoints(ring)
+	if len(pts) < 3 {
+		return nil
+	}
+	bottom, top := make([]int, len(pts)), make([]int, len(pts))
+	for i, pt := range pts {
+		bottom[i] = m.vertex(vec3.T{pt[0], pt[1], z0})
+		top[i] = m.vertex(vec3.T{pt[0], pt[1], z1})
+	}
+	for _, t := range triangulate(pts) {
+		m.triangle(top[t[0]], top[t[1]], top[t[2]])
+		m.triangle(bottom[t[0]], bottom[t[2]], bottom[t[1]])
+	}
+
+	// The cut-ins to the holes are traversed in both directions
+	// and have no walls.
+	edges := map[[2]int]bool{}
+	for i := range pts {
+		edges[[2]int{bottom[i], bottom[(i+1)%len(pts)]}] = true
+	}
+	for i := range pts {
+		j := (i + 1) % len(pts)
+		if edges[[2]int{bottom[j], bottom[i]}] {
+			continue
+		}
+		m.triangle(bottom[i], bottom[j], top[j])
+		m.triangle(bottom[i], top[j], top[i])
+	}
+	return nil
+}
+
+// modelPoints returns the points of a ring with its arcs approximated
+// by straight segments and without repeated consecutive points.
+func modelPoints(ring []Segment) []Pt {
+	pts := Contour{Start: ring[len(ring)-1].End, Segments: ring}.Points()
+	var result []Pt
+	for _, pt := range pts[1:] {
+		if len(result) == 0 || !samePt(pt, result[len(result)-1]) {
+			result = append(result, pt)
+		}
+	}
+	if len(result) > 1 && samePt(result[0], result[len(result)-1]) {
+		result = result[:len(result)-1]
+	}
+	return result
+}
+
+// triangulate returns the triangles of a counterclockwise polygon,
+// as indexes of its points, by ear clipping. The polygon may touch
+// itself, as fractured regions do along their cut-ins.
+func triangulate(pts []Pt) [][3]int {
+	n := len(pts)
+	prev, next := make([]int, n), make([]int, n)
+	for i := range pts {
+		prev[i], next[i] = (i+n-1)%n, (i+1)%n
+	}
+	cross := func(a, b, c int) float64 {
+		return (pts[b][0]-pts[a][0])*(pts[c][1]-pts[a][1]) - (pts[b][1]-pts[a][1])*(pts[c][0]-pts[a][0])
+	}
+	// An ear is a convex corner with no other point inside it.
+	// Points at the corners of the ear are visited twice by cut-ins.
+	isEar := func(a, b, c int) bool {
+		if cross(a, b, c) <= 0 {
+			return false
+		}
+		for p := next[c]; p != a; p = next[p] {
+			if samePt(pts[p], pts[a]) || samePt(pts[p], pts[b]) || samePt(pts[p], pts[c]) {
+				continue
+			}
+			if cross(a, b, p) > 0 && cross(b, c, p) > 0 && cross(c, a, p) > 0 {
+				return false
+			}
+		}
+		return true
+	}
+
+	var result [][3]int
+	var i int
+	for count := n; count > 2; count-- {
+		// Find an ear or a degenerate corner to drop. If rounding
+		// leaves no ear, the corner is clipped anyway.
+		for tries := 0; tries < count; tries++ {
+			if math.Abs(cross(prev[i], i, next[i])) <= 1e-12 || isEar(prev[i], i, next[i]) {
+				break
+			}
+			i = next[i]
+		}
+		a, c := prev[i], next[i]
+		if cross(a, i, c) > 1e-12 {
+			result = append(result, [3]int{a, i, c})
+		}
+		next[a], prev[c] = c, a
+		i = c
+	}
+	return result
+}
+
+// normal returns the unit normal of a triangle of the mesh.
+func (m *Mesh) normal(t [3]int) vec3.T {
+	u := vec3.Sub(&m.Vertices[t[1]], &m.Vertices[t[0]])
+	v := vec3.Sub(&m.Vertices[t[2]], &m.Vertices[t[0]])
+	n := vec3.Cross(&u, &v)
+	return n.Normalized()
+}
+
+// stlTriangle is a triangle of a binary STL file.
+type stlTriangle struct {
+	Normal     [3]float32
+	Vertices   [3][3]float32
+	Attributes uint16
+}
+
+// float32s converts a vector to single precision.
+func float32s(v vec3.T) [3]float32 {
+	return [3]float32{float32(v[0]), float32(v[1]), float32(v[2])}
+}
+
+// WriteSTL writes the model as a binary STL file in millimeters to w.
+func (m *Model) WriteSTL(w io.Writer) error {
+	var buf bytes.Buffer
+	var header [80]byte
+	copy(header[:], "go-gerber board model")
+	buf.Write(header[:])
+	var count uint32
+	for _, mesh := range m.Meshes {
+		count += uint32(len(mesh.Triangles))
+	}
+	binary.Write(&buf, binary.LittleEndian, count)
+	for _, mesh := range m.Meshes {
+		for _, t := range mesh.Triangles {
+			tri := stlTriangle{Normal: float32s(mesh.normal(t))}
+			for i, v := range t {
+				tri.Vertices[i] = float32s(mesh.Vertices[v])
+			}
+			binary.Write(&buf, binary.LittleEndian, &tri)
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteOBJ writes the model as a Wavefront OBJ file in millimeters
+// to w, with an object named after each mesh.
+func (m *Model) WriteOBJ(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("# OBJ file generated by github.com/gmlewis/go-gerber\n")
+	offset := 1 // OBJ vertex indexes start at 1
+	for _, mesh := range m.Meshes {
+		fmt.Fprintf(&buf, "o %v\n", strings.ReplaceAll(mesh.Name, " ", "_"))
+		for _, v := range mesh.Vertices {
+			fmt.Fprintf(&buf, "v %v %v %v\n", objNum(v[0]), objNum(v[1]), objNum(v[2]))
+		}
+		for _, t := range mesh.Triangles {
+			fmt.Fprintf(&buf, "f %v %v %v\n", t[0]+offset, t[1]+offset, t[2]+offset)
+		}
+		offset += len(mesh.Vertices)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// objNum formats a dimension in millimeters, rounded to the nanometer.
+func objNum(v float64) string {
+	v = math.Round(v*1e6) / 1e6
+	if v == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// gltfFile is the JSON part of a glTF 2.0 file.
+type gltfFile struct {
+	Asset struct {
+		Version   string `json:"version"`
+		Generator string `json:"generator"`
+	} `json:"asset"`
+	Scene       int              `json:"scene"`
+	Scenes      []gltfScene      `json:"scenes"`
+	Nodes       []gltfNode       `json:"nodes,omitempty"`
+	Meshes      []gltfMesh       `json:"meshes,omitempty"`
+	Materials   []gltfMaterial   `json:"materials,omitempty"`
+	Accessors   []gltfAccessor   `json:"accessors,omitempty"`
+	BufferViews []gltfBufferView `json:"bufferViews,omitempty"`
+	Buffers     []gltfBuffer     `json:"buffers,omitempty"`
+}
+
+type gltfScene struct {
+	Nodes []int `json:"nodes,omitempty"`
+}
+
+type gltfNode struct {
+	Name string `json:"name"`
+	Mesh int    `json:"mesh"`
+}
+
+type gltfMesh struct {
+	Name       string          `json:"name"`
+	Primitives []gltfPrimitive `json:"primitives"`
+}
+
+type gltfPrimitive struct {
+	Attributes struct {
+		Position int `json:"POSITION"`
+	} `json:"attributes"`
+	Indices  int `json:"indices"`
+	Material int `json:"material"`
+}
+
+type gltfMaterial struct {
+	Name string `json:"name"`
+	PBR  struct {
+		BaseColorFactor [4]float64 `json:"baseColorFactor"`
+		MetallicFactor  float64    `json:"metallicFactor"`
+		RoughnessFactor float64    `json:"roughnessFactor"`
+	} `json:"pbrMetallicRoughness"`
+}
+
+type gltfAccessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float32 `json:"min,omitempty"`
+	Max           []float32 `json:"max,omitempty"`
+}
+
+type gltfBufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target"`
+}
+
+type gltfBuffer struct {
+	ByteLength int `json:"byteLength"`
+}
+
+const (
+	gltfFloat        = 5126
+	gltfUnsignedInt  = 5125
+	gltfArrayBuffer  = 34962
+	gltfElementArray = 34963
+	gltfMagic        = 0x46546c67 // "glTF"
+	gltfChunkJSON    = 0x4e4f534a // "JSON"
+	gltfChunkBIN     = 0x004e4942 // "BIN"
+)
+
+// gltfLinear converts an 8-bit sRGB color component to linear.
+func gltfLinear(c uint8) float64 {
+	v := float64(c) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// WriteGLTF writes the model as a binary glTF 2.0 file (.glb) to w,
+// in meters with the Y axis up (the board's Z axis) and a node and a
+// material for each mesh. Empty meshes are omitted.
+func (m *Model) WriteGLTF(w io.Writer) error {
+	var doc gltfFile
+	doc.Asset.Version = "2.0"
+	doc.Asset.Generator = "github.com/gmlewis/go-gerber"
+	doc.Scenes = []gltfScene{{}}
+
+	var bin bytes.Buffer
+	view := func(target int) int {
+		offset := 0
+		if n := len(doc.BufferViews); n > 0 {
+			offset = doc.BufferViews[n-1].ByteOffset + doc.BufferViews[n-1].ByteLength
+		}
+		doc.BufferViews = append(doc.BufferViews, gltfBufferView{ByteOffset: offset, ByteLength: bin.Len() - offset, Target: target})
+		return len(doc.BufferViews) - 1
+	}
+	for _, mesh := range m.Meshes {
+		if len(mesh.Triangles) == 0 {
+			continue
+		}
+		lo := []float32{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32}
+		hi := []float32{-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32}
+		for _, v := range mesh.Vertices {
+			// Millimeters with Z up to meters with Y up.
+			p := [3]float32{float32(v[0] / 1000), float32(v[2] / 1000), float32(-v[1] / 1000)}
+			for i := range p {
+				lo[i], hi[i] = min(lo[i], p[i]), max(hi[i], p[i])
+			}
+			binary.Write(&bin, binary.LittleEndian, p)
+		}
+		doc.Accessors = append(doc.Accessors, gltfAccessor{
+			BufferView:    view(gltfArrayBuffer),
+			ComponentType: gltfFloat,
+			Count:         len(mesh.Vertices),
+			Type:          "VEC3",
+			Min:           lo,
+			Max:           hi,
+		})
+		for _, t := range mesh.Triangles {
+			binary.Write(&bin, binary.LittleEndian, [3]uint32{uint32(t[0]), uint32(t[1]), uint32(t[2])})
+		}
+		doc.Accessors = append(doc.Accessors, gltfAccessor{
+			BufferView:    view(gltfElementArray),
+			ComponentType: gltfUnsignedInt,
+			Count:         3 * len(mesh.Triangles),
+			Type:          "SCALAR",
+		})
+
+		var material gltfMaterial
+		material.Name = mesh.Name
+		c := mesh.Color
+		material.PBR.BaseColorFactor = [4]float64{gltfLinear(c.R), gltfLinear(c.G), gltfLinear(c.B), float64(c.A) / 255}
+		material.PBR.RoughnessFactor = 0.5
+		doc.Materials = append(doc.Materials, material)
+
+		var prim gltfPrimitive
+		prim.Attributes.Position = len(doc.Accessors) - 2
+		prim.Indices = len(doc.Accessors) - 1
+		prim.Material = len(doc.Materials) - 1
+		doc.Meshes = append(doc.Meshes, gltfMesh{Name: mesh.Name, Primitives: []gltfPrimitive{prim}})
+		doc.Nodes = append(doc.Nodes, gltfNode{Name: mesh.Name, Mesh: len(doc.Meshes) - 1})
+		doc.Scenes[0].Nodes = append(doc.Scenes[0].Nodes, len(doc.Nodes)-1)
+	}
+	if bin.Len() > 0 {
+		doc.Buffers = []gltfBuffer{{ByteLength: bin.Len()}}
+	}
+
+	data, err := json.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+	// Chunks are padded to 4 bytes, JSON with spaces.
+	for len(data)%4 != 0 {
+		data = append(data, ' ')
+	}
+	length := 12 + 8 + len(data)
+	if bin.Len() > 0 {
+		length += 8 + bin.Len()
+	}
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, [3]uint32{gltfMagic, 2, uint32(length)})
+	binary.Write(&buf, binary.LittleEndian, [2]uint32{uint32(len(data)), gltfChunkJSON})
+	buf.Write(data)
+	if bin.Len() > 0 {
+		binary.Write(&buf, binary.LittleEndian, [2]uint32{uint32(bin.Len()), gltfChunkBIN})
+		buf.Write(bin.Bytes())
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,339 @@
+package gerber
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gmlewis/go3d/float64/vec3"
+)
+
+// checkClosed checks that the mesh is a closed surface with
+// consistently oriented triangles.
+func checkClosed(t *testing.T, m *Mesh) {
+	t.Helper()
+	edges := map[[2]int]int{}
+	for _, tri := range m.Triangles {
+		for i := range tri {
+			edges[[2]int{tri[i], tri[(i+1)%3]}]++
+		}
+	}
+	for e, n := range edges {
+		if n != 1 || edges[[2]int{e[1], e[0]}] != 1 {
+			t.Errorf("mesh %v: edge %v is used %v times and reversed %v times", m.Name, e, n, edges[[2]int{e[1], e[0]}])
+			return
+		}
+	}
+}
+
+// meshVolume returns the volume enclosed by the mesh.
+func meshVolume(m *Mesh) float64 {
+	var v float64
+	for _, tri := range m.Triangles {
+		c := vec3.Cross(&m.Vertices[tri[1]], &m.Vertices[tri[2]])
+		v += vec3.Dot(&m.Vertices[tri[0]], &c) / 6
+	}
+	return v
+}
+
+func TestTriangulate(t *testing.T) {
+	tests := []struct {
+		name   string
+		region *RegionT
+		want   float64
+	}{
+		{
+			name:   "square",
+			region: Region(PolygonContour([]Pt{{0, 0}, {2, 0}, {2, 2}, {0, 2}})),
+			want:   4,
+		},
+		{
+			name:   "clockwise concave",
+			region: Region(PolygonContour([]Pt{{0, 0}, {0, 3}, {1, 3}, {1, 1}, {3, 1}, {3, 0}})),
+			want:   5,
+		},
+		{
+			name: "square with holes",
+			region: Region(PolygonContour([]Pt{{0, 0}, {10, 0}, {10, 10}, {0, 10}}),
+				PolygonContour([]Pt{{2, 2}, {4, 2}, {4, 4}, {2, 4}}),
+				PolygonContour([]Pt{{6, 6}, {8, 6}, {8, 8}, {6, 8}}),
+				PolygonContour([]Pt{{2, 6}, {4, 6}, {3, 8}})),
+			want: 100 - 4 - 4 - 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ring, err := tt.region.fracture()
+			if err != nil {
+				t.Fatal(err)
+			}
+			pts := modelPoints(ring)
+			var area float64
+			for _, tri := range triangulate(pts) {
+				a, b, c := pts[tri[0]], pts[tri[1]], pts[tri[2]]
+				v := 0.5 * ((b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0]))
+				if v <= 0 {
+					t.Errorf("triangle %v is not counterclockwise", tri)
+				}
+				area += v
+			}
+			if math.Abs(area-tt.want) > 1e-9 {
+				t.Errorf("area = %v, want %v", area, tt.want)
+			}
+		})
+	}
+}
+
+func TestOutlineContours(t *testing.T) {
+	// A rounded rectangle drawn out of order and in both directions,
+	// and an open line.
+	edges, _ := outlineEdges([]Primitive{
+		Line(20, 10, 0, 10, CircleShape, 0.1),
+		Line(20, 0, 0, 0, CircleShape, 0.1),
+		Arc(Pt{0, 5}, 5, CircleShape, 1, 1, 90, 270, 0.1),
+		Arc(Pt{20, 5}, 5, CircleShape, 1, 1, -90, 90, 0.1),
+		Line(30, 0, 30, 10, CircleShape, 0.1),
+	}, flashTransform{})
+	got := outlineContours(edges)
+	if len(got) != 1 {
+		t.Fatalf("got %v contours, want 1", len(got))
+	}
+	if area, want := math.Abs(signedArea(got[0].ring())), 200+25*math.Pi; math.Abs(area-want) > 1e-9 {
+		t.Errorf("area = %v, want %v", area, want)
+	}
+	if want := (MBB{Min: Pt{-5, 0}, Max: Pt{25, 10}}); !mbbClose(got[0].MBB(), want) {
+		t.Errorf("MBB = %v, want %v", got[0].MBB(), want)
+	}
+}
+
+func TestAddHoles(t *testing.T) {
+	// A via through the end of a trace, a hole inside the trace, a hole
+	// away from it and a hole on top of the one inside it.
+	r := strokeRegion(Pt{0, 0}, Pt{10, 0}, CircleShape, 0.25)
+	via := newModelHole(CircleContour(Pt{10, 0}, 0.15))
+	inside := newModelHole(CircleContour(Pt{5, 0}, 0.05))
+	holes := []*modelHole{
+		via,
+		inside,
+		newModelHole(CircleContour(Pt{5, 5}, 0.15)),
+		newModelHole(CircleContour(Pt{5, 0}, 0.08)),
+	}
+	crossing := addHoles(r, holes)
+	if len(crossing) != 1 || crossing[0] != via {
+		t.Errorf("crossing = %v, want the via", crossing)
+	}
+	if len(r.Holes) != 1 || r.Holes[0].MBB() != inside.mbb {
+		t.Errorf("holes = %v, want the hole inside the trace", r.Holes)
+	}
+}
+
+// testModelGerber returns a rounded board with a cutout, two drill
+// holes and copper on both sides.
+func testModelGerber() *Gerber {
+	g := New("test")
+	g.Outline().Add(
+		Line(0, 0, 20, 0, CircleShape, 0.1),
+		Arc(Pt{20, 5}, 5, CircleShape, 1, 1, -90, 90, 0.1),
+		Line(20, 10, 0, 10, CircleShape, 0.1),
+		Arc(Pt{0, 5}, 5, CircleShape, 1, 1, 90, 270, 0.1),
+		Arc(Pt{10, 5}, 1, CircleShape, 1, 1, 0, 360, 0.1),
+	)
+	g.Drill().Add(Flash(Pt{3, 5}, CircleAperture(0.8)), Flash(Pt{17, 5}, CircleAperture(0.8)))
+	g.TopCopper().Add(
+		Flash(Pt{3, 5}, CircleAperture(2)),
+		Line(3, 8, 17, 8, CircleShape, 0.5),
+	)
+	g.BottomCopper().Add(Flash(Pt{17, 5}, &Aperture{Shape: RectShape, Size: 2, YSize: 2, Hole: 0.8}))
+	return g
+}
+
+func TestGerber_Model(t *testing.T) {
+	m, err := testModelGerber().Model()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, mesh := range m.Meshes {
+		names = append(names, mesh.Name)
+		checkClosed(t, mesh)
+	}
+	if got, want := strings.Join(names, ","), "board,test.gtl,test.gbl"; got != want {
+		t.Fatalf("meshes = %v, want %v", got, want)
+	}
+
+	box := m.Box()
+	if want := (vec3.Box{Min: vec3.T{-5, 0, 0}, Max: vec3.T{25, 10, 1.6}}); vec3.Distance(&box.Min, &want.Min) > 1e-9 || vec3.Distance(&box.Max, &want.Max) > 1e-9 {
+		t.Errorf("Box = %v, want %v", box, want)
+	}
+
+	// The arcs are approximated by chords.
+	hole := math.Pi * 0.4 * 0.4
+	tests := []struct {
+		name string
+		want float64
+	}{
+		{name: "board", want: (200 + 25*math.Pi - math.Pi - 2*hole) * (1.6 - 2*0.035)},
+		{name: "test.gtl", want: (math.Pi - hole + 14*0.5 + math.Pi*0.25*0.25) * 0.035},
+		{name: "test.gbl", want: (4 - hole) * 0.035},
+	}
+	for i, tt := range tests {
+		if got := meshVolume(m.Meshes[i]); math.Abs(got-tt.want) > 0.005*tt.want {
+			t.Errorf("%v: volume = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	// The copper is on the outside of the board.
+	for i, want := range [][2]float64{{0.035, 1.565}, {1.565, 1.6}, {0, 0.035}} {
+		lo, hi := math.Inf(1), math.Inf(-1)
+		for _, v := range m.Meshes[i].Vertices {
+			lo, hi = math.Min(lo, v[2]), math.Max(hi, v[2])
+		}
+		if math.Abs(lo-want[0]) > 1e-9 || math.Abs(hi-want[1]) > 1e-9 {
+			t.Errorf("%v: Z from %v to %v, want %v to %v", m.Meshes[i].Name, lo, hi, want[0], want[1])
+		}
+	}
+}
+
+func TestGerber_Model_NoOutline(t *testing.T) {
+	g := New("bare")
+	g.Job.BoardThickness = 0.8
+	g.TopSilkscreen().Add(Polygon(Pt{}, true, []Pt{{0, 0}, {4, 0}, {4, 2}, {0, 2}}, 0))
+	m, err := g.Model()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Meshes) != 1 {
+		t.Fatalf("got %v meshes, want 1", len(m.Meshes))
+	}
+	checkClosed(t, m.Meshes[0])
+	if got := meshVolume(m.Meshes[0]); math.Abs(got-8*0.8) > 1e-9 {
+		t.Errorf("volume = %v, want %v", got, 8*0.8)
+	}
+
+	if _, err := New("empty").Model(); err == nil {
+		t.Errorf("Model of an empty design = nil error")
+	}
+}
+
+func TestGerber_Model_ClearPolarity(t *testing.T) {
+	barcode, err := Code128(5, 5, "CLEAR", 0.2, 2, &BarcodeOpts{Inverted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		p    Primitive
+	}{
+		{name: "clear", p: Clear(Circle(Pt{3, 5}, 1))},
+		{name: "knockout", p: Knockout(0.5, Circle(Pt{3, 5}, 1))},
+		{name: "inverted barcode", p: barcode},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := testModelGerber()
+			g.TopCopper().Add(tt.p)
+			if _, err := g.Model(); err == nil || !strings.Contains(err.Error(), "unsupported in 3D model") {
+				t.Errorf("Model error = %v, want unsupported in 3D model", err)
+			}
+		})
+	}
+}
+
+func TestGerber_WriteModel(t *testing.T) {
+	g := testModelGerber()
+	m, err := g.Model()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var triangles, vertices int
+	for _, mesh := range m.Meshes {
+		triangles += len(mesh.Triangles)
+		vertices += len(mesh.Vertices)
+	}
+
+	t.Run("STL", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := g.WriteModel(&buf, STLModel); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+		if len(data) != 84+50*triangles {
+			t.Fatalf("got %v bytes, want %v", len(data), 84+50*triangles)
+		}
+		if strings.HasPrefix(string(data), "solid") {
+			t.Errorf("binary STL header starts with %q", "solid")
+		}
+		if got := binary.LittleEndian.Uint32(data[80:]); got != uint32(triangles) {
+			t.Errorf("triangle count = %v, want %v", got, triangles)
+		}
+	})
+
+	t.Run("OBJ", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := g.WriteModel(&buf, OBJModel); err != nil {
+			t.Fatal(err)
+		}
+		counts := map[string]int{}
+		var maxIndex int
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			fields := strings.Fields(line)
+			counts[fields[0]]++
+			if fields[0] == "f" {
+				for _, f := range fields[1:] {
+					i, err := strconv.Atoi(f)
+					if err != nil {
+						t.Fatal(err)
+					}
+					maxIndex = max(maxIndex, i)
+				}
+			}
+		}
+		if counts["o"] != 3 || counts["v"] != vertices || counts["f"] != triangles {
+			t.Errorf("got %v objects, %v vertices and %v faces, want 3, %v and %v", counts["o"], counts["v"], counts["f"], vertices, triangles)
+		}
+		if maxIndex != vertices {
+			t.Errorf("largest vertex index = %v, want %v", maxIndex, vertices)
+		}
+	})
+
+	t.Run("glTF", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := g.WriteModel(&buf, GLTFModel); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+		if len(data)%4 != 0 {
+			t.Errorf("length %v is not a multiple of 4", len(data))
+		}
+		header := make([]uint32, 5)
+		if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, header); err != nil {
+			t.Fatal(err)
+		}
+		if header[0] != gltfMagic || header[1] != 2 || header[2] != uint32(len(data)) || header[4] != gltfChunkJSON {
+			t.Fatalf("header = %x", header)
+		}
+		var doc gltfFile
+		if err := json.Unmarshal(data[20:20+header[3]], &doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.Asset.Version != "2.0" || len(doc.Nodes) != 3 || len(doc.Scenes[0].Nodes) != 3 || len(doc.Accessors) != 6 {
+			t.Errorf("glTF = %+v", doc)
+		}
+		binLength := binary.LittleEndian.Uint32(data[20+header[3]:])
+		if want := 12*vertices + 12*triangles; int(binLength) != want || doc.Buffers[0].ByteLength != want {
+			t.Errorf("buffer length = %v and %v, want %v", binLength, doc.Buffers[0].ByteLength, want)
+		}
+		// The board is 1.6mm thick along the Y axis in meters.
+		if a := doc.Accessors[0]; a.Count != len(m.Meshes[0].Vertices) || math.Abs(float64(a.Max[1]-a.Min[1])-0.00153) > 1e-7 {
+			t.Errorf("board accessor = %+v", a)
+		}
+	})
+
+	if err := g.WriteModel(&bytes.Buffer{}, ModelFormat(3)); err == nil {
+		t.Errorf("unknown format error = nil")
+	}
+}
@@ -0,0 +1,485 @@
+package gerber
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// PanelSeparation represents how the boards of a panel are separated.
+type PanelSeparation int
+
+const (
+	// VScore separates the boards with V-score lines along the edges of
+	// their bounding boxes. The boards should be rectangular and must be
+	// butted against each other (the steps equal to the board size).
+	VScore PanelSeparation = iota
+	// MouseBites holds the boards in the panel with perforated tabs that
+	// bridge the gaps between neighboring boards and the rails.
+	MouseBites
+)
+
+const (
+	// panelLineWidth is the width of the generated panel outline strokes.
+	panelLineWidth = 0.1
+
+	defaultTabWidth     = 3.0
+	defaultHoleDiameter = 0.5
+	defaultHoleSpacing  = 0.8
+)
+
+// Panel represents the step-and-repeat panelization of a design.
+// All dimensions are in millimeters.
+type Panel struct {
+	// XRepeat and YRepeat are the number of copies of the board
+	// in the X and Y directions.
+	XRepeat, YRepeat int
+	// XStep and YStep are the distances between neighboring copies.
+	XStep, YStep float64
+	// Separation selects how the boards are separated.
+	Separation PanelSeparation
+	// RailWidth is the width of the rails along the bottom and top
+	// of the panel (zero for no rails).
+	RailWidth float64
+	// TabWidth is the width of each mouse-bite tab (default 3mm).
+	TabWidth float64
+	// HoleDiameter is the diameter of the mouse-bite holes (default 0.5mm).
+	HoleDiameter float64
+	// HoleSpacing is the center-to-center distance between
+	// the mouse-bite holes (default 0.8mm).
+	HoleSpacing float64
+}
+
+// Validate returns an error if the panel cannot be generated.
+func (p *Panel) Validate() error {
+	if p.XRepeat < 1 || p.YRepeat < 1 {
+		return fmt.Errorf("invalid panel repeat %vx%v", p.XRepeat, p.YRepeat)
+	}
+	if (p.XRepeat > 1 && p.XStep <= 0) || (p.YRepeat > 1 && p.YStep <= 0) {
+		return fmt.Errorf("invalid panel step %vx%v", p.XStep, p.YStep)
+	}
+	if p.Separation != VScore && p.Separation != MouseBites {
+		return fmt.Errorf("invalid panel separation %v", p.Separation)
+	}
+	return nil
+}
+
+// offsets returns the offset of each copy of the board in the panel.
+func (p *Panel) offsets() []Pt {
+	var result []Pt
+	for j := 0; j < p.YRepeat; j++ {
+		for i := 0; i < p.XRepeat; i++ {
+			result = append(result, Pt{float64(i) * p.XStep, float64(j) * p.YStep})
+		}
+	}
+	return result
+}
+
+// offsets returns the offsets of the copies of the layer's design,
+// or a single zero offset if the design is not panelized.
+func (l *Layer) offsets() []Pt {
+	if l.g == nil || l.g.Panel == nil {
+		return []Pt{{0, 0}}
+	}
+	return l.g.Panel.offsets()
+}
+
+// writeStepAndRepeat opens a step-and-repeat block.
+func (p *Panel) writeStepAndRepeat(w io.Writer, f *Format) {
+	fmt.Fprintf(w, "%%SRX%vY%vI%vJ%v*%%\n", p.XRepeat, p.YRepeat, f.Size(p.XStep), f.Size(p.YStep))
+}
+
+// outlineLayer returns a new layer holding the panel outline generated
+// from the board outline layer l.
+func (p *Panel) outlineLayer(l *Layer) (*Layer, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	out := &Layer{
+		Filename:    l.Filename,
+		Type:        l.Type,
+		apertureMap: map[string]int{"default": -1},
+		g:           l.g,
+	}
+
+	board := l.MBB()
+	size := Pt{board.Max[0] - board.Min[0], board.Max[1] - board.Min[1]}
+	panel := MBB{
+		Min: board.Min,
+		Max: Pt{board.Max[0] + float64(p.XRepeat-1)*p.XStep, board.Max[1] + float64(p.YRepeat-1)*p.YStep},
+	}
+	line := func(p1, p2 Pt) *LineT {
+		return Line(p1[0], p1[1], p2[0], p2[1], CircleShape, panelLineWidth)
+	}
+
+	if p.Separation == VScore {
+		const eps = 1e-6
+		if (p.XRepeat > 1 && math.Abs(p.XStep-size[0]) > eps) || (p.YRepeat > 1 && math.Abs(p.YStep-size[1]) > eps) {
+			return nil, fmt.Errorf("v-scores need boards without gaps: board size %vx%v, step %vx%v", size[0], size[1], p.XStep, p.YStep)
+		}
+		outer := panel
+		outer.Min[1] -= p.RailWidth
+		outer.Max[1] += p.RailWidth
+		out.Add(rectangle(outer, line)...)
+
+		// Score along both edges of every board (deduplicated),
+		// skipping the edges that coincide with the panel outline.
+		var xs, ys []float64
+		for i := 0; i < p.XRepeat; i++ {
+			xs = append(xs, board.Min[0]+float64(i)*p.XStep, board.Max[0]+float64(i)*p.XStep)
+		}
+		for j := 0; j < p.YRepeat; j++ {
+			ys = append(ys, board.Min[1]+float64(j)*p.YStep, board.Max[1]+float64(j)*p.YStep)
+		}
+		for _, x := range uniqueInside(xs, outer.Min[0], outer.Max[0]) {
+			score := line(Pt{x, outer.Min[1]}, Pt{x, outer.Max[1]})
+			score.Function = "Other,VScore"
+			out.Add(score)
+		}
+		for _, y := range uniqueInside(ys, outer.Min[1], outer.Max[1]) {
+			score := line(Pt{outer.Min[0], y}, Pt{outer.Max[0], y})
+			score.Function = "Other,VScore"
+			out.Add(score)
+		}
+		return out, nil
+	}
+
+	// MouseBites
+	tabWidth, holeD, holeSpacing := p.TabWidth, p.HoleDiameter, p.HoleSpacing
+	if tabWidth <= 0 {
+		tabWidth = defaultTabWidth
+	}
+	if holeD <= 0 {
+		holeD = defaultHoleDiameter
+	}
+	if holeSpacing <= 0 {
+		holeSpacing = defaultHoleSpacing
+	}
+	gap := Pt{p.XStep - size[0], p.YStep - size[1]}
+	if (p.XRepeat > 1 && gap[0] <= 0) || (p.YRepeat > 1 && gap[1] <= 0) {
+		return nil, fmt.Errorf("mouse bites need gaps between the boards: board size %vx%v, step %vx%v", size[0], size[1], p.XStep, p.YStep)
+	}
+	railGap := gap[1]
+	if p.YRepeat == 1 {
+		railGap = gap[0]
+	}
+	rails := p.RailWidth > 0
+	if rails && railGap <= 0 {
+		return nil, fmt.Errorf("mouse bites need gaps between the boards and the rails")
+	}
+
+	center := Pt{0.5 * (board.Min[0] + board.Max[0]), 0.5 * (board.Min[1] + board.Max[1])}
+	ht := 0.5 * tabWidth
+	const eps = 1e-6
+	tabs := [4]*tabT{
+		{box: MBB{Min: Pt{center[0], center[1] - ht}, Max: Pt{board.Max[0] + eps, center[1] + ht}}}, // right
+		{box: MBB{Min: Pt{board.Min[0] - eps, center[1] - ht}, Max: Pt{center[0], center[1] + ht}}}, // left
+		{box: MBB{Min: Pt{center[0] - ht, center[1]}, Max: Pt{center[0] + ht, board.Max[1] + eps}}}, // top
+		{box: MBB{Min: Pt{center[0] - ht, board.Min[1] - eps}, Max: Pt{center[0] + ht, center[1]}}}, // bottom
+	}
+	const right, left, top, bottom = 0, 1, 2, 3
+	// Default cut points on the bounding box, used if the outline
+	// does not cross the edges of a tab.
+	tabs[right].lo, tabs[right].hi = Pt{board.Max[0], center[1] - ht}, Pt{board.Max[0], center[1] + ht}
+	tabs[left].lo, tabs[left].hi = Pt{board.Min[0], center[1] - ht}, Pt{board.Min[0], center[1] + ht}
+	tabs[top].lo, tabs[top].hi = Pt{center[0] - ht, board.Max[1]}, Pt{center[0] + ht, board.Max[1]}
+	tabs[bottom].lo, tabs[bottom].hi = Pt{center[0] - ht, board.Min[1]}, Pt{center[0] + ht, board.Min[1]}
+
+	// Find where the outline crosses the edges of each tab so that
+	// the bridges of neighboring boards line up.
+	for _, tab := range tabs {
+		if _, err := clipOutside(l.Primitives, tab); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := 0; i < p.XRepeat; i++ {
+		for j := 0; j < p.YRepeat; j++ {
+			offset := Pt{float64(i) * p.XStep, float64(j) * p.YStep}
+			active := [4]bool{
+				right:  i < p.XRepeat-1,
+				left:   i > 0,
+				top:    j < p.YRepeat-1 || rails,
+				bottom: j > 0 || rails,
+			}
+
+			prims := l.Primitives
+			for side, tab := range tabs {
+				if !active[side] {
+					continue
+				}
+				var err error
+				if prims, err = clipOutside(prims, tab); err != nil {
+					return nil, err
+				}
+				// Perforate the board edge across the tab.
+				horizontal := side == top || side == bottom
+				n := int(tabWidth / holeSpacing)
+				mid := Pt{0.5 * (tab.lo[0] + tab.hi[0]), 0.5 * (tab.lo[1] + tab.hi[1])}
+				for k := 0; k < n; k++ {
+					d := (float64(k) - 0.5*float64(n-1)) * holeSpacing
+					hole := Pt{mid[0] + offset[0], mid[1] + d + offset[1]}
+					if horizontal {
+						hole = Pt{mid[0] + d + offset[0], mid[1] + offset[1]}
+					}
+					out.Add(Arc(hole, 0.5*holeD, CircleShape, 1, 1, 0, 360, panelLineWidth))
+				}
+			}
+			for _, prim := range prims {
+				moved, err := translate(prim, offset)
+				if err != nil {
+					return nil, err
+				}
+				out.Add(moved)
+			}
+
+			// Bridge the tabs to the neighbors to the right and above.
+			add := func(p1, p2 Pt) {
+				out.Add(line(Pt{p1[0] + offset[0], p1[1] + offset[1]}, Pt{p2[0] + offset[0], p2[1] + offset[1]}))
+			}
+			if active[right] {
+				next := Pt{tabs[left].lo[0] + p.XStep, tabs[left].lo[1]}
+				add(tabs[right].lo, next)
+				next = Pt{tabs[left].hi[0] + p.XStep, tabs[left].hi[1]}
+				add(tabs[right].hi, next)
+			}
+			if j < p.YRepeat-1 {
+				add(tabs[top].lo, Pt{tabs[bottom].lo[0], tabs[bottom].lo[1] + p.YStep})
+				add(tabs[top].hi, Pt{tabs[bottom].hi[0], tabs[bottom].hi[1] + p.YStep})
+			}
+			if rails && j == p.YRepeat-1 {
+				y := panel.Max[1] + railGap
+				add(tabs[top].lo, Pt{tabs[top].lo[0], y - offset[1]})
+				add(tabs[top].hi, Pt{tabs[top].hi[0], y - offset[1]})
+			}
+			if rails && j == 0 {
+				y := panel.Min[1] - railGap
+				add(tabs[bottom].lo, Pt{tabs[bottom].lo[0], y})
+				add(tabs[bottom].hi, Pt{tabs[bottom].hi[0], y})
+			}
+		}
+	}
+
+	if rails {
+		// The inner edge of each rail is interrupted by the tabs.
+		var gaps [][2]float64
+		for i := 0; i < p.XRepeat; i++ {
+			x := center[0] + float64(i)*p.XStep
+			gaps = append(gaps, [2]float64{x - ht, x + ht})
+		}
+		for _, y := range [][2]float64{
+			{panel.Max[1] + railGap, panel.Max[1] + railGap + p.RailWidth},
+			{panel.Min[1] - railGap, panel.Min[1] - railGap - p.RailWidth},
+		} {
+			inner, outer := y[0], y[1]
+			x := panel.Min[0]
+			for _, g := range gaps {
+				out.Add(line(Pt{x, inner}, Pt{g[0], inner}))
+				x = g[1]
+			}
+			out.Add(
+				line(Pt{x, inner}, Pt{panel.Max[0], inner}),
+				line(Pt{panel.Max[0], inner}, Pt{panel.Max[0], outer}),
+				line(Pt{panel.Max[0], outer}, Pt{panel.Min[0], outer}),
+				line(Pt{panel.Min[0], outer}, Pt{panel.Min[0], inner}),
+			)
+		}
+	}
+
+	return out, nil
+}
+
+// rectangle returns the four lines outlining the box.
+func rectangle(box MBB, line func(p1, p2 Pt) *LineT) []Primitive {
+	return []Primitive{
+		line(box.Min, Pt{box.Max[0], box.Min[1]}),
+		line(Pt{box.Max[0], box.Min[1]}, box.Max),
+		line(box.Max, Pt{box.Min[0], box.Max[1]}),
+		line(Pt{box.Min[0], box.Max[1]}, box.Min),
+	}
+}
+
+// uniqueInside returns the sorted unique values strictly between min and max.
+func uniqueInside(values []float64, min, max float64) []float64 {
+	const eps = 1e-6
+	sort.Float64s(values)
+	var result []float64
+	for _, v := range values {
+		if v <= min+eps || v >= max-eps {
+			continue
+		}
+		if n := len(result); n > 0 && v-result[n-1] < eps {
+			continue
+		}
+		result = append(result, v)
+	}
+	return result
+}
+
+// tabT represents a mouse-bite tab on one side of the board.
+type tabT struct {
+	// box is the region of the board outline removed by the tab.
+	box MBB
+	// lo and hi are the points where the outline was cut on the
+	// low and high edges of the tab.
+	lo, hi Pt
+}
+
+// record records a cut point of the outline if it lies on one of
+// the edges of the tab across the outline.
+func (t *tabT) record(pt Pt) {
+	const eps = 1e-6
+	if t.box.Max[1]-t.box.Min[1] < t.box.Max[0]-t.box.Min[0] {
+		// Tab on the left or right side of the board.
+		if math.Abs(pt[1]-t.box.Min[1]) < eps {
+			t.lo = pt
+		} else if math.Abs(pt[1]-t.box.Max[1]) < eps {
+			t.hi = pt
+		}
+		return
+	}
+	if math.Abs(pt[0]-t.box.Min[0]) < eps {
+		t.lo = pt
+	} else if math.Abs(pt[0]-t.box.Max[0]) < eps {
+		t.hi = pt
+	}
+}
+
+// clipOutside returns the parts of the line and arc primitives that lie
+// outside the tab's box, recording the points where they were cut.
+// Other primitives are returned unchanged.
+func clipOutside(prims []Primitive, tab *tabT) ([]Primitive, error) {
+	box := tab.box
+	inside := func(pt Pt) bool {
+		return pt[0] > box.Min[0] && pt[0] < box.Max[0] && pt[1] > box.Min[1] && pt[1] < box.Max[1]
+	}
+
+	var result []Primitive
+	for _, prim := range prims {
+		switch v := prim.(type) {
+		case *LineT:
+			// Find the parameters where the line crosses the box edges.
+			ts := []float64{0, 1}
+			d := Pt{v.P2[0] - v.P1[0], v.P2[1] - v.P1[1]}
+			for axis := 0; axis < 2; axis++ {
+				if d[axis] == 0 {
+					continue
+				}
+				for _, edge := range []float64{box.Min[axis], box.Max[axis]} {
+					if t := (edge - v.P1[axis]) / d[axis]; t > 0 && t < 1 {
+						ts = append(ts, t)
+					}
+				}
+			}
+			sort.Float64s(ts)
+			at := func(t float64) Pt { return Pt{v.P1[0] + t*d[0], v.P1[1] + t*d[1]} }
+			for i := 1; i < len(ts); i++ {
+				if ts[i]-ts[i-1] < 1e-12 {
+					continue
+				}
+				p1, p2 := at(ts[i-1]), at(ts[i])
+				if inside(at(0.5 * (ts[i-1] + ts[i]))) {
+					tab.record(p1)
+					tab.record(p2)
+					continue
+				}
+				l := *v
+				l.P1, l.P2, l.mbb = p1, p2, nil
+				result = append(result, &l)
+			}
+		case *ArcT:
+			// Find the angles where the arc crosses the box edges.
+			as := []float64{v.StartAngle, v.EndAngle}
+			addAngle := func(a float64) {
+				for a < v.StartAngle {
+					a += 2 * math.Pi
+				}
+				for ; a < v.EndAngle; a += 2 * math.Pi {
+					as = append(as, a)
+				}
+			}
+			for _, x := range []float64{box.Min[0], box.Max[0]} {
+				if c := (x - v.Center[0]) / (v.XScale * v.Radius); c >= -1 && c <= 1 {
+					addAngle(math.Acos(c))
+					addAngle(-math.Acos(c))
+				}
+			}
+			for _, y := range []float64{box.Min[1], box.Max[1]} {
+				if s := (y - v.Center[1]) / (v.YScale * v.Radius); s >= -1 && s <= 1 {
+					addAngle(math.Asin(s))
+					addAngle(math.Pi - math.Asin(s))
+				}
+			}
+			sort.Float64s(as)
+			for i := 1; i < len(as); i++ {
+				if as[i]-as[i-1] < 1e-12 {
+					continue
+				}
+				if inside(v.point(0.5 * (as[i-1] + as[i]))) {
+					tab.record(v.point(as[i-1]))
+					tab.record(v.point(as[i]))
+					continue
+				}
+				// Merge with the previous piece if contiguous.
+				if n := len(result); n > 0 {
+					if prev, ok := result[n-1].(*ArcT); ok && prev.Center == v.Center && prev.EndAngle == as[i-1] && prev.Radius == v.Radius {
+						prev.EndAngle = as[i]
+						continue
+					}
+				}
+				a := *v
+				a.StartAngle, a.EndAngle, a.mbb = as[i-1], as[i], nil
+				result = append(result, &a)
+			}
+		default:
+			result = append(result, prim)
+		}
+	}
+	return result, nil
+}
+
+// translate returns a copy of the primitive moved by the offset.
+func translate(prim Primitive, offset Pt) (Primitive, error) {
+	move := func(pt Pt) Pt { return Pt{pt[0] + offset[0], pt[1] + offset[1]} }
+	switch v := prim.(type) {
+	case *LineT:
+		l := *v
+		l.P1, l.P2, l.mbb = move(v.P1), move(v.P2), nil
+		return &l, nil
+	case *ArcT:
+		a := *v
+		a.Center, a.mbb = move(v.Center), nil
+		return &a, nil
+	case *CircleT:
+		c := *v
+		c.pt, c.mbb = move(v.pt), nil
+		return &c, nil
+	case *FlashT:
+		f := *v
+		f.Center, f.mbb = move(v.Center), nil
+		return &f, nil
+	case *PolygonT:
+		p := *v
+		p.Offset, p.mbb = move(v.Offset), nil
+		return &p, nil
+	case *RegionT:
+		r := *v
+		r.Outer, r.Holes, r.mbb = v.Outer.translate(offset), nil, nil
+		for _, h := range v.Holes {
+			r.Holes = append(r.Holes, h.translate(offset))
+		}
+		return &r, nil
+	case *ClearT:
+		c := &ClearT{}
+		for _, p := range v.Primitives {
+			moved, err := translate(p, offset)
+			if err != nil {
+				return nil, err
+			}
+			c.Primitives = append(c.Primitives, moved)
+		}
+		return c, nil
+	}
+	return nil, fmt.Errorf("unable to translate primitive %T", prim)
+}
@@ -0,0 +1,166 @@
+package gerber
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func panelDesign(panel *Panel) *Gerber {
+	g := New("panel")
+	g.CreationDate = time.Date(2019, 6, 8, 19, 30, 0, 0, time.UTC)
+	g.Panel = panel
+	g.TopCopper().Add(Line(1, 1, 9, 1, CircleShape, 0.2))
+	g.Drill().Add(Circle(Pt{5, 5}, 1))
+	g.Outline().Add(
+		Line(0, 0, 10, 0, CircleShape, 0.1),
+		Line(10, 0, 10, 10, CircleShape, 0.1),
+		Line(10, 10, 0, 10, CircleShape, 0.1),
+		Line(0, 10, 0, 0, CircleShape, 0.1),
+	)
+	return g
+}
+
+func TestPanel_StepAndRepeat(t *testing.T) {
+	g := panelDesign(&Panel{XRepeat: 2, YRepeat: 3, XStep: 12, YStep: 15, Separation: MouseBites})
+
+	var buf bytes.Buffer
+	if err := g.Layers[0].WriteGerber(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	want := `%SRX2Y3I12.00000J15.00000*%
+G54D12*
+X1000000Y1000000D02*
+X9000000Y1000000D01*
+%SR*%
+M02*
+`
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("WriteGerber =\n%v\nwant suffix:\n%v", got, want)
+	}
+}
+
+func TestPanel_Excellon(t *testing.T) {
+	g := panelDesign(&Panel{XRepeat: 2, YRepeat: 2, XStep: 12, YStep: 15, Separation: MouseBites})
+
+	var buf bytes.Buffer
+	if err := g.Layers[1].WriteGerber(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `T1
+X5.000Y5.000
+X17.000Y5.000
+X5.000Y20.000
+X17.000Y20.000
+T0
+`
+	if got := buf.String(); !strings.Contains(got, want) {
+		t.Errorf("WriteExcellon =\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestPanel_VScore(t *testing.T) {
+	g := panelDesign(&Panel{XRepeat: 3, YRepeat: 1, XStep: 10.1, YStep: 0, Separation: VScore, RailWidth: 5})
+
+	layer, err := g.Panel.outlineLayer(g.Layers[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scores []*LineT
+	for _, p := range layer.Primitives {
+		if l, ok := p.(*LineT); ok && l.Function == "Other,VScore" {
+			scores = append(scores, l)
+		}
+	}
+	// 2 vertical scores between the 3 boards and 2 horizontal
+	// scores between the boards and the rails.
+	if len(scores) != 4 {
+		t.Fatalf("got %v V-score lines, want 4", len(scores))
+	}
+	if got, want := layer.MBB(), (MBB{Min: Pt{-0.1, -5.1}, Max: Pt{30.3, 15.1}}); !mbbClose(got, want) {
+		t.Errorf("MBB = %v, want %v", got, want)
+	}
+
+	var buf bytes.Buffer
+	if err := g.Layers[2].WriteGerber(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); !strings.Contains(got, "%TA.AperFunction,Other,VScore*%") || strings.Contains(got, "%SR") {
+		t.Errorf("WriteGerber =\n%v", got)
+	}
+}
+
+func TestPanel_MouseBites(t *testing.T) {
+	g := panelDesign(&Panel{XRepeat: 2, YRepeat: 2, XStep: 12, YStep: 12, Separation: MouseBites, RailWidth: 5})
+
+	layer, err := g.Panel.outlineLayer(g.Layers[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var holes int
+	for _, p := range layer.Primitives {
+		if a, ok := p.(*ArcT); ok && a.Radius == 0.5*defaultHoleDiameter {
+			holes++
+		}
+	}
+	// Each board has 3 tabs (two neighbors and a rail) with 3 holes each.
+	if want := 4 * 3 * 3; holes != want {
+		t.Errorf("got %v mouse-bite holes, want %v", holes, want)
+	}
+	if got, want := layer.MBB(), (MBB{Min: Pt{-0.1, -7}, Max: Pt{22.1, 29}}); !mbbClose(got, want) {
+		t.Errorf("MBB = %v, want %v", got, want)
+	}
+
+	// The board outline is cut where the tabs are.
+	for _, p := range layer.Primitives {
+		l, ok := p.(*LineT)
+		if !ok || l.P1[0] != 10 || l.P2[0] != 10 {
+			continue
+		}
+		lo, hi := l.P1[1], l.P2[1]
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		if lo < 6.5-1e-9 && hi > 3.5+1e-9 {
+			t.Errorf("outline line %v crosses the right tab", l)
+		}
+	}
+}
+
+func TestPanel_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		panel *Panel
+	}{
+		{name: "no copies", panel: &Panel{XRepeat: 0, YRepeat: 1}},
+		{name: "no step", panel: &Panel{XRepeat: 2, YRepeat: 1}},
+		{name: "mouse bites without gap", panel: &Panel{XRepeat: 2, YRepeat: 1, XStep: 10, Separation: MouseBites}},
+		{name: "v-scores with x gap", panel: &Panel{XRepeat: 2, YRepeat: 1, XStep: 12, Separation: VScore}},
+		{name: "v-scores with y gap", panel: &Panel{XRepeat: 1, YRepeat: 2, YStep: 12, Separation: VScore}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := panelDesign(tt.panel)
+			var buf bytes.Buffer
+			if err := g.Layers[2].WriteGerber(&buf); err == nil {
+				t.Errorf("WriteGerber = nil, want error")
+			}
+		})
+	}
+}
+
+func mbbClose(got, want MBB) bool {
+	const eps = 1e-6
+	for i := 0; i < 2; i++ {
+		if d := got.Min[i] - want.Min[i]; d < -eps || d > eps {
+			return false
+		}
+		if d := got.Max[i] - want.Max[i]; d < -eps || d > eps {
+			return false
+		}
+	}
+	return true
+}
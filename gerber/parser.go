@@ -0,0 +1,998 @@
+package gerber
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ParseGerber reads a Gerber RS-274X file into a new layer.
+//
+// Coordinates and aperture sizes are converted to millimeters.
+// Draws become LineT and ArcT primitives, flashes become FlashT
+// primitives and regions become filled PolygonT primitives (or RegionT
+// primitives if their contours contain arcs). Objects drawn with clear
+// polarity are grouped in ClearT primitives and step-and-repeat blocks
+// are expanded.
+//
+// The layer type is taken from the %TF.FileFunction attribute,
+// if present. Errors report the line number of the offending command.
+func ParseGerber(r io.Reader) (*Layer, error) {
+	p, err := parseGerber(r)
+	if err != nil {
+		return nil, err
+	}
+	return p.layer, nil
+}
+
+// gerberParser holds the graphics state of a Gerber file being parsed.
+type gerberParser struct {
+	format    Format
+	formatSet bool
+	unitsSet  bool
+
+	macros    map[string]*Macro
+	apertures map[int]*Aperture
+	aperture  *Aperture // current aperture
+
+	pt            Pt
+	interpolation int // 1 (linear), 2 (clockwise) or 3 (counterclockwise)
+	multiQuadrant bool
+	lastOp        int
+
+	region  bool
+	contour *Contour
+	arcs    bool // whether the current contour has arcs
+
+	clear    bool
+	mirror   Mirroring
+	rotation float64
+	scale    float64
+
+	function AperFunction // current %TA.AperFunction
+	object   Attributes   // current %TO attributes
+
+	scopes []*parseScope
+	done   bool
+
+	// hasFunction reports whether the file has a %TF.FileFunction.
+	hasFunction bool
+	layer       *Layer
+}
+
+// parseScope collects the objects of the image, of a block aperture
+// or of a step-and-repeat block.
+type parseScope struct {
+	primitives []Primitive
+	clearGroup *ClearT // open group of consecutive clear objects
+
+	block  int // D code of a block aperture
+	repeat [2]int
+	step   Pt
+}
+
+// add adds an object to the scope with the given polarity.
+func (s *parseScope) add(prim Primitive, clear bool) {
+	if !clear {
+		s.clearGroup = nil
+		s.primitives = append(s.primitives, prim)
+		return
+	}
+	if s.clearGroup == nil {
+		s.clearGroup = Clear()
+		s.primitives = append(s.primitives, s.clearGroup)
+	}
+	s.clearGroup.Primitives = append(s.clearGroup.Primitives, prim)
+}
+
+// parseGerber parses a Gerber file and returns the final parser state.
+func parseGerber(r io.Reader) (*gerberParser, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &gerberParser{
+		macros:        map[string]*Macro{},
+		apertures:     map[int]*Aperture{},
+		interpolation: 1,
+		scale:         1,
+		scopes:        []*parseScope{{}},
+		layer:         &Layer{apertureMap: map[string]int{"default": -1}},
+	}
+
+	line := 1
+	for i := 0; i < len(data) && !p.done; {
+		switch c := data[i]; c {
+		case '\n':
+			line++
+			i++
+		case ' ', '\r', '\t':
+			i++
+		case '%':
+			end := bytes.IndexByte(data[i+1:], '%')
+			if end < 0 {
+				return nil, fmt.Errorf("line %v: unterminated extended command", line)
+			}
+			block := string(data[i+1 : i+1+end])
+			if err := p.extended(block); err != nil {
+				return nil, fmt.Errorf("line %v: %v", line, err)
+			}
+			line += strings.Count(block, "\n")
+			i += end + 2
+		default:
+			end := bytes.IndexByte(data[i:], '*')
+			if end < 0 {
+				return nil, fmt.Errorf("line %v: missing '*' after %q", line, strings.TrimSpace(string(data[i:])))
+			}
+			word := string(data[i : i+end])
+			if err := p.word(word); err != nil {
+				return nil, fmt.Errorf("line %v: %v", line, err)
+			}
+			line += strings.Count(word, "\n")
+			i += end + 1
+		}
+	}
+
+	if p.region {
+		return nil, fmt.Errorf("line %v: unterminated region (missing G37)", line)
+	}
+	if s := p.scope(); s.repeat[0] > 0 {
+		if err := p.endStepAndRepeat(); err != nil {
+			return nil, fmt.Errorf("line %v: %v", line, err)
+		}
+	}
+	if len(p.scopes) > 1 {
+		return nil, fmt.Errorf("line %v: unterminated block aperture D%v", line, p.scope().block)
+	}
+	p.layer.Add(p.scopes[0].primitives...)
+	return p, nil
+}
+
+// scope returns the innermost scope.
+func (p *gerberParser) scope() *parseScope {
+	return p.scopes[len(p.scopes)-1]
+}
+
+// extended processes the words of an extended (%) command.
+func (p *gerberParser) extended(block string) error {
+	block = strings.NewReplacer("\r", "", "\n", "").Replace(block)
+	if strings.HasPrefix(block, "AM") {
+		return p.macro(block)
+	}
+	for _, word := range strings.Split(block, "*") {
+		if word == "" {
+			continue
+		}
+		if err := p.command(word); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// command processes an extended command word.
+func (p *gerberParser) command(word string) error {
+	if len(word) < 2 {
+		return fmt.Errorf("invalid extended command %q", word)
+	}
+	code, value := word[:2], word[2:]
+	switch code {
+	case "FS":
+		return p.formatSpec(value)
+	case "MO":
+		switch value {
+		case "MM":
+			p.format.Units = Millimeters
+		case "IN":
+			p.format.Units = Inches
+		default:
+			return fmt.Errorf("invalid units %q", value)
+		}
+		p.unitsSet = true
+	case "AD":
+		return p.apertureDefinition(value)
+	case "LP":
+		switch value {
+		case "D":
+			p.clear = false
+		case "C":
+			p.clear = true
+		default:
+			return fmt.Errorf("invalid polarity %q", value)
+		}
+	case "LM":
+		switch value {
+		case "N":
+			p.mirror = ""
+		case "X", "Y", "XY":
+			p.mirror = Mirroring(value)
+		default:
+			return fmt.Errorf("invalid mirroring %q", value)
+		}
+	case "LR":
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid rotation %q", value)
+		}
+		p.rotation = v
+	case "LS":
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v <= 0 {
+			return fmt.Errorf("invalid scale %q", value)
+		}
+		p.scale = v
+	case "AB":
+		return p.blockAperture(value)
+	case "SR":
+		return p.stepAndRepeat(value)
+	case "TF", "TA", "TO", "TD":
+		p.attribute(code, value)
+	case "IN", "LN":
+		// Image and level names do not affect the image.
+	case "IP":
+		if value != "POS" {
+			return fmt.Errorf("unsupported image polarity %q", value)
+		}
+	case "OF", "SF", "MI", "AS", "IR":
+		// Accept the deprecated image transformations when they
+		// are the identity.
+		switch word {
+		case "OFA0B0", "OF", "SFA1B1", "SF", "MIA0B0", "MI", "ASAXBY", "IR0":
+		default:
+			return fmt.Errorf("unsupported deprecated command %q", word)
+		}
+	default:
+		return fmt.Errorf("unsupported extended command %q", word)
+	}
+	return nil
+}
+
+// formatSpec parses the %FS coordinate format, e.g. "LAX36Y36".
+func (p *gerberParser) formatSpec(value string) error {
+	if len(value) != 8 || value[2] != 'X' || value[5] != 'Y' || value[3:5] != value[6:8] {
+		return fmt.Errorf("unsupported coordinate format %q", value)
+	}
+	switch value[0] {
+	case 'L':
+		p.format.Zeros = OmitLeadingZeros
+	case 'T':
+		p.format.Zeros = OmitTrailingZeros
+	default:
+		return fmt.Errorf("invalid zero omission in coordinate format %q", value)
+	}
+	if value[1] != 'A' {
+		return fmt.Errorf("unsupported incremental coordinate format %q", value)
+	}
+	p.format.IntDigits = int(value[3] - '0')
+	p.format.DecDigits = int(value[4] - '0')
+	if p.format.IntDigits < 1 || p.format.IntDigits > 6 || p.format.DecDigits < 1 || p.format.DecDigits > 6 {
+		return fmt.Errorf("invalid coordinate format %q", value)
+	}
+	p.formatSet = true
+	return nil
+}
+
+// mm converts a length in the file's units to millimeters.
+func (p *gerberParser) mm(v float64) float64 {
+	if p.format.Units == Inches {
+		return v * mmPerInch
+	}
+	return v
+}
+
+// size parses an aperture size in the file's units.
+func (p *gerberParser) size(s string) (float64, error) {
+	if !p.unitsSet {
+		return 0, fmt.Errorf("aperture size before units (missing %%MO)")
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return p.mm(v), nil
+}
+
+// coord parses coordinate data and returns it in millimeters.
+func (p *gerberParser) coord(s string) (float64, error) {
+	if !p.formatSet {
+		return 0, fmt.Errorf("coordinate data before the coordinate format (missing %%FS)")
+	}
+	if !p.unitsSet {
+		return 0, fmt.Errorf("coordinate data before units (missing %%MO)")
+	}
+	if strings.Contains(s, ".") {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid coordinate %q", s)
+		}
+		return p.mm(v), nil
+	}
+
+	sign := 1.0
+	digits := s
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign, digits = -1, s[1:]
+	case strings.HasPrefix(s, "+"):
+		digits = s[1:]
+	}
+	n := p.format.IntDigits + p.format.DecDigits
+	if digits == "" || len(digits) > n {
+		return 0, fmt.Errorf("invalid coordinate %q for the %v.%v format", s, p.format.IntDigits, p.format.DecDigits)
+	}
+	if p.format.Zeros == OmitTrailingZeros {
+		digits += strings.Repeat("0", n-len(digits))
+	}
+	v, err := strconv.ParseInt(digits, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid coordinate %q", s)
+	}
+	return p.mm(sign * float64(v) / math.Pow10(p.format.DecDigits)), nil
+}
+
+// apertureDefinition parses an %AD command, e.g. "D10C,0.5X0.2".
+func (p *gerberParser) apertureDefinition(value string) error {
+	if !strings.HasPrefix(value, "D") {
+		return fmt.Errorf("invalid aperture definition %q", value)
+	}
+	i := 1
+	for i < len(value) && value[i] >= '0' && value[i] <= '9' {
+		i++
+	}
+	dcode, err := strconv.Atoi(value[1:i])
+	if err != nil || dcode < 10 {
+		return fmt.Errorf("invalid aperture number in %q", value)
+	}
+	name, params := value[i:], ""
+	if j := strings.IndexByte(name, ','); j >= 0 {
+		name, params = name[:j], name[j+1:]
+	}
+	var mods []string
+	if params != "" {
+		mods = strings.Split(params, "X")
+	}
+
+	sizes := func(min, max int) ([]float64, error) {
+		if len(mods) < min || len(mods) > max {
+			return nil, fmt.Errorf("aperture D%v: %v has %v parameters", dcode, name, len(mods))
+		}
+		var result []float64
+		for _, m := range mods {
+			v, err := p.size(m)
+			if err != nil {
+				return nil, fmt.Errorf("aperture D%v: %v", dcode, err)
+			}
+			result = append(result, v)
+		}
+		return result, nil
+	}
+
+	var a *Aperture
+	switch name {
+	case "C":
+		v, err := sizes(1, 2)
+		if err != nil {
+			return err
+		}
+		a = CircleAperture(v[0])
+		if len(v) > 1 {
+			a.Hole = v[1]
+		}
+	case "R", "O":
+		v, err := sizes(2, 3)
+		if err != nil {
+			return err
+		}
+		a = &Aperture{Shape: Shape(name), Size: v[0], YSize: v[1]}
+		if len(v) > 2 {
+			a.Hole = v[2]
+		}
+	case "P":
+		if len(mods) < 2 || len(mods) > 4 {
+			return fmt.Errorf("aperture D%v: P has %v parameters", dcode, len(mods))
+		}
+		diameter, err := p.size(mods[0])
+		if err != nil {
+			return fmt.Errorf("aperture D%v: %v", dcode, err)
+		}
+		vertices, err := strconv.ParseFloat(mods[1], 64)
+		if err != nil || vertices < 3 || vertices > 12 {
+			return fmt.Errorf("aperture D%v: invalid number of vertices %q", dcode, mods[1])
+		}
+		a = PolygonAperture(diameter, int(vertices), 0)
+		if len(mods) > 2 {
+			if a.Rotation, err = strconv.ParseFloat(mods[2], 64); err != nil {
+				return fmt.Errorf("aperture D%v: invalid rotation %q", dcode, mods[2])
+			}
+		}
+		if len(mods) > 3 {
+			if a.Hole, err = p.size(mods[3]); err != nil {
+				return fmt.Errorf("aperture D%v: %v", dcode, err)
+			}
+		}
+	default:
+		m, ok := p.macros[name]
+		if !ok {
+			return fmt.Errorf("aperture D%v: undefined aperture macro %q", dcode, name)
+		}
+		a = &Aperture{Macro: m}
+		for _, s := range mods {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return fmt.Errorf("aperture D%v: invalid parameter %q", dcode, s)
+			}
+			a.Params = append(a.Params, v)
+		}
+	}
+	a.Function = p.function
+	p.apertures[dcode] = a
+	return nil
+}
+
+// lengthModifiers reports which modifiers of a macro primitive
+// with n modifiers are lengths.
+func lengthModifiers(code MacroCode, n int) func(i int) bool {
+	switch code {
+	case MacroCircle:
+		return func(i int) bool { return i >= 1 && i <= 3 }
+	case MacroVectorLine:
+		return func(i int) bool { return i >= 1 && i <= 5 }
+	case MacroCenterLine:
+		return func(i int) bool { return i >= 1 && i <= 4 }
+	case MacroOutline:
+		return func(i int) bool { return i >= 2 && i < n-1 }
+	case MacroPolygon:
+		return func(i int) bool { return i >= 2 && i <= 4 }
+	case MacroMoire:
+		return func(i int) bool { return i <= 7 && i != 5 }
+	case MacroThermal:
+		return func(i int) bool { return i <= 4 }
+	}
+	return func(i int) bool { return false }
+}
+
+// macro parses an %AM command defining an aperture macro.
+//
+// Macros of files in inches are converted to millimeters by scaling
+// their length modifiers, so their parameters are kept as-is and are
+// all treated as unitless.
+func (p *gerberParser) macro(block string) error {
+	words := strings.Split(block, "*")
+	m := &Macro{Name: strings.TrimSpace(words[0][2:])}
+	if m.Name == "" {
+		return fmt.Errorf("aperture macro has no name")
+	}
+	if !p.unitsSet {
+		return fmt.Errorf("aperture macro %v before units (missing %%MO)", m.Name)
+	}
+
+	for _, word := range words[1:] {
+		word = strings.TrimSpace(word)
+		switch {
+		case word == "":
+			continue
+		case word == "0" || strings.HasPrefix(word, "0 "):
+			m.Statements = append(m.Statements, MacroPrimitive{Code: MacroComment, Modifiers: []Expr{Expr(strings.TrimSpace(word[1:]))}})
+			continue
+		case strings.HasPrefix(word, "$"):
+			i := strings.IndexByte(word, '=')
+			n, err := strconv.Atoi(strings.TrimSpace(word[1:max(i, 1)]))
+			if i < 0 || err != nil {
+				return fmt.Errorf("aperture macro %v: invalid variable definition %q", m.Name, word)
+			}
+			m.Statements = append(m.Statements, MacroVariable{N: n, Value: Expr(strings.ReplaceAll(word[i+1:], " ", ""))})
+			continue
+		}
+
+		fields := strings.Split(strings.ReplaceAll(word, " ", ""), ",")
+		code, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return fmt.Errorf("aperture macro %v: invalid primitive %q", m.Name, word)
+		}
+		var mods []Expr
+		for _, f := range fields[1:] {
+			mods = append(mods, Expr(f))
+		}
+		switch MacroCode(code) {
+		case 2: // deprecated vector line
+			code = int(MacroVectorLine)
+		case 22: // deprecated lower left line
+			if len(mods) != 6 {
+				return fmt.Errorf("aperture macro %v: lower left line has %v modifiers", m.Name, len(mods))
+			}
+			code = int(MacroCenterLine)
+			mods[3] = Expr(fmt.Sprintf("(%v)+(%v)/2", mods[3], mods[1]))
+			mods[4] = Expr(fmt.Sprintf("(%v)+(%v)/2", mods[4], mods[2]))
+		case MacroCircle, MacroVectorLine, MacroCenterLine, MacroOutline, MacroPolygon, MacroMoire, MacroThermal:
+		default:
+			return fmt.Errorf("aperture macro %v: unknown primitive code %v", m.Name, code)
+		}
+		if p.format.Units == Inches {
+			isLength := lengthModifiers(MacroCode(code), len(mods))
+			for i, e := range mods {
+				if isLength(i) {
+					mods[i] = Expr(fmt.Sprintf("(%v)x%v", e, mmPerInch))
+				}
+			}
+		}
+		m.Statements = append(m.Statements, MacroPrimitive{Code: MacroCode(code), Modifiers: mods})
+	}
+
+	m.UnitlessParams = unitlessParams(m, p.format.Units == Inches)
+	p.macros[m.Name] = m
+	return nil
+}
+
+// unitlessParams returns the parameters of the macro that are not
+// lengths: those used in modifiers such as rotations, or all of them.
+func unitlessParams(m *Macro, all bool) []int {
+	unitless := map[int]bool{}
+	for i := len(m.Statements) - 1; i >= 0; i-- {
+		switch s := m.Statements[i].(type) {
+		case MacroVariable:
+			if all || unitless[s.N] {
+				for _, n := range exprVariables(s.Value) {
+					unitless[n] = true
+				}
+			}
+		case MacroPrimitive:
+			if s.Code == MacroComment {
+				continue
+			}
+			isLength := lengthModifiers(s.Code, len(s.Modifiers))
+			for j, e := range s.Modifiers {
+				if all || !isLength(j) {
+					for _, n := range exprVariables(e) {
+						unitless[n] = true
+					}
+				}
+			}
+		}
+	}
+	var result []int
+	for n := range unitless {
+		result = append(result, n)
+	}
+	sort.Ints(result)
+	return result
+}
+
+// exprVariables returns the variables referenced by the expression.
+func exprVariables(e Expr) []int {
+	var result []int
+	s := string(e)
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' {
+			continue
+		}
+		j := i + 1
+		for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+			j++
+		}
+		if n, err := strconv.Atoi(s[i+1 : j]); err == nil {
+			result = append(result, n)
+		}
+		i = j - 1
+	}
+	return result
+}
+
+// blockAperture opens (%ABD10) or closes (%AB) a block aperture.
+func (p *gerberParser) blockAperture(value string) error {
+	if value == "" {
+		s := p.scope()
+		if s.block == 0 {
+			return fmt.Errorf("%%AB closes no block aperture")
+		}
+		p.scopes = p.scopes[:len(p.scopes)-1]
+		p.apertures[s.block] = BlockAperture(fmt.Sprintf("D%v", s.block), s.primitives...)
+		return nil
+	}
+	dcode, err := strconv.Atoi(strings.TrimPrefix(value, "D"))
+	if err != nil || !strings.HasPrefix(value, "D") || dcode < 10 {
+		return fmt.Errorf("invalid block aperture %q", value)
+	}
+	p.scopes = append(p.scopes, &parseScope{block: dcode})
+	return nil
+}
+
+// stepAndRepeat opens (%SRX2Y3I5J4) or closes (%SR) a step-and-repeat
+// block. Opening a block closes the previous one.
+func (p *gerberParser) stepAndRepeat(value string) error {
+	if p.scope().repeat[0] > 0 {
+		if err := p.endStepAndRepeat(); err != nil {
+			return err
+		}
+	}
+	if value == "" {
+		return nil
+	}
+
+	s := &parseScope{repeat: [2]int{1, 1}}
+	for _, f := range splitFields(value) {
+		switch f.letter {
+		case 'X', 'Y':
+			n, err := strconv.Atoi(f.value)
+			if err != nil || n < 1 {
+				return fmt.Errorf("invalid step and repeat %q", value)
+			}
+			s.repeat[f.letter-'X'] = n
+		case 'I', 'J':
+			v, err := p.size(f.value)
+			if err != nil {
+				return err
+			}
+			s.step[f.letter-'I'] = v
+		default:
+			return fmt.Errorf("invalid step and repeat %q", value)
+		}
+	}
+	if s.repeat == [2]int{1, 1} {
+		return nil
+	}
+	p.scopes = append(p.scopes, s)
+	return nil
+}
+
+// endStepAndRepeat closes the step-and-repeat block by adding
+// translated copies of its objects to the enclosing scope.
+func (p *gerberParser) endStepAndRepeat() error {
+	s := p.scope()
+	p.scopes = p.scopes[:len(p.scopes)-1]
+	parent := p.scope()
+	parent.clearGroup = nil
+	for j := 0; j < s.repeat[1]; j++ {
+		for i := 0; i < s.repeat[0]; i++ {
+			offset := Pt{float64(i) * s.step[0], float64(j) * s.step[1]}
+			for _, prim := range s.primitives {
+				v, err := translate(prim, offset)
+				if err != nil {
+					return err
+				}
+				parent.primitives = append(parent.primitives, v)
+			}
+		}
+	}
+	return nil
+}
+
+// attribute processes the attribute commands that map onto the model.
+// Other attributes are ignored.
+func (p *gerberParser) attribute(code, value string) {
+	name, arg := value, ""
+	if i := strings.IndexByte(value, ','); i >= 0 {
+		name, arg = value[:i], value[i+1:]
+	}
+	switch code {
+	case "TF":
+		if name == ".FileFunction" {
+			if t, n, ok := parseFileFunction(arg); ok {
+				p.layer.Type, p.layer.N, p.hasFunction = t, n, true
+			}
+		}
+	case "TA":
+		if name == ".AperFunction" {
+			p.function = AperFunction(arg)
+		}
+	case "TO":
+		switch name {
+		case ".N":
+			p.object.Net = arg
+		case ".C":
+			p.object.Component = arg
+		case ".P":
+			if parts := strings.SplitN(arg, ",", 3); len(parts) >= 2 {
+				p.object.Component, p.object.Pin = parts[0], parts[1]
+			}
+		}
+	case "TD":
+		switch name {
+		case "":
+			p.function, p.object = "", Attributes{}
+		case ".AperFunction":
+			p.function = ""
+		case ".N":
+			p.object.Net = ""
+		case ".C":
+			p.object.Component = ""
+		case ".P":
+			p.object.Pin = ""
+		}
+	}
+}
+
+// field is a letter and its value in a data word, e.g. X100.
+type field struct {
+	letter byte
+	value  string
+}
+
+// splitFields splits a word like "G01X100Y-200D01" into its fields.
+func splitFields(word string) []field {
+	var result []field
+	for i := 0; i < len(word); {
+		f := field{letter: word[i]}
+		j := i + 1
+		for j < len(word) && (word[j] == '+' || word[j] == '-' || word[j] == '.' || (word[j] >= '0' && word[j] <= '9')) {
+			j++
+		}
+		f.value = word[i+1 : j]
+		result = append(result, f)
+		i = j
+	}
+	return result
+}
+
+// word processes a data word (a command terminated by '*').
+func (p *gerberParser) word(word string) error {
+	word = strings.TrimSpace(word)
+	if strings.HasPrefix(word, "G04") || strings.HasPrefix(word, "G4 ") || word == "G4" {
+		return nil // comment
+	}
+	word = strings.Join(strings.Fields(word), "")
+
+	var coords map[byte]string
+	op := 0
+	for _, f := range splitFields(word) {
+		n, err := strconv.Atoi(f.value)
+		switch f.letter {
+		case 'G':
+			if err != nil {
+				return fmt.Errorf("invalid G code in %q", word)
+			}
+			if err := p.gcode(n); err != nil {
+				return err
+			}
+		case 'M':
+			switch {
+			case err != nil:
+				return fmt.Errorf("invalid M code in %q", word)
+			case n == 0 || n == 2:
+				p.done = true
+			case n != 1:
+				return fmt.Errorf("unsupported M code in %q", word)
+			}
+		case 'D':
+			switch {
+			case err != nil:
+				return fmt.Errorf("invalid D code in %q", word)
+			case n >= 10:
+				a, ok := p.apertures[n]
+				if !ok {
+					return fmt.Errorf("undefined aperture D%v", n)
+				}
+				p.aperture = a
+			case n >= 1 && n <= 3:
+				op = n
+			default:
+				return fmt.Errorf("invalid D code in %q", word)
+			}
+		case 'X', 'Y', 'I', 'J':
+			if coords == nil {
+				coords = map[byte]string{}
+			}
+			coords[f.letter] = f.value
+		default:
+			return fmt.Errorf("unsupported command %q", word)
+		}
+	}
+
+	if op == 0 {
+		if coords == nil {
+			return nil
+		}
+		if p.lastOp != 1 {
+			return fmt.Errorf("coordinate data without an operation code in %q", word)
+		}
+		op = 1 // deprecated modal D01
+	}
+	p.lastOp = op
+
+	end, offset := p.pt, Pt{}
+	for letter, s := range coords {
+		v, err := p.coord(s)
+		if err != nil {
+			return err
+		}
+		switch letter {
+		case 'X':
+			end[0] = v
+		case 'Y':
+			end[1] = v
+		case 'I':
+			offset[0] = v
+		case 'J':
+			offset[1] = v
+		}
+	}
+	return p.operation(op, end, offset)
+}
+
+// gcode processes a G code.
+func (p *gerberParser) gcode(n int) error {
+	switch n {
+	case 1, 2, 3:
+		p.interpolation = n
+	case 36:
+		p.region = true
+	case 37:
+		if !p.region {
+			return fmt.Errorf("G37 outside a region")
+		}
+		p.closeContour()
+		p.region = false
+	case 74:
+		p.multiQuadrant = false
+	case 75:
+		p.multiQuadrant = true
+	case 54, 55:
+		// Deprecated aperture selection and flash preparation prefixes.
+	case 70:
+		p.format.Units, p.unitsSet = Inches, true
+	case 71:
+		p.format.Units, p.unitsSet = Millimeters, true
+	case 90:
+		// Absolute coordinates are the only ones supported.
+	case 91:
+		return fmt.Errorf("unsupported incremental coordinates (G91)")
+	default:
+		return fmt.Errorf("unsupported G code G%02d", n)
+	}
+	return nil
+}
+
+// operation processes a D01 (interpolate), D02 (move) or D03 (flash)
+// operation ending at end.
+func (p *gerberParser) operation(op int, end, offset Pt) error {
+	defer func() { p.pt = end }()
+	switch op {
+	case 2:
+		if p.region {
+			p.closeContour()
+		}
+		return nil
+	case 3:
+		if p.region {
+			return fmt.Errorf("flash (D03) in a region")
+		}
+		if p.aperture == nil {
+			return fmt.Errorf("flash (D03) without an aperture")
+		}
+		f := Flash(end, p.aperture)
+		f.Mirror, f.Rotation = p.mirror, p.rotation
+		if p.scale != 1 {
+			f.Scale = p.scale
+		}
+		f.Net, f.Component, f.Pin = p.object.Net, p.object.Component, p.object.Pin
+		p.scope().add(f, p.clear)
+		return nil
+	}
+
+	var center Pt
+	if p.interpolation != 1 {
+		var err error
+		if center, err = arcCenter(p.pt, end, offset, p.interpolation == 2, p.multiQuadrant); err != nil {
+			return err
+		}
+	}
+
+	if p.region {
+		if p.contour == nil {
+			p.contour = &Contour{Start: p.pt}
+		}
+		if p.interpolation == 1 {
+			p.contour.Segments = append(p.contour.Segments, LineTo(end))
+		} else {
+			p.contour.Segments = append(p.contour.Segments, ArcTo(end, center, p.interpolation == 2))
+			p.arcs = true
+		}
+		return nil
+	}
+
+	a := p.aperture
+	if a == nil {
+		return fmt.Errorf("draw (D01) without an aperture")
+	}
+	if a.Macro != nil || a.Block != nil || (a.Shape != CircleShape && a.Shape != RectShape) ||
+		(a.Shape == RectShape && a.YSize != a.Size) {
+		return fmt.Errorf("unsupported draw (D01) with a non-circular aperture")
+	}
+
+	attrs := p.object
+	attrs.Function = a.Function
+	if p.interpolation == 1 {
+		l := Line(p.pt[0], p.pt[1], end[0], end[1], a.Shape, a.Size)
+		l.Attributes = attrs
+		p.scope().add(l, p.clear)
+		return nil
+	}
+
+	// ArcT sweeps counterclockwise, so clockwise arcs are reversed.
+	start, stop := p.pt, end
+	if p.interpolation == 2 {
+		start, stop = end, p.pt
+	}
+	a1 := math.Atan2(start[1]-center[1], start[0]-center[0])
+	a2 := math.Atan2(stop[1]-center[1], stop[0]-center[0])
+	if a2 <= a1+1e-9 && (p.multiQuadrant || !samePt(start, stop)) {
+		a2 += 2 * math.Pi
+	}
+	arc := &ArcT{
+		Center:     center,
+		Radius:     math.Hypot(p.pt[0]-center[0], p.pt[1]-center[1]),
+		Shape:      a.Shape,
+		XScale:     1,
+		YScale:     1,
+		StartAngle: a1,
+		EndAngle:   a2,
+		Thickness:  a.Size,
+		Attributes: attrs,
+	}
+	p.scope().add(arc, p.clear)
+	return nil
+}
+
+// arcCenter returns the center of a circular interpolation from start
+// to end. In single quadrant mode (G74) the signs of the offsets are
+// not given and are chosen so that the arc spans at most 90 degrees.
+func arcCenter(start, end, offset Pt, clockwise, multiQuadrant bool) (Pt, error) {
+	if multiQuadrant {
+		return Pt{start[0] + offset[0], start[1] + offset[1]}, nil
+	}
+
+	best, bestErr := Pt{}, math.Inf(1)
+	for _, sign := range []Pt{{1, 1}, {-1, 1}, {1, -1}, {-1, -1}} {
+		c := Pt{start[0] + sign[0]*math.Abs(offset[0]), start[1] + sign[1]*math.Abs(offset[1])}
+		a1 := math.Atan2(start[1]-c[1], start[0]-c[0])
+		a2 := math.Atan2(end[1]-c[1], end[0]-c[0])
+		sweep := a2 - a1
+		if clockwise {
+			sweep = -sweep
+		}
+		if sweep < 0 {
+			sweep += 2 * math.Pi
+		}
+		if sweep > 0.5*math.Pi+1e-6 {
+			continue
+		}
+		r1 := math.Hypot(start[0]-c[0], start[1]-c[1])
+		r2 := math.Hypot(end[0]-c[0], end[1]-c[1])
+		if e := math.Abs(r1 - r2); e < bestErr {
+			best, bestErr = c, e
+		}
+	}
+	if math.IsInf(bestErr, 1) {
+		return Pt{}, fmt.Errorf("no single quadrant arc from %v to %v", start, end)
+	}
+	return best, nil
+}
+
+// closeContour adds the current region contour, if any, to the image.
+func (p *gerberParser) closeContour() {
+	c := p.contour
+	p.contour = nil
+	if c == nil || len(c.Segments) == 0 {
+		return
+	}
+
+	attrs := p.object
+	attrs.Function = p.function
+	if p.arcs {
+		r := Region(*c)
+		r.Attributes = attrs
+		p.scope().add(r, p.clear)
+		p.arcs = false
+		return
+	}
+	pts := []Pt{c.Start}
+	for _, s := range c.Segments {
+		pts = append(pts, s.End)
+	}
+	poly := Polygon(Pt{}, true, pts, 0)
+	poly.Attributes = attrs
+	p.scope().add(poly, p.clear)
+}
@@ -0,0 +1,261 @@
+package gerber
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseGerber_RoundTrip(t *testing.T) {
+	g := New("test")
+	g.CreationDate = time.Date(2019, 6, 8, 19, 30, 0, 0, time.UTC)
+	top := g.TopCopper()
+	g.BottomCopper()
+
+	pad := Flash(Pt{5, 5}, RectAperture(1, 2))
+	pad.Function = SMDPad
+	pad.Net = "GND"
+	top.Add(
+		Line(0, 0, 10, 0, CircleShape, 0.25),
+		Arc(Pt{5, 5}, 2, CircleShape, 1, 1, 0, 90, 0.25),
+		Arc(Pt{5, 5}, 3, CircleShape, 1, 1, 0, 360, 0.25),
+		pad,
+		Flash(Pt{8, 8}, &Aperture{Macro: RoundRectMacro, Params: []float64{2, 1, 0.25, 45}}),
+		Polygon(Pt{1, 1}, true, []Pt{{0, 0}, {2, 0}, {2, 2}}, 0),
+		Clear(Circle(Pt{3, 3}, 1), Line(3, 3, 4, 4, CircleShape, 0.5)),
+		Region(Contour{Start: Pt{12, 0}, Segments: []Segment{LineTo(Pt{14, 0}), ArcTo(Pt{14, 2}, Pt{14, 1}, false)}}),
+	)
+
+	var want bytes.Buffer
+	if err := top.WriteGerber(&want); err != nil {
+		t.Fatal(err)
+	}
+	layer, err := ParseGerber(bytes.NewReader(want.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layer.Type != LayerTopCopper {
+		t.Errorf("Type = %v, want %v", layer.Type, LayerTopCopper)
+	}
+
+	var types []string
+	for _, p := range layer.Primitives {
+		types = append(types, typeName(p))
+	}
+	if got, want := strings.Join(types, " "), "LineT ArcT ArcT FlashT FlashT PolygonT ClearT RegionT"; got != want {
+		t.Errorf("primitives = %v, want %v", got, want)
+	}
+
+	layer.g = g
+	var got bytes.Buffer
+	if err := layer.WriteGerber(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.String() != want.String() {
+		t.Errorf("round trip =\n%v\nwant:\n%v", got.String(), want.String())
+	}
+}
+
+func typeName(p Primitive) string {
+	switch p.(type) {
+	case *LineT:
+		return "LineT"
+	case *ArcT:
+		return "ArcT"
+	case *FlashT:
+		return "FlashT"
+	case *PolygonT:
+		return "PolygonT"
+	case *ClearT:
+		return "ClearT"
+	case *RegionT:
+		return "RegionT"
+	}
+	return "unknown"
+}
+
+func TestParseGerber(t *testing.T) {
+	const eps = 1e-9
+	near := func(a, b Pt) bool { return math.Abs(a[0]-b[0]) < eps && math.Abs(a[1]-b[1]) < eps }
+
+	layer, err := ParseGerber(strings.NewReader(`G04 Inches, trailing zeros and single quadrant arcs*
+%FSTAX24Y24*%
+%MOIN*%
+%TF.FileFunction,Copper,L3,Inr*%
+%AMTHERM*
+0 thermal*
+7,0,0,$1,$1-0.02,0.01,$2*%
+%ADD10C,0.01*%
+%ADD11THERM,0.1X45*%
+%ADD12R,0.1X0.05*%
+G74*
+D10*
+X0Y0D02*
+X01Y0D01*
+Y01*
+G03X0Y02I01J0D01*
+D11*
+X02Y02D03*
+%LPC*%
+%LMX*%
+%LR90*%
+D12*
+X03Y03D03*
+%LPD*%
+%SRX2Y1I0.5J0*%
+G01*
+D10*
+X0Y0D02*
+X001Y0D01*
+%SR*%
+M02*
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layer.Type != LayerInnerCopper || layer.N != 3 {
+		t.Errorf("Type = %v, N = %v, want %v, 3", layer.Type, layer.N, LayerInnerCopper)
+	}
+	if got, want := len(layer.Primitives), 7; got != want {
+		t.Fatalf("len(Primitives) = %v, want %v", got, want)
+	}
+
+	line := layer.Primitives[0].(*LineT)
+	if !near(line.P2, Pt{25.4, 0}) || line.Shape != CircleShape || math.Abs(line.Thickness-0.254) > eps {
+		t.Errorf("line = %+v", line)
+	}
+	if modal := layer.Primitives[1].(*LineT); !near(modal.P1, Pt{25.4, 0}) || !near(modal.P2, Pt{25.4, 25.4}) {
+		t.Errorf("modal line = %+v", modal)
+	}
+	arc := layer.Primitives[2].(*ArcT)
+	if !near(arc.Center, Pt{0, 25.4}) || math.Abs(arc.Radius-25.4) > eps ||
+		math.Abs(arc.StartAngle) > eps || math.Abs(arc.EndAngle-0.5*math.Pi) > eps {
+		t.Errorf("single quadrant arc = %+v", arc)
+	}
+
+	therm := layer.Primitives[3].(*FlashT)
+	a := therm.Aperture()
+	if a.Macro == nil || a.Macro.Name != "THERM" {
+		t.Fatalf("thermal aperture = %+v", a)
+	}
+	if mbb := therm.MBB(); !mbbClose(mbb, MBB{Min: Pt{50.8 - 1.27, 50.8 - 1.27}, Max: Pt{50.8 + 1.27, 50.8 + 1.27}}) {
+		t.Errorf("thermal MBB = %v", mbb)
+	}
+	if got, want := a.Macro.UnitlessParams, []int{1, 2}; len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("UnitlessParams = %v, want %v", got, want)
+	}
+
+	clear, ok := layer.Primitives[4].(*ClearT)
+	if !ok || len(clear.Primitives) != 1 {
+		t.Fatalf("clear group = %#v", layer.Primitives[4])
+	}
+	flash := clear.Primitives[0].(*FlashT)
+	if flash.Mirror != MirrorX || flash.Rotation != 90 || !near(flash.Center, Pt{76.2, 76.2}) {
+		t.Errorf("transformed flash = %+v", flash)
+	}
+
+	for i, x := range []float64{0, 12.7} {
+		l := layer.Primitives[5+i].(*LineT)
+		if !near(l.P1, Pt{x, 0}) || !near(l.P2, Pt{x + 2.54, 0}) {
+			t.Errorf("repeated line %v = %+v", i, l)
+		}
+	}
+}
+
+func TestParseGerber_Blocks(t *testing.T) {
+	layer, err := ParseGerber(strings.NewReader(`%FSLAX26Y26*%
+%MOMM*%
+%ADD10C,0.5*%
+%ABD12*%
+D10*
+X0Y0D03*
+X1000000Y0D03*
+%AB*%
+D12*
+X5000000Y5000000D03*
+M02*
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(layer.Primitives), 1; got != want {
+		t.Fatalf("len(Primitives) = %v, want %v", got, want)
+	}
+	if mbb := layer.MBB(); !mbbClose(mbb, MBB{Min: Pt{4.75, 4.75}, Max: Pt{6.25, 5.25}}) {
+		t.Errorf("MBB = %v", mbb)
+	}
+}
+
+func TestParseGerber_Errors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "missing format",
+			input: "%MOMM*%\n%ADD10C,1*%\nD10*\nX0Y0D03*\n",
+			want:  "line 4: coordinate data before the coordinate format",
+		},
+		{
+			name:  "undefined aperture",
+			input: "%FSLAX36Y36*%\n%MOMM*%\n\nD10*\n",
+			want:  "line 4: undefined aperture D10",
+		},
+		{
+			name:  "unsupported command",
+			input: "%FSLAX36Y36*%\n%MOMM*%\n%IPNEG*%\n",
+			want:  "line 3: unsupported image polarity",
+		},
+		{
+			name:  "incremental coordinates",
+			input: "%FSLIX36Y36*%\n",
+			want:  "line 1: unsupported incremental coordinate format",
+		},
+		{
+			name:  "unterminated region",
+			input: "%FSLAX36Y36*%\n%MOMM*%\nG36*\nX0Y0D02*\nX1Y0D01*\nM02*\n",
+			want:  "line 6: unterminated region",
+		},
+		{
+			name:  "draw with macro aperture",
+			input: "%FSLAX36Y36*%\n%MOMM*%\n%AMBOX*21,1,$1,$1,0,0,0*%\n%ADD10BOX,1*%\nD10*\nX1Y1D01*\n",
+			want:  "line 6: unsupported draw (D01) with a non-circular aperture",
+		},
+		{
+			name:  "missing star",
+			input: "%FSLAX36Y36*%\n%MOMM*%\nX0Y0D02",
+			want:  "line 3: missing '*'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseGerber(strings.NewReader(tt.input))
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("ParseGerber = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFileFunction(t *testing.T) {
+	g := New("test")
+	layers := []*Layer{
+		g.TopCopper(), g.TopSolderMask(), g.TopSilkscreen(), g.LayerN(2), g.BottomCopper(),
+		g.BottomSolderMask(), g.BottomSilkscreen(), g.Drill(), g.NonPlatedDrill(), g.Outline(),
+	}
+	for _, layer := range layers {
+		t.Run(layer.Filename, func(t *testing.T) {
+			layerType, n, ok := parseFileFunction(layer.FileFunction())
+			if !ok || layerType != layer.Type || n != layer.N {
+				t.Errorf("parseFileFunction(%q) = (%v, %v, %v), want (%v, %v, true)", layer.FileFunction(), layerType, n, ok, layer.Type, layer.N)
+			}
+		})
+	}
+	if _, _, ok := parseFileFunction("Paste,Top"); ok {
+		t.Errorf("parseFileFunction(Paste,Top) = ok, want not ok")
+	}
+}
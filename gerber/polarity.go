@@ -0,0 +1,141 @@
+package gerber
+
+import (
+	"fmt"
+	"io"
+)
+
+// group is satisfied by primitives that contain other primitives
+// and are written by their layer.
+type group interface {
+	primitives() []Primitive
+}
+
+// polarizedWriter is satisfied by primitives that switch the
+// polarity while they are written, like text with counters.
+type polarizedWriter interface {
+	writePolarized(w io.Writer, f *Format, clear bool, pol *polarity) error
+}
+
+// ClearT represents a group of primitives drawn with clear polarity
+// (%LPC), erasing the image beneath them. It satisfies the Primitive
+// interface. Nested groups invert the polarity again.
+type ClearT struct {
+	Primitives []Primitive
+	mbb        *MBB // cached minimum bounding box
+}
+
+// Clear returns a group that draws the primitives with clear polarity,
+// for example to cut clearances out of a copper fill.
+func Clear(primitives ...Primitive) *ClearT {
+	return &ClearT{Primitives: primitives}
+}
+
+// WriteGerber returns an error because groups are written by their layer.
+func (c *ClearT) WriteGerber(w io.Writer, f *Format, apertureIndex int) error {
+	return fmt.Errorf("clear group must be written by its layer")
+}
+
+// Aperture returns nil for ClearT because its primitives carry
+// their own apertures.
+func (c *ClearT) Aperture() *Aperture {
+	return nil
+}
+
+func (c *ClearT) MBB() MBB {
+	if c.mbb != nil {
+		return *c.mbb
+	}
+	mbb := primitivesMBB(c.Primitives)
+	c.mbb = &mbb
+	return *c.mbb
+}
+
+func (c *ClearT) primitives() []Primitive {
+	return c.Primitives
+}
+
+// KnockoutT represents a group of primitives cut out of a dark
+// rectangle surrounding them, like reverse-video labels.
+// It satisfies the Primitive interface.
+type KnockoutT struct {
+	// Margin is the distance between the primitives and the edges
+	// of the dark rectangle.
+	Margin     float64
+	Primitives []Primitive
+	mbb        *MBB // cached minimum bounding box
+}
+
+// Knockout returns a group that draws a dark rectangle around the
+// primitives, expanded by margin, and cuts the primitives out of it.
+// All dimensions are in millimeters.
+func Knockout(margin float64, primitives ...Primitive) *KnockoutT {
+	return &KnockoutT{Margin: margin, Primitives: primitives}
+}
+
+// WriteGerber returns an error because groups are written by their layer.
+func (k *KnockoutT) WriteGerber(w io.Writer, f *Format, apertureIndex int) error {
+	return fmt.Errorf("knockout group must be written by its layer")
+}
+
+// Aperture returns nil for KnockoutT because the rectangle is a region
+// and the primitives carry their own apertures.
+func (k *KnockoutT) Aperture() *Aperture {
+	return nil
+}
+
+func (k *KnockoutT) MBB() MBB {
+	if k.mbb != nil {
+		return *k.mbb
+	}
+	mbb := primitivesMBB(k.Primitives)
+	mbb.Min = Pt{mbb.Min[0] - k.Margin, mbb.Min[1] - k.Margin}
+	mbb.Max = Pt{mbb.Max[0] + k.Margin, mbb.Max[1] + k.Margin}
+	k.mbb = &mbb
+	return *k.mbb
+}
+
+func (k *KnockoutT) primitives() []Primitive {
+	return k.Primitives
+}
+
+// Box returns the dark rectangle of the knockout as a region.
+func (k *KnockoutT) Box() *PolygonT {
+	mbb := k.MBB()
+	return &PolygonT{
+		Points: []Pt{mbb.Min, {mbb.Max[0], mbb.Min[1]}, mbb.Max, {mbb.Min[0], mbb.Max[1]}},
+		Filled: true,
+	}
+}
+
+// primitivesMBB returns the minimum bounding box of the primitives.
+func primitivesMBB(primitives []Primitive) MBB {
+	var mbb MBB
+	for i, p := range primitives {
+		v := p.MBB()
+		if i == 0 {
+			mbb = v
+			continue
+		}
+		mbb.Join(&v)
+	}
+	return mbb
+}
+
+// polarity tracks the current polarity of the Gerber image.
+type polarity struct {
+	clear bool
+}
+
+// set loads the polarity into the graphics state if it changed.
+func (p *polarity) set(w io.Writer, clear bool) {
+	if clear == p.clear {
+		return
+	}
+	if clear {
+		io.WriteString(w, "%LPC*%\n")
+	} else {
+		io.WriteString(w, "%LPD*%\n")
+	}
+	p.clear = clear
+}
@@ -0,0 +1,72 @@
+package gerber
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestLayer_WriteGerber_Polarity(t *testing.T) {
+	g := New("polarity")
+	layer := g.TopSilkscreen()
+	layer.Add(
+		Polygon(Pt{0, 0}, true, []Pt{{0, 0}, {10, 0}, {10, 10}, {0, 10}}, 0),
+		Clear(
+			Flash(Pt{5, 5}, CircleAperture(2)),
+			Clear(Flash(Pt{5, 5}, CircleAperture(1))),
+		),
+		Knockout(0.5, Line(20, 0, 22, 0, CircleShape, 1)),
+	)
+
+	var buf bytes.Buffer
+	if err := layer.WriteGerber(&buf); err != nil {
+		t.Fatal(err)
+	}
+	got := buf.String()
+	want := `%ADD11C,0.00100*%
+%ADD12C,2.00000*%
+%ADD13C,1.00000*%
+G54D11*
+G36*
+X0Y0D02*
+X10000000Y0D01*
+X10000000Y10000000D01*
+X0Y10000000D01*
+X0Y0D01*
+G37*
+%LPC*%
+G54D12*
+X5000000Y5000000D03*
+%LPD*%
+G54D13*
+X5000000Y5000000D03*
+G54D11*
+G36*
+X19000000Y-1000000D02*
+X23000000Y-1000000D01*
+X23000000Y1000000D01*
+X19000000Y1000000D01*
+X19000000Y-1000000D01*
+G37*
+%LPC*%
+G54D13*
+X20000000Y0D02*
+X22000000Y0D01*
+%LPD*%
+M02*
+`
+	if !strings.HasSuffix(got, want) {
+		t.Errorf("WriteGerber =\n%v\nwant suffix:\n%v", got, want)
+	}
+}
+
+func TestKnockoutT_MBB(t *testing.T) {
+	k := Knockout(0.5, Line(20, 0, 22, 0, CircleShape, 1), Flash(Pt{21, 2}, CircleAperture(1)))
+	if got, want := k.MBB(), (MBB{Min: Pt{19, -1}, Max: Pt{23, 3}}); !mbbClose(got, want) {
+		t.Errorf("MBB = %v, want %v", got, want)
+	}
+	var buf bytes.Buffer
+	if err := k.WriteGerber(&buf, &DefaultFormat, 11); err == nil {
+		t.Errorf("WriteGerber = nil, want error")
+	}
+}
@@ -3,7 +3,9 @@ package gerber
 import (
 	"fmt"
 	"io"
+	"log"
 	"math"
+	"strconv"
 
 	"github.com/gmlewis/go3d/float64/vec2"
 )
@@ -21,11 +23,17 @@ const (
 	RectShape Shape = "R"
 	// CircleShape uses circles for the aperture.
 	CircleShape Shape = "C"
+	// ObroundShape uses obrounds (stadiums) for the aperture.
+	ObroundShape Shape = "O"
+	// PolygonShape uses regular polygons for the aperture.
+	PolygonShape Shape = "P"
 )
 
 // Primitive is a Gerber primitive.
 type Primitive interface {
-	WriteGerber(w io.Writer, apertureIndex int) error
+	// WriteGerber writes the primitive to the Gerber file using the
+	// provided coordinate format.
+	WriteGerber(w io.Writer, f *Format, apertureIndex int) error
 	Aperture() *Aperture
 	// MBB returns the minimum bounding box in millimeters.
 	MBB() MBB
@@ -35,18 +43,111 @@ type Primitive interface {
 // and satisfies the Primitive interface.
 type Aperture struct {
 	Shape Shape
-	Size  float64
+	// Size is the diameter of circles and regular polygons
+	// and the width (X size) of rectangles and obrounds.
+	Size float64
+	// YSize is the height of rectangles and obrounds.
+	// If zero, Size is used.
+	YSize float64
+	// Vertices is the number of vertices (3-12) of a regular polygon.
+	Vertices int
+	// Rotation is the rotation of a regular polygon in degrees.
+	Rotation float64
+	// Hole is the optional diameter of a round hole in the aperture.
+	Hole float64
+	// Function is the optional Gerber X2 aperture function.
+	Function AperFunction
+	// Macro is the optional aperture macro that defines the aperture.
+	// When set, Shape and Size are ignored.
+	Macro *Macro
+	// Params are the macro parameters ($1, $2, ...).
+	Params []float64
+	// Block is the optional group of primitives that defines a block
+	// aperture. When set, Shape and Size are ignored.
+	Block *Block
 }
 
-func (a *Aperture) MBB() MBB { return MBB{} }
+// MBB returns the minimum bounding box of the aperture centered
+// on the origin.
+func (a *Aperture) MBB() MBB {
+	if a.Block != nil {
+		return a.Block.MBB()
+	}
+	if a.Macro != nil {
+		mbb, err := a.Macro.MBB(a.Params)
+		if err != nil {
+			log.Printf("MBB(%v): %v", a.Macro.Name, err)
+		}
+		return mbb
+	}
+	switch a.Shape {
+	case RectShape, ObroundShape:
+		hw, hh := 0.5*a.Size, 0.5*a.ySize()
+		return MBB{Min: Pt{-hw, -hh}, Max: Pt{hw, hh}}
+	case PolygonShape:
+		var mbb MBB
+		for i := 0; i < a.Vertices; i++ {
+			pt := rotate(Pt{0.5 * a.Size, 0}, a.Rotation+360*float64(i)/float64(a.Vertices))
+			mbb.Join(&MBB{Min: pt, Max: pt})
+		}
+		return mbb
+	}
+	r := 0.5 * a.Size
+	return MBB{Min: Pt{-r, -r}, Max: Pt{r, r}}
+}
+
+// ySize returns the height of rectangles and obrounds.
+func (a *Aperture) ySize() float64 {
+	if a.YSize == 0 {
+		return a.Size
+	}
+	return a.YSize
+}
 
 // WriteGerber writes the aperture to the Gerber file.
-func (a *Aperture) WriteGerber(w io.Writer, apertureIndex int) error {
-	if a.Shape == CircleShape {
-		fmt.Fprintf(w, "%%ADD%vC,%0.5f*%%\n", apertureIndex, a.Size)
+// Block apertures are written by their layer.
+func (a *Aperture) WriteGerber(w io.Writer, f *Format, apertureIndex int) error {
+	if a.Block != nil {
+		return fmt.Errorf("block aperture %v must be written by its layer", a.Block.Name)
+	}
+	if a.Macro != nil {
+		fmt.Fprintf(w, "%%ADD%v%v", apertureIndex, a.Macro.Name)
+		for i, v := range a.Params {
+			sep := "X"
+			if i == 0 {
+				sep = ","
+			}
+			if a.Macro.isUnitless(i + 1) {
+				fmt.Fprintf(w, "%v%v", sep, strconv.FormatFloat(v, 'f', -1, 64))
+				continue
+			}
+			fmt.Fprintf(w, "%v%v", sep, f.Size(v))
+		}
+		io.WriteString(w, "*%\n")
 		return nil
 	}
-	fmt.Fprintf(w, "%%ADD%vR,%0.5fX%0.5f*%%\n", apertureIndex, a.Size, a.Size)
+
+	var hole string
+	if a.Hole > 0 {
+		hole = "X" + f.Size(a.Hole)
+	}
+	switch a.Shape {
+	case CircleShape:
+		fmt.Fprintf(w, "%%ADD%vC,%v%v*%%\n", apertureIndex, f.Size(a.Size), hole)
+	case RectShape, ObroundShape:
+		fmt.Fprintf(w, "%%ADD%v%v,%vX%v%v*%%\n", apertureIndex, a.Shape, f.Size(a.Size), f.Size(a.ySize()), hole)
+	case PolygonShape:
+		if a.Vertices < 3 || a.Vertices > 12 {
+			return fmt.Errorf("polygon aperture has %v vertices: want 3-12", a.Vertices)
+		}
+		var rotation string
+		if a.Rotation != 0 || hole != "" {
+			rotation = "X" + strconv.FormatFloat(a.Rotation, 'f', -1, 64)
+		}
+		fmt.Fprintf(w, "%%ADD%vP,%vX%v%v%v*%%\n", apertureIndex, f.Size(a.Size), a.Vertices, rotation, hole)
+	default:
+		return fmt.Errorf("unknown aperture shape %q", a.Shape)
+	}
 	return nil
 }
 
@@ -60,7 +161,50 @@ func (a *Aperture) ID() string {
 	if a == nil {
 		return "default"
 	}
-	return fmt.Sprintf("%v%0.5f", a.Shape, sf*a.Size)
+	if a.Block != nil {
+		// Blocks are identified by pointer since their names are not
+		// written to the Gerber file.
+		return fmt.Sprintf("B%v%p", a.Block.Name, a.Block)
+	}
+	if a.Macro != nil {
+		return fmt.Sprintf("%v%v%v", a.Macro.Name, a.Params, a.Function)
+	}
+	id := fmt.Sprintf("%v%0.5f", a.Shape, sf*a.Size)
+	if a.Shape == RectShape || a.Shape == ObroundShape {
+		id += fmt.Sprintf("X%0.5f", sf*a.ySize())
+	}
+	if a.Shape == PolygonShape {
+		id += fmt.Sprintf("X%vX%v", a.Vertices, a.Rotation)
+	}
+	if a.Hole > 0 {
+		id += fmt.Sprintf("H%0.5f", sf*a.Hole)
+	}
+	return id + string(a.Function)
+}
+
+// CircleAperture returns a circle aperture.
+// All dimensions are in millimeters.
+func CircleAperture(diameter float64) *Aperture {
+	return &Aperture{Shape: CircleShape, Size: diameter}
+}
+
+// RectAperture returns a rectangle aperture.
+// All dimensions are in millimeters.
+func RectAperture(width, height float64) *Aperture {
+	return &Aperture{Shape: RectShape, Size: width, YSize: height}
+}
+
+// ObroundAperture returns an obround (stadium) aperture.
+// All dimensions are in millimeters.
+func ObroundAperture(width, height float64) *Aperture {
+	return &Aperture{Shape: ObroundShape, Size: width, YSize: height}
+}
+
+// PolygonAperture returns a regular polygon aperture with the given
+// outer diameter, number of vertices (3-12) and rotation in degrees.
+// All dimensions are in millimeters.
+func PolygonAperture(diameter float64, vertices int, rotation float64) *Aperture {
+	return &Aperture{Shape: PolygonShape, Size: diameter, Vertices: vertices, Rotation: rotation}
 }
 
 // Pt represents a 2D Point.
@@ -85,7 +229,8 @@ type ArcT struct {
 	StartAngle float64
 	EndAngle   float64
 	Thickness  float64
-	mbb        *MBB // cached minimum bounding box
+	Attributes
+	mbb *MBB // cached minimum bounding box
 }
 
 // Arc returns an arc primitive.
@@ -113,7 +258,68 @@ func Arc(
 }
 
 // WriteGerber writes the primitive to the Gerber file.
-func (a *ArcT) WriteGerber(w io.Writer, apertureIndex int) error {
+// Circular arcs drawn with a circle aperture are written using
+// multi-quadrant circular interpolation (G75 mode, set in the layer
+// header). Elliptical arcs (XScale != YScale) and arcs drawn with other
+// apertures are flattened into line segments. Sweeps that end on their
+// start point at the precision of the format are written as a dot.
+func (a *ArcT) WriteGerber(w io.Writer, f *Format, apertureIndex int) error {
+	if a.XScale != a.YScale || a.Shape != CircleShape {
+		return a.writeFlattened(w, f, apertureIndex)
+	}
+
+	// Each G03 command can sweep at most one full circle.
+	delta := a.EndAngle - a.StartAngle
+	n := int(math.Ceil(delta/(2*math.Pi) - 1e-9))
+	if n < 1 {
+		n = 1
+	}
+	start := a.point(a.StartAngle)
+	if n == 1 && delta < 2*math.Pi-1e-9 {
+		// An end point that rounds to the start point would be read
+		// as a full circle.
+		xy, err := f.XY(start)
+		if err != nil {
+			return err
+		}
+		end, err := f.XY(a.point(a.EndAngle))
+		if err != nil {
+			return err
+		}
+		if xy == end {
+			if delta < math.Pi {
+				// The sweep is below the resolution of the format.
+				fmt.Fprintf(w, "G54D%d*\n%vD02*\n%vD01*\n", apertureIndex, xy, xy)
+				return nil
+			}
+			n = 2
+		}
+	}
+	delta /= float64(n)
+
+	fmt.Fprintf(w, "G54D%d*\n", apertureIndex)
+	if err := f.writeOperation(w, start, 2); err != nil {
+		return err
+	}
+	io.WriteString(w, "G03*\n")
+	for i := 1; i <= n; i++ {
+		end := a.point(a.StartAngle + float64(i)*delta)
+		if delta >= 2*math.Pi-1e-9 {
+			end = start // full circle
+		}
+		ij := Pt{a.Center[0] - start[0], a.Center[1] - start[1]}
+		if err := f.writeArc(w, end, ij); err != nil {
+			return err
+		}
+		start = end
+	}
+	io.WriteString(w, "G01*\n")
+	return nil
+}
+
+// writeFlattened writes the arc as line segments with a
+// resolution of 0.1mm.
+func (a *ArcT) writeFlattened(w io.Writer, f *Format, apertureIndex int) error {
 	delta := a.EndAngle - a.StartAngle
 	length := delta * a.Radius
 	// Resolution of segments is 0.1mm
@@ -122,25 +328,32 @@ func (a *ArcT) WriteGerber(w io.Writer, apertureIndex int) error {
 
 	angle := float64(a.StartAngle)
 	for i := 0; i < segments; i++ {
-		x1 := a.Center[0] + a.XScale*math.Cos(angle)*a.Radius
-		y1 := a.Center[1] + a.YScale*math.Sin(angle)*a.Radius
-
+		p1 := a.point(angle)
 		angle += delta
+		p2 := a.point(angle)
 
-		x2 := a.Center[0] + a.XScale*math.Cos(angle)*a.Radius
-		y2 := a.Center[1] + a.YScale*math.Sin(angle)*a.Radius
-
-		line := Line(x1, y1, x2, y2, a.Shape, a.Thickness)
-		line.WriteGerber(w, apertureIndex)
+		line := Line(p1[0], p1[1], p2[0], p2[1], a.Shape, a.Thickness)
+		if err := line.WriteGerber(w, f, apertureIndex); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
+// point returns the point on the arc at the given angle (in radians).
+func (a *ArcT) point(angle float64) Pt {
+	return Pt{
+		a.Center[0] + a.XScale*math.Cos(angle)*a.Radius,
+		a.Center[1] + a.YScale*math.Sin(angle)*a.Radius,
+	}
+}
+
 // Aperture returns the primitive's desired aperture.
 func (a *ArcT) Aperture() *Aperture {
 	return &Aperture{
-		Shape: a.Shape,
-		Size:  a.Thickness,
+		Shape:    a.Shape,
+		Size:     a.Thickness,
+		Function: a.Function,
 	}
 }
 
@@ -149,30 +362,18 @@ func (a *ArcT) MBB() MBB {
 		return *a.mbb
 	}
 
-	delta := a.EndAngle - a.StartAngle
-	length := delta * a.Radius
-	// Resolution of segments is 0.1mm
-	segments := int(0.5+length*10.0) + 1
-	delta /= float64(segments)
-
-	angle := float64(a.StartAngle)
-	for i := 0; i < segments; i++ {
-		x1 := a.Center[0] + a.XScale*math.Cos(angle)*a.Radius
-		y1 := a.Center[1] + a.YScale*math.Sin(angle)*a.Radius
-
-		angle += delta
-
-		x2 := a.Center[0] + a.XScale*math.Cos(angle)*a.Radius
-		y2 := a.Center[1] + a.YScale*math.Sin(angle)*a.Radius
-
-		line := Line(x1, y1, x2, y2, a.Shape, a.Thickness)
-		mbb := line.MBB()
-		if a.mbb == nil {
-			a.mbb = &mbb
-		} else {
-			a.mbb.Join(&mbb)
-		}
+	p1, p2 := a.point(a.StartAngle), a.point(a.EndAngle)
+	a.mbb = &MBB{Min: p1, Max: p1}
+	a.mbb.Join(&MBB{Min: p2, Max: p2})
+	// Add each axis extreme (a multiple of 90 degrees) swept by the arc.
+	for q := math.Ceil(2 * a.StartAngle / math.Pi); q*math.Pi/2 <= a.EndAngle; q++ {
+		pt := a.point(q * math.Pi / 2)
+		a.mbb.Join(&MBB{Min: pt, Max: pt})
 	}
+	a.mbb.Min[0] -= 0.5 * a.Thickness
+	a.mbb.Min[1] -= 0.5 * a.Thickness
+	a.mbb.Max[0] += 0.5 * a.Thickness
+	a.mbb.Max[1] += 0.5 * a.Thickness
 
 	return *a.mbb
 }
@@ -181,7 +382,8 @@ func (a *ArcT) MBB() MBB {
 type CircleT struct {
 	pt        Pt
 	thickness float64
-	mbb       *MBB // cached minimum bounding box
+	Attributes
+	mbb *MBB // cached minimum bounding box
 }
 
 // Circle returns a circle primitive.
@@ -193,19 +395,18 @@ func Circle(center Pt, thickness float64) *CircleT {
 	}
 }
 
-// WriteGerber writes the primitive to the Gerber file.
-func (c *CircleT) WriteGerber(w io.Writer, apertureIndex int) error {
+// WriteGerber writes the primitive to the Gerber file as a flash.
+func (c *CircleT) WriteGerber(w io.Writer, f *Format, apertureIndex int) error {
 	fmt.Fprintf(w, "G54D%d*\n", apertureIndex)
-	fmt.Fprintf(w, "X%06dY%06dD02*\n", int(0.5+sf*(c.pt[0])), int(0.5+sf*(c.pt[1])))
-	fmt.Fprintf(w, "X%06dY%06dD01*\n", int(0.5+sf*(c.pt[0])), int(0.5+sf*(c.pt[1])))
-	return nil
+	return f.writeOperation(w, c.pt, 3)
 }
 
 // Aperture returns the primitive's desired aperture.
 func (c *CircleT) Aperture() *Aperture {
 	return &Aperture{
-		Shape: CircleShape,
-		Size:  c.thickness,
+		Shape:    CircleShape,
+		Size:     c.thickness,
+		Function: c.Function,
 	}
 }
 
@@ -220,12 +421,79 @@ func (c *CircleT) MBB() MBB {
 	return *c.mbb
 }
 
+// FlashT represents a flash (D03) of an aperture and satisfies
+// the Primitive interface.
+type FlashT struct {
+	Center Pt
+	// Mirror, Rotation (in degrees) and Scale are the optional
+	// transformations of the flashed aperture. A zero Scale means 1.
+	Mirror   Mirroring
+	Rotation float64
+	Scale    float64
+	aperture *Aperture
+	Attributes
+	mbb *MBB // cached minimum bounding box
+}
+
+// Flash returns a flash primitive that replicates the aperture
+// at the center point, typically to create a pad.
+// All dimensions are in millimeters.
+func Flash(center Pt, aperture *Aperture) *FlashT {
+	return &FlashT{
+		Center:   center,
+		aperture: aperture,
+	}
+}
+
+// WriteGerber writes the primitive to the Gerber file.
+func (f *FlashT) WriteGerber(w io.Writer, format *Format, apertureIndex int) error {
+	fmt.Fprintf(w, "G54D%d*\n", apertureIndex)
+	reset := f.writeTransforms(w)
+	if err := format.writeOperation(w, f.Center, 3); err != nil {
+		return err
+	}
+	reset()
+	return nil
+}
+
+// Aperture returns the primitive's desired aperture.
+func (f *FlashT) Aperture() *Aperture {
+	if f.Function == "" || f.aperture == nil {
+		return f.aperture
+	}
+	a := *f.aperture
+	a.Function = f.Function
+	return &a
+}
+
+func (f *FlashT) MBB() MBB {
+	if f.mbb != nil {
+		return *f.mbb
+	}
+	var mbb MBB
+	if f.aperture != nil {
+		mbb = f.aperture.MBB()
+	}
+	// Transform the corners of the aperture's bounding box.
+	for i, pt := range []Pt{mbb.Min, {mbb.Max[0], mbb.Min[1]}, mbb.Max, {mbb.Min[0], mbb.Max[1]}} {
+		pt = f.transform(pt)
+		pt = Pt{f.Center[0] + pt[0], f.Center[1] + pt[1]}
+		if i == 0 {
+			f.mbb = &MBB{Min: pt, Max: pt}
+			continue
+		}
+		f.mbb.Join(&MBB{Min: pt, Max: pt})
+	}
+	return *f.mbb
+}
+
 // LineT represents a line and satisfies the Primitive interface.
 type LineT struct {
 	P1, P2    Pt
 	Shape     Shape
 	Thickness float64
-	mbb       *MBB // cached minimum bounding box
+	Attributes
+	mbb *MBB // cached minimum bounding box
 }
 
 // Line returns a line primitive.
@@ -242,18 +510,20 @@ func Line(x1, y1, x2, y2 float64, shape Shape, thickness float64) *LineT {
 }
 
 // WriteGerber writes the primitive to the Gerber file.
-func (l *LineT) WriteGerber(w io.Writer, apertureIndex int) error {
+func (l *LineT) WriteGerber(w io.Writer, f *Format, apertureIndex int) error {
 	fmt.Fprintf(w, "G54D%d*\n", apertureIndex)
-	fmt.Fprintf(w, "X%06dY%06dD02*\n", int(0.5+sf*(l.P1[0])), int(0.5+sf*(l.P1[1])))
-	fmt.Fprintf(w, "X%06dY%06dD01*\n", int(0.5+sf*(l.P2[0])), int(0.5+sf*(l.P2[1])))
-	return nil
+	if err := f.writeOperation(w, l.P1, 2); err != nil {
+		return err
+	}
+	return f.writeOperation(w, l.P2, 1)
 }
 
 // Aperture returns the primitive's desired aperture.
 func (l *LineT) Aperture() *Aperture {
 	return &Aperture{
-		Shape: l.Shape,
-		Size:  l.Thickness,
+		Shape:    l.Shape,
+		Size:     l.Thickness,
+		Function: l.Function,
 	}
 }
 
@@ -274,37 +544,80 @@ func (l *LineT) MBB() MBB {
 type PolygonT struct {
 	Offset Pt
 	Points []Pt
-	mbb    *MBB // cached minimum bounding box
+	// Filled polygons are written as regions.
+	Filled bool
+	// Thickness is the width of the circle aperture used to stroke
+	// the outline of the polygon. If zero, the outline is not stroked.
+	Thickness float64
+	Attributes
+	mbb *MBB // cached minimum bounding box
 }
 
-// Polygon returns a polygon primitive.
+// Polygon returns a polygon primitive that is filled and/or
+// stroked with a circle aperture of the given thickness.
 // All dimensions are in millimeters.
 func Polygon(offset Pt, filled bool, points []Pt, thickness float64) *PolygonT {
 	return &PolygonT{
-		Offset: offset,
-		Points: points,
+		Offset:    offset,
+		Points:    points,
+		Filled:    filled,
+		Thickness: thickness,
 	}
 }
 
 // WriteGerber writes the primitive to the Gerber file.
-func (p *PolygonT) WriteGerber(w io.Writer, apertureIndex int) error {
-	io.WriteString(w, "G54D11*\n")
-	io.WriteString(w, "G36*\n")
+func (p *PolygonT) WriteGerber(w io.Writer, f *Format, apertureIndex int) error {
+	if !p.Filled && p.Thickness <= 0 {
+		return fmt.Errorf("polygon is neither filled nor stroked")
+	}
+	if len(p.Points) == 0 {
+		return nil
+	}
+	if p.Filled {
+		io.WriteString(w, "G54D11*\n")
+		io.WriteString(w, "G36*\n")
+		if err := p.writeContour(w, f); err != nil {
+			return err
+		}
+		io.WriteString(w, "G37*\n")
+	}
+	if p.Thickness > 0 {
+		fmt.Fprintf(w, "G54D%d*\n", apertureIndex)
+		if err := p.writeContour(w, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeContour writes the closed outline of the polygon.
+func (p *PolygonT) writeContour(w io.Writer, f *Format) error {
 	for i, pt := range p.Points {
+		d := 1
 		if i == 0 {
-			fmt.Fprintf(w, "X%06dY%06dD02*\n", int(0.5+sf*(pt[0]+p.Offset[0])), int(0.5+sf*(pt[1]+p.Offset[1])))
-			continue
+			d = 2
+		}
+		if err := f.writeOperation(w, Pt{pt[0] + p.Offset[0], pt[1] + p.Offset[1]}, d); err != nil {
+			return err
 		}
-		fmt.Fprintf(w, "X%06dY%06dD01*\n", int(0.5+sf*(pt[0]+p.Offset[0])), int(0.5+sf*(pt[1]+p.Offset[1])))
 	}
-	fmt.Fprintf(w, "X%06dY%06dD02*\n", int(0.5+sf*(p.Points[0][0]+p.Offset[0])), int(0.5+sf*(p.Points[0][1]+p.Offset[1])))
-	io.WriteString(w, "G37*\n")
-	return nil
+	if n := len(p.Points); n > 1 && p.Points[n-1] == p.Points[0] {
+		return nil // already closed
+	}
+	return f.writeOperation(w, Pt{p.Points[0][0] + p.Offset[0], p.Points[0][1] + p.Offset[1]}, 1)
 }
 
-// Aperture returns nil for PolygonT because it uses the default aperture.
+// Aperture returns the circle aperture that strokes the outline,
+// or nil for unstroked polygons because regions use the default aperture.
 func (p *PolygonT) Aperture() *Aperture {
-	return nil
+	if p.Thickness <= 0 {
+		return nil
+	}
+	return &Aperture{
+		Shape:    CircleShape,
+		Size:     p.Thickness,
+		Function: p.Function,
+	}
 }
 
 func (p *PolygonT) MBB() MBB {
@@ -320,6 +633,14 @@ func (p *PolygonT) MBB() MBB {
 		}
 		p.mbb.Join(v)
 	}
+	if p.mbb == nil {
+		p.mbb = &MBB{}
+	}
+	if p.Thickness > 0 {
+		r := 0.5 * p.Thickness
+		p.mbb.Min = Pt{p.mbb.Min[0] - r, p.mbb.Min[1] - r}
+		p.mbb.Max = Pt{p.mbb.Max[0] + r, p.mbb.Max[1] + r}
+	}
 
 	return *p.mbb
 }
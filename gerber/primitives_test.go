@@ -1,7 +1,9 @@
 package gerber
 
 import (
+	"bytes"
 	"math"
+	"strings"
 	"testing"
 )
 
@@ -13,6 +15,154 @@ func TestAperture_Primitive(t *testing.T) {
 	}
 }
 
+func TestAperture_WriteGerber(t *testing.T) {
+	tests := []struct {
+		name    string
+		a       *Aperture
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "circle",
+			a:    CircleAperture(1),
+			want: "%ADD12C,1.00000*%\n",
+		},
+		{
+			name: "circle w/ hole",
+			a:    &Aperture{Shape: CircleShape, Size: 1, Hole: 0.5},
+			want: "%ADD12C,1.00000X0.50000*%\n",
+		},
+		{
+			name: "square",
+			a:    &Aperture{Shape: RectShape, Size: 1},
+			want: "%ADD12R,1.00000X1.00000*%\n",
+		},
+		{
+			name: "rectangle",
+			a:    RectAperture(1, 2),
+			want: "%ADD12R,1.00000X2.00000*%\n",
+		},
+		{
+			name: "obround w/ hole",
+			a:    &Aperture{Shape: ObroundShape, Size: 2, YSize: 1, Hole: 0.4},
+			want: "%ADD12O,2.00000X1.00000X0.40000*%\n",
+		},
+		{
+			name: "polygon",
+			a:    PolygonAperture(1, 6, 0),
+			want: "%ADD12P,1.00000X6*%\n",
+		},
+		{
+			name: "rotated polygon",
+			a:    PolygonAperture(1, 8, 22.5),
+			want: "%ADD12P,1.00000X8X22.5*%\n",
+		},
+		{
+			name: "polygon w/ hole",
+			a:    &Aperture{Shape: PolygonShape, Size: 1, Vertices: 4, Hole: 0.5},
+			want: "%ADD12P,1.00000X4X0X0.50000*%\n",
+		},
+		{
+			name:    "polygon w/ too few vertices",
+			a:       PolygonAperture(1, 2, 0),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf strings.Builder
+			err := tt.a.WriteGerber(&buf, &DefaultFormat, 12)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("WriteGerber = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("WriteGerber: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("WriteGerber = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAperture_ID(t *testing.T) {
+	apertures := []*Aperture{
+		CircleAperture(1),
+		{Shape: CircleShape, Size: 1, Hole: 0.5},
+		{Shape: RectShape, Size: 1},
+		RectAperture(1, 2),
+		RectAperture(2, 1),
+		ObroundAperture(1, 2),
+		PolygonAperture(1, 6, 0),
+		PolygonAperture(1, 6, 30),
+		PolygonAperture(1, 8, 0),
+		{Shape: CircleShape, Size: 1, Function: ViaPad},
+	}
+	ids := map[string]int{}
+	for i, a := range apertures {
+		id := a.ID()
+		if j, ok := ids[id]; ok {
+			t.Errorf("apertures %v and %v have the same ID %q", j, i, id)
+		}
+		ids[id] = i
+	}
+
+	if got, want := RectAperture(1, 1).ID(), (&Aperture{Shape: RectShape, Size: 1}).ID(); got != want {
+		t.Errorf("ID = %q, want %q", got, want)
+	}
+}
+
+func TestAperture_MBB(t *testing.T) {
+	const eps = 1e-12
+	tests := []struct {
+		name string
+		a    *Aperture
+		want MBB
+	}{
+		{
+			name: "circle",
+			a:    CircleAperture(1),
+			want: MBB{Min: Pt{-0.5, -0.5}, Max: Pt{0.5, 0.5}},
+		},
+		{
+			name: "rectangle",
+			a:    RectAperture(1, 2),
+			want: MBB{Min: Pt{-0.5, -1}, Max: Pt{0.5, 1}},
+		},
+		{
+			name: "obround",
+			a:    ObroundAperture(2, 1),
+			want: MBB{Min: Pt{-1, -0.5}, Max: Pt{1, 0.5}},
+		},
+		{
+			name: "square polygon",
+			a:    PolygonAperture(2, 4, 0),
+			want: MBB{Min: Pt{-1, -1}, Max: Pt{1, 1}},
+		},
+		{
+			name: "rotated square polygon",
+			a:    PolygonAperture(2, 4, 45),
+			want: MBB{Min: Pt{-math.Sqrt2 / 2, -math.Sqrt2 / 2}, Max: Pt{math.Sqrt2 / 2, math.Sqrt2 / 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.a.MBB()
+			for i := 0; i < 2; i++ {
+				if math.Abs(got.Min[i]-tt.want.Min[i]) > eps || math.Abs(got.Max[i]-tt.want.Max[i]) > eps {
+					t.Errorf("MBB = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
 func TestArcT_Primitive(t *testing.T) {
 	var p Primitive = &ArcT{}
 	if p == nil {
@@ -73,6 +223,16 @@ func TestArcT_MBB(t *testing.T) {
 			p:    Arc(Pt{10, 20}, 10, CircleShape, 1, 1, 180, 270, 2),
 			want: MBB{Min: Pt{-1, 9}, Max: Pt{11, 21}},
 		},
+		{
+			name: "elliptical arc",
+			p:    Arc(Pt{0, 0}, 10, CircleShape, 2, 0.5, 45, 135, 2),
+			want: MBB{Min: Pt{-15.142, 2.536}, Max: Pt{15.142, 6}},
+		},
+		{
+			name: "arc spanning the positive x axis",
+			p:    Arc(Pt{0, 0}, 10, CircleShape, 1, 1, -45, 45, 0),
+			want: MBB{Min: Pt{7.071, -7.071}, Max: Pt{10, 7.071}},
+		},
 		{
 			name: "fourth quadrant arc w/ offset",
 			p:    Arc(Pt{10, 20}, 10, CircleShape, 1, 1, 270, 360, 2),
@@ -99,6 +259,100 @@ func TestArcT_MBB(t *testing.T) {
 	}
 }
 
+func TestArcT_WriteGerber(t *testing.T) {
+	tests := []struct {
+		name string
+		p    *ArcT
+		want string
+	}{
+		{
+			name: "half circle",
+			p:    Arc(Pt{10, 20}, 1, CircleShape, 1, 1, 180, 360, 0.1),
+			want: `G54D12*
+X9000000Y20000000D02*
+G03*
+X11000000Y20000000I1000000J0D01*
+G01*
+`,
+		},
+		{
+			name: "full circle",
+			p:    Arc(Pt{10, 20}, 1, CircleShape, 1, 1, 180, 540, 0.1),
+			want: `G54D12*
+X9000000Y20000000D02*
+G03*
+X9000000Y20000000I1000000J0D01*
+G01*
+`,
+		},
+		{
+			name: "two turns",
+			p:    Arc(Pt{10, 20}, 1, CircleShape, 1, 1, 180, 900, 0.1),
+			want: `G54D12*
+X9000000Y20000000D02*
+G03*
+X9000000Y20000000I1000000J0D01*
+X9000000Y20000000I1000000J0D01*
+G01*
+`,
+		},
+		{
+			name: "empty sweep",
+			p:    Arc(Pt{10, 20}, 1, CircleShape, 1, 1, 180, 180, 0.1),
+			want: `G54D12*
+X9000000Y20000000D02*
+X9000000Y20000000D01*
+`,
+		},
+		{
+			name: "sweep below the resolution",
+			p:    Arc(Pt{10, 20}, 1, CircleShape, 1, 1, 180, 180+1e-8, 0.1),
+			want: `G54D12*
+X9000000Y20000000D02*
+X9000000Y20000000D01*
+`,
+		},
+		{
+			name: "almost a full circle",
+			p:    Arc(Pt{10, 20}, 1, CircleShape, 1, 1, 180, 540-1e-5, 0.1),
+			want: `G54D12*
+X9000000Y20000000D02*
+G03*
+X11000000Y20000000I1000000J0D01*
+X9000000Y20000000I-1000000J0D01*
+G01*
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf strings.Builder
+			if err := tt.p.WriteGerber(&buf, &DefaultFormat, 12); err != nil {
+				t.Fatalf("WriteGerber: %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("WriteGerber =\n%v\nwant:\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArcT_WriteGerber_Elliptical(t *testing.T) {
+	p := Arc(Pt{0, 0}, 1, CircleShape, 2, 1, 0, 90, 0.1)
+	var buf strings.Builder
+	if err := p.WriteGerber(&buf, &DefaultFormat, 12); err != nil {
+		t.Fatalf("WriteGerber: %v", err)
+	}
+	got := buf.String()
+	if strings.Contains(got, "G03") {
+		t.Errorf("WriteGerber =\n%v\nwant flattened line segments", got)
+	}
+	if n := strings.Count(got, "D01*"); n < 10 {
+		t.Errorf("WriteGerber wrote %v segments, want at least 10", n)
+	}
+}
+
 func TestCircleT_Primitive(t *testing.T) {
 	var p Primitive = &CircleT{}
 	if p == nil {
@@ -140,6 +394,89 @@ func TestCircleT_MBB(t *testing.T) {
 	}
 }
 
+func TestCircleT_WriteGerber(t *testing.T) {
+	var buf strings.Builder
+	if err := Circle(Pt{1, 2}, 0.5).WriteGerber(&buf, &DefaultFormat, 12); err != nil {
+		t.Fatalf("WriteGerber: %v", err)
+	}
+	if got, want := buf.String(), "G54D12*\nX1000000Y2000000D03*\n"; got != want {
+		t.Errorf("WriteGerber = %q, want %q", got, want)
+	}
+}
+
+func TestFlashT_Primitive(t *testing.T) {
+	var p Primitive = &FlashT{}
+	if p == nil {
+		// In actuality, this test won't compile if it isn't a Primitive.
+		t.Errorf("FlashT does not implement the Primitive interface")
+	}
+}
+
+func TestFlashT_MBB(t *testing.T) {
+	const eps = 1e-12
+	tests := []struct {
+		name string
+		p    *FlashT
+		want MBB
+	}{
+		{
+			name: "circle",
+			p:    Flash(Pt{10, 20}, CircleAperture(1)),
+			want: MBB{Min: Pt{9.5, 19.5}, Max: Pt{10.5, 20.5}},
+		},
+		{
+			name: "rectangle",
+			p:    Flash(Pt{10, 20}, RectAperture(1, 2)),
+			want: MBB{Min: Pt{9.5, 19}, Max: Pt{10.5, 21}},
+		},
+		{
+			name: "macro",
+			p:    Flash(Pt{-10, 0}, RoundRectAperture(2, 1, 0.25, 0)),
+			want: MBB{Min: Pt{-11, -0.5}, Max: Pt{-9, 0.5}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.p.MBB()
+			for i := 0; i < 2; i++ {
+				if math.Abs(got.Min[i]-tt.want.Min[i]) > eps || math.Abs(got.Max[i]-tt.want.Max[i]) > eps {
+					t.Errorf("MBB = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestFlashT_WriteGerber(t *testing.T) {
+	g := New("test")
+	top := g.TopCopper()
+	pad1 := Flash(Pt{1, 2}, ObroundAperture(2, 1))
+	pad1.Function = SMDPad
+	pad2 := Flash(Pt{3, 2}, ObroundAperture(2, 1))
+	pad2.Function = SMDPad
+	top.Add(pad1, pad2, Flash(Pt{5, 2}, ObroundAperture(2, 1)))
+
+	if got, want := len(top.Apertures), 2; got != want {
+		t.Errorf("len(Apertures) = %v, want %v", got, want)
+	}
+
+	var buf strings.Builder
+	if err := top.WriteGerber(&buf); err != nil {
+		t.Fatalf("WriteGerber: %v", err)
+	}
+	got := buf.String()
+	for _, want := range []string{
+		"%TA.AperFunction,SMDPad,CuDef*%\n%ADD12O,2.00000X1.00000*%\n%TD.AperFunction*%\n%ADD13O,2.00000X1.00000*%\n",
+		"G54D12*\nX1000000Y2000000D03*\nG54D12*\nX3000000Y2000000D03*\nG54D13*\nX5000000Y2000000D03*\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("WriteGerber =\n%v\nwant it to contain:\n%v", got, want)
+		}
+	}
+}
+
 func TestLineT_Primitive(t *testing.T) {
 	var p Primitive = &LineT{}
 	if p == nil {
@@ -221,6 +558,11 @@ func TestPolygonT_MBB(t *testing.T) {
 			p:    Polygon(Pt{0, 0}, true, []Pt{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}}, 0),
 			want: MBB{Min: Pt{-1, -1}, Max: Pt{1, 1}},
 		},
+		{
+			name: "stroked box",
+			p:    Polygon(Pt{0, 0}, false, []Pt{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}}, 0.5),
+			want: MBB{Min: Pt{-1.25, -1.25}, Max: Pt{1.25, 1.25}},
+		},
 	}
 
 	for _, tt := range tests {
@@ -241,3 +583,82 @@ func TestPolygonT_MBB(t *testing.T) {
 		})
 	}
 }
+
+func TestPolygonT_WriteGerber(t *testing.T) {
+	pts := []Pt{{0, 0}, {1, 0}, {1, 1}}
+	tests := []struct {
+		name string
+		p    *PolygonT
+		want string
+	}{
+		{
+			name: "filled",
+			p:    Polygon(Pt{0, 0}, true, pts, 0),
+			want: `G54D11*
+G36*
+X0Y0D02*
+X1000000Y0D01*
+X1000000Y1000000D01*
+X0Y0D01*
+G37*
+`,
+		},
+		{
+			name: "stroked",
+			p:    Polygon(Pt{1, 0}, false, pts, 0.2),
+			want: `G54D12*
+X1000000Y0D02*
+X2000000Y0D01*
+X2000000Y1000000D01*
+X1000000Y0D01*
+`,
+		},
+		{
+			name: "filled and stroked",
+			p:    Polygon(Pt{0, 0}, true, pts, 0.2),
+			want: `G54D11*
+G36*
+X0Y0D02*
+X1000000Y0D01*
+X1000000Y1000000D01*
+X0Y0D01*
+G37*
+G54D12*
+X0Y0D02*
+X1000000Y0D01*
+X1000000Y1000000D01*
+X0Y0D01*
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.p.WriteGerber(&buf, &DefaultFormat, 12); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("WriteGerber =\n%v\nwant:\n%v", got, tt.want)
+			}
+		})
+	}
+
+	if err := Polygon(Pt{0, 0}, false, pts, 0).WriteGerber(&bytes.Buffer{}, &DefaultFormat, 12); err == nil {
+		t.Errorf("WriteGerber of unfilled and unstroked polygon = nil, want error")
+	}
+}
+
+func TestPolygonT_Aperture(t *testing.T) {
+	layer := New("polygon").TopCopper()
+	layer.Add(
+		Polygon(Pt{0, 0}, true, []Pt{{0, 0}, {1, 0}, {1, 1}}, 0),
+		Polygon(Pt{0, 0}, false, []Pt{{0, 0}, {1, 0}, {1, 1}}, 0.2),
+	)
+	if got, want := len(layer.Apertures), 1; got != want {
+		t.Fatalf("len(Apertures) = %v, want %v", got, want)
+	}
+	if got, want := layer.Apertures[0].ID(), CircleAperture(0.2).ID()+string(Conductor); got != want {
+		t.Errorf("Apertures[0].ID() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,254 @@
+package gerber
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// FabProfile represents the file naming and packaging conventions
+// expected by a PCB manufacturer (fab).
+type FabProfile struct {
+	// Name is the name of the fab.
+	Name string
+	// Filenames are the fmt patterns of the layer filenames by layer type.
+	// %[1]v is the filename prefix, %[2]v the copper layer number
+	// (2 for the first inner layer) and %[3]v the inner layer number
+	// (1 for the first inner layer).
+	Filenames map[LayerType]string
+	// SplitDrill writes the plated and non-plated holes to separate
+	// drill files. Otherwise the non-plated holes are merged into the
+	// (plated) drill file.
+	SplitDrill bool
+	// Readme is the fmt pattern of the filename of a generated readme
+	// listing the files and their functions. %v is the filename prefix.
+	// If empty, no readme is included.
+	Readme string
+	// JobFile includes a Gerber job file (.gbrjob) describing the
+	// stackup and board specifications.
+	JobFile bool
+	// ZipName is the fmt pattern of the ZIP filename.
+	// %v is the filename prefix.
+	ZipName string
+}
+
+var (
+	// ProtelProfile uses the Protel filename extensions that are
+	// the defaults of the layers.
+	ProtelProfile = &FabProfile{
+		Name: "Protel",
+		Filenames: map[LayerType]string{
+			LayerTopCopper:        "%[1]v.gtl",
+			LayerTopSolderMask:    "%[1]v.gts",
+			LayerTopSilkscreen:    "%[1]v.gto",
+			LayerBottomCopper:     "%[1]v.gbl",
+			LayerBottomSolderMask: "%[1]v.gbs",
+			LayerBottomSilkscreen: "%[1]v.gbo",
+			LayerInnerCopper:      "%[1]v.gl%[2]v",
+			LayerDrill:            "%[1]v.drl",
+			LayerNonPlatedDrill:   "%[1]v-NPTH.drl",
+			LayerOutline:          "%[1]v.gko",
+		},
+		SplitDrill: true,
+		JobFile:    true,
+		ZipName:    "%v.zip",
+	}
+
+	// JLCPCBProfile follows the JLCPCB naming conventions.
+	JLCPCBProfile = &FabProfile{
+		Name: "JLCPCB",
+		Filenames: map[LayerType]string{
+			LayerTopCopper:        "%[1]v.GTL",
+			LayerTopSolderMask:    "%[1]v.GTS",
+			LayerTopSilkscreen:    "%[1]v.GTO",
+			LayerBottomCopper:     "%[1]v.GBL",
+			LayerBottomSolderMask: "%[1]v.GBS",
+			LayerBottomSilkscreen: "%[1]v.GBO",
+			LayerInnerCopper:      "%[1]v.G%[3]v",
+			LayerDrill:            "%[1]v-PTH.DRL",
+			LayerNonPlatedDrill:   "%[1]v-NPTH.DRL",
+			LayerOutline:          "%[1]v.GKO",
+		},
+		SplitDrill: true,
+		ZipName:    "%v-jlcpcb.zip",
+	}
+
+	// OSHParkProfile follows the OSH Park naming conventions.
+	// OSH Park expects a single drill file.
+	OSHParkProfile = &FabProfile{
+		Name: "OSH Park",
+		Filenames: map[LayerType]string{
+			LayerTopCopper:        "%[1]v.GTL",
+			LayerTopSolderMask:    "%[1]v.GTS",
+			LayerTopSilkscreen:    "%[1]v.GTO",
+			LayerBottomCopper:     "%[1]v.GBL",
+			LayerBottomSolderMask: "%[1]v.GBS",
+			LayerBottomSilkscreen: "%[1]v.GBO",
+			LayerInnerCopper:      "%[1]v.G%[2]vL",
+			LayerDrill:            "%[1]v.XLN",
+			LayerOutline:          "%[1]v.GKO",
+		},
+		ZipName: "%v-oshpark.zip",
+	}
+
+	// PCBWayProfile follows the PCBWay naming conventions and
+	// includes a readme describing the files.
+	PCBWayProfile = &FabProfile{
+		Name: "PCBWay",
+		Filenames: map[LayerType]string{
+			LayerTopCopper:        "%[1]v.GTL",
+			LayerTopSolderMask:    "%[1]v.GTS",
+			LayerTopSilkscreen:    "%[1]v.GTO",
+			LayerBottomCopper:     "%[1]v.GBL",
+			LayerBottomSolderMask: "%[1]v.GBS",
+			LayerBottomSilkscreen: "%[1]v.GBO",
+			LayerInnerCopper:      "%[1]v.G%[3]v",
+			LayerDrill:            "%[1]v.DRL",
+			LayerNonPlatedDrill:   "%[1]v-NPTH.DRL",
+			LayerOutline:          "%[1]v.GKO",
+		},
+		SplitDrill: true,
+		Readme:     "%v-readme.txt",
+		ZipName:    "%v-pcbway.zip",
+	}
+
+	// AislerProfile uses the KiCad naming conventions preferred by Aisler.
+	AislerProfile = &FabProfile{
+		Name: "Aisler",
+		Filenames: map[LayerType]string{
+			LayerTopCopper:        "%[1]v-F_Cu.gbr",
+			LayerTopSolderMask:    "%[1]v-F_Mask.gbr",
+			LayerTopSilkscreen:    "%[1]v-F_Silkscreen.gbr",
+			LayerBottomCopper:     "%[1]v-B_Cu.gbr",
+			LayerBottomSolderMask: "%[1]v-B_Mask.gbr",
+			LayerBottomSilkscreen: "%[1]v-B_Silkscreen.gbr",
+			LayerInnerCopper:      "%[1]v-In%[3]v_Cu.gbr",
+			LayerDrill:            "%[1]v-PTH.drl",
+			LayerNonPlatedDrill:   "%[1]v-NPTH.drl",
+			LayerOutline:          "%[1]v-Edge_Cuts.gbr",
+		},
+		SplitDrill: true,
+		JobFile:    true,
+		ZipName:    "%v-aisler.zip",
+	}
+)
+
+// filename returns the filename of a layer of the given type.
+func (p *FabProfile) filename(prefix string, layerType LayerType, n int) (string, error) {
+	pattern, ok := p.Filenames[layerType]
+	if !ok {
+		return "", fmt.Errorf("profile %v has no filename for layer type %v", p.Name, layerType)
+	}
+	return fmt.Sprintf(pattern, prefix, n, n-1), nil
+}
+
+// WriteProfileZip writes an upload-ready ZIP file for the fab
+// profile to w. No files are created.
+func (g *Gerber) WriteProfileZip(w io.Writer, p *FabProfile) error {
+	files, err := g.renderProfile(p)
+	if err != nil {
+		return err
+	}
+	return writeZip(w, files)
+}
+
+// WriteProfile writes an upload-ready ZIP file for the fab profile
+// to the directory and returns its path.
+func (g *Gerber) WriteProfile(dir string, p *FabProfile) (string, error) {
+	files, err := g.renderProfile(p)
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Join(dir, fmt.Sprintf(p.ZipName, filepath.Base(g.FilenamePrefix)))
+	if err := writeZipFile(name, files); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// renderProfile renders each layer exactly once, named by the profile.
+// If p is nil, the layer filenames are used.
+func (g *Gerber) renderProfile(p *FabProfile) ([]file, error) {
+	layers := g.Layers
+	if p != nil && !p.SplitDrill {
+		layers = g.mergeDrills()
+	}
+
+	var files []file
+	seen := map[string]bool{}
+	for _, layer := range layers {
+		name := layer.Filename
+		if p != nil {
+			// Merged drill files are named like plated drill files,
+			// even when all of their holes are non-plated.
+			nameType := layer.Type
+			if !p.SplitDrill && layer.isDrill() {
+				nameType = LayerDrill
+			}
+			var err error
+			if name, err = p.filename(filepath.Base(g.FilenamePrefix), nameType, layer.N); err != nil {
+				return nil, err
+			}
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("two layers named %v", name)
+		}
+		seen[name] = true
+
+		var buf bytes.Buffer
+		if err := layer.render(&buf); err != nil {
+			return nil, err
+		}
+		files = append(files, file{name: name, data: buf.Bytes()})
+	}
+
+	if p != nil && p.Readme != "" {
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "%v: Gerber files for %v generated by github.com/gmlewis/go-gerber\n\n", g.FilenamePrefix, p.Name)
+		for i, f := range files {
+			fmt.Fprintf(&buf, "%v: %v\n", f.name, layers[i].FileFunction())
+		}
+		files = append(files, file{name: fmt.Sprintf(p.Readme, filepath.Base(g.FilenamePrefix)), data: buf.Bytes()})
+	}
+
+	if p == nil || p.JobFile {
+		name := g.FilenamePrefix + ".gbrjob"
+		if p != nil {
+			name = filepath.Base(g.FilenamePrefix) + ".gbrjob"
+		}
+		data, err := g.renderJob(name, layers, files[:len(layers)])
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file{name: name, data: data})
+	}
+	return files, nil
+}
+
+// mergeDrills returns the layers with the non-plated drill layers
+// merged into the (plated) drill layer. The merged layer stays
+// non-plated if every merged layer is non-plated.
+func (g *Gerber) mergeDrills() []*Layer {
+	var result []*Layer
+	var drill *Layer
+	for _, layer := range g.Layers {
+		if !layer.isDrill() {
+			result = append(result, layer)
+			continue
+		}
+		if drill == nil {
+			drill = &Layer{
+				Filename:    layer.Filename,
+				Type:        layer.Type,
+				apertureMap: map[string]int{"default": -1},
+				g:           g,
+			}
+			result = append(result, drill)
+		} else if drill.Type != layer.Type {
+			drill.setType(LayerDrill, 0)
+		}
+		drill.Add(layer.Primitives...)
+	}
+	return result
+}
@@ -0,0 +1,186 @@
+package gerber
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func profileDesign() *Gerber {
+	g := New("out/board")
+	g.CreationDate = time.Date(2019, 6, 8, 19, 30, 0, 0, time.UTC)
+	g.TopCopper().Add(Line(0, 0, 1, 0, CircleShape, 0.1))
+	g.TopSolderMask()
+	g.TopSilkscreen()
+	g.LayerN(2)
+	g.LayerN(3)
+	g.BottomCopper()
+	g.BottomSolderMask()
+	g.BottomSilkscreen()
+	g.Drill().Add(Circle(Pt{0, 0}, 0.5))
+	g.NonPlatedDrill().Add(Circle(Pt{1, 0}, 3))
+	g.Outline().Add(Line(0, 0, 1, 0, CircleShape, 0.1))
+	return g
+}
+
+func zipNames(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := map[string]string{}
+	for _, zf := range zr.File {
+		r, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(r); err != nil {
+			t.Fatal(err)
+		}
+		result[zf.Name] = buf.String()
+	}
+	return result
+}
+
+func TestGerber_WriteProfileZip(t *testing.T) {
+	tests := []struct {
+		profile *FabProfile
+		want    []string
+	}{
+		{
+			profile: ProtelProfile,
+			want: []string{
+				"board-NPTH.drl", "board.drl", "board.gbl", "board.gbo", "board.gbrjob", "board.gbs",
+				"board.gko", "board.gl2", "board.gl3", "board.gtl", "board.gto", "board.gts",
+			},
+		},
+		{
+			profile: JLCPCBProfile,
+			want: []string{
+				"board-NPTH.DRL", "board-PTH.DRL", "board.G1", "board.G2", "board.GBL", "board.GBO",
+				"board.GBS", "board.GKO", "board.GTL", "board.GTO", "board.GTS",
+			},
+		},
+		{
+			profile: OSHParkProfile,
+			want: []string{
+				"board.G2L", "board.G3L", "board.GBL", "board.GBO", "board.GBS", "board.GKO",
+				"board.GTL", "board.GTO", "board.GTS", "board.XLN",
+			},
+		},
+		{
+			profile: PCBWayProfile,
+			want: []string{
+				"board-NPTH.DRL", "board-readme.txt", "board.DRL", "board.G1", "board.G2", "board.GBL",
+				"board.GBO", "board.GBS", "board.GKO", "board.GTL", "board.GTO", "board.GTS",
+			},
+		},
+		{
+			profile: AislerProfile,
+			want: []string{
+				"board-B_Cu.gbr", "board-B_Mask.gbr", "board-B_Silkscreen.gbr", "board-Edge_Cuts.gbr",
+				"board-F_Cu.gbr", "board-F_Mask.gbr", "board-F_Silkscreen.gbr", "board-In1_Cu.gbr",
+				"board-In2_Cu.gbr", "board-NPTH.drl", "board-PTH.drl", "board.gbrjob",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.profile.Name, func(t *testing.T) {
+			g := profileDesign()
+			var buf bytes.Buffer
+			if err := g.WriteProfileZip(&buf, tt.profile); err != nil {
+				t.Fatal(err)
+			}
+			files := zipNames(t, buf.Bytes())
+			var got []string
+			for name := range files {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("files = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGerber_WriteProfileZip_MergedDrill(t *testing.T) {
+	g := profileDesign()
+	var buf bytes.Buffer
+	if err := g.WriteProfileZip(&buf, OSHParkProfile); err != nil {
+		t.Fatal(err)
+	}
+	drill := zipNames(t, buf.Bytes())["board.XLN"]
+	for _, want := range []string{"T1C0.500\n", "T2C3.000\n", "X1.000Y0.000\n", "TF.FileFunction,Plated,1,4,PTH"} {
+		if !strings.Contains(drill, want) {
+			t.Errorf("merged drill file missing %q:\n%v", want, drill)
+		}
+	}
+}
+
+func TestGerber_WriteProfileZip_MergedNonPlatedDrill(t *testing.T) {
+	g := New("out/board")
+	g.CreationDate = time.Date(2019, 6, 8, 19, 30, 0, 0, time.UTC)
+	g.TopCopper().Add(Line(0, 0, 1, 0, CircleShape, 0.1))
+	g.NonPlatedDrill().Add(Circle(Pt{1, 0}, 3))
+	g.Outline().Add(Line(0, 0, 1, 0, CircleShape, 0.1))
+	var buf bytes.Buffer
+	if err := g.WriteProfileZip(&buf, OSHParkProfile); err != nil {
+		t.Fatal(err)
+	}
+	drill := zipNames(t, buf.Bytes())["board.XLN"]
+	for _, want := range []string{"T1C3.000\n", "TF.FileFunction,NonPlated,1,2,NPTH"} {
+		if !strings.Contains(drill, want) {
+			t.Errorf("merged drill file missing %q:\n%v", want, drill)
+		}
+	}
+}
+
+func TestGerber_WriteProfileZip_Readme(t *testing.T) {
+	g := profileDesign()
+	var buf bytes.Buffer
+	if err := g.WriteProfileZip(&buf, PCBWayProfile); err != nil {
+		t.Fatal(err)
+	}
+	readme := zipNames(t, buf.Bytes())["board-readme.txt"]
+	for _, want := range []string{"board.G1: Copper,L2,Inr\n", "board-NPTH.DRL: NonPlated,1,4,NPTH\n"} {
+		if !strings.Contains(readme, want) {
+			t.Errorf("readme missing %q:\n%v", want, readme)
+		}
+	}
+}
+
+func TestGerber_WriteProfile(t *testing.T) {
+	g := profileDesign()
+	dir := t.TempDir()
+	name, err := g.WriteProfile(dir, JLCPCBProfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dir, "board-jlcpcb.zip"); name != want {
+		t.Errorf("WriteProfile = %v, want %v", name, want)
+	}
+	if _, err := os.Stat(name); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGerber_WriteProfileZip_Errors(t *testing.T) {
+	g := profileDesign()
+	incomplete := &FabProfile{Name: "incomplete", Filenames: map[LayerType]string{LayerTopCopper: "%[1]v.top"}}
+	if err := g.WriteProfileZip(&bytes.Buffer{}, incomplete); err == nil {
+		t.Errorf("WriteProfileZip(incomplete) = nil, want error")
+	}
+	g.LayerN(2)
+	if err := g.WriteProfileZip(&bytes.Buffer{}, JLCPCBProfile); err == nil {
+		t.Errorf("WriteProfileZip(duplicate layer) = nil, want error")
+	}
+}
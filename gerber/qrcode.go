@@ -0,0 +1,481 @@
+package gerber
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QRLevel represents the error correction level of a QR code.
+type QRLevel int
+
+const (
+	// QRLevelL recovers about 7% of the codewords.
+	QRLevelL QRLevel = iota
+	// QRLevelM recovers about 15% of the codewords.
+	QRLevelM
+	// QRLevelQ recovers about 25% of the codewords.
+	QRLevelQ
+	// QRLevelH recovers about 30% of the codewords.
+	QRLevelH
+)
+
+// qrECCPerBlock and qrBlocks are the number of error correction
+// codewords per block and the number of blocks by level and version.
+var qrECCPerBlock = [4][41]int{
+	{0, 7, 10, 15, 20, 26, 18, 20, 24, 30, 18, 20, 24, 26, 30, 22, 24, 28, 30, 28, 28, 28, 28, 30, 30, 26, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26, 26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28},
+	{0, 13, 22, 18, 26, 18, 24, 18, 22, 20, 24, 28, 26, 24, 20, 30, 24, 28, 28, 26, 30, 28, 30, 30, 30, 30, 28, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+	{0, 17, 28, 22, 16, 22, 28, 26, 26, 24, 28, 24, 28, 22, 24, 24, 30, 28, 28, 26, 28, 30, 24, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30, 30},
+}
+
+var qrBlocks = [4][41]int{
+	{0, 1, 1, 1, 1, 1, 2, 2, 2, 2, 4, 4, 4, 4, 4, 6, 6, 6, 6, 7, 8, 8, 9, 9, 10, 12, 12, 12, 13, 14, 15, 16, 17, 18, 19, 19, 20, 21, 22, 24, 25},
+	{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16, 17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49},
+	{0, 1, 1, 2, 2, 4, 4, 6, 6, 8, 8, 8, 10, 12, 16, 12, 17, 16, 18, 21, 20, 23, 23, 25, 27, 29, 34, 34, 35, 38, 40, 43, 45, 48, 51, 53, 56, 59, 62, 65, 68},
+	{0, 1, 1, 2, 4, 4, 4, 5, 6, 8, 8, 11, 11, 16, 16, 18, 16, 19, 21, 25, 25, 25, 34, 30, 32, 35, 37, 40, 42, 45, 48, 51, 54, 57, 60, 63, 66, 70, 74, 77, 81},
+}
+
+// qrFormatLevels are the bits of the levels in the format information.
+var qrFormatLevels = [4]int{1, 0, 3, 2}
+
+const qrAlphanumeric = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+// QRCode returns a QR code of the data at (x, y), aligned by opts like
+// text, with modules of moduleSize. The data is encoded in numeric,
+// alphanumeric or byte mode, whichever is shortest, in the smallest
+// version that fits. opts may be nil.
+// All dimensions are in millimeters.
+func QRCode(x, y float64, data string, level QRLevel, moduleSize float64, opts *BarcodeOpts) (*BarcodeT, error) {
+	if level < QRLevelL || level > QRLevelH {
+		return nil, fmt.Errorf("invalid QR code level %v", level)
+	}
+	q, err := newQRCode(data, level)
+	if err != nil {
+		return nil, err
+	}
+	quiet := 4
+	if opts != nil && opts.QuietZone > 0 {
+		quiet = opts.QuietZone
+	}
+	return newBarcode(x, y, q.modules, moduleSize, moduleSize, quiet, quiet, opts), nil
+}
+
+// qrCode is a QR code symbol under construction.
+type qrCode struct {
+	version  int
+	level    QRLevel
+	size     int
+	modules  [][]bool // by row, then column
+	function [][]bool // modules of the function patterns
+}
+
+func newQRCode(data string, level QRLevel) (*qrCode, error) {
+	q := &qrCode{level: level}
+	codewords, err := q.encode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	q.size = 4*q.version + 17
+	q.modules = make([][]bool, q.size)
+	q.function = make([][]bool, q.size)
+	for i := range q.modules {
+		q.modules[i] = make([]bool, q.size)
+		q.function[i] = make([]bool, q.size)
+	}
+	q.drawFunctionPatterns()
+	q.drawCodewords(q.interleave(codewords))
+
+	// Choose the mask with the lowest penalty.
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormat(mask)
+		if p := q.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		q.applyMask(mask) // undo
+	}
+	q.applyMask(best)
+	q.drawFormat(best)
+	return q, nil
+}
+
+// encode chooses the smallest version that holds the data and returns
+// the data codewords, padded to the capacity of the version.
+func (q *qrCode) encode(data string) ([]byte, error) {
+	if data == "" {
+		return nil, fmt.Errorf("barcode data is empty")
+	}
+	var bits *bitWriter
+	for q.version = 1; q.version <= 40; q.version++ {
+		bits = qrSegment(data, q.version)
+		if bits.len() <= 8*q.dataCodewords() {
+			break
+		}
+	}
+	if q.version > 40 {
+		return nil, fmt.Errorf("QR code data is too long (%v bytes)", len(data))
+	}
+
+	capacity := 8 * q.dataCodewords()
+	bits.write(0, min(4, capacity-bits.len())) // terminator
+	bits.write(0, (8-bits.len()%8)%8)
+	for pad := 0xec; bits.len() < capacity; pad ^= 0xec ^ 0x11 {
+		bits.write(pad, 8)
+	}
+	return bits.bytes, nil
+}
+
+// bitWriter accumulates bits, most significant first.
+type bitWriter struct {
+	bytes []byte
+	n     int
+}
+
+func (b *bitWriter) len() int { return b.n }
+
+// write appends the n low bits of v.
+func (b *bitWriter) write(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		if b.n%8 == 0 {
+			b.bytes = append(b.bytes, 0)
+		}
+		if v>>i&1 != 0 {
+			b.bytes[b.n/8] |= 0x80 >> (b.n % 8)
+		}
+		b.n++
+	}
+}
+
+// qrSegment returns the bits of the data as a single segment in the
+// most compact mode for the version.
+func qrSegment(data string, version int) *bitWriter {
+	// The widths of the character count by version range.
+	countBits := func(widths [3]int) int {
+		switch {
+		case version <= 9:
+			return widths[0]
+		case version <= 26:
+			return widths[1]
+		}
+		return widths[2]
+	}
+
+	b := &bitWriter{}
+	switch {
+	case strings.Trim(data, "0123456789") == "":
+		b.write(1, 4)
+		b.write(len(data), countBits([3]int{10, 12, 14}))
+		for i := 0; i < len(data); i += 3 {
+			n := min(3, len(data)-i)
+			v := 0
+			for _, c := range data[i : i+n] {
+				v = 10*v + int(c-'0')
+			}
+			b.write(v, 3*n+1)
+		}
+	case strings.Trim(data, qrAlphanumeric) == "":
+		b.write(2, 4)
+		b.write(len(data), countBits([3]int{9, 11, 13}))
+		for i := 0; i < len(data); i += 2 {
+			if i+1 == len(data) {
+				b.write(strings.IndexByte(qrAlphanumeric, data[i]), 6)
+				continue
+			}
+			b.write(45*strings.IndexByte(qrAlphanumeric, data[i])+strings.IndexByte(qrAlphanumeric, data[i+1]), 11)
+		}
+	default:
+		b.write(4, 4)
+		b.write(len(data), countBits([3]int{8, 16, 16}))
+		for i := 0; i < len(data); i++ {
+			b.write(int(data[i]), 8)
+		}
+	}
+	return b
+}
+
+// rawCodewords returns the number of codewords of the version.
+func (q *qrCode) rawCodewords() int {
+	v := q.version
+	bits := (16*v+128)*v + 64
+	if v >= 2 {
+		n := v/7 + 2
+		bits -= (25*n-10)*n - 55
+		if v >= 7 {
+			bits -= 36
+		}
+	}
+	return bits / 8
+}
+
+// dataCodewords returns the number of data codewords of the
+// version and level.
+func (q *qrCode) dataCodewords() int {
+	return q.rawCodewords() - qrECCPerBlock[q.level][q.version]*qrBlocks[q.level][q.version]
+}
+
+// interleave splits the data into blocks, adds their error correction
+// codewords and interleaves the blocks.
+func (q *qrCode) interleave(data []byte) []byte {
+	gf := newGF256(0x11d)
+	numBlocks := qrBlocks[q.level][q.version]
+	eccLen := qrECCPerBlock[q.level][q.version]
+	raw := q.rawCodewords()
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw/numBlocks - eccLen
+
+	var blocks, eccs [][]byte
+	for i := 0; i < numBlocks; i++ {
+		n := shortLen
+		if i >= numShort {
+			n++
+		}
+		blocks = append(blocks, data[:n])
+		eccs = append(eccs, gf.ecc(data[:n], eccLen, 0))
+		data = data[n:]
+	}
+
+	var result []byte
+	for i := 0; i <= shortLen; i++ {
+		for _, b := range blocks {
+			if i < len(b) {
+				result = append(result, b[i])
+			}
+		}
+	}
+	for i := 0; i < eccLen; i++ {
+		for _, e := range eccs {
+			result = append(result, e[i])
+		}
+	}
+	return result
+}
+
+// set sets a function module.
+func (q *qrCode) set(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+// alignmentPositions returns the centers of the alignment patterns
+// along each axis.
+func (q *qrCode) alignmentPositions() []int {
+	if q.version == 1 {
+		return nil
+	}
+	n := q.version/7 + 2
+	step := (q.version*4 + n*2 + 1) / (n*2 - 2) * 2
+	if q.version == 32 {
+		step = 26
+	}
+	result := make([]int, n)
+	result[0] = 6
+	for i, pos := n-1, q.size-7; i > 0; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+	return result
+}
+
+func (q *qrCode) drawFunctionPatterns() {
+	for i := 0; i < q.size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns with their separators.
+	for _, c := range [][2]int{{3, 3}, {q.size - 4, 3}, {3, q.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x < 0 || x >= q.size || y < 0 || y >= q.size {
+					continue
+				}
+				d := max(abs(dx), abs(dy))
+				q.set(x, y, d != 2 && d != 4)
+			}
+		}
+	}
+
+	pos := q.alignmentPositions()
+	for i, x := range pos {
+		for j, y := range pos {
+			// Skip the corners of the finder patterns.
+			if i == 0 && j == 0 || i == 0 && j == len(pos)-1 || i == len(pos)-1 && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format information, drawn with the mask.
+	q.drawFormat(0)
+
+	if q.version >= 7 {
+		bits := q.version << 12
+		rem := q.version
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ (rem>>11)*0x1f25
+		}
+		bits |= rem
+		for i := 0; i < 18; i++ {
+			a, b := q.size-11+i%3, i/3
+			q.set(a, b, bits>>i&1 != 0)
+			q.set(b, a, bits>>i&1 != 0)
+		}
+	}
+}
+
+// drawFormat draws both copies of the format information.
+func (q *qrCode) drawFormat(mask int) {
+	data := qrFormatLevels[q.level]<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		q.set(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.size-15+i, bit(i))
+	}
+	q.set(8, q.size-8, true) // the dark module
+}
+
+// drawCodewords draws the codewords in the zigzag order, in pairs of
+// columns from the right, skipping the vertical timing pattern.
+func (q *qrCode) drawCodewords(data []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.size - 1 - vert // upward
+				}
+				if !q.function[y][x] && i < 8*len(data) {
+					q.modules[y][x] = data[i/8]>>(7-i%8)&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by the mask pattern.
+func (q *qrCode) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !q.function[y][x] {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty returns the penalty score of the symbol, which the chosen
+// mask minimizes.
+func (q *qrCode) penalty() int {
+	var result, dark int
+	// line returns the module i of row or column k.
+	line := func(vertical bool, k, i int) bool {
+		if vertical {
+			return q.modules[i][k]
+		}
+		return q.modules[k][i]
+	}
+	for _, vertical := range []bool{false, true} {
+		for k := 0; k < q.size; k++ {
+			run := 0
+			for i := 0; i < q.size; i++ {
+				if i > 0 && line(vertical, k, i) == line(vertical, k, i-1) {
+					run++
+				} else {
+					run = 1
+				}
+				if run == 5 {
+					result += 3
+				} else if run > 5 {
+					result++
+				}
+				// Finder-like patterns with 4 light modules on a side.
+				if i+11 <= q.size {
+					var pattern strings.Builder
+					for j := i; j < i+11; j++ {
+						if line(vertical, k, j) {
+							pattern.WriteByte('1')
+						} else {
+							pattern.WriteByte('0')
+						}
+					}
+					if s := pattern.String(); s == "10111010000" || s == "00001011101" {
+						result += 40
+					}
+				}
+			}
+		}
+	}
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 {
+				c := q.modules[y][x]
+				if c == q.modules[y-1][x] && c == q.modules[y][x-1] && c == q.modules[y-1][x-1] {
+					result += 3
+				}
+			}
+		}
+	}
+	total := q.size * q.size
+	result += (abs(dark*20-total*10)+total-1)/total*10 - 10
+	return result
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
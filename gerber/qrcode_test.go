@@ -0,0 +1,245 @@
+package gerber
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQRCode_Codewords(t *testing.T) {
+	// The worked examples of "HELLO WORLD" in versions 1-M and 1-Q.
+	tests := []struct {
+		level QRLevel
+		want  []byte
+	}{
+		{
+			level: QRLevelM,
+			want: []byte{
+				32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17,
+				196, 35, 39, 119, 235, 215, 231, 226, 93, 23,
+			},
+		},
+		{
+			level: QRLevelQ,
+			want: []byte{
+				32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236,
+				168, 72, 22, 82, 217, 54, 156, 0, 46, 15, 180, 122, 16,
+			},
+		},
+	}
+	for _, tt := range tests {
+		q := &qrCode{level: tt.level}
+		data, err := q.encode("HELLO WORLD")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if q.version != 1 {
+			t.Errorf("level %v: version = %v, want 1", tt.level, q.version)
+		}
+		if got := q.interleave(data); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("level %v: codewords = %v, want %v", tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestQRCode_Interleave(t *testing.T) {
+	// Version 5-Q has two blocks of 15 and two of 16 data codewords.
+	q := &qrCode{version: 5, level: QRLevelQ}
+	data := make([]byte, q.dataCodewords())
+	for i := range data {
+		data[i] = byte(i)
+	}
+	got := q.interleave(data)
+	if len(got) != 134 {
+		t.Fatalf("got %v codewords, want 134", len(got))
+	}
+	if want := []byte{0, 15, 30, 46, 1, 16, 31, 47}; !reflect.DeepEqual(got[:8], want) {
+		t.Errorf("first codewords = %v, want %v", got[:8], want)
+	}
+	if want := []byte{45, 61}; !reflect.DeepEqual(got[60:62], want) {
+		t.Errorf("last data codewords = %v, want %v", got[60:62], want)
+	}
+}
+
+func TestQRCode_DataCodewords(t *testing.T) {
+	tests := []struct {
+		version int
+		level   QRLevel
+		want    int
+	}{
+		{1, QRLevelL, 19},
+		{1, QRLevelH, 9},
+		{10, QRLevelM, 216},
+		{40, QRLevelL, 2956},
+		{40, QRLevelH, 1276},
+	}
+	for _, tt := range tests {
+		q := &qrCode{version: tt.version, level: tt.level}
+		if got := q.dataCodewords(); got != tt.want {
+			t.Errorf("version %v level %v: got %v data codewords, want %v", tt.version, tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestQRCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		level   QRLevel
+		version int
+	}{
+		{name: "alphanumeric", data: "HELLO WORLD", level: QRLevelQ, version: 1},
+		{name: "numeric", data: "01234567890123456789012345678901234567890", level: QRLevelL, version: 1},
+		{name: "byte", data: "coil-0042", level: QRLevelM, version: 1},
+		{name: "alignment", data: "https://github.com/gmlewis/go-gerber", level: QRLevelH, version: 5},
+		{name: "version info", data: strings.Repeat("gerber", 30), level: QRLevelL, version: 8},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := newQRCode(tt.data, tt.level)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if q.version != tt.version {
+				t.Fatalf("version = %v, want %v", q.version, tt.version)
+			}
+			m := q.modules
+
+			// The finder patterns and the timing patterns.
+			for _, c := range [][2]int{{0, 0}, {q.size - 7, 0}, {0, q.size - 7}} {
+				for _, d := range []int{0, 6} {
+					for i := 0; i < 7; i++ {
+						if !m[c[1]+d][c[0]+i] || !m[c[1]+i][c[0]+d] {
+							t.Fatalf("finder pattern at %v is broken", c)
+						}
+					}
+				}
+			}
+			for i := 8; i < q.size-8; i++ {
+				if m[6][i] != (i%2 == 0) || m[i][6] != (i%2 == 0) {
+					t.Fatalf("timing pattern is broken at %v", i)
+				}
+			}
+
+			// Both copies of the format information decode to the level.
+			var format1, format2 int
+			for i := 0; i < 15; i++ {
+				var x1, y1, x2, y2 int
+				switch {
+				case i < 6:
+					x1, y1 = 8, i
+				case i < 8:
+					x1, y1 = 8, i+1
+				case i == 8:
+					x1, y1 = 7, 8
+				default:
+					x1, y1 = 14-i, 8
+				}
+				if i < 8 {
+					x2, y2 = q.size-1-i, 8
+				} else {
+					x2, y2 = 8, q.size-15+i
+				}
+				if m[y1][x1] {
+					format1 |= 1 << i
+				}
+				if m[y2][x2] {
+					format2 |= 1 << i
+				}
+			}
+			if format1 != format2 {
+				t.Fatalf("format information %015b != %015b", format1, format2)
+			}
+			format := format1 ^ 0x5412
+			rem := format >> 10
+			for i := 0; i < 10; i++ {
+				rem = rem<<1 ^ (rem>>9)*0x537
+			}
+			if rem != format&0x3ff {
+				t.Errorf("format information %015b has a bad BCH code", format1)
+			}
+			if got := format >> 13; got != qrFormatLevels[tt.level] {
+				t.Errorf("format level = %v, want %v", got, qrFormatLevels[tt.level])
+			}
+
+			if q.version >= 7 {
+				var v1, v2 int
+				for i := 0; i < 18; i++ {
+					a, b := q.size-11+i%3, i/3
+					if m[b][a] {
+						v1 |= 1 << i
+					}
+					if m[a][b] {
+						v2 |= 1 << i
+					}
+				}
+				if v1 != v2 || v1>>12 != q.version {
+					t.Errorf("version information = %018b and %018b, want version %v", v1, v2, q.version)
+				}
+			}
+
+			// Unmasking gives back the codewords in the zigzag order.
+			q.applyMask(format >> 10 & 7)
+			var bits []bool
+			upward := false
+			for right := q.size - 1; right >= 1; right -= 2 {
+				if right == 6 {
+					right--
+				}
+				upward = !upward
+				for vert := 0; vert < q.size; vert++ {
+					y := vert
+					if upward {
+						y = q.size - 1 - vert
+					}
+					for x := right; x >= right-1; x-- {
+						if !q.function[y][x] {
+							bits = append(bits, m[y][x])
+						}
+					}
+				}
+			}
+			codewords, err := (&qrCode{level: tt.level}).encode(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := q.interleave(codewords)
+			for i, c := range want {
+				var got byte
+				for _, b := range bits[8*i : 8*i+8] {
+					got <<= 1
+					if b {
+						got |= 1
+					}
+				}
+				if got != c {
+					t.Fatalf("codeword %v = %v, want %v", i, got, c)
+				}
+			}
+		})
+	}
+}
+
+func TestQRCode_Options(t *testing.T) {
+	b, err := QRCode(10, 10, "HELLO WORLD", QRLevelQ, 0.25, &BarcodeOpts{XAlign: XCenter, YAlign: YCenter})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 21 modules with a quiet zone of 4 on each side.
+	if got, want := b.MBB(), (MBB{Min: Pt{6.375, 6.375}, Max: Pt{13.625, 13.625}}); !mbbClose(got, want) {
+		t.Errorf("MBB = %v, want %v", got, want)
+	}
+	if len(b.Modules) != 21 || len(b.Modules[0]) != 21 {
+		t.Errorf("got %v by %v modules, want 21 by 21", len(b.Modules[0]), len(b.Modules))
+	}
+
+	if _, err := QRCode(0, 0, "", QRLevelL, 1, nil); err == nil {
+		t.Errorf("empty data error = nil")
+	}
+	if _, err := QRCode(0, 0, strings.Repeat("x", 3000), QRLevelL, 1, nil); err == nil {
+		t.Errorf("long data error = nil")
+	}
+	if _, err := QRCode(0, 0, "x", QRLevel(4), 1, nil); err == nil {
+		t.Errorf("invalid level error = nil")
+	}
+}
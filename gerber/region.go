@@ -0,0 +1,417 @@
+package gerber
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// Segment represents a straight or circular segment of a contour
+// that ends at End and starts at the end of the previous segment.
+type Segment struct {
+	End Pt
+	// Arc selects a circular arc around Center instead of a line.
+	Arc    bool
+	Center Pt
+	// Clockwise selects the direction of an arc.
+	Clockwise bool
+}
+
+// LineTo returns a straight contour segment ending at end.
+// All dimensions are in millimeters.
+func LineTo(end Pt) Segment {
+	return Segment{End: end}
+}
+
+// ArcTo returns a circular contour segment around center ending at end.
+// If end is the start of the segment, the arc is a full circle.
+// All dimensions are in millimeters.
+func ArcTo(end, center Pt, clockwise bool) Segment {
+	return Segment{End: end, Arc: true, Center: center, Clockwise: clockwise}
+}
+
+// Contour represents a closed contour of a region. If the last segment
+// does not end at Start, the contour is closed with a straight line.
+type Contour struct {
+	Start    Pt
+	Segments []Segment
+}
+
+// PolygonContour returns a contour with straight segments
+// through the points.
+// All dimensions are in millimeters.
+func PolygonContour(points []Pt) Contour {
+	if len(points) == 0 {
+		return Contour{}
+	}
+	c := Contour{Start: points[0]}
+	for _, pt := range points[1:] {
+		c.Segments = append(c.Segments, LineTo(pt))
+	}
+	return c
+}
+
+// CircleContour returns a circular contour.
+// All dimensions are in millimeters.
+func CircleContour(center Pt, radius float64) Contour {
+	start := Pt{center[0] + radius, center[1]}
+	return Contour{Start: start, Segments: []Segment{ArcTo(start, center, false)}}
+}
+
+// Points returns the points of the contour with its arcs approximated
+// by straight segments with a resolution of 0.1mm.
+func (c Contour) Points() []Pt {
+	result := []Pt{c.Start}
+	prev := c.Start
+	for _, s := range c.ring() {
+		if s.Arc {
+			sweep := s.sweep(prev)
+			r := s.radius(prev)
+			// Resolution of segments is 0.1mm
+			segments := int(0.5+math.Abs(sweep)*r*10.0) + 1
+			a0 := s.angle(prev)
+			for i := 1; i < segments; i++ {
+				a := a0 + sweep*float64(i)/float64(segments)
+				result = append(result, Pt{s.Center[0] + r*math.Cos(a), s.Center[1] + r*math.Sin(a)})
+			}
+		}
+		result = append(result, s.End)
+		prev = s.End
+	}
+	return result
+}
+
+// MBB returns the minimum bounding box of the contour.
+func (c Contour) MBB() MBB {
+	mbb := MBB{Min: c.Start, Max: c.Start}
+	prev := c.Start
+	for _, s := range c.Segments {
+		mbb.Join(&MBB{Min: s.End, Max: s.End})
+		if s.Arc {
+			r := s.radius(prev)
+			for _, a := range []float64{0, 0.5 * math.Pi, math.Pi, 1.5 * math.Pi} {
+				if s.contains(prev, a) {
+					pt := Pt{s.Center[0] + r*math.Cos(a), s.Center[1] + r*math.Sin(a)}
+					mbb.Join(&MBB{Min: pt, Max: pt})
+				}
+			}
+		}
+		prev = s.End
+	}
+	return mbb
+}
+
+// ring returns the segments of the contour closed back to its start.
+func (c Contour) ring() []Segment {
+	n := len(c.Segments)
+	if n == 0 {
+		return nil
+	}
+	ring := append([]Segment{}, c.Segments...)
+	if !samePt(ring[n-1].End, c.Start) {
+		ring = append(ring, LineTo(c.Start))
+	}
+	return ring
+}
+
+// translate returns a copy of the contour moved by the offset.
+func (c Contour) translate(offset Pt) Contour {
+	move := func(pt Pt) Pt { return Pt{pt[0] + offset[0], pt[1] + offset[1]} }
+	result := Contour{Start: move(c.Start)}
+	for _, s := range c.Segments {
+		s.End, s.Center = move(s.End), move(s.Center)
+		result.Segments = append(result.Segments, s)
+	}
+	return result
+}
+
+// angle returns the angle (in radians) of the point around the center.
+func (s Segment) angle(pt Pt) float64 {
+	return math.Atan2(pt[1]-s.Center[1], pt[0]-s.Center[0])
+}
+
+// radius returns the radius of an arc segment starting at from.
+func (s Segment) radius(from Pt) float64 {
+	return math.Hypot(from[0]-s.Center[0], from[1]-s.Center[1])
+}
+
+// sweep returns the signed angle (counterclockwise positive) swept by
+// an arc segment starting at from.
+func (s Segment) sweep(from Pt) float64 {
+	d := s.angle(s.End) - s.angle(from)
+	full := samePt(from, s.End)
+	if s.Clockwise {
+		for d >= 0 {
+			d -= 2 * math.Pi
+		}
+		if full {
+			d = -2 * math.Pi
+		}
+		return d
+	}
+	for d <= 0 {
+		d += 2 * math.Pi
+	}
+	if full {
+		d = 2 * math.Pi
+	}
+	return d
+}
+
+// contains reports whether an arc segment starting at from passes
+// through the angle (in radians), including its end points.
+func (s Segment) contains(from Pt, angle float64) bool {
+	sweep := s.sweep(from)
+	d := angle - s.angle(from)
+	if sweep < 0 {
+		d = -d
+	}
+	d = math.Mod(d, 2*math.Pi)
+	if d < 0 {
+		d += 2 * math.Pi
+	}
+	return d <= math.Abs(sweep)+1e-9 || d > 2*math.Pi-1e-9
+}
+
+// signedArea returns the area of a ring, positive if counterclockwise.
+func signedArea(ring []Segment) float64 {
+	var area float64
+	prev := ring[len(ring)-1].End
+	for _, s := range ring {
+		area += 0.5 * (prev[0]*s.End[1] - s.End[0]*prev[1])
+		if s.Arc {
+			r, sweep := s.radius(prev), s.sweep(prev)
+			area += 0.5 * r * r * (sweep - math.Sin(sweep))
+		}
+		prev = s.End
+	}
+	return area
+}
+
+// reverseRing returns the ring traversed in the opposite direction.
+func reverseRing(ring []Segment) []Segment {
+	n := len(ring)
+	result := make([]Segment, n)
+	for j := 0; j < n; j++ {
+		s := ring[n-1-j]
+		s.End = ring[(2*n-2-j)%n].End
+		s.Clockwise = !s.Clockwise
+		result[j] = s
+	}
+	return result
+}
+
+// splitSegment splits the segment ring[i] at the point pt on the segment.
+func splitSegment(ring []Segment, i int, pt Pt) []Segment {
+	first := ring[i]
+	first.End = pt
+	result := append([]Segment{}, ring[:i]...)
+	result = append(result, first)
+	return append(result, ring[i:]...)
+}
+
+// leftmost splits the arcs of the ring at their leftmost points and
+// returns the new ring and the index of the segment ending at its
+// leftmost point.
+func leftmost(ring []Segment) ([]Segment, int) {
+	for i := 0; i < len(ring); i++ {
+		s := ring[i]
+		if !s.Arc {
+			continue
+		}
+		prev := ring[(i+len(ring)-1)%len(ring)].End
+		if s.contains(prev, math.Pi) {
+			pt := Pt{s.Center[0] - s.radius(prev), s.Center[1]}
+			if !samePt(pt, prev) && !samePt(pt, s.End) {
+				ring = splitSegment(ring, i, pt)
+				i++
+			}
+		}
+	}
+	var best int
+	for i, s := range ring {
+		if s.End[0] < ring[best].End[0] {
+			best = i
+		}
+	}
+	return ring, best
+}
+
+// rayLeft finds the nearest point where the ring crosses a horizontal
+// ray from pt to the left. It returns the index of the crossed segment.
+func rayLeft(ring []Segment, pt Pt) (Pt, int, bool) {
+	var hit Pt
+	index := -1
+	prev := ring[len(ring)-1].End
+	consider := func(x float64, i int) {
+		if x <= pt[0]+1e-9 && (index < 0 || x > hit[0]) {
+			hit, index = Pt{x, pt[1]}, i
+		}
+	}
+	for i, s := range ring {
+		if s.Arc {
+			r := s.radius(prev)
+			if dy := pt[1] - s.Center[1]; math.Abs(dy) <= r {
+				dx := math.Sqrt(r*r - dy*dy)
+				for _, x := range []float64{s.Center[0] - dx, s.Center[0] + dx} {
+					if s.contains(prev, math.Atan2(dy, x-s.Center[0])) {
+						consider(x, i)
+					}
+				}
+			}
+		} else if (prev[1] <= pt[1] && pt[1] <= s.End[1]) || (s.End[1] <= pt[1] && pt[1] <= prev[1]) {
+			if prev[1] != s.End[1] {
+				t := (pt[1] - prev[1]) / (s.End[1] - prev[1])
+				consider(prev[0]+t*(s.End[0]-prev[0]), i)
+			}
+		}
+		prev = s.End
+	}
+	return hit, index, index >= 0
+}
+
+// fracture returns a single ring for the region, connecting each hole
+// to the outer contour with a pair of coincident cut-in lines.
+func (r *RegionT) fracture() ([]Segment, error) {
+	outer := r.Outer.ring()
+	if len(outer) == 0 {
+		return nil, errors.New("region has an empty outer contour")
+	}
+	if signedArea(outer) < 0 {
+		outer = reverseRing(outer)
+	}
+
+	type hole struct {
+		ring  []Segment
+		index int // index of the segment ending at the leftmost point
+	}
+	var holes []hole
+	for _, c := range r.Holes {
+		ring := c.ring()
+		if len(ring) == 0 {
+			continue
+		}
+		if signedArea(ring) > 0 {
+			ring = reverseRing(ring)
+		}
+		ring, index := leftmost(ring)
+		holes = append(holes, hole{ring: ring, index: index})
+	}
+	// Connecting the holes from left to right guarantees that the
+	// cut-ins only cross the already connected contours.
+	sort.Slice(holes, func(a, b int) bool {
+		return holes[a].ring[holes[a].index].End[0] < holes[b].ring[holes[b].index].End[0]
+	})
+
+	for _, h := range holes {
+		pt := h.ring[h.index].End
+		hit, i, ok := rayLeft(outer, pt)
+		if !ok {
+			return nil, fmt.Errorf("region hole at %v is outside its outer contour", pt)
+		}
+		prev := outer[(i+len(outer)-1)%len(outer)].End
+		switch {
+		case samePt(hit, prev):
+			i = (i + len(outer) - 1) % len(outer)
+		case samePt(hit, outer[i].End):
+		default:
+			outer = splitSegment(outer, i, hit)
+		}
+		hit = outer[i].End
+
+		// Walk the hole starting and ending at its leftmost point.
+		walk := append(append([]Segment{}, h.ring[h.index+1:]...), h.ring[:h.index+1]...)
+		merged := append([]Segment{}, outer[:i+1]...)
+		merged = append(merged, LineTo(pt))
+		merged = append(merged, walk...)
+		merged = append(merged, LineTo(hit))
+		outer = append(merged, outer[i+1:]...)
+	}
+	return outer, nil
+}
+
+// samePt reports whether the points are equal within 1nm.
+func samePt(a, b Pt) bool {
+	const eps = 1e-6
+	return math.Abs(a[0]-b[0]) < eps && math.Abs(a[1]-b[1]) < eps
+}
+
+// RegionT represents a filled region with an outer contour and optional
+// holes whose segments are lines or circular arcs. It satisfies the
+// Primitive interface. The holes are connected to the outer contour with
+// cut-ins so they do not clear any other objects beneath them.
+type RegionT struct {
+	Outer Contour
+	Holes []Contour
+	Attributes
+	mbb *MBB // cached minimum bounding box
+}
+
+// Region returns a region primitive with the outer contour and holes.
+// The holes must be inside the outer contour and must not overlap.
+// All dimensions are in millimeters.
+func Region(outer Contour, holes ...Contour) *RegionT {
+	return &RegionT{Outer: outer, Holes: holes}
+}
+
+// WriteGerber writes the primitive to the Gerber file.
+func (r *RegionT) WriteGerber(w io.Writer, f *Format, apertureIndex int) error {
+	ring, err := r.fracture()
+	if err != nil {
+		return err
+	}
+
+	io.WriteString(w, "G54D11*\n")
+	io.WriteString(w, "G36*\n")
+	prev := ring[len(ring)-1].End
+	if err := f.writeOperation(w, prev, 2); err != nil {
+		return err
+	}
+	mode := "G01"
+	for _, s := range ring {
+		if !s.Arc {
+			if mode != "G01" {
+				mode = "G01"
+				io.WriteString(w, "G01*\n")
+			}
+			if err := f.writeOperation(w, s.End, 1); err != nil {
+				return err
+			}
+			prev = s.End
+			continue
+		}
+		g := "G03"
+		if s.Clockwise {
+			g = "G02"
+		}
+		if mode != g {
+			mode = g
+			fmt.Fprintf(w, "%v*\n", g)
+		}
+		if err := f.writeArc(w, s.End, Pt{s.Center[0] - prev[0], s.Center[1] - prev[1]}); err != nil {
+			return err
+		}
+		prev = s.End
+	}
+	if mode != "G01" {
+		io.WriteString(w, "G01*\n")
+	}
+	io.WriteString(w, "G37*\n")
+	return nil
+}
+
+// Aperture returns nil for RegionT because it uses the default aperture.
+func (r *RegionT) Aperture() *Aperture {
+	return nil
+}
+
+func (r *RegionT) MBB() MBB {
+	if r.mbb != nil {
+		return *r.mbb
+	}
+	mbb := r.Outer.MBB()
+	r.mbb = &mbb
+	return *r.mbb
+}
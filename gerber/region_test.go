@@ -0,0 +1,197 @@
+package gerber
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestRegionT_Primitive(t *testing.T) {
+	var p Primitive = &RegionT{}
+	if p == nil {
+		// In actuality, this test won't compile if it isn't a Primitive.
+		t.Errorf("RegionT does not implement the Primitive interface")
+	}
+}
+
+func TestRegionT_WriteGerber(t *testing.T) {
+	tests := []struct {
+		name string
+		r    *RegionT
+		want string
+	}{
+		{
+			name: "clockwise box is written counterclockwise",
+			r:    Region(PolygonContour([]Pt{{0, 0}, {0, 1}, {1, 1}, {1, 0}})),
+			want: `G54D11*
+G36*
+X0Y0D02*
+X1000000Y0D01*
+X1000000Y1000000D01*
+X0Y1000000D01*
+X0Y0D01*
+G37*
+`,
+		},
+		{
+			name: "rounded end",
+			r: Region(Contour{
+				Start: Pt{0, 0},
+				Segments: []Segment{
+					LineTo(Pt{2, 0}),
+					ArcTo(Pt{2, 2}, Pt{2, 1}, false),
+					LineTo(Pt{0, 2}),
+				},
+			}),
+			want: `G54D11*
+G36*
+X0Y0D02*
+X2000000Y0D01*
+G03*
+X2000000Y2000000I0J1000000D01*
+G01*
+X0Y2000000D01*
+X0Y0D01*
+G37*
+`,
+		},
+		{
+			name: "annulus",
+			r:    Region(CircleContour(Pt{0, 0}, 2), CircleContour(Pt{0, 0}, 1)),
+			want: `G54D11*
+G36*
+X2000000Y0D02*
+G03*
+X-2000000Y0I-2000000J0D01*
+G01*
+X-1000000Y0D01*
+G02*
+X1000000Y0I1000000J0D01*
+X-1000000Y0I-1000000J0D01*
+G01*
+X-2000000Y0D01*
+G03*
+X2000000Y0I2000000J0D01*
+G01*
+G37*
+`,
+		},
+		{
+			name: "two square holes",
+			r: Region(
+				PolygonContour([]Pt{{0, 0}, {10, 0}, {10, 4}, {0, 4}}),
+				PolygonContour([]Pt{{6, 1}, {8, 1}, {8, 3}, {6, 3}}),
+				PolygonContour([]Pt{{2, 1}, {4, 1}, {4, 2}, {2, 2}}),
+			),
+			want: `G54D11*
+G36*
+X0Y0D02*
+X10000000Y0D01*
+X10000000Y4000000D01*
+X0Y4000000D01*
+X0Y3000000D01*
+X6000000Y3000000D01*
+X8000000Y3000000D01*
+X8000000Y1000000D01*
+X6000000Y1000000D01*
+X6000000Y3000000D01*
+X0Y3000000D01*
+X0Y2000000D01*
+X2000000Y2000000D01*
+X4000000Y2000000D01*
+X4000000Y1000000D01*
+X2000000Y1000000D01*
+X2000000Y2000000D01*
+X0Y2000000D01*
+X0Y0D01*
+G37*
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.r.WriteGerber(&buf, &DefaultFormat, 11); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("WriteGerber =\n%v\nwant:\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegionT_WriteGerber_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		r    *RegionT
+	}{
+		{name: "empty", r: Region(Contour{})},
+		{name: "hole outside", r: Region(CircleContour(Pt{0, 0}, 1), CircleContour(Pt{-5, 0}, 1))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.r.WriteGerber(&bytes.Buffer{}, &DefaultFormat, 11); err == nil {
+				t.Errorf("WriteGerber = nil, want error")
+			}
+		})
+	}
+}
+
+func TestRegionT_MBB(t *testing.T) {
+	tests := []struct {
+		name string
+		r    *RegionT
+		want MBB
+	}{
+		{
+			name: "circle with hole",
+			r:    Region(CircleContour(Pt{10, 20}, 2), CircleContour(Pt{10, 20}, 1)),
+			want: MBB{Min: Pt{8, 18}, Max: Pt{12, 22}},
+		},
+		{
+			name: "clockwise half circle",
+			r: Region(Contour{
+				Start:    Pt{-1, 0},
+				Segments: []Segment{ArcTo(Pt{1, 0}, Pt{0, 0}, true)},
+			}),
+			want: MBB{Min: Pt{-1, 0}, Max: Pt{1, 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.MBB(); !mbbClose(got, tt.want) {
+				t.Errorf("MBB = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContour_Points(t *testing.T) {
+	pts := CircleContour(Pt{0, 0}, 1).Points()
+	if got, want := len(pts), 65; got != want {
+		t.Errorf("len(Points) = %v, want %v", got, want)
+	}
+	for _, pt := range pts {
+		if r := math.Hypot(pt[0], pt[1]); math.Abs(r-1) > 1e-9 {
+			t.Errorf("point %v is not on the circle", pt)
+		}
+	}
+}
+
+func TestSignedArea(t *testing.T) {
+	const eps = 1e-9
+	if got, want := signedArea(CircleContour(Pt{3, 4}, 2).ring()), 4*math.Pi; math.Abs(got-want) > eps {
+		t.Errorf("signedArea(circle) = %v, want %v", got, want)
+	}
+	box := PolygonContour([]Pt{{0, 0}, {0, 2}, {3, 2}, {3, 0}}).ring()
+	if got, want := signedArea(box), -6.0; math.Abs(got-want) > eps {
+		t.Errorf("signedArea(box) = %v, want %v", got, want)
+	}
+	if got, want := signedArea(reverseRing(box)), 6.0; math.Abs(got-want) > eps {
+		t.Errorf("signedArea(reverseRing(box)) = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,658 @@
+package gerber
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SVGStyle represents the appearance of a layer in SVG output.
+type SVGStyle struct {
+	// Color is the CSS color of the layer, such as "#fa9600".
+	Color string
+	// Opacity is the opacity of the layer from 0 to 1.
+	// Zero means opaque.
+	Opacity float64
+}
+
+// DefaultSVGStyles are the styles of the layer types, using the
+// colors of the viewer. Inner copper layers cycle through
+// innerSVGColors unless LayerInnerCopper is in SVGOptions.Styles.
+var DefaultSVGStyles = map[LayerType]SVGStyle{
+	LayerTopCopper:        {Color: "#fa32fa", Opacity: 0.8},
+	LayerTopSolderMask:    {Color: "#0096c8", Opacity: 0.6},
+	LayerTopSilkscreen:    {Color: "#fa9600"},
+	LayerBottomCopper:     {Color: "#3232fa", Opacity: 0.8},
+	LayerBottomSolderMask: {Color: "#fa3232", Opacity: 0.6},
+	LayerBottomSilkscreen: {Color: "#fa32fa"},
+	LayerDrill:            {Color: "#c8c8c8"},
+	LayerOutline:          {Color: "#00ff00"},
+	LayerNonPlatedDrill:   {Color: "#969696"},
+}
+
+var innerSVGColors = []string{
+	"#000084", "#840000", "#c2b833", "#004800", "#840084",
+	"#c2c2c2", "#008400", "#840084", "#008484", "#848400",
+}
+
+// SVGOptions represents the options of the SVG output.
+type SVGOptions struct {
+	// Styles overrides DefaultSVGStyles by layer type.
+	Styles map[LayerType]SVGStyle
+	// BottomView shows the board as seen from below: the image is
+	// mirrored left to right and the bottom layers are stacked on top.
+	BottomView bool
+	// Background is the CSS color of the background.
+	// If empty, the background is transparent.
+	Background string
+	// Margin is the margin around the design in millimeters.
+	Margin float64
+}
+
+// style returns the style of a layer.
+func (o *SVGOptions) style(l *Layer) SVGStyle {
+	if s, ok := o.Styles[l.Type]; ok {
+		return s
+	}
+	if l.Type == LayerInnerCopper {
+		n := l.N - 2
+		if n < 0 {
+			n = 0
+		}
+		return SVGStyle{Color: innerSVGColors[n%len(innerSVGColors)], Opacity: 0.8}
+	}
+	return DefaultSVGStyles[l.Type]
+}
+
+// WriteSVG writes the layer as an SVG image to w.
+// The dimensions of the image are in millimeters. opts may be nil.
+//
+// Clear polarity primitives are drawn as masks. Macro primitives with
+// exposure off are not subtracted from their apertures and panels are
+// not drawn.
+func (l *Layer) WriteSVG(w io.Writer, opts *SVGOptions) error {
+	return writeSVG(w, []*Layer{l}, l.MBB(), opts)
+}
+
+// WriteSVG writes all the layers of the design stacked into a single
+// SVG image to w, from the bottom layer up (or from the top layer up
+// for a bottom view). opts may be nil.
+func (g *Gerber) WriteSVG(w io.Writer, opts *SVGOptions) error {
+	bottomView := opts != nil && opts.BottomView
+	layers := append([]*Layer{}, g.Layers...)
+	sort.SliceStable(layers, func(a, b int) bool {
+		return svgRank(layers[a], bottomView) < svgRank(layers[b], bottomView)
+	})
+	return writeSVG(w, layers, g.MBB(), opts)
+}
+
+// WriteSVGDir writes each layer as an SVG image to the directory,
+// named after the layer filename with an added ".svg" extension.
+// The directory is created if necessary.
+func (g *Gerber) WriteSVGDir(dir string, opts *SVGOptions) error {
+	var files []file
+	for _, layer := range g.Layers {
+		var buf bytes.Buffer
+		if err := layer.WriteSVG(&buf, opts); err != nil {
+			return err
+		}
+		files = append(files, file{name: layer.Filename + ".svg", data: buf.Bytes()})
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return writeDir(dir, files)
+}
+
+// svgRank returns the stacking order of a layer, lowest first.
+func svgRank(l *Layer, bottomView bool) int {
+	order := []LayerType{
+		LayerOutline, LayerBottomSilkscreen, LayerBottomSolderMask, LayerBottomCopper, LayerInnerCopper,
+		LayerTopCopper, LayerTopSolderMask, LayerTopSilkscreen, LayerDrill, LayerNonPlatedDrill,
+	}
+	if bottomView {
+		order = []LayerType{
+			LayerOutline, LayerTopSilkscreen, LayerTopSolderMask, LayerTopCopper, LayerInnerCopper,
+			LayerBottomCopper, LayerBottomSolderMask, LayerBottomSilkscreen, LayerDrill, LayerNonPlatedDrill,
+		}
+	}
+	for i, t := range order {
+		if l.Type != t {
+			continue
+		}
+		// Inner layers closer to the viewer are stacked higher.
+		if t == LayerInnerCopper && bottomView {
+			return 1000*i + 500 + l.N
+		}
+		return 1000*i + 500 - l.N
+	}
+	return 1000 * len(order)
+}
+
+// writeSVG writes the layers in order to an SVG image of the bounding box.
+func writeSVG(w io.Writer, layers []*Layer, mbb MBB, opts *SVGOptions) error {
+	if opts == nil {
+		opts = &SVGOptions{}
+	}
+	m := opts.Margin
+	area := MBB{Min: Pt{mbb.Min[0] - m, mbb.Min[1] - m}, Max: Pt{mbb.Max[0] + m, mbb.Max[1] + m}}
+	width, height := area.Max[0]-area.Min[0], area.Max[1]-area.Min[1]
+
+	// The Y axis of SVG points down, so the design is flipped
+	// (and mirrored for the bottom view).
+	viewX, flip := area.Min[0], "scale(1 -1)"
+	if opts.BottomView {
+		viewX, flip = -area.Max[0], "scale(-1 -1)"
+	}
+
+	s := &svgWriter{area: area}
+	var body bytes.Buffer
+	for _, l := range layers {
+		if err := s.writeLayer(&body, l, opts.style(l)); err != nil {
+			return fmt.Errorf("%v: %v", l.Filename, err)
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(&out, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%vmm\" height=\"%vmm\" viewBox=\"%v %v %v %v\">\n",
+		svgNum(width), svgNum(height), svgNum(viewX), svgNum(-area.Max[1]), svgNum(width), svgNum(height))
+	if s.defs.Len() > 0 {
+		out.WriteString("<defs>\n")
+		out.Write(s.defs.Bytes())
+		out.WriteString("</defs>\n")
+	}
+	if opts.Background != "" {
+		fmt.Fprintf(&out, "<rect x=\"%v\" y=\"%v\" width=\"%v\" height=\"%v\" fill=\"%v\"/>\n",
+			svgNum(viewX), svgNum(-area.Max[1]), svgNum(width), svgNum(height), html.EscapeString(opts.Background))
+	}
+	fmt.Fprintf(&out, "<g transform=\"%v\">\n", flip)
+	out.Write(body.Bytes())
+	out.WriteString("</g>\n</svg>\n")
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// svgWriter holds the state of an SVG image being written.
+type svgWriter struct {
+	area  MBB // area of the image in millimeters
+	defs  bytes.Buffer
+	masks int
+}
+
+// svgItem is a primitive to draw with its polarity and
+// the transformation of the block apertures containing it.
+type svgItem struct {
+	prim      Primitive
+	dark      bool
+	transform string
+}
+
+// flattenSVG appends the primitives to items, expanding the groups
+// and block apertures.
+func flattenSVG(primitives []Primitive, dark bool, transform string, items []svgItem) []svgItem {
+	for _, p := range primitives {
+		switch v := p.(type) {
+		case *ClearT:
+			items = flattenSVG(v.Primitives, !dark, transform, items)
+		case *KnockoutT:
+			items = append(items, svgItem{prim: v.Box(), dark: dark, transform: transform})
+			items = flattenSVG(v.Primitives, !dark, transform, items)
+		case *FlashT:
+			if v.aperture != nil && v.aperture.Block != nil {
+				items = flattenSVG(v.aperture.Block.Primitives, dark, strings.TrimSpace(transform+" "+v.svgTransform()), items)
+				continue
+			}
+			items = append(items, svgItem{prim: p, dark: dark, transform: transform})
+		case *ImageT:
+			for _, r := range v.Regions {
+				items = append(items, svgItem{prim: r, dark: dark, transform: transform})
+			}
+		case *BarcodeT:
+			if v.Inverted {
+				items = append(items, svgItem{prim: v.Box(), dark: dark, transform: transform})
+			}
+			for _, r := range v.Regions {
+				items = append(items, svgItem{prim: r, dark: dark != v.Inverted, transform: transform})
+			}
+		default:
+			items = append(items, svgItem{prim: p, dark: dark, transform: transform})
+		}
+	}
+	return items
+}
+
+// writeLayer writes the layer as a group. Each run of clear primitives
+// becomes a mask of the primitives drawn before it.
+func (s *svgWriter) writeLayer(w io.Writer, l *Layer, style SVGStyle) error {
+	items := flattenSVG(l.Primitives, true, "", nil)
+	var content bytes.Buffer
+	for i := 0; i < len(items); {
+		j := i
+		for j < len(items) && items[j].dark == items[i].dark {
+			j++
+		}
+		var run bytes.Buffer
+		for _, item := range items[i:j] {
+			if err := writeSVGItem(&run, item); err != nil {
+				return err
+			}
+		}
+		switch {
+		case items[i].dark:
+			content.Write(run.Bytes())
+		case content.Len() > 0:
+			s.masks++
+			id := fmt.Sprintf("clear%v", s.masks)
+			x, y := svgNum(s.area.Min[0]), svgNum(s.area.Min[1])
+			width, height := svgNum(s.area.Max[0]-s.area.Min[0]), svgNum(s.area.Max[1]-s.area.Min[1])
+			fmt.Fprintf(&s.defs, "<mask id=\"%v\" maskUnits=\"userSpaceOnUse\" x=\"%v\" y=\"%v\" width=\"%v\" height=\"%v\">\n", id, x, y, width, height)
+			fmt.Fprintf(&s.defs, "<rect x=\"%v\" y=\"%v\" width=\"%v\" height=\"%v\" fill=\"#fff\"/>\n", x, y, width, height)
+			io.WriteString(&s.defs, "<g color=\"#000\" fill=\"currentColor\" stroke=\"none\">\n")
+			s.defs.Write(run.Bytes())
+			io.WriteString(&s.defs, "</g>\n</mask>\n")
+
+			masked := fmt.Sprintf("<g mask=\"url(#%v)\">\n%s</g>\n", id, content.Bytes())
+			content.Reset()
+			content.WriteString(masked)
+		}
+		i = j
+	}
+
+	var opacity string
+	if style.Opacity > 0 && style.Opacity < 1 {
+		opacity = fmt.Sprintf(" opacity=\"%v\"", svgNum(style.Opacity))
+	}
+	fmt.Fprintf(w, "<g id=\"%v\" color=\"%v\" fill=\"currentColor\" stroke=\"none\"%v>\n", svgID(l.Filename), html.EscapeString(style.Color), opacity)
+	w.Write(content.Bytes())
+	io.WriteString(w, "</g>\n")
+	return nil
+}
+
+// svgID returns a valid XML ID for a layer filename.
+func svgID(filename string) string {
+	return "layer-" + strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '-'
+	}, filename)
+}
+
+// writeSVGItem writes a primitive as an SVG element.
+func writeSVGItem(w io.Writer, item svgItem) error {
+	if item.transform != "" {
+		fmt.Fprintf(w, "<g transform=\"%v\">\n", item.transform)
+	}
+	switch v := item.prim.(type) {
+	case *LineT:
+		fmt.Fprintf(w, "<line x1=\"%v\" y1=\"%v\" x2=\"%v\" y2=\"%v\" stroke=\"currentColor\" stroke-width=\"%v\" stroke-linecap=\"%v\"/>\n",
+			svgNum(v.P1[0]), svgNum(v.P1[1]), svgNum(v.P2[0]), svgNum(v.P2[1]), svgNum(v.Thickness), svgLineCap(v.Shape))
+	case *ArcT:
+		fmt.Fprintf(w, "<path d=\"%v\" fill=\"none\" stroke=\"currentColor\" stroke-width=\"%v\" stroke-linecap=\"%v\"/>\n",
+			v.svgPath(), svgNum(v.Thickness), svgLineCap(v.Shape))
+	case *CircleT:
+		fmt.Fprintf(w, "<circle cx=\"%v\" cy=\"%v\" r=\"%v\"/>\n", svgNum(v.pt[0]), svgNum(v.pt[1]), svgNum(0.5*v.thickness))
+	case *PolygonT:
+		if !v.Filled && v.Thickness <= 0 {
+			return fmt.Errorf("polygon is neither filled nor stroked")
+		}
+		if len(v.Points) == 0 {
+			break
+		}
+		var d []string
+		for i, pt := range v.Points {
+			cmd := "L"
+			if i == 0 {
+				cmd = "M"
+			}
+			d = append(d, cmd+svgPt(Pt{pt[0] + v.Offset[0], pt[1] + v.Offset[1]}))
+		}
+		var attrs string
+		if !v.Filled {
+			attrs = " fill=\"none\""
+		}
+		if v.Thickness > 0 {
+			attrs += fmt.Sprintf(" stroke=\"currentColor\" stroke-width=\"%v\" stroke-linejoin=\"round\"", svgNum(v.Thickness))
+		}
+		fmt.Fprintf(w, "<path d=\"%vZ\"%v/>\n", strings.Join(d, ""), attrs)
+	case *RegionT:
+		d := v.Outer.svgPath()
+		for _, h := range v.Holes {
+			d += h.svgPath()
+		}
+		fmt.Fprintf(w, "<path d=\"%v\" fill-rule=\"evenodd\"/>\n", d)
+	case *FlashT:
+		if v.aperture == nil {
+			return fmt.Errorf("flash has no aperture")
+		}
+		if m := v.aperture.Macro; m != nil {
+			// Overlapping macro primitives add up, so they are
+			// filled with the nonzero rule.
+			d, err := m.svgPath(v.aperture.Params)
+			if err != nil {
+				return err
+			}
+			if d != "" {
+				fmt.Fprintf(w, "<path transform=\"%v\" d=\"%v\"/>\n", v.svgTransform(), d)
+			}
+			break
+		}
+		fmt.Fprintf(w, "<path transform=\"%v\" d=\"%v\" fill-rule=\"evenodd\"/>\n", v.svgTransform(), v.aperture.svgPath())
+	case *TextT:
+		if err := v.renderText(); err != nil {
+			return err
+		}
+		// The glyph counters are holes within the dark glyphs.
+		var d []string
+		for _, poly := range v.Render.Polygons {
+			for i, pt := range poly.Pts {
+				cmd := "L"
+				if i == 0 {
+					cmd = "M"
+				}
+				d = append(d, cmd+svgPt(pt))
+			}
+			d = append(d, "Z")
+		}
+		fmt.Fprintf(w, "<path d=\"%v\" fill-rule=\"evenodd\"/>\n", strings.Join(d, ""))
+	default:
+		return fmt.Errorf("unsupported primitive %T in SVG output", v)
+	}
+	if item.transform != "" {
+		io.WriteString(w, "</g>\n")
+	}
+	return nil
+}
+
+// svgLineCap returns the stroke-linecap of a line drawn with a shape.
+func svgLineCap(shape Shape) string {
+	if shape == RectShape {
+		return "square"
+	}
+	return "round"
+}
+
+// svgTransform returns the SVG transform of the flashed aperture.
+func (f *FlashT) svgTransform() string {
+	result := "translate(" + svgNum(f.Center[0]) + " " + svgNum(f.Center[1]) + ")"
+	if f.Scale != 0 && f.Scale != 1 {
+		result += " scale(" + svgNum(f.Scale) + ")"
+	}
+	if f.Rotation != 0 {
+		result += " rotate(" + svgNum(f.Rotation) + ")"
+	}
+	switch f.Mirror {
+	case MirrorX:
+		result += " scale(-1 1)"
+	case MirrorY:
+		result += " scale(1 -1)"
+	case MirrorXY:
+		result += " scale(-1 -1)"
+	}
+	return result
+}
+
+// svgPath returns the outline of a standard aperture centered on the
+// origin.
+func (a *Aperture) svgPath() string {
+	var d string
+	switch {
+	case a.Shape == RectShape:
+		hw, hh := 0.5*a.Size, 0.5*a.ySize()
+		d = svgRectPath(Pt{-hw, -hh}, Pt{hw, hh})
+	case a.Shape == ObroundShape:
+		hw, hh := 0.5*a.Size, 0.5*a.ySize()
+		r := svgNum(math.Min(hw, hh))
+		if hw >= hh {
+			d = "M" + svgPt(Pt{-hw + hh, -hh}) + "H" + svgNum(hw-hh) + svgArc(r, r, true, Pt{hw - hh, hh}) +
+				"H" + svgNum(-hw+hh) + svgArc(r, r, true, Pt{-hw + hh, -hh}) + "Z"
+		} else {
+			d = "M" + svgPt(Pt{hw, -hh + hw}) + "V" + svgNum(hh-hw) + svgArc(r, r, true, Pt{-hw, hh - hw}) +
+				"V" + svgNum(-hh+hw) + svgArc(r, r, true, Pt{hw, -hh + hw}) + "Z"
+		}
+	case a.Shape == PolygonShape:
+		for i := 0; i < a.Vertices; i++ {
+			cmd := "L"
+			if i == 0 {
+				cmd = "M"
+			}
+			d += cmd + svgPt(rotate(Pt{0.5 * a.Size, 0}, a.Rotation+360*float64(i)/float64(a.Vertices)))
+		}
+		d += "Z"
+	default:
+		d = svgCirclePath(Pt{0, 0}, 0.5*a.Size)
+	}
+	if a.Hole > 0 {
+		d += svgCirclePath(Pt{0, 0}, 0.5*a.Hole)
+	}
+	return d
+}
+
+// svgPath returns the outlines of the macro's exposed primitives for
+// the parameters, centered on the origin. Every outline is
+// counterclockwise except the holes of moire rings, so the path is
+// filled with the nonzero rule. Primitives with exposure off are
+// skipped.
+func (m *Macro) svgPath(params []float64) (string, error) {
+	var d strings.Builder
+	polygon := func(pts []Pt, rotation float64) {
+		reversed := imageArea(pts) < 0
+		for i := range pts {
+			pt := pts[i]
+			if reversed {
+				pt = pts[len(pts)-1-i]
+			}
+			cmd := "L"
+			if i == 0 {
+				cmd = "M"
+			}
+			d.WriteString(cmd + svgPt(rotate(pt, rotation)))
+		}
+		d.WriteString("Z")
+	}
+	circle := func(center Pt, r float64, ccw bool) {
+		rs := svgNum(r)
+		d.WriteString("M" + svgPt(Pt{center[0] + r, center[1]}) +
+			svgArc(rs, rs, ccw, Pt{center[0] - r, center[1]}) +
+			svgArc(rs, rs, ccw, Pt{center[0] + r, center[1]}) + "Z")
+	}
+	rect := func(center Pt, width, height, rotation float64) {
+		hw, hh := 0.5*width, 0.5*height
+		polygon([]Pt{
+			{center[0] - hw, center[1] - hh}, {center[0] + hw, center[1] - hh},
+			{center[0] + hw, center[1] + hh}, {center[0] - hw, center[1] + hh},
+		}, rotation)
+	}
+
+	err := m.eval(params, func(code MacroCode, mods []float64) error {
+		mod := func(i int) float64 {
+			if i < len(mods) {
+				return mods[i]
+			}
+			return 0
+		}
+
+		switch code {
+		case MacroCircle:
+			if mod(0) == 0 || mod(1) <= 0 {
+				return nil
+			}
+			circle(rotate(Pt{mod(2), mod(3)}, mod(4)), 0.5*mod(1), true)
+		case MacroVectorLine:
+			start, end := Pt{mod(2), mod(3)}, Pt{mod(4), mod(5)}
+			dx, dy := end[0]-start[0], end[1]-start[1]
+			length := math.Hypot(dx, dy)
+			if mod(0) == 0 || length == 0 {
+				return nil
+			}
+			nx, ny := -0.5*mod(1)*dy/length, 0.5*mod(1)*dx/length
+			polygon([]Pt{
+				{start[0] - nx, start[1] - ny}, {end[0] - nx, end[1] - ny},
+				{end[0] + nx, end[1] + ny}, {start[0] + nx, start[1] + ny},
+			}, mod(6))
+		case MacroCenterLine:
+			if mod(0) == 0 {
+				return nil
+			}
+			rect(Pt{mod(3), mod(4)}, mod(1), mod(2), mod(5))
+		case MacroOutline:
+			if mod(0) == 0 {
+				return nil
+			}
+			n := int(mod(1))
+			if len(mods) < 2*n+5 {
+				return fmt.Errorf("macro %v: outline with %v vertices has %v modifiers", m.Name, n, len(mods))
+			}
+			var pts []Pt
+			for i := 0; i < n; i++ {
+				pts = append(pts, Pt{mod(2 + 2*i), mod(3 + 2*i)})
+			}
+			polygon(pts, mod(2*n+4))
+		case MacroPolygon:
+			n := int(mod(1))
+			if mod(0) == 0 || n < 3 {
+				return nil
+			}
+			var pts []Pt
+			for i := 0; i < n; i++ {
+				s, c := math.Sincos(2 * math.Pi * float64(i) / float64(n))
+				pts = append(pts, Pt{mod(2) + 0.5*mod(4)*c, mod(3) + 0.5*mod(4)*s})
+			}
+			polygon(pts, mod(5))
+		case MacroMoire:
+			center := rotate(Pt{mod(0), mod(1)}, mod(8))
+			thickness, gap := mod(3), mod(4)
+			for i, r := 0, 0.5*mod(2); i < int(mod(5)) && r > 0 && thickness > 0; i, r = i+1, r-thickness-gap {
+				circle(center, r, true)
+				if inner := r - thickness; inner > 0 {
+					circle(center, inner, false)
+				}
+			}
+			if mod(6) > 0 && mod(7) > 0 {
+				rect(Pt{mod(0), mod(1)}, mod(7), mod(6), mod(8))
+				rect(Pt{mod(0), mod(1)}, mod(6), mod(7), mod(8))
+			}
+		case MacroThermal:
+			// Four quarters of the ring between the gaps, each traced
+			// counterclockwise along the outer edge and back along the
+			// inner edge.
+			center, ro, ri, hg := Pt{mod(0), mod(1)}, 0.5*mod(2), 0.5*mod(3), 0.5*mod(4)
+			if ro <= hg {
+				return nil
+			}
+			so := math.Sqrt(ro*ro - hg*hg)
+			rs, ris := svgNum(ro), svgNum(ri)
+			for q := 0.0; q < 4; q++ {
+				quarter := func(x, y float64) Pt {
+					p := rotate(Pt{x, y}, 90*q)
+					return rotate(Pt{center[0] + p[0], center[1] + p[1]}, mod(5))
+				}
+				d.WriteString("M" + svgPt(quarter(so, hg)) + svgArc(rs, rs, true, quarter(hg, so)))
+				if ri > hg {
+					si := math.Sqrt(ri*ri - hg*hg)
+					d.WriteString("L" + svgPt(quarter(hg, si)) + svgArc(ris, ris, false, quarter(si, hg)))
+				} else {
+					d.WriteString("L" + svgPt(quarter(hg, hg)))
+				}
+				d.WriteString("Z")
+			}
+		default:
+			return fmt.Errorf("macro %v: unknown primitive code %v", m.Name, code)
+		}
+		return nil
+	})
+	return d.String(), err
+}
+
+// svgPath returns the SVG path of the arc, which is an ellipse
+// if its X and Y scales differ.
+func (a *ArcT) svgPath() string {
+	delta := a.EndAngle - a.StartAngle
+	pt := func(angle float64) Pt {
+		return Pt{a.Center[0] + a.XScale*math.Cos(angle)*a.Radius, a.Center[1] + a.YScale*math.Sin(angle)*a.Radius}
+	}
+	sweep := delta > 0
+	if a.XScale*a.YScale < 0 {
+		sweep = !sweep
+	}
+	rx, ry := svgNum(math.Abs(a.XScale*a.Radius)), svgNum(math.Abs(a.YScale*a.Radius))
+
+	// SVG arcs cannot be full circles, so long arcs are split.
+	segments := int(math.Ceil(math.Abs(delta)/math.Pi - 1e-9))
+	if segments < 1 {
+		segments = 1
+	}
+	d := "M" + svgPt(pt(a.StartAngle))
+	for i := 1; i <= segments; i++ {
+		d += svgArc(rx, ry, sweep, pt(a.StartAngle+delta*float64(i)/float64(segments)))
+	}
+	return d
+}
+
+// svgPath returns the closed SVG path of the contour.
+func (c Contour) svgPath() string {
+	d := "M" + svgPt(c.Start)
+	prev := c.Start
+	for _, s := range c.ring() {
+		if !s.Arc {
+			d += "L" + svgPt(s.End)
+			prev = s.End
+			continue
+		}
+		sweep := s.sweep(prev)
+		r := svgNum(s.radius(prev))
+		if math.Abs(sweep) > math.Pi {
+			// SVG arcs cannot be full circles, so long arcs are split.
+			a := s.angle(prev) + 0.5*sweep
+			mid := Pt{s.Center[0] + s.radius(prev)*math.Cos(a), s.Center[1] + s.radius(prev)*math.Sin(a)}
+			d += svgArc(r, r, sweep > 0, mid)
+		}
+		d += svgArc(r, r, sweep > 0, s.End)
+		prev = s.End
+	}
+	return d + "Z"
+}
+
+// svgArc returns an elliptical arc command of at most 180 degrees.
+// Sweep selects the counterclockwise direction in design coordinates.
+func svgArc(rx, ry string, sweep bool, end Pt) string {
+	flag := "0"
+	if sweep {
+		flag = "1"
+	}
+	return "A" + rx + " " + ry + " 0 0 " + flag + " " + svgPt(end)
+}
+
+// svgCirclePath returns the closed SVG path of a circle.
+func svgCirclePath(center Pt, r float64) string {
+	rs := svgNum(r)
+	return "M" + svgPt(Pt{center[0] + r, center[1]}) +
+		svgArc(rs, rs, true, Pt{center[0] - r, center[1]}) +
+		svgArc(rs, rs, true, Pt{center[0] + r, center[1]}) + "Z"
+}
+
+// svgRectPath returns the closed SVG path of a rectangle.
+func svgRectPath(lo, hi Pt) string {
+	return "M" + svgPt(lo) + "H" + svgNum(hi[0]) + "V" + svgNum(hi[1]) + "H" + svgNum(lo[0]) + "Z"
+}
+
+// svgPt formats a point for an SVG path.
+func svgPt(pt Pt) string {
+	return svgNum(pt[0]) + " " + svgNum(pt[1])
+}
+
+// svgNum formats a dimension in millimeters, rounded to the nanometer.
+func svgNum(v float64) string {
+	v = math.Round(v*1e6) / 1e6
+	if v == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
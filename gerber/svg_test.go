@@ -0,0 +1,251 @@
+package gerber
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// checkSVG verifies that the SVG image is well-formed XML.
+func checkSVG(t *testing.T, svg string) {
+	t.Helper()
+	d := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		_, err := d.Token()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatalf("invalid SVG: %v\n%v", err, svg)
+		}
+	}
+}
+
+func TestLayer_WriteSVG(t *testing.T) {
+	g := New("test")
+	top := g.TopCopper()
+	pad := Flash(Pt{5, 5}, RectAperture(1, 2))
+	pad.Rotation = 90
+	top.Add(
+		Line(0, 0, 10, 0, CircleShape, 0.25),
+		Line(0, 1, 10, 1, RectShape, 0.25),
+		Arc(Pt{5, 5}, 2, CircleShape, 1, 1, 0, 90, 0.25),
+		Circle(Pt{1, 1}, 0.5),
+		pad,
+		Flash(Pt{8, 8}, &Aperture{Shape: ObroundShape, Size: 2, YSize: 1, Hole: 0.5}),
+		Polygon(Pt{1, 1}, true, []Pt{{0, 0}, {2, 0}, {2, 2}}, 0),
+		Clear(Circle(Pt{3, 3}, 1)),
+		Region(Contour{Start: Pt{12, 0}, Segments: []Segment{LineTo(Pt{14, 0}), ArcTo(Pt{14, 2}, Pt{14, 1}, false)}}),
+	)
+
+	var buf bytes.Buffer
+	if err := top.WriteSVG(&buf, &SVGOptions{Margin: 1}); err != nil {
+		t.Fatal(err)
+	}
+	svg := buf.String()
+	checkSVG(t, svg)
+
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" width="17.125mm" height="10.625mm" viewBox="-1.125 -9.5 17.125 10.625">`,
+		`<mask id="clear1" maskUnits="userSpaceOnUse" x="-1.125" y="-1.125" width="17.125" height="10.625">`,
+		`<circle cx="3" cy="3" r="0.5"/>`,
+		`<g transform="scale(1 -1)">`,
+		`<g id="layer-test-gtl" color="#fa32fa" fill="currentColor" stroke="none" opacity="0.8">`,
+		`<g mask="url(#clear1)">`,
+		`<line x1="0" y1="0" x2="10" y2="0" stroke="currentColor" stroke-width="0.25" stroke-linecap="round"/>`,
+		`<line x1="0" y1="1" x2="10" y2="1" stroke="currentColor" stroke-width="0.25" stroke-linecap="square"/>`,
+		`<path d="M7 5A2 2 0 0 1 5 7" fill="none" stroke="currentColor" stroke-width="0.25" stroke-linecap="round"/>`,
+		`<circle cx="1" cy="1" r="0.25"/>`,
+		`<path transform="translate(5 5) rotate(90)" d="M-0.5 -1H0.5V1H-0.5Z" fill-rule="evenodd"/>`,
+		`<path transform="translate(8 8)" d="M-0.5 -0.5H0.5A0.5 0.5 0 0 1 0.5 0.5H-0.5A0.5 0.5 0 0 1 -0.5 -0.5ZM0.25 0A0.25 0.25 0 0 1 -0.25 0A0.25 0.25 0 0 1 0.25 0Z" fill-rule="evenodd"/>`,
+		`<path d="M1 1L3 1L3 3Z"/>`,
+		`<path d="M12 0L14 0A1 1 0 0 1 14 2L12 0Z" fill-rule="evenodd"/>`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("missing %v in:\n%v", want, svg)
+		}
+	}
+	// The region is drawn after the clear group, outside of its mask.
+	if strings.Index(svg, "</g>\n<path d=\"M12 0") < 0 {
+		t.Errorf("region is masked:\n%v", svg)
+	}
+}
+
+func TestArcT_SVGPath(t *testing.T) {
+	tests := []struct {
+		name string
+		arc  *ArcT
+		want string
+	}{
+		{
+			name: "full circle",
+			arc:  Arc(Pt{0, 0}, 1, CircleShape, 1, 1, 0, 360, 0.1),
+			want: "M1 0A1 1 0 0 1 -1 0A1 1 0 0 1 1 0",
+		},
+		{
+			name: "mirrored ellipse",
+			arc:  &ArcT{Radius: 1, XScale: -2, YScale: 1, EndAngle: 0.5 * math.Pi},
+			want: "M-2 0A2 1 0 0 0 0 1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.arc.svgPath(); got != tt.want {
+				t.Errorf("svgPath = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMacro_SVGPath(t *testing.T) {
+	tests := []struct {
+		name string
+		a    *Aperture
+		want string
+	}{
+		{
+			name: "thermal",
+			a:    ThermalAperture(2, 1, 0.4, 0),
+			want: "M0.979796 0.2A1 1 0 0 1 0.2 0.979796L0.2 0.458258A0.5 0.5 0 0 0 0.458258 0.2Z" +
+				"M-0.2 0.979796A1 1 0 0 1 -0.979796 0.2L-0.458258 0.2A0.5 0.5 0 0 0 -0.2 0.458258Z" +
+				"M-0.979796 -0.2A1 1 0 0 1 -0.2 -0.979796L-0.2 -0.458258A0.5 0.5 0 0 0 -0.458258 -0.2Z" +
+				"M0.2 -0.979796A1 1 0 0 1 0.979796 -0.2L0.458258 -0.2A0.5 0.5 0 0 0 0.2 -0.458258Z",
+		},
+		{
+			name: "round rect",
+			a:    RoundRectAperture(2, 1, 0.25, 0),
+			want: "M-1 -0.25L1 -0.25L1 0.25L-1 0.25ZM-0.75 -0.5L0.75 -0.5L0.75 0.5L-0.75 0.5Z" +
+				"M1 0.25A0.25 0.25 0 0 1 0.5 0.25A0.25 0.25 0 0 1 1 0.25ZM-0.5 0.25A0.25 0.25 0 0 1 -1 0.25A0.25 0.25 0 0 1 -0.5 0.25Z" +
+				"M-0.5 -0.25A0.25 0.25 0 0 1 -1 -0.25A0.25 0.25 0 0 1 -0.5 -0.25ZM1 -0.25A0.25 0.25 0 0 1 0.5 -0.25A0.25 0.25 0 0 1 1 -0.25Z",
+		},
+		{
+			name: "rotated chamfered rect",
+			a:    ChamferedRectAperture(2, 1, 0.25, 90),
+			want: "M0.5 -0.75L0.5 0.75L0.25 1L-0.25 1L-0.5 0.75L-0.5 -0.75L-0.25 -1L0.25 -1Z",
+		},
+		{
+			name: "clockwise outline",
+			a: &Aperture{Macro: &Macro{Name: "CW", Statements: []MacroStatement{
+				MacroPrimitive{Code: MacroOutline, Modifiers: []Expr{"1", "3", "0", "0", "0", "1", "1", "0", "0", "0", "0"}},
+			}}},
+			want: "M1 0L0 1L0 0Z",
+		},
+		{
+			name: "moire",
+			a: &Aperture{Macro: &Macro{Name: "Moire", Statements: []MacroStatement{
+				MacroPrimitive{Code: MacroMoire, Modifiers: []Expr{"0", "0", "4", "0.5", "0.5", "3", "0.1", "5", "0"}},
+			}}},
+			want: "M2 0A2 2 0 0 1 -2 0A2 2 0 0 1 2 0ZM1.5 0A1.5 1.5 0 0 0 -1.5 0A1.5 1.5 0 0 0 1.5 0Z" +
+				"M1 0A1 1 0 0 1 -1 0A1 1 0 0 1 1 0ZM0.5 0A0.5 0.5 0 0 0 -0.5 0A0.5 0.5 0 0 0 0.5 0Z" +
+				"M-2.5 -0.05L2.5 -0.05L2.5 0.05L-2.5 0.05ZM-0.05 -2.5L0.05 -2.5L0.05 2.5L-0.05 2.5Z",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.a.Macro.svgPath(tt.a.Params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("svgPath =\n%v\nwant:\n%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayer_WriteSVG_Errors(t *testing.T) {
+	g := New("test")
+	g.TopCopper().Add(Flash(Pt{1, 1}, ThermalAperture(2, 1, 0.4, 0)))
+	if err := g.TopCopper().WriteSVG(failingWriter{}, nil); !errors.Is(err, errWrite) {
+		t.Errorf("WriteSVG = %v, want %v", err, errWrite)
+	}
+	if err := g.WriteSVG(failingWriter{}, nil); !errors.Is(err, errWrite) {
+		t.Errorf("Gerber.WriteSVG = %v, want %v", err, errWrite)
+	}
+}
+
+func TestGerber_WriteSVG(t *testing.T) {
+	g := New("board")
+	g.TopSilkscreen().Add(Line(0, 0, 1, 0, CircleShape, 0.1))
+	g.TopCopper().Add(Line(0, 0, 1, 0, CircleShape, 0.1))
+	g.LayerN(3).Add(Line(0, 0, 1, 0, CircleShape, 0.1))
+	g.LayerN(2).Add(Line(0, 0, 1, 0, CircleShape, 0.1))
+	g.BottomCopper().Add(Line(0, 0, 1, 0, CircleShape, 0.1))
+	g.Drill().Add(Circle(Pt{0, 0}, 0.5))
+	g.Outline().Add(Line(0, 0, 1, 0, CircleShape, 0.1))
+
+	tests := []struct {
+		name      string
+		opts      *SVGOptions
+		transform string
+		order     []string
+	}{
+		{
+			name:      "top view",
+			transform: `<g transform="scale(1 -1)">`,
+			order:     []string{"board-gko", "board-gbl", "board-gl3", "board-gl2", "board-gtl", "board-gto", "board-drl"},
+		},
+		{
+			name:      "bottom view",
+			opts:      &SVGOptions{BottomView: true, Background: "#000", Styles: map[LayerType]SVGStyle{LayerTopCopper: {Color: "red", Opacity: 0.5}}},
+			transform: `<g transform="scale(-1 -1)">`,
+			order:     []string{"board-gko", "board-gto", "board-gtl", "board-gl2", "board-gl3", "board-gbl", "board-drl"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := g.WriteSVG(&buf, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			svg := buf.String()
+			checkSVG(t, svg)
+			if !strings.Contains(svg, tt.transform) {
+				t.Errorf("missing %v in:\n%v", tt.transform, svg)
+			}
+			last := -1
+			for _, name := range tt.order {
+				i := strings.Index(svg, `id="layer-`+name+`"`)
+				if i <= last {
+					t.Errorf("layer %v out of order:\n%v", name, svg)
+				}
+				last = i
+			}
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := g.WriteSVG(&buf, tests[1].opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<rect x="-1.05" y="-0.25" width="1.3" height="0.5" fill="#000"/>`,
+		`<g id="layer-board-gtl" color="red" fill="currentColor" stroke="none" opacity="0.5">`,
+		`<g id="layer-board-gl2" color="#000084" fill="currentColor" stroke="none" opacity="0.8">`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %v in:\n%v", want, buf.String())
+		}
+	}
+}
+
+func TestGerber_WriteSVGDir(t *testing.T) {
+	dir := t.TempDir()
+	g := testDesign()
+	if err := g.WriteSVGDir(dir, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, layer := range g.Layers {
+		data, err := os.ReadFile(filepath.Join(dir, layer.Filename+".svg"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkSVG(t, string(data))
+	}
+}
@@ -0,0 +1,820 @@
+package gerber
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SVGImportOptions represents the options of the SVG importer.
+type SVGImportOptions struct {
+	// Tolerance is the maximum distance in millimeters between the
+	// flattened polygons and the Bézier curves and arcs of the image.
+	// Zero means 0.01mm.
+	Tolerance float64
+	// Mirror mirrors the artwork left to right, for bottom layers.
+	Mirror bool
+}
+
+// ImportSVG reads the filled shapes of an SVG image from a file.
+// See ParseSVG.
+func ImportSVG(filename string, center Pt, width, height float64, opts *SVGImportOptions) ([]Primitive, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	primitives, err := ParseSVG(f, center, width, height, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", filename, err)
+	}
+	return primitives, nil
+}
+
+// ParseSVG reads the filled shapes of an SVG image (<path>, <polygon>,
+// <polyline>, <rect>, <circle> and <ellipse> elements, with their
+// transforms) and returns them as filled polygons.
+//
+// The artwork is centered on center and scaled to fit in width by
+// height millimeters, keeping its aspect ratio. A zero width or height
+// does not constrain the size, and if both are zero the image keeps
+// its own size (96 user units per inch unless the root element gives
+// its size in other units).
+//
+// Holes made by the fill rule of a shape are returned as clear
+// polarity polygons, so they also clear anything drawn before them on
+// the layer. Strokes, text, gradients and <use> references are ignored.
+// opts may be nil.
+func ParseSVG(r io.Reader, center Pt, width, height float64, opts *SVGImportOptions) ([]Primitive, error) {
+	if opts == nil {
+		opts = &SVGImportOptions{}
+	}
+	tolerance := opts.Tolerance
+	if tolerance <= 0 {
+		tolerance = 0.01
+	}
+
+	p := &svgParser{unit: 25.4 / 96}
+	if err := p.parse(r); err != nil {
+		return nil, err
+	}
+	if len(p.shapes) == 0 {
+		return nil, fmt.Errorf("no filled shapes found")
+	}
+
+	// Find the bounding box of the artwork from a coarse flattening.
+	var mbb *MBB
+	for _, s := range p.shapes {
+		for _, sp := range s.subpaths {
+			for _, pt := range sp.flatten(0) {
+				if mbb == nil {
+					mbb = &MBB{Min: pt, Max: pt}
+					continue
+				}
+				mbb.Join(&MBB{Min: pt, Max: pt})
+			}
+		}
+	}
+	w, h := mbb.Max[0]-mbb.Min[0], mbb.Max[1]-mbb.Min[1]
+	scale := p.unit
+	if width > 0 || height > 0 {
+		scale = math.Inf(1)
+		if width > 0 && w > 0 {
+			scale = width / w
+		}
+		if height > 0 && h > 0 {
+			scale = math.Min(scale, height/h)
+		}
+		if math.IsInf(scale, 1) {
+			return nil, fmt.Errorf("artwork has no area")
+		}
+	}
+	xScale := scale
+	if opts.Mirror {
+		xScale = -scale
+	}
+	mid := Pt{0.5 * (mbb.Min[0] + mbb.Max[0]), 0.5 * (mbb.Min[1] + mbb.Max[1])}
+
+	var primitives []Primitive
+	for _, s := range p.shapes {
+		var polys [][]Pt
+		for _, sp := range s.subpaths {
+			if pts := sp.flatten(tolerance / scale); len(pts) >= 3 {
+				polys = append(polys, pts)
+			}
+		}
+		for _, poly := range s.classify(polys) {
+			// SVG coordinates point down, Gerber coordinates up.
+			pts := make([]Pt, len(poly.pts))
+			for i, pt := range poly.pts {
+				pts[i] = Pt{xScale * (pt[0] - mid[0]), -scale * (pt[1] - mid[1])}
+			}
+			polygon := Polygon(center, true, pts, 0)
+			if !poly.hole {
+				primitives = append(primitives, polygon)
+				continue
+			}
+			if n := len(primitives); n > 0 {
+				if c, ok := primitives[n-1].(*ClearT); ok {
+					c.Primitives = append(c.Primitives, polygon)
+					continue
+				}
+			}
+			primitives = append(primitives, Clear(polygon))
+		}
+	}
+	if len(primitives) == 0 {
+		return nil, fmt.Errorf("no filled shapes found")
+	}
+	return primitives, nil
+}
+
+// svgParser collects the filled shapes of an SVG document.
+type svgParser struct {
+	d      *xml.Decoder
+	unit   float64 // millimeters per user unit
+	root   bool    // whether the root element was seen
+	shapes []*svgShape
+}
+
+// svgState is the inherited state of an element.
+type svgState struct {
+	m       svgMatrix
+	fill    bool
+	evenOdd bool
+}
+
+// svgSkipped are the elements whose content is not rendered directly.
+var svgSkipped = map[string]bool{
+	"clipPath": true, "defs": true, "desc": true, "linearGradient": true,
+	"marker": true, "mask": true, "metadata": true, "pattern": true,
+	"radialGradient": true, "style": true, "symbol": true, "text": true,
+	"title": true,
+}
+
+func (p *svgParser) parse(r io.Reader) error {
+	p.d = xml.NewDecoder(r)
+	stack := []svgState{{m: svgIdentity, fill: true}}
+	for {
+		tok, err := p.d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if svgSkipped[t.Name.Local] || svgAttr(t, "display") == "none" {
+				if err := p.d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if !p.root {
+				if t.Name.Local != "svg" {
+					return fmt.Errorf("root element is <%v>, want <svg>", t.Name.Local)
+				}
+				p.root = true
+				p.rootUnit(t)
+			}
+			state, err := p.state(t, stack[len(stack)-1])
+			if err != nil {
+				return err
+			}
+			stack = append(stack, state)
+			if err := p.element(t, state); err != nil {
+				return fmt.Errorf("<%v>: %v", t.Name.Local, err)
+			}
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if !p.root {
+		return fmt.Errorf("no <svg> element found")
+	}
+	return nil
+}
+
+// rootUnit sets the size of the user units from the width and
+// viewBox of the root element.
+func (p *svgParser) rootUnit(t xml.StartElement) {
+	viewBox := svgNumbers(svgAttr(t, "viewBox"))
+	width := strings.TrimSpace(svgAttr(t, "width"))
+	if len(viewBox) != 4 || viewBox[2] <= 0 || width == "" {
+		return
+	}
+	units := map[string]float64{"mm": 1, "cm": 10, "in": 25.4, "pt": 25.4 / 72, "pc": 25.4 / 6, "px": 25.4 / 96}
+	mm := 25.4 / 96
+	for suffix, v := range units {
+		if strings.HasSuffix(width, suffix) {
+			width, mm = strings.TrimSuffix(width, suffix), v
+			break
+		}
+	}
+	if v, err := strconv.ParseFloat(width, 64); err == nil && v > 0 {
+		p.unit = v * mm / viewBox[2]
+	}
+}
+
+// state returns the state of an element inheriting from its parent.
+func (p *svgParser) state(t xml.StartElement, parent svgState) (svgState, error) {
+	state := parent
+	if v := svgAttr(t, "transform"); v != "" {
+		m, err := parseSVGTransform(v)
+		if err != nil {
+			return state, err
+		}
+		state.m = parent.m.mul(m)
+	}
+	if v := svgAttr(t, "fill"); v != "" && v != "inherit" {
+		state.fill = v != "none" && v != "transparent"
+	}
+	switch svgAttr(t, "fill-rule") {
+	case "evenodd":
+		state.evenOdd = true
+	case "nonzero":
+		state.evenOdd = false
+	}
+	return state, nil
+}
+
+// element adds the shape of an element.
+func (p *svgParser) element(t xml.StartElement, state svgState) error {
+	if !state.fill {
+		return nil
+	}
+	b := &svgPathBuilder{}
+	num := func(name string) float64 { return svgLength(svgAttr(t, name)) }
+	switch t.Name.Local {
+	case "path":
+		if err := b.parse(svgAttr(t, "d")); err != nil {
+			return err
+		}
+	case "polygon", "polyline":
+		v := svgNumbers(svgAttr(t, "points"))
+		for i := 0; i+1 < len(v); i += 2 {
+			if i == 0 {
+				b.moveTo(Pt{v[0], v[1]})
+				continue
+			}
+			b.lineTo(Pt{v[i], v[i+1]})
+		}
+		b.close()
+	case "rect":
+		x, y, w, h := num("x"), num("y"), num("width"), num("height")
+		if w <= 0 || h <= 0 {
+			return nil
+		}
+		rx, ry := num("rx"), num("ry")
+		if svgAttr(t, "rx") == "" {
+			rx = ry
+		}
+		if svgAttr(t, "ry") == "" {
+			ry = rx
+		}
+		rx, ry = math.Min(rx, 0.5*w), math.Min(ry, 0.5*h)
+		b.moveTo(Pt{x + rx, y})
+		b.lineTo(Pt{x + w - rx, y})
+		b.arcTo(rx, ry, 0, false, true, Pt{x + w, y + ry})
+		b.lineTo(Pt{x + w, y + h - ry})
+		b.arcTo(rx, ry, 0, false, true, Pt{x + w - rx, y + h})
+		b.lineTo(Pt{x + rx, y + h})
+		b.arcTo(rx, ry, 0, false, true, Pt{x, y + h - ry})
+		b.lineTo(Pt{x, y + ry})
+		b.arcTo(rx, ry, 0, false, true, Pt{x + rx, y})
+		b.close()
+	case "circle", "ellipse":
+		cx, cy, rx, ry := num("cx"), num("cy"), num("rx"), num("ry")
+		if t.Name.Local == "circle" {
+			rx, ry = num("r"), num("r")
+		}
+		if rx <= 0 || ry <= 0 {
+			return nil
+		}
+		b.moveTo(Pt{cx + rx, cy})
+		b.arcTo(rx, ry, 0, false, true, Pt{cx, cy + ry})
+		b.arcTo(rx, ry, 0, false, true, Pt{cx - rx, cy})
+		b.arcTo(rx, ry, 0, false, true, Pt{cx, cy - ry})
+		b.arcTo(rx, ry, 0, false, true, Pt{cx + rx, cy})
+		b.close()
+	default:
+		return nil
+	}
+
+	s := &svgShape{evenOdd: state.evenOdd}
+	for _, sp := range b.subpaths {
+		if len(sp.segments) == 0 {
+			continue
+		}
+		sp.start = state.m.apply(sp.start)
+		for i, seg := range sp.segments {
+			sp.segments[i] = svgSegment{
+				c1:    state.m.apply(seg.c1),
+				c2:    state.m.apply(seg.c2),
+				end:   state.m.apply(seg.end),
+				curve: seg.curve,
+			}
+		}
+		s.subpaths = append(s.subpaths, sp)
+	}
+	if len(s.subpaths) > 0 {
+		p.shapes = append(p.shapes, s)
+	}
+	return nil
+}
+
+// svgAttr returns the value of a presentation attribute, which the
+// style attribute overrides.
+func svgAttr(t xml.StartElement, name string) string {
+	var value string
+	for _, a := range t.Attr {
+		if a.Name.Local == name {
+			value = a.Value
+		}
+		if a.Name.Local != "style" {
+			continue
+		}
+		for _, decl := range strings.Split(a.Value, ";") {
+			if k, v, ok := strings.Cut(decl, ":"); ok && strings.TrimSpace(k) == name {
+				return strings.TrimSpace(v)
+			}
+		}
+	}
+	return strings.TrimSpace(value)
+}
+
+// svgLength returns the value of a length in user units,
+// or zero if it is missing or has other units.
+func svgLength(s string) float64 {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "px"), 64)
+	if err != nil {
+		return 0
+	}
+	return v
+}
+
+// svgNumbers returns the numbers of a list separated by commas
+// and/or whitespace, up to the first invalid number.
+func svgNumbers(s string) []float64 {
+	var v []float64
+	sc := &svgScanner{s: s}
+	for !sc.done() {
+		n, err := sc.number()
+		if err != nil {
+			break
+		}
+		v = append(v, n)
+	}
+	return v
+}
+
+// svgMatrix is an affine transformation [a b c d e f] that maps
+// (x, y) to (a*x + c*y + e, b*x + d*y + f).
+type svgMatrix [6]float64
+
+var svgIdentity = svgMatrix{1, 0, 0, 1, 0, 0}
+
+func (m svgMatrix) apply(pt Pt) Pt {
+	return Pt{m[0]*pt[0] + m[2]*pt[1] + m[4], m[1]*pt[0] + m[3]*pt[1] + m[5]}
+}
+
+// mul returns the transformation applying n, then m.
+func (m svgMatrix) mul(n svgMatrix) svgMatrix {
+	return svgMatrix{
+		m[0]*n[0] + m[2]*n[1],
+		m[1]*n[0] + m[3]*n[1],
+		m[0]*n[2] + m[2]*n[3],
+		m[1]*n[2] + m[3]*n[3],
+		m[0]*n[4] + m[2]*n[5] + m[4],
+		m[1]*n[4] + m[3]*n[5] + m[5],
+	}
+}
+
+// parseSVGTransform parses a transform attribute such as
+// "translate(10 20) rotate(45)".
+func parseSVGTransform(s string) (svgMatrix, error) {
+	m := svgIdentity
+	for {
+		s = strings.TrimLeft(s, " \t\r\n,")
+		if s == "" {
+			return m, nil
+		}
+		open := strings.Index(s, "(")
+		end := strings.Index(s, ")")
+		if open < 0 || end < open {
+			return m, fmt.Errorf("invalid transform %q", s)
+		}
+		name, v := strings.TrimSpace(s[:open]), svgNumbers(s[open+1:end])
+		s = s[end+1:]
+
+		var n svgMatrix
+		switch {
+		case name == "matrix" && len(v) == 6:
+			n = svgMatrix{v[0], v[1], v[2], v[3], v[4], v[5]}
+		case name == "translate" && len(v) == 1:
+			n = svgMatrix{1, 0, 0, 1, v[0], 0}
+		case name == "translate" && len(v) == 2:
+			n = svgMatrix{1, 0, 0, 1, v[0], v[1]}
+		case name == "scale" && len(v) == 1:
+			n = svgMatrix{v[0], 0, 0, v[0], 0, 0}
+		case name == "scale" && len(v) == 2:
+			n = svgMatrix{v[0], 0, 0, v[1], 0, 0}
+		case name == "rotate" && (len(v) == 1 || len(v) == 3):
+			sin, cos := math.Sincos(v[0] * math.Pi / 180)
+			n = svgMatrix{cos, sin, -sin, cos, 0, 0}
+			if len(v) == 3 {
+				n = svgMatrix{1, 0, 0, 1, v[1], v[2]}.mul(n).mul(svgMatrix{1, 0, 0, 1, -v[1], -v[2]})
+			}
+		case name == "skewX" && len(v) == 1:
+			n = svgMatrix{1, 0, math.Tan(v[0] * math.Pi / 180), 1, 0, 0}
+		case name == "skewY" && len(v) == 1:
+			n = svgMatrix{1, math.Tan(v[0] * math.Pi / 180), 0, 1, 0, 0}
+		default:
+			return m, fmt.Errorf("invalid transform %v with %v arguments", name, len(v))
+		}
+		m = m.mul(n)
+	}
+}
+
+// svgShape is a filled shape made of one or more subpaths.
+type svgShape struct {
+	subpaths []svgSubpath
+	evenOdd  bool
+}
+
+// svgSubpath is a closed outline of lines and cubic Bézier curves.
+type svgSubpath struct {
+	start    Pt
+	segments []svgSegment
+}
+
+// svgSegment is a line or cubic Bézier curve ending at end.
+// The control points c1 and c2 are only used by curves.
+type svgSegment struct {
+	c1, c2, end Pt
+	curve       bool
+}
+
+// flatten returns the outline of the subpath as a polygon whose
+// distance to the curves is at most tolerance. A zero tolerance
+// flattens each curve into a fixed number of lines.
+func (sp svgSubpath) flatten(tolerance float64) []Pt {
+	pts := []Pt{sp.start}
+	p0 := sp.start
+	for _, seg := range sp.segments {
+		if seg.curve {
+			// The distance between a cubic curve and its chords is
+			// at most max|B''| / (8n²) for n chords of equal steps.
+			dd := 6 * math.Max(
+				math.Hypot(p0[0]-2*seg.c1[0]+seg.c2[0], p0[1]-2*seg.c1[1]+seg.c2[1]),
+				math.Hypot(seg.c1[0]-2*seg.c2[0]+seg.end[0], seg.c1[1]-2*seg.c2[1]+seg.end[1]))
+			n := 16
+			if tolerance > 0 {
+				n = int(math.Ceil(math.Sqrt(dd / (8 * tolerance))))
+			}
+			n = max(1, min(n, 1000))
+			for i := 1; i < n; i++ {
+				t := float64(i) / float64(n)
+				u := 1 - t
+				a, b, c, d := u*u*u, 3*u*u*t, 3*u*t*t, t*t*t
+				pts = append(pts, Pt{
+					a*p0[0] + b*seg.c1[0] + c*seg.c2[0] + d*seg.end[0],
+					a*p0[1] + b*seg.c1[1] + c*seg.c2[1] + d*seg.end[1],
+				})
+			}
+		}
+		pts = append(pts, seg.end)
+		p0 = seg.end
+	}
+	// Drop the closing point, which PolygonT adds.
+	if n := len(pts); n > 1 && pts[n-1] == pts[0] {
+		pts = pts[:n-1]
+	}
+	return pts
+}
+
+// svgPolygon is a flattened subpath that is filled or a hole.
+type svgPolygon struct {
+	pts   []Pt
+	hole  bool
+	depth int // number of polygons of the shape enclosing it
+}
+
+// classify decides which polygons of the shape are holes according
+// to its fill rule and sorts them so that each polygon follows the
+// polygons enclosing it.
+func (s *svgShape) classify(polys [][]Pt) []svgPolygon {
+	result := make([]svgPolygon, len(polys))
+	for i, poly := range polys {
+		var winding, odd int
+		for j, other := range polys {
+			if i == j {
+				continue
+			}
+			w := svgWinding(poly[0], other)
+			winding += w
+			if w != 0 {
+				result[i].depth++
+			}
+			if w%2 != 0 {
+				odd++
+			}
+		}
+		result[i].pts = poly
+		if s.evenOdd {
+			result[i].hole = odd%2 != 0
+			continue
+		}
+		// A nonzero subpath is a hole if it cancels the winding
+		// of the subpaths around it.
+		result[i].hole = winding != 0 && winding+svgOrientation(poly) == 0
+	}
+	sort.SliceStable(result, func(a, b int) bool { return result[a].depth < result[b].depth })
+	return result
+}
+
+// svgWinding returns the winding number of the polygon around pt.
+func svgWinding(pt Pt, poly []Pt) int {
+	var w int
+	for i, a := range poly {
+		b := poly[(i+1)%len(poly)]
+		cross := (b[0]-a[0])*(pt[1]-a[1]) - (pt[0]-a[0])*(b[1]-a[1])
+		switch {
+		case a[1] <= pt[1] && b[1] > pt[1] && cross > 0:
+			w++
+		case a[1] > pt[1] && b[1] <= pt[1] && cross < 0:
+			w--
+		}
+	}
+	return w
+}
+
+// svgOrientation returns 1 for polygons with a positive area and -1
+// for polygons with a negative area.
+func svgOrientation(poly []Pt) int {
+	var area float64
+	for i, a := range poly {
+		b := poly[(i+1)%len(poly)]
+		area += a[0]*b[1] - b[0]*a[1]
+	}
+	if area < 0 {
+		return -1
+	}
+	return 1
+}
+
+// svgPathBuilder builds the subpaths of a shape.
+type svgPathBuilder struct {
+	subpaths []svgSubpath
+	open     bool // whether the last subpath is open
+	cur      Pt
+	ctrl     Pt // last control point, for smooth curves
+}
+
+func (b *svgPathBuilder) moveTo(pt Pt) {
+	b.subpaths = append(b.subpaths, svgSubpath{start: pt})
+	b.open = true
+	b.cur, b.ctrl = pt, pt
+}
+
+// add adds a segment, starting a new subpath after a closed one.
+func (b *svgPathBuilder) add(seg svgSegment) {
+	if !b.open {
+		b.moveTo(b.cur)
+	}
+	sp := &b.subpaths[len(b.subpaths)-1]
+	sp.segments = append(sp.segments, seg)
+	b.cur = seg.end
+}
+
+func (b *svgPathBuilder) lineTo(pt Pt) {
+	b.add(svgSegment{end: pt})
+	b.ctrl = pt
+}
+
+func (b *svgPathBuilder) cubicTo(c1, c2, pt Pt) {
+	b.add(svgSegment{c1: c1, c2: c2, end: pt, curve: true})
+	b.ctrl = c2
+}
+
+func (b *svgPathBuilder) quadTo(q, pt Pt) {
+	p0 := b.cur
+	b.cubicTo(
+		Pt{p0[0] + 2.0/3*(q[0]-p0[0]), p0[1] + 2.0/3*(q[1]-p0[1])},
+		Pt{pt[0] + 2.0/3*(q[0]-pt[0]), pt[1] + 2.0/3*(q[1]-pt[1])},
+		pt)
+	b.ctrl = q
+}
+
+// arcTo adds an elliptical arc as cubic curves of at most 45 degrees,
+// following the SVG endpoint parameterization. The curves differ from
+// the arc by less than 5e-6 of its radius.
+func (b *svgPathBuilder) arcTo(rx, ry, rotation float64, large, sweep bool, pt Pt) {
+	p0 := b.cur
+	rx, ry = math.Abs(rx), math.Abs(ry)
+	if p0 == pt {
+		return
+	}
+	if rx == 0 || ry == 0 {
+		b.lineTo(pt)
+		return
+	}
+
+	sin, cos := math.Sincos(rotation * math.Pi / 180)
+	dx, dy := 0.5*(p0[0]-pt[0]), 0.5*(p0[1]-pt[1])
+	x1, y1 := cos*dx+sin*dy, -sin*dx+cos*dy
+	if l := x1*x1/(rx*rx) + y1*y1/(ry*ry); l > 1 {
+		rx, ry = rx*math.Sqrt(l), ry*math.Sqrt(l)
+	}
+	num := rx*rx*ry*ry - rx*rx*y1*y1 - ry*ry*x1*x1
+	den := rx*rx*y1*y1 + ry*ry*x1*x1
+	coef := math.Sqrt(math.Max(0, num/den))
+	if large == sweep {
+		coef = -coef
+	}
+	cx1, cy1 := coef*rx*y1/ry, -coef*ry*x1/rx
+	cx := cos*cx1 - sin*cy1 + 0.5*(p0[0]+pt[0])
+	cy := sin*cx1 + cos*cy1 + 0.5*(p0[1]+pt[1])
+
+	ux, uy := (x1-cx1)/rx, (y1-cy1)/ry
+	vx, vy := (-x1-cx1)/rx, (-y1-cy1)/ry
+	start := math.Atan2(uy, ux)
+	delta := math.Atan2(ux*vy-uy*vx, ux*vx+uy*vy)
+	if sweep && delta < 0 {
+		delta += 2 * math.Pi
+	} else if !sweep && delta > 0 {
+		delta -= 2 * math.Pi
+	}
+
+	// mapPt maps a point of the unit circle to the ellipse.
+	mapPt := func(x, y float64) Pt {
+		return Pt{cx + rx*x*cos - ry*y*sin, cy + rx*x*sin + ry*y*cos}
+	}
+	n := int(math.Ceil(math.Abs(delta)/(0.25*math.Pi) - 1e-9))
+	step := delta / float64(n)
+	k := 4.0 / 3 * math.Tan(step/4)
+	for i := 0; i < n; i++ {
+		s1, c1 := math.Sincos(start + float64(i)*step)
+		s2, c2 := math.Sincos(start + float64(i+1)*step)
+		end := mapPt(c2, s2)
+		if i == n-1 {
+			end = pt
+		}
+		b.cubicTo(mapPt(c1-k*s1, s1+k*c1), mapPt(c2+k*s2, s2-k*c2), end)
+	}
+}
+
+func (b *svgPathBuilder) close() {
+	if !b.open {
+		return
+	}
+	sp := b.subpaths[len(b.subpaths)-1]
+	if b.cur != sp.start {
+		b.lineTo(sp.start)
+	}
+	b.open = false
+	b.cur, b.ctrl = sp.start, sp.start
+}
+
+// parse adds the subpaths of path data such as "M0 0L10 0 10 10Z".
+// Every subpath is closed because shapes are filled.
+func (b *svgPathBuilder) parse(d string) error {
+	sc := &svgScanner{s: d}
+	var cmd, last byte
+	for !sc.done() {
+		if c := sc.s[sc.i]; strings.IndexByte("MmLlHhVvCcSsQqTtAaZz", c) >= 0 {
+			cmd = c
+			sc.i++
+		} else if cmd == 0 || cmd == 'Z' || cmd == 'z' {
+			return fmt.Errorf("path data %q: unexpected %q", d, c)
+		}
+
+		rel := cmd >= 'a'
+		var origin Pt
+		if rel {
+			origin = b.cur
+		}
+		var v [7]float64
+		for i := 0; i < svgPathArgs[cmd&^0x20]; i++ {
+			var err error
+			if cmd&^0x20 == 'A' && (i == 3 || i == 4) {
+				var flag bool
+				flag, err = sc.flag()
+				if flag {
+					v[i] = 1
+				}
+			} else {
+				v[i], err = sc.number()
+			}
+			if err != nil {
+				return fmt.Errorf("path data %q: %v", d, err)
+			}
+		}
+		pt := func(i int) Pt { return Pt{origin[0] + v[i], origin[1] + v[i+1]} }
+		// reflect returns the reflection of the last control point if
+		// the last command was of the same kind, or the current point.
+		reflect := func(kinds string) Pt {
+			if strings.IndexByte(kinds, last&^0x20) < 0 {
+				return b.cur
+			}
+			return Pt{2*b.cur[0] - b.ctrl[0], 2*b.cur[1] - b.ctrl[1]}
+		}
+
+		switch cmd &^ 0x20 {
+		case 'M':
+			b.close()
+			b.moveTo(pt(0))
+			// Further coordinates are implicit lines.
+			cmd--
+		case 'L':
+			b.lineTo(pt(0))
+		case 'H':
+			b.lineTo(Pt{origin[0] + v[0], b.cur[1]})
+		case 'V':
+			b.lineTo(Pt{b.cur[0], origin[1] + v[0]})
+		case 'C':
+			b.cubicTo(pt(0), pt(2), pt(4))
+		case 'S':
+			b.cubicTo(reflect("CS"), pt(0), pt(2))
+		case 'Q':
+			b.quadTo(pt(0), pt(2))
+		case 'T':
+			b.quadTo(reflect("QT"), pt(0))
+		case 'A':
+			b.arcTo(v[0], v[1], v[2], v[3] != 0, v[4] != 0, pt(5))
+		case 'Z':
+			b.close()
+		}
+		last = cmd
+	}
+	b.close()
+	return nil
+}
+
+// svgPathArgs are the numbers of arguments of the path commands.
+var svgPathArgs = map[byte]int{'M': 2, 'L': 2, 'H': 1, 'V': 1, 'C': 6, 'S': 4, 'Q': 4, 'T': 2, 'A': 7, 'Z': 0}
+
+// svgScanner reads the numbers and flags of path data.
+type svgScanner struct {
+	s string
+	i int
+}
+
+// done skips separators and reports whether the end was reached.
+func (sc *svgScanner) done() bool {
+	for sc.i < len(sc.s) && strings.IndexByte(" \t\r\n,", sc.s[sc.i]) >= 0 {
+		sc.i++
+	}
+	return sc.i >= len(sc.s)
+}
+
+// number reads a number such as "-1.5e3". Numbers need no separator
+// if they can't be confused, as in "1-2" or "0.5.5".
+func (sc *svgScanner) number() (float64, error) {
+	if sc.done() {
+		return 0, fmt.Errorf("missing number")
+	}
+	start := sc.i
+	digits := func() {
+		for sc.i < len(sc.s) && sc.s[sc.i] >= '0' && sc.s[sc.i] <= '9' {
+			sc.i++
+		}
+	}
+	sign := func() {
+		if sc.i < len(sc.s) && (sc.s[sc.i] == '+' || sc.s[sc.i] == '-') {
+			sc.i++
+		}
+	}
+	sign()
+	digits()
+	if sc.i < len(sc.s) && sc.s[sc.i] == '.' {
+		sc.i++
+		digits()
+	}
+	if sc.i < len(sc.s) && (sc.s[sc.i] == 'e' || sc.s[sc.i] == 'E') {
+		sc.i++
+		sign()
+		digits()
+	}
+	v, err := strconv.ParseFloat(sc.s[start:sc.i], 64)
+	if err != nil {
+		sc.i = start
+		return 0, fmt.Errorf("invalid number at %q", sc.s[start:])
+	}
+	return v, nil
+}
+
+// flag reads an arc flag, which needs no separator as in "a1 1 0 011 1".
+func (sc *svgScanner) flag() (bool, error) {
+	if sc.done() || (sc.s[sc.i] != '0' && sc.s[sc.i] != '1') {
+		return false, fmt.Errorf("invalid arc flag at %q", sc.s[sc.i:])
+	}
+	sc.i++
+	return sc.s[sc.i-1] == '1', nil
+}
@@ -0,0 +1,344 @@
+package gerber
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const svgEpsilon = 1e-6
+
+func svgPolygons(t *testing.T, primitives []Primitive) (dark, clear []*PolygonT) {
+	t.Helper()
+	for _, p := range primitives {
+		switch v := p.(type) {
+		case *PolygonT:
+			dark = append(dark, v)
+		case *ClearT:
+			for _, p := range v.Primitives {
+				clear = append(clear, p.(*PolygonT))
+			}
+		default:
+			t.Fatalf("unexpected %T", p)
+		}
+	}
+	return dark, clear
+}
+
+func TestParseSVG(t *testing.T) {
+	tests := []struct {
+		name          string
+		svg           string
+		center        Pt
+		width, height float64
+		opts          *SVGImportOptions
+		want          MBB
+		dark, clear   int
+	}{
+		{
+			name:   "rect fit to width",
+			svg:    `<svg xmlns="http://www.w3.org/2000/svg"><rect x="10" y="10" width="10" height="20"/></svg>`,
+			center: Pt{1, 1},
+			width:  5,
+			want:   MBB{Min: Pt{-1.5, -4}, Max: Pt{3.5, 6}},
+			dark:   1,
+		},
+		{
+			name:   "fit to height",
+			svg:    `<svg><rect width="10" height="20"/></svg>`,
+			width:  100,
+			height: 10,
+			want:   MBB{Min: Pt{-2.5, -5}, Max: Pt{2.5, 5}},
+			dark:   1,
+		},
+		{
+			name: "natural size in millimeters",
+			svg:  `<svg width="100mm" height="100mm" viewBox="0 0 50 50"><circle cx="25" cy="25" r="5"/></svg>`,
+			want: MBB{Min: Pt{-10, -10}, Max: Pt{10, 10}},
+			dark: 1,
+		},
+		{
+			name: "natural size in pixels",
+			svg:  `<svg><polygon points="0,0 96,0 96,48"/></svg>`,
+			want: MBB{Min: Pt{-12.7, -6.35}, Max: Pt{12.7, 6.35}},
+			dark: 1,
+		},
+		{
+			name:  "evenodd hole",
+			svg:   `<svg><path fill-rule="evenodd" d="M0 0H10V10H0Z M2 2H8V8H2Z M4 4H6V6H4Z"/></svg>`,
+			width: 10,
+			want:  MBB{Min: Pt{-5, -5}, Max: Pt{5, 5}},
+			dark:  2,
+			clear: 1,
+		},
+		{
+			name:  "nonzero with same direction",
+			svg:   `<svg><path d="M0 0H10V10H0Z M2 2H8V8H2Z"/></svg>`,
+			width: 10,
+			want:  MBB{Min: Pt{-5, -5}, Max: Pt{5, 5}},
+			dark:  2,
+		},
+		{
+			name:  "nonzero with opposite direction",
+			svg:   `<svg><path style="fill:#123;fill-rule:nonzero" d="M0 0H10V10H0Z M2 2V8H8V2Z"/></svg>`,
+			width: 10,
+			want:  MBB{Min: Pt{-5, -5}, Max: Pt{5, 5}},
+			dark:  1,
+			clear: 1,
+		},
+		{
+			name: "transforms, hidden and unfilled shapes",
+			svg: `<svg xmlns="http://www.w3.org/2000/svg">
+<defs><rect id="r" width="1000" height="1000"/></defs>
+<g transform="translate(10 0)" fill="none">
+  <rect width="50" height="50"/>
+  <g transform="scale(2)"><rect x="10" width="10" height="10" fill="black"/></g>
+</g>
+<rect width="1000" height="1000" display="none"/>
+<rect width="1000" height="1000" style="fill:none"/>
+<rect width="10" height="10" transform="rotate(90)"/>
+</svg>`,
+			width: 30,
+			want:  MBB{Min: Pt{-15, -5}, Max: Pt{15, 5}},
+			dark:  2,
+		},
+		{
+			name:   "mirror",
+			svg:    `<svg><polygon points="0,0 10,0 10,10 0,10"/><polygon points="20,0 30,0 30,10"/></svg>`,
+			center: Pt{10, 0},
+			width:  30,
+			opts:   &SVGImportOptions{Mirror: true},
+			want:   MBB{Min: Pt{-5, -5}, Max: Pt{25, 5}},
+			dark:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			primitives, err := ParseSVG(strings.NewReader(tt.svg), tt.center, tt.width, tt.height, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dark, clear := svgPolygons(t, primitives)
+			if len(dark) != tt.dark || len(clear) != tt.clear {
+				t.Errorf("got %v dark and %v clear polygons, want %v and %v", len(dark), len(clear), tt.dark, tt.clear)
+			}
+			mbb := primitives[0].MBB()
+			for _, p := range primitives[1:] {
+				v := p.MBB()
+				mbb.Join(&v)
+			}
+			for i := 0; i < 2; i++ {
+				if math.Abs(mbb.Min[i]-tt.want.Min[i]) > svgEpsilon || math.Abs(mbb.Max[i]-tt.want.Max[i]) > svgEpsilon {
+					t.Errorf("MBB = %v, want %v", mbb, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSVG_Order(t *testing.T) {
+	// The island in the hole must be drawn after the hole is cleared.
+	svg := `<svg><path fill-rule="evenodd" d="M4 4H6V6H4Z M2 2H8V8H2Z M0 0H10V10H0Z"/></svg>`
+	primitives, err := ParseSVG(strings.NewReader(svg), Pt{}, 10, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, p := range primitives {
+		mbb := p.MBB()
+		got = append(got, strings.TrimPrefix(fmt.Sprintf("%T", p), "*gerber.")+" "+svgNum(mbb.Max[0]-mbb.Min[0]))
+	}
+	if want := "PolygonT 10, ClearT 6, PolygonT 2"; strings.Join(got, ", ") != want {
+		t.Errorf("primitives = %v, want %v", strings.Join(got, ", "), want)
+	}
+}
+
+func TestParseSVG_Tolerance(t *testing.T) {
+	// A half circle below the X axis, closed by curves above it.
+	svg := `<svg><path d="M-10 0A10 10 0 0 0 10 0C10 -5.5 5.5 -10 0 -10Q-10 -10 -10 0Z"/></svg>`
+	for _, tolerance := range []float64{0.1, 0.01, 0.001} {
+		primitives, err := ParseSVG(strings.NewReader(svg), Pt{}, 20, 0, &SVGImportOptions{Tolerance: tolerance})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		for i, pt := range primitives[0].(*PolygonT).Points {
+			if pt[1] > -svgEpsilon {
+				continue
+			}
+			n++
+			if d := math.Abs(math.Hypot(pt[0], pt[1]) - 10); d > tolerance+1e-4 {
+				t.Errorf("tolerance %v: point %v is %v from the arc", tolerance, i, d)
+			}
+		}
+		// The chords of the half circle are at most sqrt(8 r tolerance) long.
+		if want := int(10 * math.Pi / math.Sqrt(80*tolerance)); n < want {
+			t.Errorf("tolerance %v: got %v points on the arc, want at least %v", tolerance, n, want)
+		}
+	}
+}
+
+// svgPathPoints returns the start of the subpath and the ends of its
+// segments, preceded by their midpoints for curves.
+func svgPathPoints(sp svgSubpath) []Pt {
+	pts := []Pt{sp.start}
+	p0 := sp.start
+	for _, seg := range sp.segments {
+		if seg.curve {
+			pts = append(pts, Pt{
+				(p0[0] + 3*seg.c1[0] + 3*seg.c2[0] + seg.end[0]) / 8,
+				(p0[1] + 3*seg.c1[1] + 3*seg.c2[1] + seg.end[1]) / 8,
+			})
+		}
+		pts = append(pts, seg.end)
+		p0 = seg.end
+	}
+	return pts
+}
+
+func TestSVGPathBuilder_Parse(t *testing.T) {
+	tests := []struct {
+		d    string
+		want [][]Pt
+	}{
+		{
+			d:    "M0,0L10-5.5.5 1e1z",
+			want: [][]Pt{{{0, 0}, {10, -5.5}, {0.5, 10}, {0, 0}}},
+		},
+		{
+			d:    "m1 1 2 0 0 2h-2v-2zm5 0l1 0 0 1",
+			want: [][]Pt{{{1, 1}, {3, 1}, {3, 3}, {1, 3}, {1, 1}}, {{6, 1}, {7, 1}, {7, 2}, {6, 1}}},
+		},
+		{
+			d:    "M0 0H2Z L2 2",
+			want: [][]Pt{{{0, 0}, {2, 0}, {0, 0}}, {{0, 0}, {2, 2}, {0, 0}}},
+		},
+		{
+			d:    "M0 0C0 1 1 1 1 0S2-1 2 0Q3 1 4 0T6 0",
+			want: [][]Pt{{{0, 0}, {0.5, 0.75}, {1, 0}, {1.5, -0.75}, {2, 0}, {3, 0.5}, {4, 0}, {5, -0.5}, {6, 0}, {0, 0}}},
+		},
+		{
+			d:    "M0 0a1 1 0 000 2a1 1 0 000-2",
+			want: [][]Pt{svgCirclePoints(Pt{0, 1}, 1, -90, -450, 16)},
+		},
+		{
+			d:    "M0 0A1 1 0 0 0 0 0L1 0A0 1 0 0 0 1 1",
+			want: [][]Pt{{{0, 0}, {1, 0}, {1, 1}, {0, 0}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.d, func(t *testing.T) {
+			b := &svgPathBuilder{}
+			if err := b.parse(tt.d); err != nil {
+				t.Fatal(err)
+			}
+			if len(b.subpaths) != len(tt.want) {
+				t.Fatalf("got %v subpaths, want %v", len(b.subpaths), len(tt.want))
+			}
+			for i, sp := range b.subpaths {
+				got := svgPathPoints(sp)
+				if len(got) != len(tt.want[i]) {
+					t.Fatalf("subpath %v = %v, want %v", i, got, tt.want[i])
+				}
+				for j, pt := range got {
+					if math.Abs(pt[0]-tt.want[i][j][0]) > 1e-5 || math.Abs(pt[1]-tt.want[i][j][1]) > 1e-5 {
+						t.Errorf("subpath %v = %v, want %v", i, got, tt.want[i])
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+// svgCirclePoints returns n+1 points from angle start to end in degrees.
+func svgCirclePoints(center Pt, r, start, end float64, n int) []Pt {
+	var pts []Pt
+	for i := 0; i <= n; i++ {
+		sin, cos := math.Sincos((start + (end-start)*float64(i)/float64(n)) * math.Pi / 180)
+		pts = append(pts, Pt{center[0] + r*cos, center[1] + r*sin})
+	}
+	return pts
+}
+
+func TestParseSVGTransform(t *testing.T) {
+	tests := []struct {
+		transform string
+		want      svgMatrix
+	}{
+		{transform: "translate(10)", want: svgMatrix{1, 0, 0, 1, 10, 0}},
+		{transform: "translate(10, 20) scale(2)", want: svgMatrix{2, 0, 0, 2, 10, 20}},
+		{transform: "scale(2 3)", want: svgMatrix{2, 0, 0, 3, 0, 0}},
+		{transform: "rotate(90)", want: svgMatrix{0, 1, -1, 0, 0, 0}},
+		{transform: "rotate(90 10 0)", want: svgMatrix{0, 1, -1, 0, 10, -10}},
+		{transform: "matrix(1,2,3,4,5,6)", want: svgMatrix{1, 2, 3, 4, 5, 6}},
+		{transform: "skewX(45)", want: svgMatrix{1, 0, 1, 1, 0, 0}},
+		{transform: "scale(2),skewY(45)", want: svgMatrix{2, 2, 0, 2, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.transform, func(t *testing.T) {
+			got, err := parseSVGTransform(tt.transform)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range got {
+				if math.Abs(got[i]-tt.want[i]) > svgEpsilon {
+					t.Errorf("parseSVGTransform = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestParseSVG_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		svg  string
+		want string
+	}{
+		{name: "not svg", svg: `<html></html>`, want: "root element is <html>, want <svg>"},
+		{name: "empty", svg: ``, want: "no <svg> element found"},
+		{name: "no shapes", svg: `<svg><rect width="10" height="10" fill="none"/></svg>`, want: "no filled shapes found"},
+		{name: "no area", svg: `<svg><path d="M0 0H10Z"/></svg>`, want: "no filled shapes found"},
+		{name: "bad path", svg: `<svg><path d="M0 0L1"/></svg>`, want: `<path>: path data "M0 0L1": missing number`},
+		{name: "bad command", svg: `<svg><path d="M0 0X1 1"/></svg>`, want: `<path>: path data "M0 0X1 1": invalid number at "X1 1"`},
+		{name: "bad flag", svg: `<svg><path d="M0 0A1 1 0 2 0 1 1"/></svg>`, want: `<path>: path data "M0 0A1 1 0 2 0 1 1": invalid arc flag at "2 0 1 1"`},
+		{name: "bad transform", svg: `<svg><g transform="spin(3)"/></svg>`, want: "invalid transform spin with 1 arguments"},
+		{name: "xml", svg: `<svg><path></svg>`, want: "XML syntax error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseSVG(strings.NewReader(tt.svg), Pt{}, 10, 10, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("ParseSVG error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportSVG(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "logo.svg")
+	if err := os.WriteFile(filename, []byte(`<svg><circle r="1"/></svg>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	primitives, err := ImportSVG(filename, Pt{5, 5}, 2, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := New("logo")
+	layer := g.TopSilkscreen()
+	layer.Add(primitives...)
+	mbb := layer.MBB()
+	if math.Abs(mbb.Min[0]-4) > svgEpsilon || math.Abs(mbb.Max[1]-6) > svgEpsilon {
+		t.Errorf("MBB = %v, want (4,4)-(6,6)", mbb)
+	}
+
+	if _, err := ImportSVG(filepath.Join(t.TempDir(), "missing.svg"), Pt{}, 1, 1, nil); err == nil {
+		t.Error("ImportSVG of a missing file succeeded")
+	}
+}
@@ -1,7 +1,6 @@
 package gerber
 
 import (
-	"fmt"
 	"io"
 	"log"
 
@@ -44,6 +43,7 @@ type TextT struct {
 	fontName string
 	pts      float64
 	Render   *fonts.Render
+	Attributes
 }
 
 func verifyOrSubstituteFont(fontName string) string {
@@ -139,37 +139,41 @@ func (t *TextT) Height() float64 {
 }
 
 // WriteGerber writes the primitive to the Gerber file.
-func (t *TextT) WriteGerber(w io.Writer, apertureIndex int) error {
+func (t *TextT) WriteGerber(w io.Writer, f *Format, apertureIndex int) error {
+	pol := &polarity{}
+	if err := t.writePolarized(w, f, false, pol); err != nil {
+		return err
+	}
+	pol.set(w, false)
+	return nil
+}
+
+// writePolarized writes the text with clear polarity for the glyphs
+// (and dark polarity for their counters) if clear is true.
+func (t *TextT) writePolarized(w io.Writer, f *Format, clear bool, pol *polarity) error {
 	if err := t.renderText(); err != nil {
 		return err
 	}
 
-	currentDark := true
 	for _, poly := range t.Render.Polygons {
-		if poly.Dark && !currentDark {
-			io.WriteString(w, "%LPD*%\n")
-			currentDark = true
-		} else if !poly.Dark && currentDark {
-			io.WriteString(w, "%LPC*%\n")
-			currentDark = false
-		}
+		pol.set(w, poly.Dark == clear)
 
 		io.WriteString(w, "G54D11*\n")
 		io.WriteString(w, "G36*\n")
 		for i, pt := range poly.Pts {
+			d := 1
 			if i == 0 {
-				fmt.Fprintf(w, "X%06dY%06dD02*\n", int(0.5+sf*pt[0]), int(0.5+sf*pt[1]))
-				continue
+				d = 2
 			}
-			fmt.Fprintf(w, "X%06dY%06dD01*\n", int(0.5+sf*pt[0]), int(0.5+sf*pt[1]))
+			if err := f.writeOperation(w, pt, d); err != nil {
+				return err
+			}
+		}
+		if err := f.writeOperation(w, poly.Pts[0], 2); err != nil {
+			return err
 		}
-		fmt.Fprintf(w, "X%06dY%06dD02*\n", int(0.5+sf*poly.Pts[0][0]), int(0.5+sf*poly.Pts[0][1]))
 		io.WriteString(w, "G37*\n")
 	}
-
-	if !currentDark {
-		io.WriteString(w, "%LPD*%\n")
-	}
 	return nil
 }
 
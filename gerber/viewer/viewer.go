@@ -8,8 +8,6 @@ import (
 	"log"
 	"math"
 	"os"
-	"regexp"
-	"strconv"
 	"sync"
 
 	"fyne.io/fyne/v2"
@@ -22,10 +20,6 @@ import (
 	"github.com/gmlewis/go-gerber/gerber"
 )
 
-var (
-	layerRE = regexp.MustCompile(`\.gl(\d+)$`)
-)
-
 type viewController struct {
 	g         *gerber.Gerber
 	mbb       gerber.MBB
@@ -43,6 +37,7 @@ type viewController struct {
 	yOffset int
 
 	indexDrill            int
+	indexNonPlatedDrill   int
 	indexTopSilkscreen    int
 	indexTopSolderMask    int
 	indexTop              int
@@ -67,6 +62,7 @@ func initController(g *gerber.Gerber, app fyne.App, allLayersOn bool) *viewContr
 		center:                gerber.Pt{0.5 * (mbb.Max[0] + mbb.Min[0]), 0.5 * (mbb.Max[1] + mbb.Min[1])},
 		drawLayer:             make([]bool, len(g.Layers)),
 		indexDrill:            -1,
+		indexNonPlatedDrill:   -1,
 		indexTopSilkscreen:    -1,
 		indexTopSolderMask:    -1,
 		indexTop:              -1,
@@ -78,11 +74,10 @@ func initController(g *gerber.Gerber, app fyne.App, allLayersOn bool) *viewContr
 	}
 
 	for i, layer := range g.Layers {
-
-		if m := layerRE.FindStringSubmatch(layer.Filename); len(m) == 2 {
-			n, err := strconv.Atoi(m[1])
-			if err != nil || n < 2 {
-				log.Fatalf("error parsing layer suffix %v", layer.Filename)
+		if layer.Type == gerber.LayerInnerCopper {
+			n := layer.N
+			if n < 2 {
+				log.Fatalf("invalid inner copper layer number %v: %v", n, layer.Filename)
 			}
 			vc.indexLayerN[n] = i
 			if n > vc.maxN {
@@ -93,22 +88,24 @@ func initController(g *gerber.Gerber, app fyne.App, allLayersOn bool) *viewContr
 		}
 
 		vc.drawLayer[i] = true
-		switch layer.Filename[len(layer.Filename)-4:] {
-		case ".gtl":
+		switch layer.Type {
+		case gerber.LayerTopCopper:
 			vc.indexTop = i
-		case ".gts":
+		case gerber.LayerTopSolderMask:
 			vc.indexTopSolderMask = i
-		case ".gto":
+		case gerber.LayerTopSilkscreen:
 			vc.indexTopSilkscreen = i
-		case ".gbl":
+		case gerber.LayerBottomCopper:
 			vc.indexBottom = i
-		case ".gbs":
+		case gerber.LayerBottomSolderMask:
 			vc.indexBottomSolderMask = i
-		case ".gbo":
+		case gerber.LayerBottomSilkscreen:
 			vc.indexBottomSilkscreen = i
-		case ".drl":
+		case gerber.LayerDrill:
 			vc.indexDrill = i
-		case ".gko":
+		case gerber.LayerNonPlatedDrill:
+			vc.indexNonPlatedDrill = i
+		case gerber.LayerOutline:
 			vc.indexOutline = i
 		default:
 			log.Fatalf("Unknown Gerber layer: %v", layer.Filename)
@@ -165,6 +162,7 @@ func Gerber(g *gerber.Gerber, allLayersOn bool) {
 	}
 	scroller := container.NewVScroll(layers)
 	addCheck(vc.indexDrill, "Drill")
+	addCheck(vc.indexNonPlatedDrill, "Non-Plated Drill")
 	addCheck(vc.indexTopSilkscreen, "Top Silkscreen")
 	addCheck(vc.indexTopSolderMask, "Top Solder Mask")
 	addCheck(vc.indexTop, "Top")
@@ -315,13 +313,21 @@ func (vc *viewController) Refresh() {
 		foreground := func(ctx *gg.Context) {
 			ctx.SetRGBA(fr, fg, fb, fa)
 		}
+		// Approximate clear polarity with the background color.
+		polarity := func(ctx *gg.Context, dark bool) {
+			if dark {
+				foreground(ctx)
+			} else {
+				ctx.SetRGB(0, 0, 0)
+			}
+		}
 		foreground(dc)
 		layer := vc.g.Layers[index]
-		for _, p := range layer.Primitives {
+		// draw renders p with the color of the given polarity, which is
+		// already set on dc, and leaves that color set when it returns.
+		var draw func(p gerber.Primitive, dark bool)
+		draw = func(p gerber.Primitive, dark bool) {
 			mbb := p.MBB()
-			if !bbox.Intersects(&mbb) {
-				continue
-			}
 			// Render this primitive.
 			switch v := p.(type) {
 			case *gerber.ArcT:
@@ -355,6 +361,42 @@ func (vc *viewController) Refresh() {
 				x, y, r := 0.5*(mbb.Min[0]+mbb.Max[0]), 0.5*(mbb.Min[1]+mbb.Max[1]), 0.5*(mbb.Max[0]-mbb.Min[0])
 				dc.DrawCircle(xf(x), yf(y), r*vc.scale)
 				dc.Fill()
+			case *gerber.ClearT:
+				polarity(dc, !dark)
+				for _, p := range v.Primitives {
+					draw(p, !dark)
+				}
+				polarity(dc, dark)
+			case *gerber.KnockoutT:
+				draw(v.Box(), dark)
+				polarity(dc, !dark)
+				for _, p := range v.Primitives {
+					draw(p, !dark)
+				}
+				polarity(dc, dark)
+			case *gerber.FlashT:
+				if a := v.Aperture(); a != nil && a.Block != nil {
+					vc.drawBlock(dc, v, a.Block, xf, yf, func(p gerber.Primitive) { draw(p, dark) })
+					return
+				}
+				vc.drawAperture(dc, v.Aperture(), v.Center, xf, yf)
+			case *gerber.ImageT:
+				for _, r := range v.Regions {
+					draw(r, dark)
+				}
+			case *gerber.BarcodeT:
+				if !v.Inverted {
+					for _, r := range v.Regions {
+						draw(r, dark)
+					}
+					break
+				}
+				draw(v.Box(), dark)
+				polarity(dc, !dark)
+				for _, r := range v.Regions {
+					draw(r, !dark)
+				}
+				polarity(dc, dark)
 			case *gerber.LineT:
 				dc.SetLineWidth(v.Thickness * vc.scale)
 				switch v.Shape {
@@ -389,7 +431,7 @@ func (vc *viewController) Refresh() {
 				urx, ury := int(0.5+xf(mbb.Max[0])), int(0.5+yf(mbb.Min[1]))
 				// log.Printf("ll=(%v,%v), ur=(%v,%v)", llx, lly, urx, ury)
 				img := nc.Image()
-				foreground(dc)
+				polarity(dc, dark)
 				for y := lly; y <= ury; y++ {
 					for x := llx; x <= urx; x++ {
 						c := img.At(x, y)
@@ -409,11 +451,38 @@ func (vc *viewController) Refresh() {
 						dc.LineTo(xf(p[0]), yf(p[1]))
 					}
 				}
+				dc.ClosePath()
+				if v.Filled {
+					dc.FillPreserve()
+				}
+				if v.Thickness > 0 {
+					dc.SetLineWidth(v.Thickness * vc.scale)
+					dc.SetLineCapRound()
+					dc.Stroke()
+				}
+				dc.ClearPath()
+			case *gerber.RegionT:
+				for _, c := range append([]gerber.Contour{v.Outer}, v.Holes...) {
+					dc.NewSubPath()
+					for _, pt := range c.Points() {
+						dc.LineTo(xf(pt[0]), yf(pt[1]))
+					}
+					dc.ClosePath()
+				}
+				dc.SetFillRuleEvenOdd()
 				dc.Fill()
+				dc.SetFillRuleWinding()
 			default:
 				log.Printf("%T not yet supported", v)
 			}
 		}
+		for _, p := range layer.Primitives {
+			mbb := p.MBB()
+			if !bbox.Intersects(&mbb) {
+				continue
+			}
+			draw(p, true)
+		}
 	}
 	// Draw layers from bottom up
 	renderLayer(vc.indexOutline, color.RGBA{R: 0, G: 255, B: 0, A: 255})
@@ -427,9 +496,75 @@ func (vc *viewController) Refresh() {
 	renderLayer(vc.indexTopSolderMask, color.RGBA{R: 0, G: 150, B: 200, A: 255})
 	renderLayer(vc.indexTopSilkscreen, color.RGBA{R: 250, G: 150, B: 0, A: 255})
 	renderLayer(vc.indexDrill, color.RGBA{R: 200, G: 200, B: 200, A: 255})
+	renderLayer(vc.indexNonPlatedDrill, color.RGBA{R: 150, G: 150, B: 150, A: 255})
 	vc.img = dc.Image().(*image.RGBA)
 }
 
+// drawBlock draws the primitives of a block aperture flashed by f.
+func (vc *viewController) drawBlock(dc *gg.Context, f *gerber.FlashT, b *gerber.Block, xf, yf func(float64) float64, draw func(p gerber.Primitive)) {
+	dc.Push()
+	defer dc.Pop()
+	// Move the block origin to the flash center, then apply the
+	// transformations (the image Y axis points down).
+	dc.Translate(xf(f.Center[0]), yf(f.Center[1]))
+	if f.Scale != 0 {
+		dc.Scale(f.Scale, f.Scale)
+	}
+	dc.Rotate(-gg.Radians(f.Rotation))
+	switch f.Mirror {
+	case gerber.MirrorX:
+		dc.Scale(-1, 1)
+	case gerber.MirrorY:
+		dc.Scale(1, -1)
+	case gerber.MirrorXY:
+		dc.Scale(-1, -1)
+	}
+	dc.Translate(-xf(0), -yf(0))
+	for _, p := range b.Primitives {
+		draw(p)
+	}
+}
+
+// drawAperture fills the shape of the aperture centered at pt.
+func (vc *viewController) drawAperture(dc *gg.Context, a *gerber.Aperture, pt gerber.Pt, xf, yf func(float64) float64) {
+	if a == nil {
+		return
+	}
+	x, y := xf(pt[0]), yf(pt[1])
+	switch {
+	case a.Macro != nil:
+		// Approximate macro apertures by their bounding box.
+		mbb := a.MBB()
+		dc.DrawRectangle(xf(pt[0]+mbb.Min[0]), yf(pt[1]+mbb.Max[1]), vc.scale*(mbb.Max[0]-mbb.Min[0]), vc.scale*(mbb.Max[1]-mbb.Min[1]))
+	case a.Shape == gerber.RectShape, a.Shape == gerber.ObroundShape:
+		mbb := a.MBB()
+		w, h := vc.scale*(mbb.Max[0]-mbb.Min[0]), vc.scale*(mbb.Max[1]-mbb.Min[1])
+		if a.Shape == gerber.ObroundShape {
+			dc.DrawRoundedRectangle(x-0.5*w, y-0.5*h, w, h, 0.5*math.Min(w, h))
+		} else {
+			dc.DrawRectangle(x-0.5*w, y-0.5*h, w, h)
+		}
+	case a.Shape == gerber.PolygonShape:
+		r := 0.5 * a.Size
+		for i := 0; i < a.Vertices; i++ {
+			angle := math.Pi * (a.Rotation + 360*float64(i)/float64(a.Vertices)) / 180
+			dc.LineTo(xf(pt[0]+r*math.Cos(angle)), yf(pt[1]+r*math.Sin(angle)))
+		}
+		dc.ClosePath()
+	default:
+		dc.DrawCircle(x, y, 0.5*a.Size*vc.scale)
+	}
+	if a.Hole > 0 {
+		dc.SetFillRuleEvenOdd()
+		dc.NewSubPath()
+		dc.DrawCircle(x, y, 0.5*a.Hole*vc.scale)
+		dc.Fill()
+		dc.SetFillRuleWinding()
+		return
+	}
+	dc.Fill()
+}
+
 func (vc *viewController) imageFunc(w, h int) image.Image {
 	if vc.lastW != w || vc.lastH != h {
 		vc.mu.Lock()
@@ -0,0 +1,258 @@
+package gerber
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// x2DateFormat is the ISO 8601 layout of the %TF.CreationDate attribute.
+const x2DateFormat = "2006-01-02T15:04:05-07:00"
+
+// AperFunction represents the Gerber X2 function of an aperture
+// (or of a region), written as a %TA.AperFunction attribute.
+type AperFunction string
+
+const (
+	// ViaPad is a pad around a via.
+	ViaPad AperFunction = "ViaPad"
+	// ComponentPad is a pad for a through-hole component.
+	ComponentPad AperFunction = "ComponentPad"
+	// SMDPad is a copper-defined pad for a surface mount component.
+	SMDPad AperFunction = "SMDPad,CuDef"
+	// Conductor is copper that conducts electricity (e.g. tracks and pours).
+	Conductor AperFunction = "Conductor"
+	// NonConductor is copper that does not conduct electricity.
+	NonConductor AperFunction = "NonConductor"
+	// Profile identifies the board outline.
+	Profile AperFunction = "Profile"
+)
+
+// Attributes represents the Gerber X2 attributes that a primitive can carry.
+type Attributes struct {
+	// Function is the function of the primitive's aperture (or region).
+	// If empty, the layer's default function is used.
+	Function AperFunction
+	// Net is the name of the net the primitive belongs to (%TO.N).
+	Net string
+	// Component is the reference designator of the component
+	// the primitive belongs to (%TO.C).
+	Component string
+	// Pin is the pin number of the component pad (%TO.P).
+	// It is only written when Component is also set.
+	Pin string
+}
+
+func (a *Attributes) attributes() *Attributes { return a }
+
+// attributer is satisfied by all primitives that embed Attributes.
+type attributer interface {
+	attributes() *Attributes
+}
+
+// primitiveAttributes returns the attributes of the primitive
+// or nil if it does not carry any.
+func primitiveAttributes(p Primitive) *Attributes {
+	if v, ok := p.(attributer); ok {
+		return v.attributes()
+	}
+	return nil
+}
+
+// writeObjectAttributes writes the %TO attributes of a primitive and
+// reports whether any were written.
+func (a *Attributes) writeObjectAttributes(w io.Writer) bool {
+	if a == nil {
+		return false
+	}
+	var written bool
+	if a.Net != "" {
+		fmt.Fprintf(w, "%%TO.N,%v*%%\n", escapeX2(a.Net))
+		written = true
+	}
+	if a.Component != "" {
+		fmt.Fprintf(w, "%%TO.C,%v*%%\n", escapeX2(a.Component))
+		written = true
+		if a.Pin != "" {
+			fmt.Fprintf(w, "%%TO.P,%v,%v*%%\n", escapeX2(a.Component), escapeX2(a.Pin))
+		}
+	}
+	return written
+}
+
+// escapeX2 escapes the characters that are reserved in Gerber
+// attribute values using the \uXXXX notation.
+func escapeX2(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		switch r {
+		case '%', '*', ',', '\\':
+			fmt.Fprintf(&sb, "\\u%04X", r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// copperLayers returns the number of copper layers in the design.
+func (g *Gerber) copperLayers() int {
+	var n int
+	for _, layer := range g.Layers {
+		switch layer.Type {
+		case LayerTopCopper, LayerBottomCopper, LayerInnerCopper:
+			n++
+		}
+	}
+	return n
+}
+
+// creationDate returns the creation date written to the output files.
+func (g *Gerber) creationDate() time.Time {
+	if g == nil || g.CreationDate.IsZero() {
+		return time.Now()
+	}
+	return g.CreationDate
+}
+
+// FileFunction returns the Gerber X2 file function of the layer.
+func (l *Layer) FileFunction() string {
+	copperLayers := 2
+	if l.g != nil {
+		if n := l.g.copperLayers(); n > copperLayers {
+			copperLayers = n
+		}
+	}
+	switch l.Type {
+	case LayerTopCopper:
+		return "Copper,L1,Top"
+	case LayerTopSolderMask:
+		return "Soldermask,Top"
+	case LayerTopSilkscreen:
+		return "Legend,Top"
+	case LayerBottomCopper:
+		return fmt.Sprintf("Copper,L%v,Bot", copperLayers)
+	case LayerBottomSolderMask:
+		return "Soldermask,Bot"
+	case LayerBottomSilkscreen:
+		return "Legend,Bot"
+	case LayerInnerCopper:
+		return fmt.Sprintf("Copper,L%v,Inr", l.N)
+	case LayerDrill:
+		return fmt.Sprintf("Plated,1,%v,PTH", copperLayers)
+	case LayerNonPlatedDrill:
+		return fmt.Sprintf("NonPlated,1,%v,NPTH", copperLayers)
+	case LayerOutline:
+		return "Profile,NP"
+	}
+	return "Other,Unknown"
+}
+
+// parseFileFunction returns the layer type (and copper layer number)
+// of a Gerber X2 file function, reporting whether it is recognized.
+func parseFileFunction(value string) (LayerType, int, bool) {
+	fields := strings.Split(value, ",")
+	side := func(top, bottom LayerType) (LayerType, int, bool) {
+		if len(fields) < 2 {
+			return 0, 0, false
+		}
+		switch fields[1] {
+		case "Top":
+			return top, 0, true
+		case "Bot":
+			return bottom, 0, true
+		}
+		return 0, 0, false
+	}
+
+	switch fields[0] {
+	case "Copper":
+		if len(fields) < 3 || !strings.HasPrefix(fields[1], "L") {
+			return 0, 0, false
+		}
+		n, err := strconv.Atoi(fields[1][1:])
+		if err != nil {
+			return 0, 0, false
+		}
+		switch fields[2] {
+		case "Top":
+			return LayerTopCopper, 0, true
+		case "Inr":
+			return LayerInnerCopper, n, true
+		case "Bot":
+			return LayerBottomCopper, 0, true
+		}
+	case "Soldermask":
+		return side(LayerTopSolderMask, LayerBottomSolderMask)
+	case "Legend":
+		return side(LayerTopSilkscreen, LayerBottomSilkscreen)
+	case "Profile":
+		return LayerOutline, 0, true
+	case "Plated":
+		return LayerDrill, 0, true
+	case "NonPlated":
+		return LayerNonPlatedDrill, 0, true
+	}
+	return 0, 0, false
+}
+
+// FilePolarity returns the Gerber X2 file polarity of the layer.
+// Solder mask layers describe the openings in the mask and are
+// therefore negative.
+func (l *Layer) FilePolarity() string {
+	switch l.Type {
+	case LayerTopSolderMask, LayerBottomSolderMask:
+		return "Negative"
+	}
+	return "Positive"
+}
+
+// defaultFunction returns the aperture function used for primitives
+// on this layer that do not specify their own.
+func (l *Layer) defaultFunction(p Primitive) AperFunction {
+	switch l.Type {
+	case LayerOutline:
+		return Profile
+	case LayerTopCopper, LayerBottomCopper, LayerInnerCopper:
+		switch p.(type) {
+		case *CircleT, *FlashT:
+			return "" // pads must be identified by the caller
+		}
+		return Conductor
+	}
+	return ""
+}
+
+// function returns the aperture function of a primitive on this layer.
+func (l *Layer) function(p Primitive) AperFunction {
+	if a := primitiveAttributes(p); a != nil && a.Function != "" {
+		return a.Function
+	}
+	return l.defaultFunction(p)
+}
+
+// aperture returns the primitive's aperture with its function resolved
+// for this layer, or nil if the primitive uses the default aperture.
+func (l *Layer) aperture(p Primitive) *Aperture {
+	a := p.Aperture()
+	if a == nil {
+		return nil
+	}
+	if a.Function != "" || a.Block != nil {
+		return a
+	}
+	v := *a
+	v.Function = l.defaultFunction(p)
+	return &v
+}
+
+// writeFileAttributes writes the Gerber X2 file attributes of the layer.
+func (l *Layer) writeFileAttributes(w io.Writer) {
+	io.WriteString(w, "%TF.GenerationSoftware,gmlewis,go-gerber*%\n")
+	fmt.Fprintf(w, "%%TF.CreationDate,%v*%%\n", l.g.creationDate().Format(x2DateFormat))
+	io.WriteString(w, "%TF.SameCoordinates*%\n")
+	fmt.Fprintf(w, "%%TF.FileFunction,%v*%%\n", l.FileFunction())
+	fmt.Fprintf(w, "%%TF.FilePolarity,%v*%%\n", l.FilePolarity())
+}
@@ -0,0 +1,116 @@
+package gerber
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLayer_FileFunction(t *testing.T) {
+	g := New("test")
+	top := g.TopCopper()
+	topMask := g.TopSolderMask()
+	topSilk := g.TopSilkscreen()
+	layer2 := g.LayerN(2)
+	layer3 := g.LayerN(3)
+	bottom := g.BottomCopper()
+	bottomMask := g.BottomSolderMask()
+	bottomSilk := g.BottomSilkscreen()
+	drill := g.Drill()
+	outline := g.Outline()
+
+	tests := []struct {
+		layer        *Layer
+		wantFunction string
+		wantPolarity string
+	}{
+		{layer: top, wantFunction: "Copper,L1,Top", wantPolarity: "Positive"},
+		{layer: topMask, wantFunction: "Soldermask,Top", wantPolarity: "Negative"},
+		{layer: topSilk, wantFunction: "Legend,Top", wantPolarity: "Positive"},
+		{layer: layer2, wantFunction: "Copper,L2,Inr", wantPolarity: "Positive"},
+		{layer: layer3, wantFunction: "Copper,L3,Inr", wantPolarity: "Positive"},
+		{layer: bottom, wantFunction: "Copper,L4,Bot", wantPolarity: "Positive"},
+		{layer: bottomMask, wantFunction: "Soldermask,Bot", wantPolarity: "Negative"},
+		{layer: bottomSilk, wantFunction: "Legend,Bot", wantPolarity: "Positive"},
+		{layer: drill, wantFunction: "Plated,1,4,PTH", wantPolarity: "Positive"},
+		{layer: outline, wantFunction: "Profile,NP", wantPolarity: "Positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.layer.Filename, func(t *testing.T) {
+			if got := tt.layer.FileFunction(); got != tt.wantFunction {
+				t.Errorf("FileFunction = %q, want %q", got, tt.wantFunction)
+			}
+			if got := tt.layer.FilePolarity(); got != tt.wantPolarity {
+				t.Errorf("FilePolarity = %q, want %q", got, tt.wantPolarity)
+			}
+		})
+	}
+}
+
+func TestLayer_WriteGerber_X2(t *testing.T) {
+	g := New("test")
+	g.CreationDate = time.Date(2019, 6, 8, 19, 30, 0, 0, time.UTC)
+	top := g.TopCopper()
+	g.BottomCopper()
+
+	via := Circle(Pt{1, 2}, 0.5)
+	via.Function = ViaPad
+	via.Net = "GND"
+	pour := Polygon(Pt{0, 0}, true, []Pt{{0, 0}, {1, 0}, {1, 1}}, 0)
+	pour.Net = "VCC"
+	top.Add(
+		via,
+		Line(0, 0, 1, 0, CircleShape, 0.15),
+		pour,
+	)
+
+	var buf strings.Builder
+	if err := top.WriteGerber(&buf); err != nil {
+		t.Fatalf("WriteGerber: %v", err)
+	}
+
+	want := `%TF.GenerationSoftware,gmlewis,go-gerber*%
+%TF.CreationDate,2019-06-08T19:30:00+00:00*%
+%TF.SameCoordinates*%
+%TF.FileFunction,Copper,L1,Top*%
+%TF.FilePolarity,Positive*%
+%FSLAX36Y36*%
+%MOMM*%
+%LPD*%
+G75*
+%ADD11C,0.00100*%
+%TA.AperFunction,ViaPad*%
+%ADD12C,0.50000*%
+%TA.AperFunction,Conductor*%
+%ADD13C,0.15000*%
+%TD.AperFunction*%
+%TO.N,GND*%
+G54D12*
+X1000000Y2000000D03*
+%TD*%
+G54D13*
+X0Y0D02*
+X1000000Y0D01*
+%TA.AperFunction,Conductor*%
+%TO.N,VCC*%
+G54D11*
+G36*
+X0Y0D02*
+X1000000Y0D01*
+X1000000Y1000000D01*
+X0Y0D01*
+G37*
+%TD*%
+M02*
+`
+	if got := buf.String(); got != want {
+		t.Errorf("WriteGerber =\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestEscapeX2(t *testing.T) {
+	if got, want := escapeX2(`a,b*c%d\e`), `a\u002Cb\u002Ac\u0025d\u005Ce`; got != want {
+		t.Errorf("escapeX2 = %q, want %q", got, want)
+	}
+}